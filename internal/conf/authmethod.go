@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/headers"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // AuthMethods is the authMethods parameter.
@@ -64,3 +66,14 @@ func (d *AuthMethods) UnmarshalEnv(s string) error {
 	byts, _ := json.Marshal(strings.Split(s, ","))
 	return d.UnmarshalJSON(byts)
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d AuthMethods) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "string",
+			"enum": []string{"basic", "digest"},
+		},
+	}
+}
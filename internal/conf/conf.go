@@ -14,7 +14,6 @@ import (
 	"github.com/bluenviron/gortsplib/v3"
 	"github.com/bluenviron/gortsplib/v3/pkg/headers"
 
-	"github.com/aler9/mediamtx/internal/conf/decrypt"
 	"github.com/aler9/mediamtx/internal/conf/env"
 	"github.com/aler9/mediamtx/internal/conf/yaml"
 	"github.com/aler9/mediamtx/internal/logger"
@@ -31,14 +30,19 @@ func getSortedKeys(paths map[string]*PathConf) []string {
 	return ret
 }
 
-func loadFromFile(fpath string, conf *Conf) (bool, error) {
+func actualConfPath(fpath string) string {
 	if fpath == "mediamtx.yml" {
 		// give priority to the legacy configuration file, in order not to break
 		// existing setups
 		if _, err := os.Stat("rtsp-simple-server.yml"); err == nil {
-			fpath = "rtsp-simple-server.yml"
+			return "rtsp-simple-server.yml"
 		}
 	}
+	return fpath
+}
+
+func loadFromFile(fpath string, conf *Conf) (bool, error) {
+	fpath = actualConfPath(fpath)
 
 	// mediamtx.yml is optional
 	// other configuration files are not
@@ -49,75 +53,152 @@ func loadFromFile(fpath string, conf *Conf) (bool, error) {
 		}
 	}
 
-	byts, err := os.ReadFile(fpath)
+	m, err := readMap(fpath)
 	if err != nil {
 		return true, err
 	}
 
-	if key, ok := os.LookupEnv("RTSP_CONFKEY"); ok { // legacy format
-		byts, err = decrypt.Decrypt(key, byts)
-		if err != nil {
-			return true, err
+	return true, loadFromMap(fpath, m, conf)
+}
+
+func loadFromMap(fpath string, m map[string]interface{}, conf *Conf) error {
+	origins, err := mergeIncludes(fpath, m)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := yaml.Interpolate(m)
+	if err != nil {
+		return err
+	}
+
+	byts, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(byts, conf)
+	if err != nil {
+		return err
+	}
+
+	conf.secrets = make(map[string]yaml.Secret, len(secrets))
+	for _, s := range secrets {
+		// "all" is an alias for "~^.*$"
+		if len(s.Path) >= 2 && s.Path[0] == "paths" && s.Path[1] == "all" {
+			s.Path = append([]string{"paths", "~^.*$"}, s.Path[2:]...)
 		}
+		conf.secrets[secretKey(s.Path)] = s
 	}
+	conf.origins = origins
+
+	return nil
+}
 
-	if key, ok := os.LookupEnv("MTX_CONFKEY"); ok {
-		byts, err = decrypt.Decrypt(key, byts)
+func loadFromEnvAndCheck(conf *Conf) error {
+	// save the values loaded from files, in order to prevent
+	// Persist() from writing values loaded from environment variables.
+	fileConf := conf.Clone()
+	if fileConf.Check() == nil {
+		var err error
+		conf.fileView, err = jsonMap(fileConf.RawView())
 		if err != nil {
-			return true, err
+			return err
 		}
 	}
 
-	err = yaml.Load(byts, conf)
+	err := env.Load("RTSP", conf) // legacy prefix
 	if err != nil {
-		return true, err
+		return err
+	}
+	conf.setEnvFields("RTSP")
+
+	err = env.Load("MTX", conf)
+	if err != nil {
+		return err
 	}
+	conf.setEnvFields("MTX")
 
-	return true, nil
+	err = conf.Check()
+	if err != nil {
+		return err
+	}
+
+	if conf.fileView != nil {
+		conf.loadedView, err = jsonMap(conf.RawView())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Conf is a configuration.
 type Conf struct {
 	// general
-	LogLevel                  LogLevel        `json:"logLevel"`
-	LogDestinations           LogDestinations `json:"logDestinations"`
-	LogFile                   string          `json:"logFile"`
-	ReadTimeout               StringDuration  `json:"readTimeout"`
-	WriteTimeout              StringDuration  `json:"writeTimeout"`
-	ReadBufferCount           int             `json:"readBufferCount"`
-	UDPMaxPayloadSize         int             `json:"udpMaxPayloadSize"`
-	ExternalAuthenticationURL string          `json:"externalAuthenticationURL"`
-	API                       bool            `json:"api"`
-	APIAddress                string          `json:"apiAddress"`
-	Metrics                   bool            `json:"metrics"`
-	MetricsAddress            string          `json:"metricsAddress"`
-	PPROF                     bool            `json:"pprof"`
-	PPROFAddress              string          `json:"pprofAddress"`
-	RunOnConnect              string          `json:"runOnConnect"`
-	RunOnConnectRestart       bool            `json:"runOnConnectRestart"`
+	LogLevel                  LogLevel              `json:"logLevel"`
+	LogLevels                 map[string]LogLevel   `json:"logLevels"`
+	LogDestinations           LogDestinations       `json:"logDestinations"`
+	LogFile                   string                `json:"logFile"`
+	LogFileMaxSize            StringSize            `json:"logFileMaxSize"`
+	LogFileRotationInterval   StringDuration        `json:"logFileRotationInterval"`
+	LogFileMaxBackups         int                   `json:"logFileMaxBackups"`
+	LogFileCompress           bool                  `json:"logFileCompress"`
+	LogFormat                 LogFormat             `json:"logFormat"`
+	LogTimezone               string                `json:"logTimezone"`
+	ReadTimeout               StringDuration        `json:"readTimeout"`
+	WriteTimeout              StringDuration        `json:"writeTimeout"`
+	ReadBufferCount           int                   `json:"readBufferCount"`
+	UDPMaxPayloadSize         int                   `json:"udpMaxPayloadSize"`
+	ExternalAuthenticationURL string                `json:"externalAuthenticationURL"`
+	API                       bool                  `json:"api"`
+	APIAddress                string                `json:"apiAddress"`
+	APIPersistConfig          bool                  `json:"apiPersistConfig"`
+	Metrics                   bool                  `json:"metrics"`
+	MetricsAddress            string                `json:"metricsAddress"`
+	OTLP                      bool                  `json:"otlp"`
+	OTLPEndpoint              string                `json:"otlpEndpoint"`
+	OTLPInterval              StringDuration        `json:"otlpInterval"`
+	OTLPHeaders               map[string]string     `json:"otlpHeaders"`
+	PPROF                     bool                  `json:"pprof"`
+	PPROFAddress              string                `json:"pprofAddress"`
+	RunOnConnect              string                `json:"runOnConnect"`
+	RunOnConnectRestart       bool                  `json:"runOnConnectRestart"`
+	RunOnDisconnect           string                `json:"runOnDisconnect"`
+	OnConnectWebhook          string                `json:"onConnectWebhook"`
+	OnDisconnectWebhook       string                `json:"onDisconnectWebhook"`
+	WebhookTimeout            StringDuration        `json:"webhookTimeout"`
+	WebhookMaxRetries         int                   `json:"webhookMaxRetries"`
+	WebhookSecret             string                `json:"webhookSecret"`
+	UsageQuotas               map[string]UsageQuota `json:"usageQuotas"`
 
 	// RTSP
-	RTSPDisable       bool        `json:"rtspDisable"`
-	Protocols         Protocols   `json:"protocols"`
-	Encryption        Encryption  `json:"encryption"`
-	RTSPAddress       string      `json:"rtspAddress"`
-	RTSPSAddress      string      `json:"rtspsAddress"`
-	RTPAddress        string      `json:"rtpAddress"`
-	RTCPAddress       string      `json:"rtcpAddress"`
-	MulticastIPRange  string      `json:"multicastIPRange"`
-	MulticastRTPPort  int         `json:"multicastRTPPort"`
-	MulticastRTCPPort int         `json:"multicastRTCPPort"`
-	ServerKey         string      `json:"serverKey"`
-	ServerCert        string      `json:"serverCert"`
-	AuthMethods       AuthMethods `json:"authMethods"`
+	RTSPDisable        bool        `json:"rtspDisable"`
+	Protocols          Protocols   `json:"protocols"`
+	Encryption         Encryption  `json:"encryption"`
+	RTSPAddress        string      `json:"rtspAddress"`
+	RTSPSAddress       string      `json:"rtspsAddress"`
+	RTPAddress         string      `json:"rtpAddress"`
+	RTCPAddress        string      `json:"rtcpAddress"`
+	MulticastIPRange   string      `json:"multicastIPRange"`
+	MulticastRTPPort   int         `json:"multicastRTPPort"`
+	MulticastRTCPPort  int         `json:"multicastRTCPPort"`
+	ServerKey          string      `json:"serverKey"`
+	ServerCert         string      `json:"serverCert"`
+	ClientCA           string      `json:"clientCA"`
+	AuthMethods        AuthMethods `json:"authMethods"`
+	RTSPMaxConnections int         `json:"rtspMaxConnections"`
 
 	// RTMP
-	RTMPDisable    bool       `json:"rtmpDisable"`
-	RTMPAddress    string     `json:"rtmpAddress"`
-	RTMPEncryption Encryption `json:"rtmpEncryption"`
-	RTMPSAddress   string     `json:"rtmpsAddress"`
-	RTMPServerKey  string     `json:"rtmpServerKey"`
-	RTMPServerCert string     `json:"rtmpServerCert"`
+	RTMPDisable        bool       `json:"rtmpDisable"`
+	RTMPAddress        string     `json:"rtmpAddress"`
+	RTMPEncryption     Encryption `json:"rtmpEncryption"`
+	RTMPSAddress       string     `json:"rtmpsAddress"`
+	RTMPServerKey      string     `json:"rtmpServerKey"`
+	RTMPServerCert     string     `json:"rtmpServerCert"`
+	RTMPClientCA       string     `json:"rtmpClientCA"`
+	RTMPMaxConnections int        `json:"rtmpMaxConnections"`
 
 	// HLS
 	HLSDisable         bool           `json:"hlsDisable"`
@@ -125,6 +206,7 @@ type Conf struct {
 	HLSEncryption      bool           `json:"hlsEncryption"`
 	HLSServerKey       string         `json:"hlsServerKey"`
 	HLSServerCert      string         `json:"hlsServerCert"`
+	HLSClientCA        string         `json:"hlsClientCA"`
 	HLSAlwaysRemux     bool           `json:"hlsAlwaysRemux"`
 	HLSVariant         HLSVariant     `json:"hlsVariant"`
 	HLSSegmentCount    int            `json:"hlsSegmentCount"`
@@ -134,6 +216,7 @@ type Conf struct {
 	HLSAllowOrigin     string         `json:"hlsAllowOrigin"`
 	HLSTrustedProxies  IPsOrCIDRs     `json:"hlsTrustedProxies"`
 	HLSDirectory       string         `json:"hlsDirectory"`
+	HLSMaxConnections  int            `json:"hlsMaxConnections"`
 
 	// WebRTC
 	WebRTCDisable           bool       `json:"webrtcDisable"`
@@ -141,15 +224,29 @@ type Conf struct {
 	WebRTCEncryption        bool       `json:"webrtcEncryption"`
 	WebRTCServerKey         string     `json:"webrtcServerKey"`
 	WebRTCServerCert        string     `json:"webrtcServerCert"`
+	WebRTCClientCA          string     `json:"webrtcClientCA"`
 	WebRTCAllowOrigin       string     `json:"webrtcAllowOrigin"`
 	WebRTCTrustedProxies    IPsOrCIDRs `json:"webrtcTrustedProxies"`
 	WebRTCICEServers        []string   `json:"webrtcICEServers"`
 	WebRTCICEHostNAT1To1IPs []string   `json:"webrtcICEHostNAT1To1IPs"`
 	WebRTCICEUDPMuxAddress  string     `json:"webrtcICEUDPMuxAddress"`
 	WebRTCICETCPMuxAddress  string     `json:"webrtcICETCPMuxAddress"`
+	WebRTCMaxConnections    int        `json:"webrtcMaxConnections"`
 
 	// paths
-	Paths map[string]*PathConf `json:"paths"`
+	Include        []string             `json:"include"`
+	PathsDirectory string               `json:"pathsDirectory"`
+	PathDefaults   *PathConf            `json:"pathDefaults"`
+	PathTemplates  map[string]*PathConf `json:"pathTemplates"`
+	Paths          map[string]*PathConf `json:"paths"`
+
+	defaultsInheritance  *pathInheritance
+	templatesInheritance map[string]*pathInheritance
+	pathsInheritance     map[string]*pathInheritance
+	secrets              map[string]yaml.Secret
+	origins              map[string]string
+	fileView             map[string]interface{}
+	loadedView           map[string]interface{}
 }
 
 // Load loads a Conf.
@@ -161,22 +258,51 @@ func Load(fpath string) (*Conf, bool, error) {
 		return nil, false, err
 	}
 
-	err = env.Load("RTSP", conf) // legacy prefix
+	err = loadFromEnvAndCheck(conf)
 	if err != nil {
 		return nil, false, err
 	}
 
-	err = env.Load("MTX", conf)
+	return conf, found, nil
+}
+
+// LoadBuffer loads a Conf from the content of a configuration file provided by a client.
+// Included files, paths directories and references to environment variables and files
+// are not allowed, since they would be resolved on the filesystem and the environment of the server.
+func LoadBuffer(byts []byte) (*Conf, error) {
+	conf := &Conf{}
+
+	m, err := yaml.LoadMap(byts)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	err = conf.Check()
+	for _, key := range []string{"include", "pathsDirectory"} {
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("'%s' is not allowed", key)
+		}
+	}
+
+	if ref := yaml.FindReference(m); ref != "" {
+		return nil, fmt.Errorf("reference '%s' is not allowed", ref)
+	}
+
+	byts, err = json.Marshal(m)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	return conf, found, nil
+	err = json.Unmarshal(byts, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadFromEnvAndCheck(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf, nil
 }
 
 // Clone clones the configuration.
@@ -192,6 +318,14 @@ func (conf Conf) Clone() *Conf {
 		panic(err)
 	}
 
+	dest.defaultsInheritance = conf.defaultsInheritance
+	dest.templatesInheritance = copyInheritance(conf.templatesInheritance)
+	dest.pathsInheritance = copyInheritance(conf.pathsInheritance)
+	dest.secrets = conf.secrets
+	dest.origins = conf.origins
+	dest.fileView = conf.fileView
+	dest.loadedView = conf.loadedView
+
 	return &dest
 }
 
@@ -204,15 +338,62 @@ func contains(list []headers.AuthMethod, item headers.AuthMethod) bool {
 	return false
 }
 
+// logComponents are the values of the "component" log field.
+var logComponents = map[string]struct{}{
+	"rtsp":    {},
+	"rtsps":   {},
+	"rtmp":    {},
+	"rtmps":   {},
+	"hls":     {},
+	"webrtc":  {},
+	"path":    {},
+	"api":     {},
+	"metrics": {},
+	"pprof":   {},
+	"webhook": {},
+}
+
+func checkWebhookURL(key string, ur string) error {
+	if ur != "" && !strings.HasPrefix(ur, "http://") && !strings.HasPrefix(ur, "https://") {
+		return fmt.Errorf("'%s' must be a HTTP URL", key)
+	}
+	return nil
+}
+
 // Check checks the configuration for errors.
 func (conf *Conf) Check() error {
 	// general
+	for component := range conf.LogLevels {
+		if _, ok := logComponents[component]; !ok {
+			return fmt.Errorf("invalid component in 'logLevels': '%s'", component)
+		}
+	}
+	if conf.LogFileMaxBackups < 0 {
+		return fmt.Errorf("'logFileMaxBackups' must be greater or equal than zero")
+	}
+	if _, err := conf.LogLocation(); err != nil {
+		return fmt.Errorf("invalid 'logTimezone': %v", err)
+	}
 	if (conf.ReadBufferCount & (conf.ReadBufferCount - 1)) != 0 {
 		return fmt.Errorf("'readBufferCount' must be a power of two")
 	}
 	if conf.UDPMaxPayloadSize > 1472 {
 		return fmt.Errorf("'udpMaxPayloadSize' must be less than 1472")
 	}
+	if conf.OTLP {
+		if !strings.HasPrefix(conf.OTLPEndpoint, "http://") &&
+			!strings.HasPrefix(conf.OTLPEndpoint, "https://") {
+			return fmt.Errorf("'otlpEndpoint' must be a HTTP URL")
+		}
+		if conf.OTLPInterval <= 0 {
+			return fmt.Errorf("'otlpInterval' must be greater than zero")
+		}
+	}
+	for user := range conf.UsageQuotas {
+		if user == "" {
+			return fmt.Errorf("'usageQuotas' contains an empty user")
+		}
+	}
 	if conf.ExternalAuthenticationURL != "" {
 		if !strings.HasPrefix(conf.ExternalAuthenticationURL, "http://") &&
 			!strings.HasPrefix(conf.ExternalAuthenticationURL, "https://") {
@@ -223,6 +404,15 @@ func (conf *Conf) Check() error {
 			return fmt.Errorf("'externalAuthenticationURL' can't be used when 'digest' is in authMethods")
 		}
 	}
+	if err := checkWebhookURL("onConnectWebhook", conf.OnConnectWebhook); err != nil {
+		return err
+	}
+	if err := checkWebhookURL("onDisconnectWebhook", conf.OnDisconnectWebhook); err != nil {
+		return err
+	}
+	if conf.WebhookMaxRetries < 0 {
+		return fmt.Errorf("'webhookMaxRetries' can't be negative")
+	}
 
 	// RTSP
 	if conf.Encryption == EncryptionStrict {
@@ -235,16 +425,56 @@ func (conf *Conf) Check() error {
 		}
 	}
 
+	if conf.RTSPMaxConnections < 0 {
+		return fmt.Errorf("'rtspMaxConnections' can't be negative")
+	}
+
+	// RTMP
+	if conf.RTMPMaxConnections < 0 {
+		return fmt.Errorf("'rtmpMaxConnections' can't be negative")
+	}
+
+	// HLS
+	if conf.HLSMaxConnections < 0 {
+		return fmt.Errorf("'hlsMaxConnections' can't be negative")
+	}
+
+	// WebRTC
+	if conf.WebRTCMaxConnections < 0 {
+		return fmt.Errorf("'webrtcMaxConnections' can't be negative")
+	}
+
 	// do not add automatically "all", since user may want to
 	// initialize all paths through API or hot reloading.
 	if conf.Paths == nil {
 		conf.Paths = make(map[string]*PathConf)
 	}
 
+	if conf.pathsInheritance == nil {
+		conf.pathsInheritance = make(map[string]*pathInheritance)
+	}
+
 	// "all" is an alias for "~^.*$"
 	if _, ok := conf.Paths["all"]; ok {
 		conf.Paths["~^.*$"] = conf.Paths["all"]
 		delete(conf.Paths, "all")
+
+		if inh, ok := conf.pathsInheritance["all"]; ok {
+			conf.pathsInheritance["~^.*$"] = inh
+			delete(conf.pathsInheritance, "all")
+		}
+	}
+
+	// remove the inheritance state of deleted paths
+	for name := range conf.pathsInheritance {
+		if _, ok := conf.Paths[name]; !ok {
+			delete(conf.pathsInheritance, name)
+		}
+	}
+
+	err := conf.checkPathTemplates()
+	if err != nil {
+		return err
 	}
 
 	for _, name := range getSortedKeys(conf.Paths) {
@@ -252,10 +482,15 @@ func (conf *Conf) Check() error {
 		if pconf == nil {
 			pconf = &PathConf{}
 			pconf.UnmarshalJSON(nil) // fill defaults
-			conf.Paths[name] = pconf
 		}
 
-		err := pconf.check(conf, name)
+		pconf, err := conf.resolvePath(name, pconf)
+		if err != nil {
+			return fmt.Errorf("path '%s': %s", name, err)
+		}
+		conf.Paths[name] = pconf
+
+		err = pconf.check(conf, name)
 		if err != nil {
 			return err
 		}
@@ -276,7 +511,11 @@ func (conf *Conf) UnmarshalJSON(b []byte) error {
 	conf.UDPMaxPayloadSize = 1472
 	conf.APIAddress = "127.0.0.1:9997"
 	conf.MetricsAddress = "127.0.0.1:9998"
+	conf.OTLPEndpoint = "http://localhost:4318"
+	conf.OTLPInterval = 10 * StringDuration(time.Second)
 	conf.PPROFAddress = "127.0.0.1:9999"
+	conf.WebhookTimeout = 10 * StringDuration(time.Second)
+	conf.WebhookMaxRetries = 3
 
 	// RTSP
 	conf.Protocols = Protocols{
@@ -320,5 +559,31 @@ func (conf *Conf) UnmarshalJSON(b []byte) error {
 	type alias Conf
 	d := json.NewDecoder(bytes.NewReader(b))
 	d.DisallowUnknownFields()
-	return d.Decode((*alias)(conf))
+	err := d.Decode((*alias)(conf))
+	if err != nil {
+		return err
+	}
+
+	// keep track of fields that have been set explicitly,
+	// in order to exclude them from inheritance.
+	var raw struct {
+		PathDefaults  map[string]json.RawMessage            `json:"pathDefaults"`
+		PathTemplates map[string]map[string]json.RawMessage `json:"pathTemplates"`
+		Paths         map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	json.Unmarshal(b, &raw) //nolint:errcheck
+	conf.defaultsInheritance = setFieldsOf(map[string]map[string]json.RawMessage{"": raw.PathDefaults})[""]
+	conf.templatesInheritance = setFieldsOf(raw.PathTemplates)
+	conf.pathsInheritance = setFieldsOf(raw.Paths)
+
+	return nil
+}
+
+// LogLocation returns the location of log timestamps.
+// When 'logTimezone' is empty, the local timezone is used.
+func (conf Conf) LogLocation() (*time.Location, error) {
+	if conf.LogTimezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(conf.LogTimezone)
 }
@@ -1,6 +1,7 @@
 package conf
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -27,16 +28,96 @@ func (d *Credential) UnmarshalJSON(b []byte) error {
 	}
 
 	if in != "" &&
-		!strings.HasPrefix(in, "sha256:") &&
+		!Credential(in).IsHashed() &&
 		!reCredential.MatchString(in) {
 		return fmt.Errorf("credential contains unsupported characters. Supported are: %s", credentialSupportedChars)
 	}
 
+	if strings.HasPrefix(in, "argon2:") {
+		err := checkArgon2Hash(in[len("argon2:"):])
+		if err != nil {
+			return fmt.Errorf("invalid argon2 hash: %s", err)
+		}
+	}
+
 	*d = Credential(in)
 	return nil
 }
 
+// checkArgon2Hash checks a hash in the PHC string format
+// ("$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>").
+func checkArgon2Hash(encoded string) error {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return fmt.Errorf("invalid format")
+	}
+
+	if parts[1] != "argon2id" && parts[1] != "argon2i" {
+		return fmt.Errorf("unsupported variant '%s'", parts[1])
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != 19 {
+		return fmt.Errorf("unsupported version")
+	}
+
+	var memory uint32
+	var iterations uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads)
+	if err != nil {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	if iterations == 0 {
+		return fmt.Errorf("t must be greater than zero")
+	}
+
+	if threads == 0 {
+		return fmt.Errorf("p must be greater than zero")
+	}
+
+	if memory < 8*uint32(threads) {
+		return fmt.Errorf("m must be at least 8 times p")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return fmt.Errorf("invalid salt")
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return fmt.Errorf("invalid hash")
+	}
+
+	return nil
+}
+
 // UnmarshalEnv implements envUnmarshaler.
 func (d *Credential) UnmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
 }
+
+// IsHashed checks whether the credential is hashed
+// (i.e. "sha256:...", "argon2:$argon2id$...", "bcrypt:$2a$...").
+func (d Credential) IsHashed() bool {
+	for _, prefix := range []string{"sha256:", "argon2:", "bcrypt:"} {
+		if strings.HasPrefix(string(d), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCertIdentity checks whether the credential is the identity of a TLS client certificate
+// (i.e. "CN=cam-042", "DNS=cam.local", "EMAIL=cam@local", "IP=192.168.1.2").
+func (d Credential) IsCertIdentity() bool {
+	for _, prefix := range []string{"CN=", "DNS=", "EMAIL=", "IP="} {
+		if strings.HasPrefix(string(d), prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,20 +1,27 @@
-// Package decrypt contains the Decrypt function.
+// Package decrypt contains the Decrypt and Encrypt functions.
 package decrypt
 
 import (
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"strings"
 
 	"golang.org/x/crypto/nacl/secretbox"
 )
 
 // Decrypt decrypts the configuration with the given key.
 func Decrypt(key string, byts []byte) ([]byte, error) {
-	enc, err := base64.StdEncoding.DecodeString(string(byts))
+	enc, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(byts)))
 	if err != nil {
 		return nil, err
 	}
 
+	if len(enc) < 24 {
+		return nil, fmt.Errorf("decryption error")
+	}
+
 	var secretKey [32]byte
 	copy(secretKey[:], key)
 
@@ -27,3 +34,19 @@ func Decrypt(key string, byts []byte) ([]byte, error) {
 
 	return decrypted, nil
 }
+
+// Encrypt encrypts the configuration with the given key,
+// in the format read by Decrypt.
+func Encrypt(key string, byts []byte) ([]byte, error) {
+	var secretKey [32]byte
+	copy(secretKey[:], key)
+
+	var nonce [24]byte
+	_, err := io.ReadFull(rand.Reader, nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := secretbox.Seal(nonce[:], byts, &nonce, &secretKey)
+	return []byte(base64.StdEncoding.EncodeToString(encrypted)), nil
+}
@@ -3,6 +3,8 @@ package conf
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // Encryption is the encryption parameter.
@@ -64,3 +66,10 @@ func (d *Encryption) UnmarshalJSON(b []byte) error {
 func (d *Encryption) UnmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d Encryption) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"enum": []interface{}{"no", "optional", "strict", "yes", "true", "false", true, false},
+	}
+}
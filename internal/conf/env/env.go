@@ -103,6 +103,23 @@ func loadEnvInternal(env map[string]string, prefix string, rv reflect.Value) err
 			}
 
 			mapKeyLower := strings.ToLower(mapKey)
+
+			// values that are not pointers are not addressable and must be copied
+			if rt.Elem().Kind() != reflect.Ptr {
+				nv := reflect.New(rt.Elem())
+				if existing := rv.MapIndex(reflect.ValueOf(mapKeyLower)); existing.IsValid() {
+					nv.Elem().Set(existing)
+				}
+
+				err := loadEnvInternal(env, prefix+"_"+mapKey, nv.Elem())
+				if err != nil {
+					return err
+				}
+
+				rv.SetMapIndex(reflect.ValueOf(mapKeyLower), nv.Elem())
+				continue
+			}
+
 			nv := rv.MapIndex(reflect.ValueOf(mapKeyLower))
 			zero := reflect.Value{}
 			if nv == zero {
@@ -120,13 +137,36 @@ func loadEnvInternal(env map[string]string, prefix string, rv reflect.Value) err
 		}
 		return nil
 
+	case reflect.Ptr:
+		if rt.Elem().Kind() != reflect.Struct {
+			break
+		}
+
+		for k := range env {
+			if !strings.HasPrefix(k, prefix+"_") {
+				continue
+			}
+
+			// initialize only if there's at least one key
+			if rv.IsNil() {
+				nv := reflect.New(rt.Elem())
+				if unm, ok := nv.Interface().(json.Unmarshaler); ok {
+					unm.UnmarshalJSON(nil) // load defaults
+				}
+				rv.Set(nv)
+			}
+
+			return loadEnvInternal(env, prefix, rv.Elem())
+		}
+		return nil
+
 	case reflect.Struct:
 		flen := rt.NumField()
 		for i := 0; i < flen; i++ {
 			f := rt.Field(i)
 
 			// load only public fields
-			if f.Tag.Get("json") == "-" {
+			if f.Tag.Get("json") == "-" || !f.IsExported() {
 				continue
 			}
 
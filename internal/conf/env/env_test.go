@@ -47,6 +47,7 @@ type testStruct struct {
 	MyBool       bool
 	MyDuration   myDuration
 	MyMap        map[string]*mapEntry
+	MyValueMap   map[string]myDuration
 	MySlice      []string
 	MySliceEmpty []string
 }
@@ -76,6 +77,9 @@ func TestLoad(t *testing.T) {
 	os.Setenv("MYPREFIX_MYMAP_MYKEY2_MYSTRUCT_MYPARAM", "456")
 	defer os.Unsetenv("MYPREFIX_MYMAP_MYKEY2_MYSTRUCT_MYPARAM")
 
+	os.Setenv("MYPREFIX_MYVALUEMAP_MYKEY", "5s")
+	defer os.Unsetenv("MYPREFIX_MYVALUEMAP_MYKEY")
+
 	os.Setenv("MYPREFIX_MYSLICE", "val1,val2")
 	defer os.Unsetenv("MYPREFIX_MYSLICE")
 
@@ -100,6 +104,8 @@ func TestLoad(t *testing.T) {
 	require.Equal(t, "asd", v.MyValue)
 	require.Equal(t, 456, v.MyStruct.MyParam)
 
+	require.Equal(t, map[string]myDuration{"mykey": 5 * myDuration(time.Second)}, s.MyValueMap)
+
 	require.Equal(t, []string{"val1", "val2"}, s.MySlice)
 	require.Equal(t, []string{}, s.MySliceEmpty)
 }
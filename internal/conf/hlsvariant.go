@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/bluenviron/gohlslib"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // HLSVariant is the hlsVariant parameter.
@@ -59,3 +61,11 @@ func (d *HLSVariant) UnmarshalJSON(b []byte) error {
 func (d *HLSVariant) UnmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d HLSVariant) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "string",
+		"enum": []string{"mpegts", "fmp4", "lowLatency"},
+	}
+}
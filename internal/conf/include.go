@@ -0,0 +1,211 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aler9/mediamtx/internal/conf/decrypt"
+	"github.com/aler9/mediamtx/internal/conf/yaml"
+)
+
+func resolveIncludePath(confPath string, fpath string) string {
+	if filepath.IsAbs(fpath) {
+		return fpath
+	}
+	return filepath.Join(filepath.Dir(confPath), fpath)
+}
+
+func readFile(fpath string) ([]byte, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := os.LookupEnv("RTSP_CONFKEY"); ok { // legacy format
+		byts, err = decrypt.Decrypt(key, byts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := os.LookupEnv("MTX_CONFKEY"); ok {
+		byts, err = decrypt.Decrypt(key, byts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return byts, nil
+}
+
+func readMap(fpath string) (map[string]interface{}, error) {
+	byts, err := readFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := yaml.LoadMap(byts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fpath, err)
+	}
+
+	return m, nil
+}
+
+func mergePaths(dest map[string]interface{}, paths interface{}, fpath string, origins map[string]string) error {
+	if paths == nil {
+		return nil
+	}
+
+	pathsMap, ok := paths.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: 'paths' must be a map", fpath)
+	}
+
+	for name, pconf := range pathsMap {
+		if _, ok := dest[name]; ok {
+			return fmt.Errorf("%s: path '%s' is defined twice", fpath, name)
+		}
+		dest[name] = pconf
+
+		if origins != nil {
+			origins["paths/"+name] = fpath
+		}
+	}
+
+	return nil
+}
+
+func includeList(m map[string]interface{}) ([]string, error) {
+	switch x := m["include"].(type) {
+	case nil:
+		return nil, nil
+
+	case string:
+		return []string{x}, nil
+
+	case []interface{}:
+		ret := make([]string, len(x))
+		for i, v := range x {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("'include' must be a list of files")
+			}
+			ret[i] = s
+		}
+		return ret, nil
+	}
+
+	return nil, fmt.Errorf("'include' must be a list of files")
+}
+
+// mergeIncludes merges into the main configuration the files listed in 'include'
+// and the path definitions contained in 'pathsDirectory'.
+// It returns the file where each key defined outside the main configuration comes from,
+// where keys of paths are prefixed with "paths/".
+func mergeIncludes(confPath string, m map[string]interface{}) (map[string]string, error) {
+	paths := make(map[string]interface{})
+	origins := make(map[string]string)
+
+	err := mergePaths(paths, m["paths"], confPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	includes, err := includeList(m)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fpath := range includes {
+		fpath = resolveIncludePath(confPath, fpath)
+
+		im, err := readMap(fpath)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := im["include"]; ok {
+			return nil, fmt.Errorf("%s: nested includes are not supported", fpath)
+		}
+		if _, ok := im["pathsDirectory"]; ok {
+			return nil, fmt.Errorf("%s: 'pathsDirectory' can be set in the main configuration file only", fpath)
+		}
+
+		for key, val := range im {
+			if key == "paths" {
+				err := mergePaths(paths, val, fpath, origins)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			if _, ok := m[key]; ok {
+				origin, ok := origins[key]
+				if !ok {
+					origin = confPath
+				}
+				return nil, fmt.Errorf("%s: '%s' is already defined in %s", fpath, key, origin)
+			}
+
+			m[key] = val
+			origins[key] = fpath
+		}
+	}
+
+	if dir, ok := m["pathsDirectory"].(string); ok && dir != "" {
+		dir = resolveIncludePath(confPath, dir)
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		var fpaths []string
+		for _, entry := range entries {
+			if !entry.IsDir() &&
+				(strings.HasSuffix(entry.Name(), ".yml") || strings.HasSuffix(entry.Name(), ".yaml")) {
+				fpaths = append(fpaths, filepath.Join(dir, entry.Name()))
+			}
+		}
+		sort.Strings(fpaths)
+
+		for _, fpath := range fpaths {
+			pm, err := readMap(fpath)
+			if err != nil {
+				return nil, err
+			}
+
+			err = mergePaths(paths, pm, fpath, origins)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(paths) != 0 || m["paths"] != nil {
+		m["paths"] = paths
+	}
+
+	return origins, nil
+}
+
+// IncludedPaths returns the files listed in 'include' and the 'pathsDirectory'.
+// Relative paths are resolved with respect to the main configuration file.
+func (conf Conf) IncludedPaths(confPath string) []string {
+	var ret []string
+
+	for _, fpath := range conf.Include {
+		ret = append(ret, resolveIncludePath(confPath, fpath))
+	}
+
+	if conf.PathsDirectory != "" {
+		ret = append(ret, resolveIncludePath(confPath, conf.PathsDirectory))
+	}
+
+	return ret
+}
@@ -6,6 +6,8 @@ import (
 	"net"
 	"sort"
 	"strings"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // IPsOrCIDRs is a parameter that contains a list of IPs or CIDRs.
@@ -55,3 +57,11 @@ func (d *IPsOrCIDRs) UnmarshalEnv(s string) error {
 	byts, _ := json.Marshal(strings.Split(s, ","))
 	return d.UnmarshalJSON(byts)
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d IPsOrCIDRs) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/aler9/mediamtx/internal/jsonschema"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
@@ -89,3 +90,14 @@ func (d *LogDestinations) UnmarshalEnv(s string) error {
 	byts, _ := json.Marshal(strings.Split(s, ","))
 	return d.UnmarshalJSON(byts)
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d LogDestinations) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "string",
+			"enum": []string{"stdout", "file", "syslog"},
+		},
+	}
+}
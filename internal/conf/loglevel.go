@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/aler9/mediamtx/internal/jsonschema"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
@@ -65,3 +66,11 @@ func (d *LogLevel) UnmarshalJSON(b []byte) error {
 func (d *LogLevel) UnmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d LogLevel) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "string",
+		"enum": []string{"error", "warn", "info", "debug"},
+	}
+}
@@ -42,6 +42,9 @@ func IsValidPathName(name string) error {
 type PathConf struct {
 	Regexp *regexp.Regexp `json:"-"`
 
+	// inheritance
+	Use string `json:"use"`
+
 	// source
 	Source                     string         `json:"source"`
 	SourceProtocol             SourceProtocol `json:"sourceProtocol"`
@@ -51,8 +54,12 @@ type PathConf struct {
 	SourceOnDemandStartTimeout StringDuration `json:"sourceOnDemandStartTimeout"`
 	SourceOnDemandCloseAfter   StringDuration `json:"sourceOnDemandCloseAfter"`
 	SourceRedirect             string         `json:"sourceRedirect"`
+	SourceFailover             []string       `json:"sourceFailover"`
+	SourceFailbackPeriod       StringDuration `json:"sourceFailbackPeriod"`
+	SourceFileLoop             bool           `json:"sourceFileLoop"`
 	DisablePublisherOverride   bool           `json:"disablePublisherOverride"`
 	Fallback                   string         `json:"fallback"`
+	OfflineSource              string         `json:"offlineSource"`
 	RPICameraCamID             int            `json:"rpiCameraCamID"`
 	RPICameraWidth             int            `json:"rpiCameraWidth"`
 	RPICameraHeight            int            `json:"rpiCameraHeight"`
@@ -93,6 +100,18 @@ type PathConf struct {
 	ReadPass    Credential `json:"readPass"`
 	ReadIPs     IPsOrCIDRs `json:"readIPs"`
 
+	// health
+	HealthMaxUnitAge     StringDuration `json:"healthMaxUnitAge"`
+	HealthMaxKeyFrameAge StringDuration `json:"healthMaxKeyFrameAge"`
+	HealthMinBitrate     int            `json:"healthMinBitrate"`
+	HealthExpectedTracks []string       `json:"healthExpectedTracks"`
+	HealthCloseSource    bool           `json:"healthCloseSource"`
+
+	// limits
+	MaxReaders            int            `json:"maxReaders"`
+	MaxBitrate            int            `json:"maxBitrate"`
+	MaxBitrateGracePeriod StringDuration `json:"maxBitrateGracePeriod"`
+
 	// external commands
 	RunOnInit               string         `json:"runOnInit"`
 	RunOnInitRestart        bool           `json:"runOnInitRestart"`
@@ -102,8 +121,18 @@ type PathConf struct {
 	RunOnDemandCloseAfter   StringDuration `json:"runOnDemandCloseAfter"`
 	RunOnReady              string         `json:"runOnReady"`
 	RunOnReadyRestart       bool           `json:"runOnReadyRestart"`
+	RunOnNotReady           string         `json:"runOnNotReady"`
 	RunOnRead               string         `json:"runOnRead"`
 	RunOnReadRestart        bool           `json:"runOnReadRestart"`
+	RunOnUnread             string         `json:"runOnUnread"`
+	RunOnUnhealthy          string         `json:"runOnUnhealthy"`
+
+	// webhooks
+	OnReadyWebhook     string `json:"onReadyWebhook"`
+	OnNotReadyWebhook  string `json:"onNotReadyWebhook"`
+	OnReadWebhook      string `json:"onReadWebhook"`
+	OnUnreadWebhook    string `json:"onUnreadWebhook"`
+	OnUnhealthyWebhook string `json:"onUnhealthyWebhook"`
 }
 
 func (pconf *PathConf) check(conf *Conf, name string) error {
@@ -132,9 +161,9 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a RTSP source. use another path")
 		}
 
-		_, err := url.Parse(pconf.Source)
+		err := checkSourceURL(pconf.Source)
 		if err != nil {
-			return fmt.Errorf("'%s' is not a valid RTSP URL", pconf.Source)
+			return err
 		}
 
 	case strings.HasPrefix(pconf.Source, "rtmp://") ||
@@ -143,18 +172,9 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a RTMP source. use another path")
 		}
 
-		u, err := gourl.Parse(pconf.Source)
+		err := checkSourceURL(pconf.Source)
 		if err != nil {
-			return fmt.Errorf("'%s' is not a valid RTMP URL", pconf.Source)
-		}
-
-		if u.User != nil {
-			pass, _ := u.User.Password()
-			user := u.User.Username()
-			if user != "" && pass == "" ||
-				user == "" && pass != "" {
-				return fmt.Errorf("username and password must be both provided")
-			}
+			return err
 		}
 
 	case strings.HasPrefix(pconf.Source, "http://") ||
@@ -163,21 +183,9 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a HLS source. use another path")
 		}
 
-		u, err := gourl.Parse(pconf.Source)
+		err := checkSourceURL(pconf.Source)
 		if err != nil {
-			return fmt.Errorf("'%s' is not a valid HLS URL", pconf.Source)
-		}
-		if u.Scheme != "http" && u.Scheme != "https" {
-			return fmt.Errorf("'%s' is not a valid HLS URL", pconf.Source)
-		}
-
-		if u.User != nil {
-			pass, _ := u.User.Password()
-			user := u.User.Username()
-			if user != "" && pass == "" ||
-				user == "" && pass != "" {
-				return fmt.Errorf("username and password must be both provided")
-			}
+			return err
 		}
 
 	case strings.HasPrefix(pconf.Source, "udp://"):
@@ -185,14 +193,19 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a HLS source. use another path")
 		}
 
-		host, _, err := net.SplitHostPort(pconf.Source[len("udp://"):])
+		err := checkSourceURL(pconf.Source)
 		if err != nil {
-			return fmt.Errorf("'%s' is not a valid UDP URL", pconf.Source)
+			return err
 		}
 
-		ip := net.ParseIP(host)
-		if ip == nil {
-			return fmt.Errorf("'%s' is not a valid IP", host)
+	case strings.HasPrefix(pconf.Source, "file://"):
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a file source. use another path")
+		}
+
+		err := checkSourceURL(pconf.Source)
+		if err != nil {
+			return err
 		}
 
 	case pconf.Source == "redirect":
@@ -229,6 +242,23 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 		}
 	}
 
+	if len(pconf.SourceFailover) != 0 {
+		if !pconf.HasStaticSource() || pconf.Source == "rpiCamera" {
+			return fmt.Errorf("'sourceFailover' can be used only when source is a URL")
+		}
+
+		for _, source := range pconf.SourceFailover {
+			err := checkSourceURL(source)
+			if err != nil {
+				return fmt.Errorf("invalid failover source: %s", err)
+			}
+		}
+	}
+
+	if pconf.SourceFailbackPeriod < 0 {
+		return fmt.Errorf("'sourceFailbackPeriod' can't be negative")
+	}
+
 	if pconf.Fallback != "" {
 		if strings.HasPrefix(pconf.Fallback, "/") {
 			err := IsValidPathName(pconf.Fallback[1:])
@@ -243,7 +273,21 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 		}
 	}
 
-	if (pconf.PublishUser != "" && pconf.PublishPass == "") ||
+	if pconf.OfflineSource != "" {
+		if pconf.Source == "redirect" {
+			return fmt.Errorf("'offlineSource' can't be used when source is 'redirect'")
+		}
+
+		if pconf.SourceOnDemand || pconf.RunOnDemand != "" {
+			return fmt.Errorf("'offlineSource' can't be used together with on-demand sources")
+		}
+	}
+
+	if pconf.PublishUser.IsCertIdentity() {
+		if pconf.PublishPass != "" {
+			return fmt.Errorf("publish password can't be used together with a certificate identity")
+		}
+	} else if (pconf.PublishUser != "" && pconf.PublishPass == "") ||
 		(pconf.PublishUser == "" && pconf.PublishPass != "") {
 		return fmt.Errorf("read username and password must be both filled")
 	}
@@ -258,16 +302,20 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 			"the stream is not provided by a publisher, but by a fixed source")
 	}
 
-	if (pconf.ReadUser != "" && pconf.ReadPass == "") ||
+	if pconf.ReadUser.IsCertIdentity() {
+		if pconf.ReadPass != "" {
+			return fmt.Errorf("read password can't be used together with a certificate identity")
+		}
+	} else if (pconf.ReadUser != "" && pconf.ReadPass == "") ||
 		(pconf.ReadUser == "" && pconf.ReadPass != "") {
 		return fmt.Errorf("read username and password must be both filled")
 	}
 
 	if contains(conf.AuthMethods, headers.AuthDigest) {
-		if strings.HasPrefix(string(pconf.PublishUser), "sha256:") ||
-			strings.HasPrefix(string(pconf.PublishPass), "sha256:") ||
-			strings.HasPrefix(string(pconf.ReadUser), "sha256:") ||
-			strings.HasPrefix(string(pconf.ReadPass), "sha256:") {
+		if pconf.PublishUser.IsHashed() ||
+			pconf.PublishPass.IsHashed() ||
+			pconf.ReadUser.IsHashed() ||
+			pconf.ReadPass.IsHashed() {
 			return fmt.Errorf("hashed credentials can't be used when the digest auth method is available")
 		}
 	}
@@ -289,6 +337,120 @@ func (pconf *PathConf) check(conf *Conf, name string) error {
 		return fmt.Errorf("'runOnDemand' can be used only when source is 'publisher'")
 	}
 
+	if pconf.HealthMaxUnitAge < 0 || pconf.HealthMaxKeyFrameAge < 0 {
+		return fmt.Errorf("health durations can't be negative")
+	}
+
+	if pconf.HealthMinBitrate < 0 {
+		return fmt.Errorf("'healthMinBitrate' can't be negative")
+	}
+
+	for _, codec := range pconf.HealthExpectedTracks {
+		if codec == "" {
+			return fmt.Errorf("'healthExpectedTracks' can't contain empty codecs")
+		}
+	}
+
+	if pconf.HealthCloseSource && !pconf.HasHealthRules() {
+		return fmt.Errorf("'healthCloseSource' requires at least one health rule")
+	}
+
+	if pconf.MaxReaders < 0 {
+		return fmt.Errorf("'maxReaders' can't be negative")
+	}
+
+	if pconf.MaxBitrate < 0 {
+		return fmt.Errorf("'maxBitrate' can't be negative")
+	}
+
+	if pconf.MaxBitrate != 0 && pconf.Source != "publisher" {
+		return fmt.Errorf("'maxBitrate' can be used only when source is 'publisher'")
+	}
+
+	if pconf.MaxBitrateGracePeriod < 0 {
+		return fmt.Errorf("'maxBitrateGracePeriod' can't be negative")
+	}
+
+	for key, ur := range map[string]string{
+		"onReadyWebhook":     pconf.OnReadyWebhook,
+		"onNotReadyWebhook":  pconf.OnNotReadyWebhook,
+		"onReadWebhook":      pconf.OnReadWebhook,
+		"onUnreadWebhook":    pconf.OnUnreadWebhook,
+		"onUnhealthyWebhook": pconf.OnUnhealthyWebhook,
+	} {
+		if err := checkWebhookURL(key, ur); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSourceURL checks the URL of a static source.
+func checkSourceURL(source string) error {
+	switch {
+	case strings.HasPrefix(source, "rtsp://") ||
+		strings.HasPrefix(source, "rtsps://"):
+		_, err := url.Parse(source)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid RTSP URL", source)
+		}
+
+	case strings.HasPrefix(source, "rtmp://") ||
+		strings.HasPrefix(source, "rtmps://"):
+		u, err := gourl.Parse(source)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid RTMP URL", source)
+		}
+
+		if u.User != nil {
+			pass, _ := u.User.Password()
+			user := u.User.Username()
+			if user != "" && pass == "" ||
+				user == "" && pass != "" {
+				return fmt.Errorf("username and password must be both provided")
+			}
+		}
+
+	case strings.HasPrefix(source, "http://") ||
+		strings.HasPrefix(source, "https://"):
+		u, err := gourl.Parse(source)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid HLS URL", source)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("'%s' is not a valid HLS URL", source)
+		}
+
+		if u.User != nil {
+			pass, _ := u.User.Password()
+			user := u.User.Username()
+			if user != "" && pass == "" ||
+				user == "" && pass != "" {
+				return fmt.Errorf("username and password must be both provided")
+			}
+		}
+
+	case strings.HasPrefix(source, "udp://"):
+		host, _, err := net.SplitHostPort(source[len("udp://"):])
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid UDP URL", source)
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("'%s' is not a valid IP", host)
+		}
+
+	case strings.HasPrefix(source, "file://"):
+		if source == "file://" {
+			return fmt.Errorf("'%s' is not a valid file URL", source)
+		}
+
+	default:
+		return fmt.Errorf("invalid source: '%s'", source)
+	}
+
 	return nil
 }
 
@@ -322,14 +484,28 @@ func (pconf PathConf) HasStaticSource() bool {
 		strings.HasPrefix(pconf.Source, "http://") ||
 		strings.HasPrefix(pconf.Source, "https://") ||
 		strings.HasPrefix(pconf.Source, "udp://") ||
+		strings.HasPrefix(pconf.Source, "file://") ||
 		pconf.Source == "rpiCamera"
 }
 
+// StaticSources returns the URLs of the static source, in order of priority.
+func (pconf PathConf) StaticSources() []string {
+	return append([]string{pconf.Source}, pconf.SourceFailover...)
+}
+
 // HasOnDemandStaticSource checks whether the path has a on demand static source.
 func (pconf PathConf) HasOnDemandStaticSource() bool {
 	return pconf.HasStaticSource() && pconf.SourceOnDemand
 }
 
+// HasHealthRules checks whether the path has at least one health rule.
+func (pconf PathConf) HasHealthRules() bool {
+	return pconf.HealthMaxUnitAge != 0 ||
+		pconf.HealthMaxKeyFrameAge != 0 ||
+		pconf.HealthMinBitrate != 0 ||
+		len(pconf.HealthExpectedTracks) != 0
+}
+
 // HasOnDemandPublisher checks whether the path has a on-demand publisher.
 func (pconf PathConf) HasOnDemandPublisher() bool {
 	return pconf.RunOnDemand != ""
@@ -341,6 +517,7 @@ func (pconf *PathConf) UnmarshalJSON(b []byte) error {
 	pconf.Source = "publisher"
 	pconf.SourceOnDemandStartTimeout = 10 * StringDuration(time.Second)
 	pconf.SourceOnDemandCloseAfter = 10 * StringDuration(time.Second)
+	pconf.SourceFailbackPeriod = 30 * StringDuration(time.Second)
 	pconf.RPICameraWidth = 1920
 	pconf.RPICameraHeight = 1080
 	pconf.RPICameraContrast = 1
@@ -353,12 +530,61 @@ func (pconf *PathConf) UnmarshalJSON(b []byte) error {
 	pconf.RPICameraLevel = "4.1"
 	pconf.RPICameraTextOverlay = "%Y-%m-%d %H:%M:%S - MediaMTX"
 
+	// limits
+	pconf.MaxBitrateGracePeriod = 10 * StringDuration(time.Second)
+
 	// external commands
 	pconf.RunOnDemandStartTimeout = 10 * StringDuration(time.Second)
 	pconf.RunOnDemandCloseAfter = 10 * StringDuration(time.Second)
 
 	type alias PathConf
+	aux := struct {
+		*alias
+		Source json.RawMessage `json:"source"`
+	}{
+		alias: (*alias)(pconf),
+	}
+
 	d := json.NewDecoder(bytes.NewReader(b))
 	d.DisallowUnknownFields()
-	return d.Decode((*alias)(pconf))
+	err := d.Decode(&aux)
+	if err != nil {
+		return err
+	}
+
+	return pconf.unmarshalSource(aux.Source)
+}
+
+// unmarshalSource decodes 'source', that can be a string or a list of sources in order of priority.
+// In the latter case, sources after the first one are used as failover sources.
+func (pconf *PathConf) unmarshalSource(b json.RawMessage) error {
+	if b == nil {
+		return nil
+	}
+
+	source := pconf.Source
+	err := json.Unmarshal(b, &source)
+	if err == nil {
+		pconf.Source = source
+		return nil
+	}
+
+	var sources []string
+	err = json.Unmarshal(b, &sources)
+	if err != nil {
+		return fmt.Errorf("'source' must be a string or a list of strings")
+	}
+
+	if len(sources) == 0 {
+		return fmt.Errorf("'source' can't be an empty list")
+	}
+
+	if len(pconf.SourceFailover) != 0 {
+		return fmt.Errorf("'sourceFailover' can't be used when 'source' is a list")
+	}
+
+	pconf.Source = sources[0]
+	pconf.SourceFailover = sources[1:]
+
+	return nil
 }
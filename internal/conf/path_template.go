@@ -0,0 +1,267 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// pathInheritance is the inheritance state of a path or template.
+type pathInheritance struct {
+	// fields that have been provided in the configuration
+	setFields map[string]struct{}
+
+	// values inherited from 'pathDefaults' and templates.
+	// When nil, values are inherited from default values.
+	base *PathConf
+}
+
+func defaultPathConf() *PathConf {
+	pconf := &PathConf{}
+	pconf.UnmarshalJSON(nil) //nolint:errcheck
+	return pconf
+}
+
+func pathConfFieldKey(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+func setFieldsOf(raw map[string]map[string]json.RawMessage) map[string]*pathInheritance {
+	ret := make(map[string]*pathInheritance, len(raw))
+	for name, fields := range raw {
+		inh := &pathInheritance{
+			setFields: make(map[string]struct{}, len(fields)),
+		}
+		for key := range fields {
+			inh.setFields[key] = struct{}{}
+		}
+		ret[name] = inh
+	}
+	return ret
+}
+
+func copyInheritance(src map[string]*pathInheritance) map[string]*pathInheritance {
+	ret := make(map[string]*pathInheritance, len(src))
+	for name, inh := range src {
+		ret[name] = inh
+	}
+	return ret
+}
+
+// withSetFields returns a copy of inh in which keys are marked as set.
+// inh is not modified, since it may be shared with other configurations.
+func withSetFields(inh *pathInheritance, keys []string) *pathInheritance {
+	ret := &pathInheritance{
+		setFields: make(map[string]struct{}),
+	}
+
+	if inh != nil {
+		ret.base = inh.base
+		for key := range inh.setFields {
+			ret.setFields[key] = struct{}{}
+		}
+	}
+
+	for _, key := range keys {
+		ret.setFields[key] = struct{}{}
+	}
+
+	return ret
+}
+
+// SetPathFields marks fields of a path as explicitly set, in order to
+// exclude them from inheritance. keys are the JSON keys of the fields.
+// It is used when a path is edited without going through UnmarshalJSON.
+func (conf *Conf) SetPathFields(name string, keys []string) {
+	if conf.pathsInheritance == nil {
+		conf.pathsInheritance = make(map[string]*pathInheritance)
+	}
+	conf.pathsInheritance[name] = withSetFields(conf.pathsInheritance[name], keys)
+}
+
+// setEnvFields marks fields of paths, templates and 'pathDefaults'
+// that are loaded from environment variables as explicitly set.
+func (conf *Conf) setEnvFields(prefix string) {
+	envKeys := make(map[string]string)
+	rt := reflect.TypeOf(PathConf{})
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if key := pathConfFieldKey(f); key != "" && key != "-" && f.IsExported() {
+			envKeys[strings.ToUpper(f.Name)] = key
+		}
+	}
+
+	for _, kv := range os.Environ() {
+		k := strings.SplitN(kv, "=", 2)[0]
+
+		switch {
+		case strings.HasPrefix(k, prefix+"_PATHDEFAULTS_"):
+			if key, ok := envKeys[k[len(prefix+"_PATHDEFAULTS_"):]]; ok {
+				conf.defaultsInheritance = withSetFields(conf.defaultsInheritance, []string{key})
+			}
+
+		case strings.HasPrefix(k, prefix+"_PATHTEMPLATES_"):
+			parts := strings.Split(k[len(prefix+"_PATHTEMPLATES_"):], "_")
+			if key, ok := envKeys[parts[len(parts)-1]]; ok && len(parts) == 2 {
+				if conf.templatesInheritance == nil {
+					conf.templatesInheritance = make(map[string]*pathInheritance)
+				}
+				name := strings.ToLower(parts[0])
+				conf.templatesInheritance[name] = withSetFields(conf.templatesInheritance[name], []string{key})
+			}
+
+		case strings.HasPrefix(k, prefix+"_PATHS_"):
+			parts := strings.Split(k[len(prefix+"_PATHS_"):], "_")
+			if key, ok := envKeys[parts[len(parts)-1]]; ok && len(parts) == 2 {
+				conf.SetPathFields(strings.ToLower(parts[0]), []string{key})
+			}
+		}
+	}
+}
+
+// explicitFields returns the indexes of fields that have been explicitly set,
+// i.e. fields that have been provided in the configuration or that differ from inherited values.
+func explicitFields(pconf *PathConf, inh *pathInheritance) []int {
+	base := defaultPathConf()
+	var setFields map[string]struct{}
+	if inh != nil {
+		if inh.base != nil {
+			base = inh.base
+		}
+		setFields = inh.setFields
+	}
+
+	rt := reflect.TypeOf(*pconf)
+	rv := reflect.ValueOf(*pconf)
+	rbase := reflect.ValueOf(*base)
+	var ret []int
+
+	for i := 0; i < rt.NumField(); i++ {
+		key := pathConfFieldKey(rt.Field(i))
+		if key == "" || key == "-" || key == "use" {
+			continue
+		}
+
+		if _, ok := setFields[key]; ok ||
+			!reflect.DeepEqual(rv.Field(i).Interface(), rbase.Field(i).Interface()) {
+			ret = append(ret, i)
+		}
+	}
+
+	return ret
+}
+
+// overlayPathConf copies into dest the fields that have been explicitly set in src.
+func overlayPathConf(dest *PathConf, src *PathConf, inh *pathInheritance) {
+	rsrc := reflect.ValueOf(src).Elem()
+	rdest := reflect.ValueOf(dest).Elem()
+
+	for _, i := range explicitFields(src, inh) {
+		rdest.Field(i).Set(rsrc.Field(i))
+	}
+}
+
+// rawPathConf returns the fields of a path that have been explicitly set.
+func rawPathConf(pconf *PathConf, inh *pathInheritance) map[string]interface{} {
+	ret := make(map[string]interface{})
+
+	if pconf.Use != "" {
+		ret["use"] = pconf.Use
+	}
+
+	rt := reflect.TypeOf(*pconf)
+	rv := reflect.ValueOf(*pconf)
+
+	for _, i := range explicitFields(pconf, inh) {
+		ret[pathConfFieldKey(rt.Field(i))] = rv.Field(i).Interface()
+	}
+
+	return ret
+}
+
+// resolvePath computes the final configuration of a path, by applying, in order,
+// default values, 'pathDefaults', the template referenced by 'use' and path fields.
+func (conf *Conf) resolvePath(name string, pconf *PathConf) (*PathConf, error) {
+	var base *PathConf
+	if conf.PathDefaults != nil {
+		base = conf.PathDefaults.Clone()
+	} else {
+		base = defaultPathConf()
+	}
+
+	if pconf.Use != "" {
+		tpl, ok := conf.PathTemplates[pconf.Use]
+		if !ok {
+			return nil, fmt.Errorf("template '%s' not found", pconf.Use)
+		}
+
+		if tpl != nil {
+			overlayPathConf(base, tpl, conf.templatesInheritance[pconf.Use])
+		}
+	}
+
+	resolved := base.Clone()
+	overlayPathConf(resolved, pconf, conf.pathsInheritance[name])
+	resolved.Use = pconf.Use
+
+	inh := &pathInheritance{base: base}
+	if prev, ok := conf.pathsInheritance[name]; ok {
+		inh.setFields = prev.setFields
+	}
+	conf.pathsInheritance[name] = inh
+
+	return resolved, nil
+}
+
+func (conf *Conf) checkPathTemplates() error {
+	if conf.PathDefaults != nil && conf.PathDefaults.Use != "" {
+		return fmt.Errorf("'pathDefaults' cannot use a template")
+	}
+
+	for name, tpl := range conf.PathTemplates {
+		if tpl != nil && tpl.Use != "" {
+			return fmt.Errorf("template '%s' cannot use another template", name)
+		}
+	}
+
+	return nil
+}
+
+// RawView returns the configuration, where paths, templates and path defaults
+// contain only the fields that have been explicitly set, without inherited ones.
+func (conf Conf) RawView() map[string]interface{} {
+	enc, err := json.Marshal(conf)
+	if err != nil {
+		panic(err)
+	}
+
+	var ret map[string]interface{}
+	err = json.Unmarshal(enc, &ret)
+	if err != nil {
+		panic(err)
+	}
+
+	if conf.PathDefaults != nil {
+		ret["pathDefaults"] = rawPathConf(conf.PathDefaults, conf.defaultsInheritance)
+	}
+
+	paths := make(map[string]interface{})
+	for name, pconf := range conf.Paths {
+		if pconf != nil {
+			paths[name] = rawPathConf(pconf, conf.pathsInheritance[name])
+		}
+	}
+	ret["paths"] = paths
+
+	templates := make(map[string]interface{})
+	for name, tpl := range conf.PathTemplates {
+		if tpl != nil {
+			templates[name] = rawPathConf(tpl, conf.templatesInheritance[name])
+		}
+	}
+	ret["pathTemplates"] = templates
+
+	return ret
+}
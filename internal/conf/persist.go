@@ -0,0 +1,286 @@
+package conf
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/aler9/mediamtx/internal/conf/decrypt"
+	"github.com/aler9/mediamtx/internal/conf/yaml"
+)
+
+func encryptFile(byts []byte) ([]byte, error) {
+	// apply keys in reverse order with respect to readFile()
+	if key, ok := os.LookupEnv("MTX_CONFKEY"); ok {
+		var err error
+		byts, err = decrypt.Encrypt(key, byts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := os.LookupEnv("RTSP_CONFKEY"); ok { // legacy format
+		var err error
+		byts, err = decrypt.Encrypt(key, byts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return byts, nil
+}
+
+// writeFileAtomic writes a file by using a temporary file and renaming it,
+// in order to never leave the file in a partially-written state.
+func writeFileAtomic(fpath string, byts []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(fpath); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	// follow symlinks, in order to replace the target file
+	if target, err := filepath.EvalSymlinks(fpath); err == nil {
+		fpath = target
+	}
+
+	tmpf, err := os.CreateTemp(filepath.Dir(fpath), "."+filepath.Base(fpath)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpf.Name())
+
+	_, err = tmpf.Write(byts)
+	if err != nil {
+		tmpf.Close()
+		return err
+	}
+
+	err = tmpf.Sync()
+	if err != nil {
+		tmpf.Close()
+		return err
+	}
+
+	err = tmpf.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmpf.Name(), mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpf.Name(), fpath)
+}
+
+func updateFile(fpath string, m map[string]interface{}) error {
+	var byts []byte
+	if _, err := os.Stat(fpath); err == nil {
+		byts, err = readFile(fpath)
+		if err != nil {
+			return err
+		}
+	}
+
+	updated, err := yaml.Update(byts, m)
+	if err != nil {
+		return err
+	}
+
+	if string(updated) == string(byts) {
+		return nil
+	}
+
+	updated, err = encryptFile(updated)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fpath, updated)
+}
+
+func loadExisting(fpath string) (map[string]interface{}, error) {
+	byts, err := readFile(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+
+	return yaml.LoadMap(byts)
+}
+
+// keepEmptyUse keeps an empty 'use' key, that is omitted by the raw view.
+func keepEmptyUse(val interface{}, existing interface{}) {
+	existingMap, ok := existing.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if use, ok := existingMap["use"]; ok {
+		m := val.(map[string]interface{})
+		if _, ok := m["use"]; !ok {
+			m["use"] = use
+		}
+	}
+}
+
+// withoutEnv replaces the values that have not been changed since loading
+// with the ones loaded from files, removing values loaded from environment variables.
+func withoutEnv(cur interface{}, loaded interface{}, file interface{}, fileOk bool) (interface{}, bool) {
+	if reflect.DeepEqual(cur, loaded) {
+		return file, fileOk
+	}
+
+	curMap, ok1 := cur.(map[string]interface{})
+	loadedMap, ok2 := loaded.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return cur, true
+	}
+
+	fileMap, _ := file.(map[string]interface{})
+
+	ret := make(map[string]interface{})
+	for key, val := range curMap {
+		loadedVal, ok := loadedMap[key]
+		if !ok {
+			// key added after loading
+			ret[key] = val
+			continue
+		}
+
+		fileVal, ok := fileMap[key]
+		if v, ok := withoutEnv(val, loadedVal, fileVal, ok); ok {
+			ret[key] = v
+		}
+	}
+
+	return ret, true
+}
+
+func jsonMap(v interface{}) (map[string]interface{}, error) {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret map[string]interface{}
+	err = json.Unmarshal(enc, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Persist writes the configuration into the files it has been loaded from.
+// Secrets are written as references, paths contain only explicitly-set fields
+// and keys defined in included files are written into those files.
+// Global keys that are not in the file and have default values are not added.
+// Values loaded from environment variables are not written.
+func (conf Conf) Persist(confPath string) error {
+	confPath = actualConfPath(confPath)
+
+	rawMap, err := jsonMap(conf.RawView())
+	if err != nil {
+		return err
+	}
+
+	var raw interface{} = rawMap
+	if conf.loadedView != nil {
+		raw, _ = withoutEnv(raw, conf.loadedView, conf.fileView, true)
+	}
+
+	redacted, err := conf.Redact(raw)
+	if err != nil {
+		return err
+	}
+	view := redacted.(map[string]interface{})
+
+	var defaults Conf
+	defaults.UnmarshalJSON(nil) //nolint:errcheck
+	defaultView, err := jsonMap(defaults.RawView())
+	if err != nil {
+		return err
+	}
+
+	existing, err := loadExisting(confPath)
+	if err != nil {
+		return err
+	}
+
+	// "all" is the documented alias of "~^.*$"
+	paths, _ := view["paths"].(map[string]interface{})
+	if v, ok := paths["~^.*$"]; ok {
+		paths["all"] = v
+		delete(paths, "~^.*$")
+	}
+	delete(view, "paths")
+
+	files := map[string]map[string]interface{}{
+		confPath: {},
+	}
+	for _, fpath := range conf.origins {
+		files[fpath] = make(map[string]interface{})
+	}
+
+	for key, val := range view {
+		if fpath, ok := conf.origins[key]; ok {
+			files[fpath][key] = val
+			continue
+		}
+
+		if _, ok := existing[key]; !ok && reflect.DeepEqual(val, defaultView[key]) {
+			continue
+		}
+
+		files[confPath][key] = val
+	}
+
+	pathsDir := ""
+	if conf.PathsDirectory != "" {
+		pathsDir = resolveIncludePath(confPath, conf.PathsDirectory)
+	}
+
+	for name, val := range paths {
+		fpath, ok := conf.origins["paths/"+name]
+		if !ok {
+			fpath = confPath
+		}
+
+		fileExisting, err := loadExisting(fpath)
+		if err != nil {
+			return err
+		}
+
+		if pathsDir != "" && filepath.Dir(fpath) == pathsDir {
+			keepEmptyUse(val, fileExisting[name])
+			files[fpath][name] = val
+			continue
+		}
+
+		existingPaths, _ := fileExisting["paths"].(map[string]interface{})
+		keepEmptyUse(val, existingPaths[name])
+
+		if files[fpath]["paths"] == nil {
+			files[fpath]["paths"] = make(map[string]interface{})
+		}
+		files[fpath]["paths"].(map[string]interface{})[name] = val
+	}
+
+	if _, ok := existing["paths"]; ok && files[confPath]["paths"] == nil {
+		files[confPath]["paths"] = make(map[string]interface{})
+	}
+
+	for fpath, m := range files {
+		err := updateFile(fpath, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
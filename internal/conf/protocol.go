@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/bluenviron/gortsplib/v3"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // Protocol is a RTSP transport.
@@ -79,3 +81,14 @@ func (d *Protocols) UnmarshalEnv(s string) error {
 	byts, _ := json.Marshal(strings.Split(s, ","))
 	return d.UnmarshalJSON(byts)
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d Protocols) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "string",
+			"enum": []string{"udp", "multicast", "tcp"},
+		},
+	}
+}
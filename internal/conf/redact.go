@@ -0,0 +1,119 @@
+package conf
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/aler9/mediamtx/internal/conf/yaml"
+)
+
+func secretKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// secretOf returns the value that has been loaded into the given location.
+// Fields of paths and templates that are not set explicitly
+// are looked up in the template and in 'pathDefaults'.
+func (conf *Conf) secretOf(path []string) (yaml.Secret, bool) {
+	if s, ok := conf.secrets[secretKey(path)]; ok {
+		return s, true
+	}
+
+	if len(path) < 3 {
+		return yaml.Secret{}, false
+	}
+
+	field := path[2:]
+
+	switch path[0] {
+	case "paths":
+		if inh, ok := conf.pathsInheritance[path[1]]; ok {
+			if _, ok := inh.setFields[field[0]]; ok {
+				return yaml.Secret{}, false
+			}
+		}
+
+		if pconf, ok := conf.Paths[path[1]]; ok && pconf != nil && pconf.Use != "" {
+			if s, ok := conf.secretOf(append([]string{"pathTemplates", pconf.Use}, field...)); ok {
+				return s, true
+			}
+		}
+
+		s, ok := conf.secrets[secretKey(append([]string{"pathDefaults"}, field...))]
+		return s, ok
+
+	case "pathTemplates":
+		if inh, ok := conf.templatesInheritance[path[1]]; ok {
+			if _, ok := inh.setFields[field[0]]; ok {
+				return yaml.Secret{}, false
+			}
+		}
+
+		s, ok := conf.secrets[secretKey(append([]string{"pathDefaults"}, field...))]
+		return s, ok
+	}
+
+	return yaml.Secret{}, false
+}
+
+func (conf *Conf) redactValue(i interface{}, path []string) interface{} {
+	switch x := i.(type) {
+	case string:
+		// values are replaced only if they have not been changed after loading
+		if s, ok := conf.secretOf(path); ok && s.Value == x {
+			return s.Raw
+		}
+
+	case map[string]interface{}:
+		for k, v := range x {
+			x[k] = conf.redactValue(v, append(path[:len(path):len(path)], k))
+		}
+
+	case []interface{}:
+		for i, v := range x {
+			x[i] = conf.redactValue(v, append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+	}
+
+	return i
+}
+
+func toGeneric(v interface{}) (interface{}, error) {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret interface{}
+	err = json.Unmarshal(enc, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Redact converts v into a generic JSON value, in which values loaded
+// from environment variables and files are replaced by their references.
+// v can be the configuration itself or its raw view.
+func (conf Conf) Redact(v interface{}) (interface{}, error) {
+	g, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf.redactValue(g, nil), nil
+}
+
+// RedactPath converts the configuration of a path into a generic JSON value,
+// in which values loaded from environment variables and files are replaced by their references.
+// name is the name of the path in the configuration.
+func (conf Conf) RedactPath(name string, pconf *PathConf) (interface{}, error) {
+	g, err := toGeneric(pconf)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf.redactValue(g, []string{"paths", name}), nil
+}
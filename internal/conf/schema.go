@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"reflect"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
+)
+
+// JSONSchema implements jsonschema.Provider.
+func (conf Conf) JSONSchema(g *jsonschema.Generator) map[string]interface{} {
+	ret := g.Object(reflect.TypeOf(conf))
+	props := ret["properties"].(map[string]interface{})
+
+	// a single file can be included without using a list
+	props["include"] = map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			props["include"],
+		},
+	}
+
+	return ret
+}
+
+// JSONSchema implements jsonschema.Provider.
+func (pconf PathConf) JSONSchema(g *jsonschema.Generator) map[string]interface{} {
+	ret := g.Object(reflect.TypeOf(pconf))
+	props := ret["properties"].(map[string]interface{})
+
+	// failover sources can be listed after the main one
+	source := props["source"].(map[string]interface{})
+	props["source"] = map[string]interface{}{
+		"anyOf": []interface{}{
+			source,
+			map[string]interface{}{
+				"type":     "array",
+				"items":    map[string]interface{}{"type": "string"},
+				"minItems": 1,
+			},
+		},
+		"default": source["default"],
+	}
+
+	return ret
+}
+
+// Schema returns the JSON Schema of the configuration file.
+// Default values are the ones that are used when a field is not set.
+func Schema() map[string]interface{} {
+	g := jsonschema.NewGenerator("#/$defs/")
+	g.AcceptNull = true
+
+	ret := Conf{}.JSONSchema(g)
+	ret["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	ret["title"] = "MediaMTX configuration"
+	ret["$defs"] = g.Defs
+
+	return ret
+}
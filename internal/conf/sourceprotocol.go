@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/bluenviron/gortsplib/v3"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // SourceProtocol is the sourceProtocol parameter.
@@ -71,3 +73,11 @@ func (d *SourceProtocol) UnmarshalJSON(b []byte) error {
 func (d *SourceProtocol) UnmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d SourceProtocol) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "string",
+		"enum": []string{"udp", "multicast", "tcp", "automatic"},
+	}
+}
@@ -3,6 +3,8 @@ package conf
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/aler9/mediamtx/internal/jsonschema"
 )
 
 // StringDuration is a duration that is unmarshaled from a string.
@@ -34,3 +36,11 @@ func (d *StringDuration) UnmarshalJSON(b []byte) error {
 func (d *StringDuration) UnmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
 }
+
+// JSONSchema implements jsonschema.Provider.
+func (d StringDuration) JSONSchema(_ *jsonschema.Generator) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "string",
+		"pattern": `^([0-9]*\.?[0-9]+(ns|us|µs|ms|s|m|h))+$`,
+	}
+}
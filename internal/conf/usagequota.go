@@ -0,0 +1,8 @@
+package conf
+
+// UsageQuota is the amount of bytes that a user is allowed to read.
+// Zero values disable the related quota.
+type UsageQuota struct {
+	Daily   StringSize `json:"daily"`
+	Monthly StringSize `json:"monthly"`
+}
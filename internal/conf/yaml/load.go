@@ -4,10 +4,137 @@ package yaml
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+var reReference = regexp.MustCompile(`\$\{(ENV|FILE):([^}]+)\}`)
+
+// Secret is a value that contains references to environment variables or files.
+type Secret struct {
+	// the location of the value, i.e. the keys and the indexes that lead to it
+	Path []string
+
+	// the original value, that contains references like "${ENV:NAME}" or "${FILE:/run/secrets/x}"
+	Raw string
+
+	// the resolved value
+	Value string
+}
+
+func resolveReference(kind string, name string) (string, error) {
+	if kind == "ENV" {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' is not set", name)
+		}
+		return v, nil
+	}
+
+	byts, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(byts), "\r\n"), nil
+}
+
+func interpolateString(s string, path []string, secrets *[]Secret) (string, error) {
+	var err error
+	found := false
+
+	ret := reReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+
+		m := reReference.FindStringSubmatch(ref)
+
+		var v string
+		v, err = resolveReference(m[1], m[2])
+		if err != nil {
+			return ""
+		}
+
+		found = true
+		return v
+	})
+
+	if err == nil && found {
+		*secrets = append(*secrets, Secret{
+			Path:  path,
+			Raw:   s,
+			Value: ret,
+		})
+	}
+
+	return ret, err
+}
+
+func interpolate(i interface{}, path []string, secrets *[]Secret) (interface{}, error) {
+	switch x := i.(type) {
+	case string:
+		return interpolateString(x, path, secrets)
+
+	case map[string]interface{}:
+		for k, v := range x {
+			var err error
+			x[k], err = interpolate(v, append(path[:len(path):len(path)], k), secrets)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	case []interface{}:
+		for i, v := range x {
+			var err error
+			x[i], err = interpolate(v, append(path[:len(path):len(path)], strconv.Itoa(i)), secrets)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return i, nil
+}
+
+// Interpolate replaces ${ENV:NAME} and ${FILE:path} references
+// with the content of environment variables and files.
+// It returns the values that contained references.
+func Interpolate(m map[string]interface{}) ([]Secret, error) {
+	var secrets []Secret
+	_, err := interpolate(m, nil, &secrets)
+	return secrets, err
+}
+
+// FindReference returns the first ${ENV:NAME} or ${FILE:path} reference contained in i,
+// or an empty string if there are no references.
+func FindReference(i interface{}) string {
+	switch x := i.(type) {
+	case string:
+		return reReference.FindString(x)
+
+	case map[string]interface{}:
+		for _, v := range x {
+			if ref := FindReference(v); ref != "" {
+				return ref
+			}
+		}
+
+	case []interface{}:
+		for _, v := range x {
+			if ref := FindReference(v); ref != "" {
+				return ref
+			}
+		}
+	}
+
+	return ""
+}
+
 func convertKeys(i interface{}) (interface{}, error) {
 	switch x := i.(type) {
 	case map[interface{}]interface{}:
@@ -41,6 +168,31 @@ func convertKeys(i interface{}) (interface{}, error) {
 	return i, nil
 }
 
+// LoadMap loads a Yaml document into a generic map.
+func LoadMap(buf []byte) (map[string]interface{}, error) {
+	var temp interface{}
+	err := yaml.Unmarshal(buf, &temp)
+	if err != nil {
+		return nil, err
+	}
+
+	if temp == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	temp, err = convertKeys(temp)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := temp.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the document is not a map")
+	}
+
+	return m, nil
+}
+
 // Load loads the configuration from Yaml.
 func Load(buf []byte, dest interface{}) error {
 	// load YAML into a generic map
@@ -56,6 +208,13 @@ func Load(buf []byte, dest interface{}) error {
 		return err
 	}
 
+	// replace environment and file references
+	var secrets []Secret
+	temp, err = interpolate(temp, nil, &secrets)
+	if err != nil {
+		return err
+	}
+
 	// convert the generic map into JSON
 	buf, err = json.Marshal(temp)
 	if err != nil {
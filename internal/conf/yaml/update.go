@@ -0,0 +1,301 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+func normalize(i interface{}) interface{} {
+	byts, err := json.Marshal(i)
+	if err != nil {
+		return i
+	}
+
+	var ret interface{}
+	json.Unmarshal(byts, &ret) //nolint:errcheck
+	return ret
+}
+
+func isZero(i interface{}) bool {
+	switch x := i.(type) {
+	case nil:
+		return true
+	case string:
+		return x == ""
+	case bool:
+		return !x
+	case float64:
+		return x == 0
+	case []interface{}:
+		return len(x) == 0
+	case map[string]interface{}:
+		return len(x) == 0
+	}
+	return false
+}
+
+// nodeValue decodes a node with the same rules used by Load.
+func nodeValue(n *yaml3.Node) interface{} {
+	byts, err := yaml3.Marshal(n)
+	if err != nil {
+		return nil
+	}
+
+	var temp interface{}
+	err = yaml.Unmarshal(byts, &temp)
+	if err != nil {
+		return nil
+	}
+
+	temp, err = convertKeys(temp)
+	if err != nil {
+		return nil
+	}
+
+	return normalize(temp)
+}
+
+func sortedScalars(i interface{}) ([]string, bool) {
+	sl, ok := i.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	ret := make([]string, len(sl))
+	for i, v := range sl {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, false
+		}
+		ret[i] = fmt.Sprint(v)
+	}
+
+	sort.Strings(ret)
+	return ret, true
+}
+
+func valuesEqual(a interface{}, b interface{}) bool {
+	if isZero(a) && isZero(b) {
+		return true
+	}
+
+	// lists of scalars are compared regardless of order,
+	// since most of them are sets that are sorted when serialized.
+	if sa, ok := sortedScalars(a); ok {
+		if sb, ok := sortedScalars(b); ok {
+			return reflect.DeepEqual(sa, sb)
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func newValueNode(v interface{}) (*yaml3.Node, error) {
+	var n yaml3.Node
+	err := n.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func updateMapping(n *yaml3.Node, m map[string]interface{}) error {
+	found := make(map[string]struct{})
+	var content []*yaml3.Node
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode := n.Content[i]
+		valNode := n.Content[i+1]
+
+		v, ok := m[keyNode.Value]
+		if !ok {
+			// key has been removed
+			continue
+		}
+		found[keyNode.Value] = struct{}{}
+
+		if vm, ok := v.(map[string]interface{}); ok && valNode.Kind == yaml3.MappingNode {
+			err := updateMapping(valNode, vm)
+			if err != nil {
+				return err
+			}
+		} else if !valuesEqual(nodeValue(valNode), v) {
+			newNode, err := newValueNode(v)
+			if err != nil {
+				return err
+			}
+			if newNode.Kind == valNode.Kind {
+				newNode.Style = valNode.Style
+			}
+			newNode.HeadComment = valNode.HeadComment
+			newNode.LineComment = valNode.LineComment
+			newNode.FootComment = valNode.FootComment
+			valNode = newNode
+		}
+
+		content = append(content, keyNode, valNode)
+	}
+
+	var added []string
+	for key := range m {
+		if _, ok := found[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+
+	for _, key := range added {
+		valNode, err := newValueNode(m[key])
+		if err != nil {
+			return err
+		}
+
+		content = append(content, &yaml3.Node{
+			Kind:  yaml3.ScalarNode,
+			Tag:   "!!str",
+			Value: key,
+		}, valNode)
+	}
+
+	n.Content = content
+	return nil
+}
+
+// firstLine returns the line of a node or of its head comment (1-based).
+func firstLine(line int, headComment string) int {
+	if headComment != "" {
+		return line - strings.Count(headComment, "\n") - 1
+	}
+	return line
+}
+
+// blankLinesBefore returns the number of blank lines that precede a line (1-based).
+func blankLinesBefore(line int, lines []string) int {
+	n := 0
+	for i := line - 2; i >= 0 && i < len(lines) && strings.TrimSpace(lines[i]) == ""; i-- {
+		n++
+	}
+	return n
+}
+
+// findMissingBlankLines walks a document and its encoded version in parallel,
+// and returns the lines of the encoded version that must be preceded by blank lines,
+// together with the number of missing blank lines,
+// since blank lines are not preserved by the parser.
+// Nodes added or replaced by the update have no position and are skipped.
+func findMissingBlankLines(
+	n *yaml3.Node,
+	out *yaml3.Node,
+	lines []string,
+	outLines []string,
+	ret map[int]int,
+) {
+	if n.Kind != out.Kind || len(n.Content) != len(out.Content) {
+		return
+	}
+
+	// the head comment of the original node is used for both nodes,
+	// since the parser may split it differently when reading the encoded version.
+	check := func(a *yaml3.Node, b *yaml3.Node) {
+		if a.Line == 0 {
+			return
+		}
+
+		outLine := firstLine(b.Line, a.HeadComment)
+		missing := blankLinesBefore(firstLine(a.Line, a.HeadComment), lines) - blankLinesBefore(outLine, outLines)
+		if missing > 0 {
+			ret[outLine] = missing
+		}
+	}
+
+	switch n.Kind {
+	case yaml3.DocumentNode:
+		for i := range n.Content {
+			findMissingBlankLines(n.Content[i], out.Content[i], lines, outLines, ret)
+		}
+
+	case yaml3.SequenceNode:
+		for i := range n.Content {
+			check(n.Content[i], out.Content[i])
+			findMissingBlankLines(n.Content[i], out.Content[i], lines, outLines, ret)
+		}
+
+	case yaml3.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			check(n.Content[i], out.Content[i])
+			findMissingBlankLines(n.Content[i+1], out.Content[i+1], lines, outLines, ret)
+		}
+	}
+}
+
+// restoreBlankLines inserts into the encoded document the blank lines of the original one.
+func restoreBlankLines(doc *yaml3.Node, buf []byte, out []byte) ([]byte, error) {
+	var outDoc yaml3.Node
+	err := yaml3.Unmarshal(out, &outDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(buf), "\n")
+	outLines := strings.Split(string(out), "\n")
+
+	missing := make(map[int]int)
+	findMissingBlankLines(doc, &outDoc, lines, outLines, missing)
+
+	ret := make([]string, 0, len(outLines))
+	for i, line := range outLines {
+		for j := 0; j < missing[i+1]; j++ {
+			ret = append(ret, "")
+		}
+		ret = append(ret, line)
+	}
+
+	return []byte(strings.Join(ret, "\n")), nil
+}
+
+// Update updates a YAML document in order to make it contain the values of m,
+// preserving comments, key order and formatting of values that did not change.
+// Keys of the document that are not in m are removed.
+func Update(buf []byte, m map[string]interface{}) ([]byte, error) {
+	m = normalize(m).(map[string]interface{})
+
+	var doc yaml3.Node
+	err := yaml3.Unmarshal(buf, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yaml3.DocumentNode || len(doc.Content) == 0 ||
+		doc.Content[0].Kind != yaml3.MappingNode {
+		doc = yaml3.Node{
+			Kind: yaml3.DocumentNode,
+			Content: []*yaml3.Node{{
+				Kind: yaml3.MappingNode,
+				Tag:  "!!map",
+			}},
+		}
+	}
+
+	err = updateMapping(doc.Content[0], m)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	enc := yaml3.NewEncoder(&out)
+	enc.SetIndent(2)
+	err = enc.Encode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	enc.Close()
+
+	return restoreBlankLines(&doc, buf, out.Bytes())
+}
@@ -4,6 +4,7 @@ package confwatcher
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -14,10 +15,20 @@ const (
 	additionalWait = 10 * time.Millisecond
 )
 
+type watchedFile struct {
+	path     string
+	previous string
+}
+
+func isConfFile(fpath string) bool {
+	return strings.HasSuffix(fpath, ".yml") || strings.HasSuffix(fpath, ".yaml")
+}
+
 // ConfWatcher is a configuration file watcher.
 type ConfWatcher struct {
-	inner       *fsnotify.Watcher
-	watchedPath string
+	inner        *fsnotify.Watcher
+	watchedFiles []*watchedFile
+	watchedDirs  []string
 
 	// out
 	signal chan struct{}
@@ -25,7 +36,8 @@ type ConfWatcher struct {
 }
 
 // New allocates a ConfWatcher.
-func New(confPath string) (*ConfWatcher, error) {
+// additionalPaths are included files and directories, that are watched too.
+func New(confPath string, additionalPaths ...string) (*ConfWatcher, error) {
 	if _, err := os.Stat(confPath); err != nil {
 		if confPath == "mediamtx.yml" {
 			confPath = "rtsp-simple-server.yml"
@@ -42,21 +54,42 @@ func New(confPath string) (*ConfWatcher, error) {
 		return nil, err
 	}
 
-	// use absolute paths to support Darwin
-	absolutePath, _ := filepath.Abs(confPath)
-	parentPath := filepath.Dir(absolutePath)
-
-	err = inner.Add(parentPath)
-	if err != nil {
-		inner.Close()
-		return nil, err
+	w := &ConfWatcher{
+		inner:  inner,
+		signal: make(chan struct{}),
+		done:   make(chan struct{}),
 	}
 
-	w := &ConfWatcher{
-		inner:       inner,
-		watchedPath: absolutePath,
-		signal:      make(chan struct{}),
-		done:        make(chan struct{}),
+	watchedParents := make(map[string]struct{})
+
+	for _, fpath := range append([]string{confPath}, additionalPaths...) {
+		// use absolute paths to support Darwin
+		absolutePath, _ := filepath.Abs(fpath)
+
+		var toAdd string
+
+		if fi, err := os.Stat(absolutePath); err == nil && fi.IsDir() {
+			w.watchedDirs = append(w.watchedDirs, absolutePath)
+			toAdd = absolutePath
+		} else {
+			previous, _ := filepath.EvalSymlinks(absolutePath)
+			w.watchedFiles = append(w.watchedFiles, &watchedFile{
+				path:     absolutePath,
+				previous: previous,
+			})
+			toAdd = filepath.Dir(absolutePath)
+		}
+
+		if _, ok := watchedParents[toAdd]; ok {
+			continue
+		}
+		watchedParents[toAdd] = struct{}{}
+
+		err = inner.Add(toAdd)
+		if err != nil {
+			inner.Close()
+			return nil, err
+		}
 	}
 
 	go w.run()
@@ -78,7 +111,6 @@ func (w *ConfWatcher) run() {
 	defer close(w.done)
 
 	var lastCalled time.Time
-	previousWatchedPath, _ := filepath.EvalSymlinks(w.watchedPath)
 
 outer:
 	for {
@@ -88,19 +120,9 @@ outer:
 				continue
 			}
 
-			currentWatchedPath, _ := filepath.EvalSymlinks(w.watchedPath)
-			eventPath, _ := filepath.Abs(event.Name)
-
-			if currentWatchedPath == "" {
-				// watched file was removed; wait for write event to trigger reload
-				previousWatchedPath = ""
-			} else if currentWatchedPath != previousWatchedPath ||
-				(eventPath == currentWatchedPath &&
-					((event.Op&fsnotify.Write) == fsnotify.Write ||
-						(event.Op&fsnotify.Create) == fsnotify.Create)) {
+			if w.changed(event) {
 				// wait some additional time to allow the writer to complete its job
 				time.Sleep(additionalWait)
-				previousWatchedPath = currentWatchedPath
 
 				lastCalled = time.Now()
 				w.signal <- struct{}{}
@@ -114,6 +136,37 @@ outer:
 	close(w.signal)
 }
 
+func (w *ConfWatcher) changed(event fsnotify.Event) bool {
+	eventPath, _ := filepath.Abs(event.Name)
+	ret := false
+
+	for _, f := range w.watchedFiles {
+		current, _ := filepath.EvalSymlinks(f.path)
+
+		if current == "" {
+			// watched file was removed; wait for write event to trigger reload
+			f.previous = ""
+		} else if current != f.previous ||
+			(eventPath == current &&
+				((event.Op&fsnotify.Write) == fsnotify.Write ||
+					(event.Op&fsnotify.Create) == fsnotify.Create)) {
+			f.previous = current
+			ret = true
+		}
+	}
+
+	// any change to a configuration file inside a watched directory
+	// triggers a reload, including additions and removals.
+	for _, dir := range w.watchedDirs {
+		if filepath.Dir(eventPath) == dir && isConfFile(eventPath) &&
+			(event.Op&fsnotify.Chmod) != fsnotify.Chmod {
+			ret = true
+		}
+	}
+
+	return ret
+}
+
 // Watch returns a channel that is called after the configuration file has changed.
 func (w *ConfWatcher) Watch() chan struct{} {
 	return w.signal
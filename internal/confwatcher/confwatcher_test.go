@@ -2,6 +2,7 @@ package confwatcher
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -153,3 +154,27 @@ func TestSymlinkDeleteCreate(t *testing.T) {
 		return
 	}
 }
+
+func TestDirectoryAdd(t *testing.T) {
+	fpath, err := writeTempFile([]byte("{}"))
+	require.NoError(t, err)
+	defer os.Remove(fpath)
+
+	dir, err := os.MkdirTemp(os.TempDir(), "confwatcher-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := New(fpath, dir)
+	require.NoError(t, err)
+	defer w.Close()
+
+	err = os.WriteFile(filepath.Join(dir, "cam1.yml"), []byte("cam1:\n"), 0o644)
+	require.NoError(t, err)
+
+	select {
+	case <-w.Watch():
+	case <-time.After(500 * time.Millisecond):
+		t.Errorf("timed out")
+		return
+	}
+}
@@ -3,10 +3,14 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,25 +20,36 @@ import (
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+const (
+	// maximum size of request bodies that contain configurations
+	apiMaxBodySize = 1 * 1024 * 1024
+)
+
 func interfaceIsEmpty(i interface{}) bool {
 	return reflect.ValueOf(i).Kind() != reflect.Ptr || reflect.ValueOf(i).IsNil()
 }
 
-func fillStruct(dest interface{}, source interface{}) {
+// fillStruct copies the non-nil fields of source into dest
+// and returns the JSON keys of the copied fields.
+func fillStruct(dest interface{}, source interface{}) []string {
 	rvsource := reflect.ValueOf(source).Elem()
 	rvdest := reflect.ValueOf(dest)
 	nf := rvsource.NumField()
+	var keys []string
 	for i := 0; i < nf; i++ {
 		fnew := rvsource.Field(i)
 		if !fnew.IsNil() {
-			f := rvdest.Elem().FieldByName(rvsource.Type().Field(i).Name)
-			if f.Kind() == reflect.Ptr {
+			sf := rvsource.Type().Field(i)
+			f := rvdest.Elem().FieldByName(sf.Name)
+			if fnew.Type() == f.Type() {
 				f.Set(fnew)
 			} else {
 				f.Set(fnew.Elem())
 			}
+			keys = append(keys, strings.Split(sf.Tag.Get("json"), ",")[0])
 		}
 	}
+	return keys
 }
 
 func generateStructWithOptionalFields(model interface{}) interface{} {
@@ -46,7 +61,7 @@ func generateStructWithOptionalFields(model interface{}) interface{} {
 		f := rt.Field(i)
 		j := f.Tag.Get("json")
 
-		if j != "-" && j != "paths" {
+		if j != "-" && j != "paths" && f.IsExported() {
 			fields = append(fields, reflect.StructField{
 				Name: f.Name,
 				Type: reflect.PtrTo(f.Type),
@@ -60,7 +75,7 @@ func generateStructWithOptionalFields(model interface{}) interface{} {
 
 func loadConfData(ctx *gin.Context) (interface{}, error) {
 	in := generateStructWithOptionalFields(conf.Conf{})
-	err := json.NewDecoder(ctx.Request.Body).Decode(in)
+	err := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, apiMaxBodySize)).Decode(in)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +85,7 @@ func loadConfData(ctx *gin.Context) (interface{}, error) {
 
 func loadConfPathData(ctx *gin.Context) (interface{}, error) {
 	in := generateStructWithOptionalFields(conf.PathConf{})
-	err := json.NewDecoder(ctx.Request.Body).Decode(in)
+	err := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, apiMaxBodySize)).Decode(in)
 	if err != nil {
 		return nil, err
 	}
@@ -80,30 +95,36 @@ func loadConfPathData(ctx *gin.Context) (interface{}, error) {
 
 type apiPathManager interface {
 	apiPathsList() pathAPIPathsListRes
+	apiPathsGet(string) pathAPIPathsGetRes
 }
 
 type apiHLSServer interface {
 	apiMuxersList() hlsServerAPIMuxersListRes
+	apiMuxersGet(string) hlsServerAPIMuxersGetRes
 }
 
 type apiRTSPServer interface {
 	apiConnsList() rtspServerAPIConnsListRes
+	apiConnsGet(string) rtspServerAPIConnsGetRes
 	apiSessionsList() rtspServerAPISessionsListRes
+	apiSessionsGet(string) rtspServerAPISessionsGetRes
 	apiSessionsKick(string) rtspServerAPISessionsKickRes
 }
 
 type apiRTMPServer interface {
 	apiConnsList() rtmpServerAPIConnsListRes
+	apiConnsGet(id string) rtmpServerAPIConnsGetRes
 	apiConnsKick(id string) rtmpServerAPIConnsKickRes
 }
 
 type apiParent interface {
 	logger.Writer
-	apiConfigSet(conf *conf.Conf)
+	apiConfigSet(ctx context.Context, conf *conf.Conf) error
 }
 
 type apiWebRTCServer interface {
 	apiConnsList() webRTCServerAPIConnsListRes
+	apiConnsGet(id string) webRTCServerAPIConnsGetRes
 	apiConnsKick(id string) webRTCServerAPIConnsKickRes
 }
 
@@ -116,8 +137,12 @@ type api struct {
 	rtmpsServer  apiRTMPServer
 	hlsServer    apiHLSServer
 	webRTCServer apiWebRTCServer
+	eventBus     *eventBus
+	usageMeter   *usageMeter
 	parent       apiParent
 
+	ctx        context.Context
+	ctxCancel  func()
 	ln         net.Listener
 	httpServer *http.Server
 	mutex      sync.Mutex
@@ -134,6 +159,8 @@ func newAPI(
 	rtmpsServer apiRTMPServer,
 	hlsServer apiHLSServer,
 	webRTCServer apiWebRTCServer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	parent apiParent,
 ) (*api, error) {
 	ln, err := net.Listen(restrictNetwork("tcp", address))
@@ -150,10 +177,14 @@ func newAPI(
 		rtmpsServer:  rtmpsServer,
 		hlsServer:    hlsServer,
 		webRTCServer: webRTCServer,
+		eventBus:     eventBus,
+		usageMeter:   usageMeter,
 		parent:       parent,
 		ln:           ln,
 	}
 
+	a.ctx, a.ctxCancel = context.WithCancel(context.Background())
+
 	router := gin.New()
 	router.SetTrustedProxies(nil)
 
@@ -161,11 +192,19 @@ func newAPI(
 	router.NoRoute(mwLog, httpServerHeaderMiddleware)
 	group := router.Group("/", mwLog, httpServerHeaderMiddleware)
 
+	// event streams are long-lived and must not be buffered by the logger.
+	streamGroup := router.Group("/", httpServerHeaderMiddleware)
+	streamGroup.GET("/v1/events", a.onEvents)
+	streamGroup.GET("/v1/events/ws", a.onEventsWS)
+
 	group.GET("/v1/config/get", a.onConfigGet)
 	group.POST("/v1/config/set", a.onConfigSet)
 	group.POST("/v1/config/paths/add/*name", a.onConfigPathsAdd)
 	group.POST("/v1/config/paths/edit/*name", a.onConfigPathsEdit)
 	group.POST("/v1/config/paths/remove/*name", a.onConfigPathsDelete)
+	group.POST("/v1/config/validate", a.onConfigValidate)
+	group.GET("/v1/config/schema", a.onConfigSchema)
+	group.GET("/v1/openapi.json", a.onOpenAPI)
 
 	if !interfaceIsEmpty(a.hlsServer) {
 		group.GET("/v1/hlsmuxers/list", a.onHLSMuxersList)
@@ -200,6 +239,57 @@ func newAPI(
 		group.POST("/v1/webrtcconns/kick/:id", a.onWebRTCConnsKick)
 	}
 
+	group.GET("/v1/usage", a.onUsage)
+
+	group.GET("/v2/config/get", a.onConfigGet)
+	group.POST("/v2/config/set", a.onV2ConfigSet)
+	group.POST("/v2/config/paths/add/*name", a.onV2ConfigPathsAdd)
+	group.POST("/v2/config/paths/edit/*name", a.onV2ConfigPathsEdit)
+	group.POST("/v2/config/paths/remove/*name", a.onV2ConfigPathsDelete)
+	group.POST("/v2/config/validate", a.onConfigValidate)
+
+	group.GET("/v2/paths/list", a.onV2PathsList)
+	group.GET("/v2/paths/get/*name", a.onV2PathsGet)
+
+	if !interfaceIsEmpty(a.hlsServer) {
+		group.GET("/v2/hlsmuxers/list", a.onV2HLSMuxersList)
+		group.GET("/v2/hlsmuxers/get/*name", a.onV2HLSMuxersGet)
+	}
+
+	if !interfaceIsEmpty(a.rtspServer) {
+		group.GET("/v2/rtspconns/list", a.onV2RTSPConnsList(a.rtspServer))
+		group.GET("/v2/rtspconns/get/:id", a.onV2RTSPConnsGet(a.rtspServer))
+		group.GET("/v2/rtspsessions/list", a.onV2RTSPSessionsList(a.rtspServer))
+		group.GET("/v2/rtspsessions/get/:id", a.onV2RTSPSessionsGet(a.rtspServer))
+		group.POST("/v2/rtspsessions/kick/:id", a.onV2RTSPSessionsKick(a.rtspServer))
+	}
+
+	if !interfaceIsEmpty(a.rtspsServer) {
+		group.GET("/v2/rtspsconns/list", a.onV2RTSPConnsList(a.rtspsServer))
+		group.GET("/v2/rtspsconns/get/:id", a.onV2RTSPConnsGet(a.rtspsServer))
+		group.GET("/v2/rtspssessions/list", a.onV2RTSPSessionsList(a.rtspsServer))
+		group.GET("/v2/rtspssessions/get/:id", a.onV2RTSPSessionsGet(a.rtspsServer))
+		group.POST("/v2/rtspssessions/kick/:id", a.onV2RTSPSessionsKick(a.rtspsServer))
+	}
+
+	if !interfaceIsEmpty(a.rtmpServer) {
+		group.GET("/v2/rtmpconns/list", a.onV2RTMPConnsList(a.rtmpServer))
+		group.GET("/v2/rtmpconns/get/:id", a.onV2RTMPConnsGet(a.rtmpServer))
+		group.POST("/v2/rtmpconns/kick/:id", a.onV2RTMPConnsKick(a.rtmpServer))
+	}
+
+	if !interfaceIsEmpty(a.rtmpsServer) {
+		group.GET("/v2/rtmpsconns/list", a.onV2RTMPConnsList(a.rtmpsServer))
+		group.GET("/v2/rtmpsconns/get/:id", a.onV2RTMPConnsGet(a.rtmpsServer))
+		group.POST("/v2/rtmpsconns/kick/:id", a.onV2RTMPConnsKick(a.rtmpsServer))
+	}
+
+	if !interfaceIsEmpty(a.webRTCServer) {
+		group.GET("/v2/webrtcconns/list", a.onV2WebRTCConnsList)
+		group.GET("/v2/webrtcconns/get/:id", a.onV2WebRTCConnsGet)
+		group.POST("/v2/webrtcconns/kick/:id", a.onV2WebRTCConnsKick)
+	}
+
 	a.httpServer = &http.Server{
 		Handler:           router,
 		ReadHeaderTimeout: time.Duration(readTimeout),
@@ -215,12 +305,13 @@ func newAPI(
 
 func (a *api) close() {
 	a.Log(logger.Info, "listener is closing")
+	a.ctxCancel()
 	a.httpServer.Shutdown(context.Background())
 	a.ln.Close() // in case Shutdown() is called before Serve()
 }
 
 func (a *api) Log(level logger.Level, format string, args ...interface{}) {
-	a.parent.Log(level, "[API] "+format, args...)
+	a.parent.Log(level, "[API] "+format, logger.WithField("component", "api", args)...)
 }
 
 func (a *api) onConfigGet(ctx *gin.Context) {
@@ -228,16 +319,93 @@ func (a *api) onConfigGet(ctx *gin.Context) {
 	c := a.conf
 	a.mutex.Unlock()
 
-	ctx.JSON(http.StatusOK, c)
+	// the raw view shows paths without inherited fields
+	var v interface{} = c
+	if ctx.Query("view") == "raw" {
+		v = c.RawView()
+	}
+
+	// secrets are never returned
+	redacted, err := c.Redact(v)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, redacted)
 }
 
 func (a *api) onConfigSet(ctx *gin.Context) {
-	in, err := loadConfData(ctx)
+	if _, err := a.configSet(ctx); err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onConfigPathsAdd(ctx *gin.Context) {
+	if _, err := a.configPathsAdd(ctx); err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onConfigPathsEdit(ctx *gin.Context) {
+	if _, err := a.configPathsEdit(ctx); err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onConfigPathsDelete(ctx *gin.Context) {
+	if _, err := a.configPathsDelete(ctx); err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// onConfigValidate checks a configuration file, provided in the request body,
+// and returns the differences with the running configuration.
+func (a *api) onConfigValidate(ctx *gin.Context) {
+	byts, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, apiMaxBodySize))
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			ctx.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
+	newConf, err := conf.LoadBuffer(byts)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &confDiff{Error: err.Error()})
+		return
+	}
+
+	a.mutex.Lock()
+	c := a.conf
+	a.mutex.Unlock()
+
+	ctx.JSON(http.StatusOK, newConfDiff(c, newConf))
+}
+
+// configSet applies a configuration patch and returns the HTTP status
+// that describes a possible error.
+func (a *api) configSet(ctx *gin.Context) (int, error) {
+	in, err := loadConfData(ctx)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
@@ -247,31 +415,36 @@ func (a *api) onConfigSet(ctx *gin.Context) {
 
 	err = newConf.Check()
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, err
 	}
 
-	a.conf = newConf
+	err = a.parent.apiConfigSet(a.ctx, newConf)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
-	// since reloading the configuration can cause the shutdown of the API,
-	// call it in a goroutine
-	go a.parent.apiConfigSet(newConf)
+	a.conf = newConf
 
-	ctx.Status(http.StatusOK)
+	return http.StatusOK, nil
 }
 
-func (a *api) onConfigPathsAdd(ctx *gin.Context) {
+func pathNameFromParam(ctx *gin.Context) (string, error) {
 	name := ctx.Param("name")
 	if len(name) < 2 || name[0] != '/' {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return "", fmt.Errorf("invalid path name")
+	}
+	return name[1:], nil
+}
+
+func (a *api) configPathsAdd(ctx *gin.Context) (int, error) {
+	name, err := pathNameFromParam(ctx)
+	if err != nil {
+		return http.StatusBadRequest, err
 	}
-	name = name[1:]
 
 	in, err := loadConfPathData(ctx)
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, err
 	}
 
 	a.mutex.Lock()
@@ -280,42 +453,40 @@ func (a *api) onConfigPathsAdd(ctx *gin.Context) {
 	newConf := a.conf.Clone()
 
 	if _, ok := newConf.Paths[name]; ok {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, fmt.Errorf("path '%s' already exists", name)
 	}
 
 	newConfPath := &conf.PathConf{}
-	fillStruct(newConfPath, in)
+	newConfPath.UnmarshalJSON(nil) // fill defaults
+	keys := fillStruct(newConfPath, in)
 
 	newConf.Paths[name] = newConfPath
+	newConf.SetPathFields(name, keys)
 
 	err = newConf.Check()
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, err
 	}
 
-	a.conf = newConf
+	err = a.parent.apiConfigSet(a.ctx, newConf)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
-	// since reloading the configuration can cause the shutdown of the API,
-	// call it in a goroutine
-	go a.parent.apiConfigSet(newConf)
+	a.conf = newConf
 
-	ctx.Status(http.StatusOK)
+	return http.StatusOK, nil
 }
 
-func (a *api) onConfigPathsEdit(ctx *gin.Context) {
-	name := ctx.Param("name")
-	if len(name) < 2 || name[0] != '/' {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+func (a *api) configPathsEdit(ctx *gin.Context) (int, error) {
+	name, err := pathNameFromParam(ctx)
+	if err != nil {
+		return http.StatusBadRequest, err
 	}
-	name = name[1:]
 
 	in, err := loadConfPathData(ctx)
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, err
 	}
 
 	a.mutex.Lock()
@@ -325,34 +496,32 @@ func (a *api) onConfigPathsEdit(ctx *gin.Context) {
 
 	newConfPath, ok := newConf.Paths[name]
 	if !ok {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusNotFound, fmt.Errorf("path '%s' not found", name)
 	}
 
-	fillStruct(newConfPath, in)
+	keys := fillStruct(newConfPath, in)
+	newConf.SetPathFields(name, keys)
 
 	err = newConf.Check()
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, err
 	}
 
-	a.conf = newConf
+	err = a.parent.apiConfigSet(a.ctx, newConf)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
-	// since reloading the configuration can cause the shutdown of the API,
-	// call it in a goroutine
-	go a.parent.apiConfigSet(newConf)
+	a.conf = newConf
 
-	ctx.Status(http.StatusOK)
+	return http.StatusOK, nil
 }
 
-func (a *api) onConfigPathsDelete(ctx *gin.Context) {
-	name := ctx.Param("name")
-	if len(name) < 2 || name[0] != '/' {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+func (a *api) configPathsDelete(ctx *gin.Context) (int, error) {
+	name, err := pathNameFromParam(ctx)
+	if err != nil {
+		return http.StatusBadRequest, err
 	}
-	name = name[1:]
 
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
@@ -360,25 +529,41 @@ func (a *api) onConfigPathsDelete(ctx *gin.Context) {
 	newConf := a.conf.Clone()
 
 	if _, ok := newConf.Paths[name]; !ok {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusNotFound, fmt.Errorf("path '%s' not found", name)
 	}
 
 	delete(newConf.Paths, name)
 
-	err := newConf.Check()
+	err = newConf.Check()
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, err
+	}
+
+	err = a.parent.apiConfigSet(a.ctx, newConf)
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
 
 	a.conf = newConf
 
-	// since reloading the configuration can cause the shutdown of the API,
-	// call it in a goroutine
-	go a.parent.apiConfigSet(newConf)
+	return http.StatusOK, nil
+}
 
-	ctx.Status(http.StatusOK)
+// redactPathConf replaces values loaded from environment variables and files
+// in the configuration of a path.
+func (a *api) redactPathConf(item pathAPIPathsListItem) (pathAPIPathsListItem, error) {
+	a.mutex.Lock()
+	c := a.conf
+	a.mutex.Unlock()
+
+	if pconf, ok := item.Conf.(*conf.PathConf); ok {
+		var err error
+		item.Conf, err = c.RedactPath(item.ConfName, pconf)
+		if err != nil {
+			return pathAPIPathsListItem{}, err
+		}
+	}
+	return item, nil
 }
 
 func (a *api) onPathsList(ctx *gin.Context) {
@@ -388,9 +573,22 @@ func (a *api) onPathsList(ctx *gin.Context) {
 		return
 	}
 
+	for name, item := range res.data.Items {
+		var err error
+		res.data.Items[name], err = a.redactPathConf(item)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	ctx.JSON(http.StatusOK, res.data)
 }
 
+func (a *api) onUsage(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.usageMeter.apiUsageList())
+}
+
 func (a *api) onRTSPConnsList(ctx *gin.Context) {
 	res := a.rtspServer.apiConnsList()
 	if res.err != nil {
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/websocket"
+)
+
+// onEvents streams server events with the Server-Sent Events protocol.
+func (a *api) onEvents(ctx *gin.Context) {
+	flusher, ok := ctx.Writer.(http.Flusher)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ch := a.eventBus.subscribe()
+	defer a.eventBus.unsubscribe(ch)
+
+	ctx.Writer.Header().Set("Content-Type", "text/event-stream")
+	ctx.Writer.Header().Set("Cache-Control", "no-cache")
+	ctx.Writer.Header().Set("Connection", "keep-alive")
+	ctx.Writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case evt := <-ch:
+			byts, err := json.Marshal(evt)
+			if err != nil {
+				return
+			}
+
+			_, err = ctx.Writer.Write([]byte("event: " + string(evt.Type) + "\ndata: " + string(byts) + "\n\n"))
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-ctx.Request.Context().Done():
+			return
+
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// onEventsWS streams server events through a WebSocket.
+func (a *api) onEventsWS(ctx *gin.Context) {
+	wsconn, err := websocket.NewServerConn(ctx.Writer, ctx.Request)
+	if err != nil {
+		return
+	}
+	defer wsconn.Close()
+
+	ch := a.eventBus.subscribe()
+	defer a.eventBus.unsubscribe(ch)
+
+	// incoming messages are ignored; reading is needed to detect closure.
+	readErr := make(chan struct{})
+	go func() {
+		defer close(readErr)
+		for {
+			var in interface{}
+			err := wsconn.ReadJSON(&in)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case evt := <-ch:
+			err := wsconn.WriteJSON(evt)
+			if err != nil {
+				a.Log(logger.Debug, "events websocket closed: %v", err)
+				return
+			}
+
+		case <-readErr:
+			return
+
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
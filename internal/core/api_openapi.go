@@ -0,0 +1,287 @@
+package core
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/jsonschema"
+)
+
+var reAPIParam = regexp.MustCompile(`[:*]([a-z]+)`)
+
+// apiOperation describes an API endpoint.
+type apiOperation struct {
+	method  string
+	path    string
+	summary string
+
+	// request body. nil means no body.
+	request interface{}
+
+	// response body. nil means no body.
+	response interface{}
+
+	// response is a paginated list of items of this type.
+	list interface{}
+
+	// response is an event stream.
+	events bool
+}
+
+var apiOperations = []apiOperation{
+	{method: http.MethodGet, path: "/v1/config/get", summary: "returns the configuration", response: conf.Conf{}},
+	{method: http.MethodPost, path: "/v1/config/set", summary: "changes the configuration", request: conf.Conf{}},
+	{method: http.MethodPost, path: "/v1/config/paths/add/*name", summary: "adds the configuration of a path", request: conf.PathConf{}},
+	{method: http.MethodPost, path: "/v1/config/paths/edit/*name", summary: "changes the configuration of a path", request: conf.PathConf{}},
+	{method: http.MethodPost, path: "/v1/config/paths/remove/*name", summary: "removes the configuration of a path"},
+	{method: http.MethodPost, path: "/v1/config/validate", summary: "validates a configuration", request: conf.Conf{}, response: confDiff{}},
+	{method: http.MethodGet, path: "/v1/config/schema", summary: "returns the JSON Schema of the configuration"},
+	{method: http.MethodGet, path: "/v1/openapi.json", summary: "returns the OpenAPI description of the API"},
+	{method: http.MethodGet, path: "/v1/events", summary: "returns a stream of server-sent events", events: true},
+	{method: http.MethodGet, path: "/v1/events/ws", summary: "returns a stream of events through a WebSocket", events: true},
+	{method: http.MethodGet, path: "/v1/hlsmuxers/list", summary: "returns all HLS muxers", response: hlsServerAPIMuxersListData{}},
+	{method: http.MethodGet, path: "/v1/paths/list", summary: "returns all active paths", response: pathAPIPathsListData{}},
+	{method: http.MethodGet, path: "/v1/rtspconns/list", summary: "returns all RTSP connections", response: rtspServerAPIConnsListData{}},
+	{method: http.MethodGet, path: "/v1/rtspsessions/list", summary: "returns all RTSP sessions", response: rtspServerAPISessionsListData{}},
+	{method: http.MethodPost, path: "/v1/rtspsessions/kick/:id", summary: "kicks out a RTSP session"},
+	{method: http.MethodGet, path: "/v1/rtspsconns/list", summary: "returns all RTSPS connections", response: rtspServerAPIConnsListData{}},
+	{method: http.MethodGet, path: "/v1/rtspssessions/list", summary: "returns all RTSPS sessions", response: rtspServerAPISessionsListData{}},
+	{method: http.MethodPost, path: "/v1/rtspssessions/kick/:id", summary: "kicks out a RTSPS session"},
+	{method: http.MethodGet, path: "/v1/rtmpconns/list", summary: "returns all RTMP connections", response: rtmpServerAPIConnsListData{}},
+	{method: http.MethodPost, path: "/v1/rtmpconns/kick/:id", summary: "kicks out a RTMP connection"},
+	{method: http.MethodGet, path: "/v1/rtmpsconns/list", summary: "returns all RTMPS connections", response: rtmpServerAPIConnsListData{}},
+	{method: http.MethodPost, path: "/v1/rtmpsconns/kick/:id", summary: "kicks out a RTMPS connection"},
+	{method: http.MethodGet, path: "/v1/webrtcconns/list", summary: "returns all WebRTC connections", response: webRTCServerAPIConnsListData{}},
+	{method: http.MethodPost, path: "/v1/webrtcconns/kick/:id", summary: "kicks out a WebRTC connection"},
+	{method: http.MethodGet, path: "/v1/usage", summary: "returns the amount of bytes sent to every user, and their quotas", response: usageAPIData{}},
+
+	{method: http.MethodGet, path: "/v2/config/get", summary: "returns the configuration", response: conf.Conf{}},
+	{method: http.MethodPost, path: "/v2/config/set", summary: "changes the configuration", request: conf.Conf{}},
+	{method: http.MethodPost, path: "/v2/config/paths/add/*name", summary: "adds the configuration of a path", request: conf.PathConf{}},
+	{method: http.MethodPost, path: "/v2/config/paths/edit/*name", summary: "changes the configuration of a path", request: conf.PathConf{}},
+	{method: http.MethodPost, path: "/v2/config/paths/remove/*name", summary: "removes the configuration of a path"},
+	{method: http.MethodPost, path: "/v2/config/validate", summary: "validates a configuration", request: conf.Conf{}, response: confDiff{}},
+	{method: http.MethodGet, path: "/v2/paths/list", summary: "returns all active paths", list: apiV2Path{}},
+	{method: http.MethodGet, path: "/v2/paths/get/*name", summary: "returns an active path", response: apiV2Path{}},
+	{method: http.MethodGet, path: "/v2/hlsmuxers/list", summary: "returns all HLS muxers", list: apiV2HLSMuxer{}},
+	{method: http.MethodGet, path: "/v2/hlsmuxers/get/*name", summary: "returns a HLS muxer", response: apiV2HLSMuxer{}},
+	{method: http.MethodGet, path: "/v2/rtspconns/list", summary: "returns all RTSP connections", list: apiV2RTSPConn{}},
+	{method: http.MethodGet, path: "/v2/rtspconns/get/:id", summary: "returns a RTSP connection", response: apiV2RTSPConn{}},
+	{method: http.MethodGet, path: "/v2/rtspsessions/list", summary: "returns all RTSP sessions", list: apiV2RTSPSession{}},
+	{method: http.MethodGet, path: "/v2/rtspsessions/get/:id", summary: "returns a RTSP session", response: apiV2RTSPSession{}},
+	{method: http.MethodPost, path: "/v2/rtspsessions/kick/:id", summary: "kicks out a RTSP session"},
+	{method: http.MethodGet, path: "/v2/rtspsconns/list", summary: "returns all RTSPS connections", list: apiV2RTSPConn{}},
+	{method: http.MethodGet, path: "/v2/rtspsconns/get/:id", summary: "returns a RTSPS connection", response: apiV2RTSPConn{}},
+	{method: http.MethodGet, path: "/v2/rtspssessions/list", summary: "returns all RTSPS sessions", list: apiV2RTSPSession{}},
+	{method: http.MethodGet, path: "/v2/rtspssessions/get/:id", summary: "returns a RTSPS session", response: apiV2RTSPSession{}},
+	{method: http.MethodPost, path: "/v2/rtspssessions/kick/:id", summary: "kicks out a RTSPS session"},
+	{method: http.MethodGet, path: "/v2/rtmpconns/list", summary: "returns all RTMP connections", list: apiV2RTMPConn{}},
+	{method: http.MethodGet, path: "/v2/rtmpconns/get/:id", summary: "returns a RTMP connection", response: apiV2RTMPConn{}},
+	{method: http.MethodPost, path: "/v2/rtmpconns/kick/:id", summary: "kicks out a RTMP connection"},
+	{method: http.MethodGet, path: "/v2/rtmpsconns/list", summary: "returns all RTMPS connections", list: apiV2RTMPConn{}},
+	{method: http.MethodGet, path: "/v2/rtmpsconns/get/:id", summary: "returns a RTMPS connection", response: apiV2RTMPConn{}},
+	{method: http.MethodPost, path: "/v2/rtmpsconns/kick/:id", summary: "kicks out a RTMPS connection"},
+	{method: http.MethodGet, path: "/v2/webrtcconns/list", summary: "returns all WebRTC connections", list: apiV2WebRTCConn{}},
+	{method: http.MethodGet, path: "/v2/webrtcconns/get/:id", summary: "returns a WebRTC connection", response: apiV2WebRTCConn{}},
+	{method: http.MethodPost, path: "/v2/webrtcconns/kick/:id", summary: "kicks out a WebRTC connection"},
+}
+
+// names of the schemas of the API types.
+var apiSchemaNames = map[reflect.Type]string{
+	reflect.TypeOf(confDiff{}):                      "ConfDiff",
+	reflect.TypeOf(confDiffPath{}):                  "ConfDiffPath",
+	reflect.TypeOf(event{}):                         "Event",
+	reflect.TypeOf(apiV2Error{}):                    "Error",
+	reflect.TypeOf(pathAPIPathsListData{}):          "PathsList",
+	reflect.TypeOf(pathAPIPathsListItem{}):          "PathsListItem",
+	reflect.TypeOf(streamFormatStats{}):             "TrackStats",
+	reflect.TypeOf(hlsServerAPIMuxersListData{}):    "HLSMuxersList",
+	reflect.TypeOf(hlsServerAPIMuxersListItem{}):    "HLSMuxersListItem",
+	reflect.TypeOf(rtspServerAPIConnsListData{}):    "RTSPConnsList",
+	reflect.TypeOf(rtspServerAPIConnsListItem{}):    "RTSPConnsListItem",
+	reflect.TypeOf(rtspServerAPISessionsListData{}): "RTSPSessionsList",
+	reflect.TypeOf(rtspServerAPISessionsListItem{}): "RTSPSessionsListItem",
+	reflect.TypeOf(rtmpServerAPIConnsListData{}):    "RTMPConnsList",
+	reflect.TypeOf(rtmpServerAPIConnsListItem{}):    "RTMPConnsListItem",
+	reflect.TypeOf(webRTCServerAPIConnsListData{}):  "WebRTCConnsList",
+	reflect.TypeOf(webRTCServerAPIConnsListItem{}):  "WebRTCConnsListItem",
+	reflect.TypeOf(apiV2Path{}):                     "Path",
+	reflect.TypeOf(apiV2HLSMuxer{}):                 "HLSMuxer",
+	reflect.TypeOf(apiV2RTSPConn{}):                 "RTSPConn",
+	reflect.TypeOf(apiV2RTSPSession{}):              "RTSPSession",
+	reflect.TypeOf(apiV2RTMPConn{}):                 "RTMPConn",
+	reflect.TypeOf(apiV2WebRTCConn{}):               "WebRTCConn",
+}
+
+func openAPIContent(mimeType string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		mimeType: map[string]interface{}{"schema": schema},
+	}
+}
+
+// openAPIListSchema returns the schema of a paginated list.
+func openAPIListSchema(g *jsonschema.Generator, item interface{}) map[string]interface{} {
+	itemRef := g.Schema(reflect.TypeOf(item))
+	name := strings.TrimPrefix(itemRef["$ref"].(string), g.RefPrefix) + "List"
+
+	if _, ok := g.Defs[name]; !ok {
+		s := g.Object(reflect.TypeOf(apiV2List{}))
+		s["properties"].(map[string]interface{})["items"] = map[string]interface{}{
+			"type":  "array",
+			"items": itemRef,
+		}
+		g.Defs[name] = s
+	}
+
+	return map[string]interface{}{"$ref": g.RefPrefix + name}
+}
+
+func openAPIOperation(g *jsonschema.Generator, op apiOperation) map[string]interface{} {
+	ret := map[string]interface{}{
+		"summary": op.summary,
+		"tags":    []string{op.path[1:3]},
+	}
+
+	var params []interface{}
+	for _, m := range reAPIParam.FindAllStringSubmatch(op.path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	if op.list != nil {
+		for _, name := range []string{"page", "itemsPerPage"} {
+			params = append(params, map[string]interface{}{
+				"name":   name,
+				"in":     "query",
+				"schema": map[string]interface{}{"type": "integer", "minimum": 0},
+			})
+		}
+	}
+
+	if strings.HasSuffix(op.path, "/config/get") {
+		params = append(params, map[string]interface{}{
+			"name":        "view",
+			"in":          "query",
+			"description": "when 'raw', paths contain only the fields that have been explicitly set",
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"raw"}},
+		})
+	}
+
+	if params != nil {
+		ret["parameters"] = params
+	}
+
+	if op.request != nil {
+		schema := g.Schema(reflect.TypeOf(op.request))
+		content := openAPIContent("application/json", schema)
+
+		if strings.HasSuffix(op.path, "/config/validate") {
+			content["application/x-yaml"] = map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+			}
+		}
+
+		ret["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  content,
+		}
+	}
+
+	res := map[string]interface{}{"description": "OK"}
+
+	switch {
+	case op.events && !strings.HasSuffix(op.path, "/ws"):
+		res["content"] = openAPIContent("text/event-stream", g.Schema(reflect.TypeOf(event{})))
+
+	case op.list != nil:
+		res["content"] = openAPIContent("application/json", openAPIListSchema(g, op.list))
+
+	case op.response != nil:
+		res["content"] = openAPIContent("application/json", g.Schema(reflect.TypeOf(op.response)))
+
+	case strings.HasSuffix(op.path, "/config/schema") || strings.HasSuffix(op.path, "/openapi.json"):
+		res["content"] = openAPIContent("application/json", map[string]interface{}{"type": "object"})
+	}
+
+	responses := map[string]interface{}{"200": res}
+
+	switch {
+	case strings.HasSuffix(op.path, "/ws"):
+		responses = map[string]interface{}{
+			"101": map[string]interface{}{"description": http.StatusText(http.StatusSwitchingProtocols)},
+		}
+
+	case strings.HasPrefix(op.path, "/v2/"):
+		for _, code := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError} {
+			responses[strconv.Itoa(code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content":     openAPIContent("application/json", g.Schema(reflect.TypeOf(apiV2Error{}))),
+			}
+		}
+
+	default:
+		responses[strconv.Itoa(http.StatusBadRequest)] = map[string]interface{}{
+			"description": http.StatusText(http.StatusBadRequest),
+		}
+	}
+
+	ret["responses"] = responses
+
+	return ret
+}
+
+// openAPIDocument generates the OpenAPI description of the API from the Go types.
+func openAPIDocument() map[string]interface{} {
+	g := jsonschema.NewGenerator("#/components/schemas/")
+	g.Names = apiSchemaNames
+
+	paths := make(map[string]interface{})
+
+	ops := append([]apiOperation(nil), apiOperations...)
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].path < ops[j].path
+	})
+
+	for _, op := range ops {
+		p := reAPIParam.ReplaceAllString(op.path, "{$1}")
+		if paths[p] == nil {
+			paths[p] = make(map[string]interface{})
+		}
+		paths[p].(map[string]interface{})[strings.ToLower(op.method)] = openAPIOperation(g, op)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "MediaMTX API",
+			"version": version,
+			"license": map[string]interface{}{
+				"name": "MIT",
+				"url":  "https://opensource.org/licenses/MIT",
+			},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.Defs,
+		},
+	}
+}
+
+func (a *api) onOpenAPI(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, openAPIDocument())
+}
+
+func (a *api) onConfigSchema(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, conf.Schema())
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/gin-gonic/gin"
 	"github.com/pion/rtp"
 	"github.com/stretchr/testify/require"
 
@@ -93,6 +96,35 @@ func TestAPIConfigGet(t *testing.T) {
 	require.Equal(t, true, out["api"])
 }
 
+func TestAPIConfigGetRaw(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"pathTemplates:\n" +
+		"  cameraTemplate:\n" +
+		"    readUser: myuser\n" +
+		"    readPass: mypass\n" +
+		"paths:\n" +
+		"  cam1:\n" +
+		"    use: cameraTemplate\n" +
+		"    runOnReady: echo\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	var out struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	err := httpRequest(http.MethodGet, "http://localhost:9997/v1/config/get", nil, &out)
+	require.NoError(t, err)
+	require.Equal(t, "myuser", out.Paths["cam1"]["readUser"])
+	require.Equal(t, "echo", out.Paths["cam1"]["runOnReady"])
+
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v1/config/get?view=raw", nil, &out)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"use":        "cameraTemplate",
+		"runOnReady": "echo",
+	}, out.Paths["cam1"])
+}
+
 func TestAPIConfigSet(t *testing.T) {
 	p, ok := newInstance("api: yes\n")
 	require.Equal(t, true, ok)
@@ -133,6 +165,211 @@ func TestAPIConfigPathsAdd(t *testing.T) {
 		out["paths"].(map[string]interface{})["my/path"].(map[string]interface{})["source"])
 }
 
+func TestAPIConfigPathsAddTemplate(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"pathTemplates:\n" +
+		"  cameraTemplate:\n" +
+		"    source: rtsp://127.0.0.1:9999/mypath\n" +
+		"    sourceOnDemand: yes\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	err := httpRequest(http.MethodPost, "http://localhost:9997/v1/config/paths/add/my/path", map[string]interface{}{
+		"use":            "cameraTemplate",
+		"sourceOnDemand": false,
+	}, nil)
+	require.NoError(t, err)
+
+	var out map[string]interface{}
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v1/config/get", nil, &out)
+	require.NoError(t, err)
+	pconf := out["paths"].(map[string]interface{})["my/path"].(map[string]interface{})
+	require.Equal(t, "rtsp://127.0.0.1:9999/mypath", pconf["source"])
+	require.Equal(t, false, pconf["sourceOnDemand"])
+}
+
+func TestAPIConfigPersist(t *testing.T) {
+	tmpf, err := writeTempFile([]byte("api: yes\n" +
+		"apiPersistConfig: yes\n" +
+		"# comment\n" +
+		"paths:\n"))
+	require.NoError(t, err)
+	defer os.Remove(tmpf)
+
+	p, ok := New([]string{tmpf})
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	err = httpRequest(http.MethodPost, "http://localhost:9997/v1/config/paths/add/mypath", map[string]interface{}{
+		"runOnReady": "echo",
+	}, nil)
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	byts, err := os.ReadFile(tmpf)
+	require.NoError(t, err)
+	require.Equal(t, "api: yes\n"+
+		"apiPersistConfig: yes\n"+
+		"# comment\n"+
+		"paths:\n"+
+		"  mypath:\n"+
+		"    runOnReady: echo\n", string(byts))
+}
+
+func TestAPIConfigValidate(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  cam1:\n" +
+		"    runOnReady: echo\n" +
+		"  cam2:\n" +
+		"    rpiCameraBrightness: 0.5\n" +
+		"    source: publisher\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	var out map[string]interface{}
+	err := httpRequest(http.MethodPost, "http://localhost:9997/v1/config/validate", map[string]interface{}{
+		"api": true,
+		"paths": map[string]interface{}{
+			"cam2": map[string]interface{}{
+				"rpiCameraBrightness": 0.7,
+			},
+			"cam3": map[string]interface{}{},
+		},
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"valid":               true,
+		"changedFields":       []interface{}{},
+		"restartedComponents": []interface{}{},
+		"addedPaths":          []interface{}{"cam3"},
+		"removedPaths":        []interface{}{"cam1"},
+		"changedPaths": []interface{}{
+			map[string]interface{}{
+				"name":          "cam2",
+				"changedFields": []interface{}{"rpiCameraBrightness"},
+				"hotUpdate":     true,
+			},
+		},
+	}, out)
+
+	err = httpRequest(http.MethodPost, "http://localhost:9997/v1/config/validate", map[string]interface{}{
+		"readBufferCount": 1000,
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, false, out["valid"])
+	require.Equal(t, "'readBufferCount' must be a power of two", out["error"])
+
+	for _, ca := range []struct {
+		in  map[string]interface{}
+		err string
+	}{
+		{
+			map[string]interface{}{"include": []string{"/etc/passwd"}},
+			"'include' is not allowed",
+		},
+		{
+			map[string]interface{}{"pathsDirectory": "/etc"},
+			"'pathsDirectory' is not allowed",
+		},
+		{
+			map[string]interface{}{"paths": map[string]interface{}{
+				"cam1": map[string]interface{}{"source": "rtsp://${FILE:/etc/passwd}"},
+			}},
+			"reference '${FILE:/etc/passwd}' is not allowed",
+		},
+	} {
+		out = nil
+		err = httpRequest(http.MethodPost, "http://localhost:9997/v1/config/validate", ca.in, &out)
+		require.NoError(t, err)
+		require.Equal(t, false, out["valid"])
+		require.Equal(t, ca.err, out["error"])
+	}
+
+	res, err := http.Post("http://localhost:9997/v1/config/validate", "application/x-yaml",
+		bytes.NewReader(bytes.Repeat([]byte("#"), apiMaxBodySize+1)))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+}
+
+func TestAPISchemas(t *testing.T) {
+	serverCertFpath, err := writeTempFile(serverCert)
+	require.NoError(t, err)
+	defer os.Remove(serverCertFpath)
+
+	serverKeyFpath, err := writeTempFile(serverKey)
+	require.NoError(t, err)
+	defer os.Remove(serverKeyFpath)
+
+	// enable all servers, in order to register all routes
+	p, ok := newInstance("api: yes\n" +
+		"encryption: optional\n" +
+		"serverCert: " + serverCertFpath + "\n" +
+		"serverKey: " + serverKeyFpath + "\n" +
+		"rtmpEncryption: optional\n" +
+		"rtmpServerCert: " + serverCertFpath + "\n" +
+		"rtmpServerKey: " + serverKeyFpath + "\n" +
+		"webrtcServerCert: " + serverCertFpath + "\n" +
+		"webrtcServerKey: " + serverKeyFpath + "\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	var schema map[string]interface{}
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v1/config/schema", nil, &schema)
+	require.NoError(t, err)
+	require.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+
+	readTimeout := schema["properties"].(map[string]interface{})["readTimeout"]
+	require.Equal(t, "10s", readTimeout.(map[string]interface{})["default"])
+
+	var doc map[string]interface{}
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v1/openapi.json", nil, &doc)
+	require.NoError(t, err)
+	require.Equal(t, "3.1.0", doc["openapi"])
+
+	paths := doc["paths"].(map[string]interface{})
+
+	// the document must describe exactly the routes of the router
+	var documented []string
+	for name, methods := range paths {
+		for method := range methods.(map[string]interface{}) {
+			documented = append(documented, strings.ToUpper(method)+" "+name)
+		}
+	}
+
+	var routes []string
+	for _, r := range p.api.httpServer.Handler.(*gin.Engine).Routes() {
+		routes = append(routes, r.Method+" "+reAPIParam.ReplaceAllString(r.Path, "{$1}"))
+	}
+
+	require.ElementsMatch(t, routes, documented)
+
+	get := paths["/v2/rtspconns/get/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{
+		"schema": map[string]interface{}{"$ref": "#/components/schemas/RTSPConn"},
+	}, get["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"])
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	rtspConn := schemas["RTSPConn"].(map[string]interface{})["properties"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"type": "string"}, rtspConn["id"])
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "date-time"}, rtspConn["created"])
+
+	source := schemas["PathConf"].(map[string]interface{})["properties"].(map[string]interface{})["source"]
+	require.Equal(t, map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string", "default": "publisher"},
+			map[string]interface{}{
+				"type":     "array",
+				"items":    map[string]interface{}{"type": "string"},
+				"minItems": float64(1),
+			},
+		},
+		"default": "publisher",
+	}, source)
+}
+
 func TestAPIConfigPathsEdit(t *testing.T) {
 	p, ok := newInstance("api: yes\n")
 	require.Equal(t, true, ok)
@@ -392,6 +629,252 @@ func TestAPIPathsList(t *testing.T) {
 	})
 }
 
+func TestAPIPathsListTracksStats(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  mypath:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	v := gortsplib.TransportTCP
+	source := gortsplib.Client{
+		Transport: &v,
+	}
+	err := source.StartRecording("rtsp://localhost:8554/mypath", media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	for i, ca := range []struct {
+		timestamp uint32
+		payload   []byte
+	}{
+		{0, []byte{0x05, 0x01}},          // IDR
+		{3000, []byte{0x01, 0x01}},       // non-IDR
+		{6000, []byte{0x01, 0x01}},       // non-IDR
+		{90000, []byte{0x05, 0x01}},      // IDR, one second after the first one
+		{90000, []byte{0x05, 0x02}},      // second slice of the same IDR
+		{20 * 90000, []byte{0x01, 0x01}}, // discontinuity
+	} {
+		err := source.WritePacketRTP(testMediaH264, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    96,
+				SequenceNumber: uint16(i),
+				Timestamp:      ca.timestamp,
+				Marker:         true,
+			},
+			Payload: ca.payload,
+		})
+		require.NoError(t, err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	var out struct {
+		Items map[string]struct {
+			PacketsLost  uint64              `json:"packetsLost"`
+			DecodeErrors uint64              `json:"decodeErrors"`
+			TracksStats  []streamFormatStats `json:"tracksStats"`
+		} `json:"items"`
+	}
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v1/paths/list", nil, &out)
+	require.NoError(t, err)
+
+	item := out.Items["mypath"]
+	require.Equal(t, uint64(0), item.PacketsLost)
+	require.Equal(t, uint64(0), item.DecodeErrors)
+	require.Equal(t, 1, len(item.TracksStats))
+
+	st := item.TracksStats[0]
+	require.Equal(t, 0, st.Track)
+	require.Equal(t, "H264", st.Codec)
+	require.Equal(t, uint64(6*14), st.BytesReceived)
+	require.Equal(t, uint64(5), st.Frames)
+	require.Equal(t, uint64(2), st.KeyFrames)
+	require.Equal(t, float64(1), st.GOPDuration)
+	require.Equal(t, uint64(1), st.TimestampDiscontinuities)
+}
+
+func TestAPIPathsListHealth(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  mypath:\n" +
+		"    healthMaxUnitAge: 1s\n" +
+		"    healthExpectedTracks: [H264, Opus]\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	v := gortsplib.TransportTCP
+	source := gortsplib.Client{
+		Transport: &v,
+	}
+	err := source.StartRecording("rtsp://localhost:8554/mypath", media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	time.Sleep(2500 * time.Millisecond)
+
+	var out struct {
+		Items map[string]struct {
+			Health pathHealth `json:"health"`
+		} `json:"items"`
+	}
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v1/paths/list", nil, &out)
+	require.NoError(t, err)
+
+	require.Equal(t, pathHealth{
+		Healthy: false,
+		Issues: []string{
+			"no units received for more than 1s",
+			"expected track 'H264' is not receiving units",
+			"expected track 'Opus' is missing",
+		},
+	}, out.Items["mypath"].Health)
+}
+
+func TestAPIV2Paths(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  path1:\n" +
+		"  path2:\n" +
+		"  path3:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	type pathItem struct {
+		Name string `json:"name"`
+	}
+
+	type pathList struct {
+		ItemCount int        `json:"itemCount"`
+		PageCount int        `json:"pageCount"`
+		Items     []pathItem `json:"items"`
+	}
+
+	var out pathList
+	err := httpRequest(http.MethodGet, "http://localhost:9997/v2/paths/list?itemsPerPage=2&page=1", nil, &out)
+	require.NoError(t, err)
+	require.Equal(t, pathList{
+		ItemCount: 3,
+		PageCount: 2,
+		Items:     []pathItem{{Name: "path3"}},
+	}, out)
+
+	var item pathItem
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v2/paths/get/path2", nil, &item)
+	require.NoError(t, err)
+	require.Equal(t, pathItem{Name: "path2"}, item)
+
+	res, err := http.Get("http://localhost:9997/v2/paths/get/nonexisting")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	var e struct {
+		Error string `json:"error"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&e)
+	require.NoError(t, err)
+	require.Equal(t, "path 'nonexisting' not found", e.Error)
+}
+
+func TestAPIPathsListRedact(t *testing.T) {
+	os.Setenv("MYPASS", "mypass")
+	defer os.Unsetenv("MYPASS")
+
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  mypath:\n" +
+		"    readUser: myuser\n" +
+		"    readPass: ${ENV:MYPASS}\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	type pathConf struct {
+		ReadUser string `json:"readUser"`
+		ReadPass string `json:"readPass"`
+	}
+
+	type pathItem struct {
+		Conf pathConf `json:"conf"`
+	}
+
+	expected := pathConf{ReadUser: "myuser", ReadPass: "${ENV:MYPASS}"}
+
+	var out1 struct {
+		Items map[string]pathItem `json:"items"`
+	}
+	err := httpRequest(http.MethodGet, "http://localhost:9997/v1/paths/list", nil, &out1)
+	require.NoError(t, err)
+	require.Equal(t, expected, out1.Items["mypath"].Conf)
+
+	var out2 struct {
+		Items []pathItem `json:"items"`
+	}
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v2/paths/list", nil, &out2)
+	require.NoError(t, err)
+	require.Equal(t, expected, out2.Items[0].Conf)
+
+	var out3 pathItem
+	err = httpRequest(http.MethodGet, "http://localhost:9997/v2/paths/get/mypath", nil, &out3)
+	require.NoError(t, err)
+	require.Equal(t, expected, out3.Conf)
+}
+
+func TestAPIEvents(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	res, err := http.Get("http://localhost:9997/v1/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	source := gortsplib.Client{}
+	err = source.StartRecording("rtsp://localhost:8554/mypath", media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	type eventData struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	}
+
+	br := bufio.NewReader(res.Body)
+	var events []eventData
+
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var evt eventData
+		err = json.Unmarshal([]byte(line[len("data: "):]), &evt)
+		require.NoError(t, err)
+		events = append(events, evt)
+
+		if evt.Type == "sourceReady" {
+			break
+		}
+	}
+
+	require.Equal(t, []eventData{
+		{Type: "connOpened"},
+		{Type: "pathCreated", Path: "mypath"},
+		{Type: "sourceReady", Path: "mypath"},
+	}, events)
+}
+
 func TestAPIProtocolSpecificList(t *testing.T) {
 	serverCertFpath, err := writeTempFile(serverCert)
 	require.NoError(t, err)
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiV2DefaultItemsPerPage = 100
+)
+
+type apiV2Error struct {
+	Error string `json:"error"`
+}
+
+type apiV2List struct {
+	ItemCount int         `json:"itemCount"`
+	PageCount int         `json:"pageCount"`
+	Items     interface{} `json:"items"`
+}
+
+type apiV2Path struct {
+	Name string `json:"name"`
+	pathAPIPathsListItem
+}
+
+type apiV2HLSMuxer struct {
+	Path string `json:"path"`
+	hlsServerAPIMuxersListItem
+}
+
+type apiV2RTSPConn struct {
+	ID string `json:"id"`
+	rtspServerAPIConnsListItem
+}
+
+type apiV2RTSPSession struct {
+	ID string `json:"id"`
+	rtspServerAPISessionsListItem
+}
+
+type apiV2RTMPConn struct {
+	ID string `json:"id"`
+	rtmpServerAPIConnsListItem
+}
+
+type apiV2WebRTCConn struct {
+	ID string `json:"id"`
+	webRTCServerAPIConnsListItem
+}
+
+// apiErrNotFound is returned by per-item lookups when the item doesn't exist.
+type apiErrNotFound struct {
+	kind string
+	id   string
+}
+
+// Error implements the error interface.
+func (e apiErrNotFound) Error() string {
+	return fmt.Sprintf("%s '%s' not found", e.kind, e.id)
+}
+
+func apiV2WriteError(ctx *gin.Context, status int, err error) {
+	ctx.AbortWithStatusJSON(status, &apiV2Error{Error: err.Error()})
+}
+
+func apiV2WriteGetError(ctx *gin.Context, err error) {
+	if _, ok := err.(apiErrNotFound); ok {
+		apiV2WriteError(ctx, http.StatusNotFound, err)
+		return
+	}
+
+	apiV2WriteError(ctx, http.StatusInternalServerError, err)
+}
+
+func sortedKeys(m interface{}) []string {
+	rv := reflect.ValueOf(m)
+	ret := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		ret = append(ret, k.String())
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// paginate slices the slice pointed by itemsPtr according to the
+// "page" and "itemsPerPage" query parameters, and returns the page count.
+func paginate(ctx *gin.Context, itemsPtr interface{}) (int, error) {
+	itemsPerPage := apiV2DefaultItemsPerPage
+	if s := ctx.Query("itemsPerPage"); s != "" {
+		tmp, err := strconv.ParseUint(s, 10, 31)
+		if err != nil || tmp == 0 {
+			return 0, fmt.Errorf("invalid 'itemsPerPage' parameter")
+		}
+		itemsPerPage = int(tmp)
+	}
+
+	page := 0
+	if s := ctx.Query("page"); s != "" {
+		tmp, err := strconv.ParseUint(s, 10, 31)
+		if err != nil {
+			return 0, fmt.Errorf("invalid 'page' parameter")
+		}
+		page = int(tmp)
+	}
+
+	ritems := reflect.ValueOf(itemsPtr).Elem()
+	itemsLen := ritems.Len()
+
+	pageCount := itemsLen / itemsPerPage
+	if (itemsLen % itemsPerPage) != 0 {
+		pageCount++
+	}
+
+	min := page * itemsPerPage
+	if min > itemsLen {
+		min = itemsLen
+	}
+
+	max := (page + 1) * itemsPerPage
+	if max > itemsLen {
+		max = itemsLen
+	}
+
+	ritems.Set(ritems.Slice(min, max))
+
+	return pageCount, nil
+}
+
+// apiV2WriteList paginates and writes a list of items.
+func apiV2WriteList(ctx *gin.Context, itemsPtr interface{}) {
+	itemCount := reflect.ValueOf(itemsPtr).Elem().Len()
+
+	pageCount, err := paginate(ctx, itemsPtr)
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &apiV2List{
+		ItemCount: itemCount,
+		PageCount: pageCount,
+		Items:     reflect.ValueOf(itemsPtr).Elem().Interface(),
+	})
+}
+
+func (a *api) v2PathsList() ([]apiV2Path, error) {
+	res := a.pathManager.apiPathsList()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	items := make([]apiV2Path, 0, len(res.data.Items))
+	for _, name := range sortedKeys(res.data.Items) {
+		item, err := a.redactPathConf(res.data.Items[name])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, apiV2Path{name, item})
+	}
+	return items, nil
+}
+
+func (a *api) v2HLSMuxersList() ([]apiV2HLSMuxer, error) {
+	res := a.hlsServer.apiMuxersList()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	items := make([]apiV2HLSMuxer, 0, len(res.data.Items))
+	for _, name := range sortedKeys(res.data.Items) {
+		items = append(items, apiV2HLSMuxer{name, res.data.Items[name]})
+	}
+	return items, nil
+}
+
+func (a *api) v2RTSPConnsList(s apiRTSPServer) ([]apiV2RTSPConn, error) {
+	res := s.apiConnsList()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	items := make([]apiV2RTSPConn, 0, len(res.data.Items))
+	for _, id := range sortedKeys(res.data.Items) {
+		items = append(items, apiV2RTSPConn{id, res.data.Items[id]})
+	}
+	return items, nil
+}
+
+func (a *api) v2RTSPSessionsList(s apiRTSPServer) ([]apiV2RTSPSession, error) {
+	res := s.apiSessionsList()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	items := make([]apiV2RTSPSession, 0, len(res.data.Items))
+	for _, id := range sortedKeys(res.data.Items) {
+		items = append(items, apiV2RTSPSession{id, res.data.Items[id]})
+	}
+	return items, nil
+}
+
+func (a *api) v2RTMPConnsList(s apiRTMPServer) ([]apiV2RTMPConn, error) {
+	res := s.apiConnsList()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	items := make([]apiV2RTMPConn, 0, len(res.data.Items))
+	for _, id := range sortedKeys(res.data.Items) {
+		items = append(items, apiV2RTMPConn{id, res.data.Items[id]})
+	}
+	return items, nil
+}
+
+func (a *api) v2WebRTCConnsList() ([]apiV2WebRTCConn, error) {
+	res := a.webRTCServer.apiConnsList()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	items := make([]apiV2WebRTCConn, 0, len(res.data.Items))
+	for _, id := range sortedKeys(res.data.Items) {
+		items = append(items, apiV2WebRTCConn{id, res.data.Items[id]})
+	}
+	return items, nil
+}
+
+func (a *api) onV2ConfigSet(ctx *gin.Context) {
+	if status, err := a.configSet(ctx); err != nil {
+		apiV2WriteError(ctx, status, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onV2ConfigPathsAdd(ctx *gin.Context) {
+	if status, err := a.configPathsAdd(ctx); err != nil {
+		apiV2WriteError(ctx, status, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onV2ConfigPathsEdit(ctx *gin.Context) {
+	if status, err := a.configPathsEdit(ctx); err != nil {
+		apiV2WriteError(ctx, status, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onV2ConfigPathsDelete(ctx *gin.Context) {
+	if status, err := a.configPathsDelete(ctx); err != nil {
+		apiV2WriteError(ctx, status, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onV2PathsList(ctx *gin.Context) {
+	items, err := a.v2PathsList()
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	apiV2WriteList(ctx, &items)
+}
+
+func (a *api) onV2PathsGet(ctx *gin.Context) {
+	name, err := pathNameFromParam(ctx)
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	res := a.pathManager.apiPathsGet(name)
+	if res.err != nil {
+		apiV2WriteGetError(ctx, res.err)
+		return
+	}
+
+	item, err := a.redactPathConf(*res.data)
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &apiV2Path{name, item})
+}
+
+func (a *api) onV2HLSMuxersList(ctx *gin.Context) {
+	items, err := a.v2HLSMuxersList()
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	apiV2WriteList(ctx, &items)
+}
+
+func (a *api) onV2HLSMuxersGet(ctx *gin.Context) {
+	name, err := pathNameFromParam(ctx)
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	res := a.hlsServer.apiMuxersGet(name)
+	if res.err != nil {
+		apiV2WriteGetError(ctx, res.err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &apiV2HLSMuxer{name, *res.data})
+}
+
+func (a *api) onV2RTSPConnsList(s apiRTSPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		items, err := a.v2RTSPConnsList(s)
+		if err != nil {
+			apiV2WriteError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		apiV2WriteList(ctx, &items)
+	}
+}
+
+func (a *api) onV2RTSPConnsGet(s apiRTSPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		res := s.apiConnsGet(id)
+		if res.err != nil {
+			apiV2WriteGetError(ctx, res.err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, &apiV2RTSPConn{id, *res.data})
+	}
+}
+
+func (a *api) onV2RTSPSessionsList(s apiRTSPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		items, err := a.v2RTSPSessionsList(s)
+		if err != nil {
+			apiV2WriteError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		apiV2WriteList(ctx, &items)
+	}
+}
+
+func (a *api) onV2RTSPSessionsGet(s apiRTSPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		res := s.apiSessionsGet(id)
+		if res.err != nil {
+			apiV2WriteGetError(ctx, res.err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, &apiV2RTSPSession{id, *res.data})
+	}
+}
+
+func (a *api) onV2RTSPSessionsKick(s apiRTSPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		res := s.apiSessionsKick(ctx.Param("id"))
+		if res.err != nil {
+			apiV2WriteError(ctx, http.StatusNotFound, res.err)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func (a *api) onV2RTMPConnsList(s apiRTMPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		items, err := a.v2RTMPConnsList(s)
+		if err != nil {
+			apiV2WriteError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		apiV2WriteList(ctx, &items)
+	}
+}
+
+func (a *api) onV2RTMPConnsGet(s apiRTMPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		res := s.apiConnsGet(id)
+		if res.err != nil {
+			apiV2WriteGetError(ctx, res.err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, &apiV2RTMPConn{id, *res.data})
+	}
+}
+
+func (a *api) onV2RTMPConnsKick(s apiRTMPServer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		res := s.apiConnsKick(ctx.Param("id"))
+		if res.err != nil {
+			apiV2WriteError(ctx, http.StatusNotFound, res.err)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func (a *api) onV2WebRTCConnsList(ctx *gin.Context) {
+	items, err := a.v2WebRTCConnsList()
+	if err != nil {
+		apiV2WriteError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	apiV2WriteList(ctx, &items)
+}
+
+func (a *api) onV2WebRTCConnsGet(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	res := a.webRTCServer.apiConnsGet(id)
+	if res.err != nil {
+		apiV2WriteGetError(ctx, res.err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &apiV2WebRTCConn{id, *res.data})
+}
+
+func (a *api) onV2WebRTCConnsKick(ctx *gin.Context) {
+	res := a.webRTCServer.apiConnsKick(ctx.Param("id"))
+	if res.err != nil {
+		apiV2WriteError(ctx, http.StatusNotFound, res.err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -2,7 +2,11 @@ package core
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -10,12 +14,16 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/auth"
 	"github.com/bluenviron/gortsplib/v3/pkg/base"
 	"github.com/bluenviron/gortsplib/v3/pkg/headers"
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/aler9/mediamtx/internal/conf"
 )
@@ -26,14 +34,217 @@ func sha256Base64(in string) string {
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
+// argon2 parameters used when hashing credentials.
+const (
+	argon2Memory     = 19 * 1024
+	argon2Iterations = 2
+	argon2Threads    = 1
+	argon2SaltLen    = 16
+	argon2KeyLen     = 32
+)
+
+// checkArgon2 checks a credential against a hash in the PHC string format
+// ("$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>").
+func checkArgon2(encoded string, guess string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return false
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false
+	}
+
+	var memory uint32
+	var iterations uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads)
+	if err != nil || iterations == 0 || threads == 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+
+	var computed []byte
+	switch parts[1] {
+	case "argon2id":
+		computed = argon2.IDKey([]byte(guess), salt, iterations, memory, threads, uint32(len(hash)))
+
+	case "argon2i":
+		computed = argon2.Key([]byte(guess), salt, iterations, memory, threads, uint32(len(hash)))
+
+	default:
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(hash, computed) == 1
+}
+
+const (
+	credentialCacheTTL  = 1 * time.Minute
+	credentialCacheSize = 1024
+)
+
+// credentialCache contains successful verifications of argon2 and bcrypt hashes,
+// that are too expensive to be computed on every request
+// (i.e. on every HLS segment or RTSP DESCRIBE).
+// Entries are indexed by the HMAC-SHA256 of the hash and the guess, computed with a
+// random secret, in order not to keep guesses in memory and not to allow to test
+// guesses quickly by reading the process memory.
+type credentialCache struct {
+	secretOnce sync.Once
+	secret     []byte
+	mutex      sync.Mutex
+	entries    map[[sha256.Size]byte]time.Time
+}
+
+// key returns the key of an entry. It returns false when the secret can't be generated,
+// and in that case verifications are not cached.
+func (c *credentialCache) key(right string, guess string) ([sha256.Size]byte, bool) {
+	c.secretOnce.Do(func() {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err == nil {
+			c.secret = secret
+		}
+	})
+
+	var ret [sha256.Size]byte
+
+	if c.secret == nil {
+		return ret, false
+	}
+
+	h := hmac.New(sha256.New, c.secret)
+	h.Write([]byte(right))
+	h.Write([]byte{0})
+	h.Write([]byte(guess))
+	copy(ret[:], h.Sum(nil))
+	return ret, true
+}
+
+func (c *credentialCache) has(key [sha256.Size]byte) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expire, ok := c.entries[key]
+	return ok && time.Now().Before(expire)
+}
+
+func (c *credentialCache) add(key [sha256.Size]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	if len(c.entries) >= credentialCacheSize {
+		for k, expire := range c.entries {
+			if !now.Before(expire) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= credentialCacheSize {
+			return
+		}
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]time.Time)
+	}
+	c.entries[key] = now.Add(credentialCacheTTL)
+}
+
+var slowCredentialCache credentialCache
+
+func checkSlowCredential(right string, guess string, check func() bool) bool {
+	key, cacheable := slowCredentialCache.key(right, guess)
+
+	if cacheable && slowCredentialCache.has(key) {
+		return true
+	}
+
+	if !check() {
+		return false
+	}
+
+	if cacheable {
+		slowCredentialCache.add(key)
+	}
+	return true
+}
+
 func checkCredential(right string, guess string) bool {
-	if strings.HasPrefix(right, "sha256:") {
+	switch {
+	case strings.HasPrefix(right, "sha256:"):
 		return right[len("sha256:"):] == sha256Base64(guess)
+
+	case strings.HasPrefix(right, "argon2:"):
+		return checkSlowCredential(right, guess, func() bool {
+			return checkArgon2(right[len("argon2:"):], guess)
+		})
+
+	case strings.HasPrefix(right, "bcrypt:"):
+		return checkSlowCredential(right, guess, func() bool {
+			return bcrypt.CompareHashAndPassword([]byte(right[len("bcrypt:"):]), []byte(guess)) == nil
+		})
 	}
 
 	return right == guess
 }
 
+// hashCredential hashes a credential with the given algorithm,
+// in the format read by checkCredential().
+func hashCredential(algorithm string, in string) (string, error) {
+	switch algorithm {
+	case "sha256":
+		return "sha256:" + sha256Base64(in), nil
+
+	case "argon2id":
+		salt := make([]byte, argon2SaltLen)
+		_, err := io.ReadFull(rand.Reader, salt)
+		if err != nil {
+			return "", err
+		}
+
+		hash := argon2.IDKey([]byte(in), salt, argon2Iterations, argon2Memory, argon2Threads, argon2KeyLen)
+
+		return fmt.Sprintf("argon2:$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Iterations, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(hash)), nil
+
+	case "bcrypt":
+		hash, err := bcrypt.GenerateFromPassword([]byte(in), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+
+		return "bcrypt:" + string(hash), nil
+	}
+
+	return "", fmt.Errorf("unsupported algorithm '%s'", algorithm)
+}
+
+func certIdentityMatches(certIDs []string, pathUser string) bool {
+	for _, id := range certIDs {
+		if id == pathUser {
+			return true
+		}
+	}
+	return false
+}
+
 type authProtocol string
 
 const (
@@ -53,16 +264,18 @@ func externalAuth(
 	id *uuid.UUID,
 	publish bool,
 	query string,
+	fingerprint string,
 ) error {
 	enc, _ := json.Marshal(struct {
-		IP       string     `json:"ip"`
-		User     string     `json:"user"`
-		Password string     `json:"password"`
-		Path     string     `json:"path"`
-		Protocol string     `json:"protocol"`
-		ID       *uuid.UUID `json:"id"`
-		Action   string     `json:"action"`
-		Query    string     `json:"query"`
+		IP              string     `json:"ip"`
+		User            string     `json:"user"`
+		Password        string     `json:"password"`
+		Path            string     `json:"path"`
+		Protocol        string     `json:"protocol"`
+		ID              *uuid.UUID `json:"id"`
+		Action          string     `json:"action"`
+		Query           string     `json:"query"`
+		CertFingerprint string     `json:"certFingerprint,omitempty"`
 	}{
 		IP:       ip,
 		User:     user,
@@ -75,7 +288,8 @@ func externalAuth(
 			}
 			return "read"
 		}(),
-		Query: query,
+		Query:           query,
+		CertFingerprint: fingerprint,
 	})
 	res, err := http.Post(ur, "application/json", bytes.NewReader(enc))
 	if err != nil {
@@ -104,8 +318,34 @@ type authCredentials struct {
 	rtspRequest *base.Request
 	rtspBaseURL *url.URL
 	rtspNonce   string
+	cert        *x509.Certificate
 }
 
+// authUser returns the user that is associated with the credentials.
+func (c authCredentials) authUser() string {
+	if c.rtspRequest != nil {
+		if user := rtspRequestUser(c.rtspRequest); user != "" {
+			return user
+		}
+	}
+
+	if c.user != "" {
+		return c.user
+	}
+
+	if c.cert != nil {
+		if ids := certIdentities(c.cert); len(ids) != 0 {
+			return ids[0]
+		}
+	}
+
+	return ""
+}
+
+// authenticate checks the credentials of a client and returns the verified user,
+// i.e. the user that has been checked by the external authentication server,
+// by the client certificate or by the credentials of the path.
+// The verified user is empty when no check has been performed.
 func authenticate(
 	externalAuthenticationURL string,
 	rtspAuthMethods conf.AuthMethods,
@@ -113,7 +353,7 @@ func authenticate(
 	pathConf *conf.PathConf,
 	publish bool,
 	credentials authCredentials,
-) error {
+) (string, error) {
 	var rtspAuth headers.Authorization
 	if credentials.rtspRequest != nil {
 		err := rtspAuth.Unmarshal(credentials.rtspRequest.Header["Authorization"])
@@ -123,6 +363,20 @@ func authenticate(
 		}
 	}
 
+	var certIDs []string
+	var certFP string
+	if credentials.cert != nil {
+		certIDs = certIdentities(credentials.cert)
+		certFP = certFingerprint(credentials.cert)
+
+		// use the certificate identity as user when no user is provided
+		if credentials.user == "" && len(certIDs) != 0 {
+			credentials.user = certIDs[0]
+		}
+	}
+
+	verifiedUser := ""
+
 	if externalAuthenticationURL != "" {
 		err := externalAuth(
 			externalAuthenticationURL,
@@ -134,10 +388,12 @@ func authenticate(
 			credentials.id,
 			publish,
 			credentials.query,
+			certFP,
 		)
 		if err != nil {
-			return fmt.Errorf("external authentication failed: %s", err)
+			return "", fmt.Errorf("external authentication failed: %s", err)
 		}
+		verifiedUser = credentials.user
 	}
 
 	var pathIPs conf.IPsOrCIDRs
@@ -156,11 +412,16 @@ func authenticate(
 
 	if pathIPs != nil {
 		if !ipEqualOrInRange(credentials.ip, pathIPs) {
-			return fmt.Errorf("IP '%s' not allowed", credentials.ip)
+			return "", fmt.Errorf("IP '%s' not allowed", credentials.ip)
 		}
 	}
 
-	if pathUser != "" {
+	if conf.Credential(pathUser).IsCertIdentity() {
+		if !certIdentityMatches(certIDs, pathUser) {
+			return "", fmt.Errorf("invalid client certificate")
+		}
+		verifiedUser = pathUser
+	} else if pathUser != "" {
 		if credentials.rtspRequest != nil && rtspAuth.Method == headers.AuthDigest {
 			err := auth.Validate(
 				credentials.rtspRequest,
@@ -171,13 +432,17 @@ func authenticate(
 				"IPCAM",
 				credentials.rtspNonce)
 			if err != nil {
-				return err
+				return "", err
 			}
-		} else if !checkCredential(pathUser, credentials.user) ||
-			!checkCredential(pathPass, credentials.pass) {
-			return fmt.Errorf("invalid credentials")
+			verifiedUser = rtspRequestUser(credentials.rtspRequest)
+		} else {
+			if !checkCredential(pathUser, credentials.user) ||
+				!checkCredential(pathPass, credentials.pass) {
+				return "", fmt.Errorf("invalid credentials")
+			}
+			verifiedUser = credentials.user
 		}
 	}
 
-	return nil
+	return verifiedUser, nil
 }
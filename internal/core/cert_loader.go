@@ -0,0 +1,122 @@
+package core
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/confwatcher"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	certLoaderRetryPause = 1 * time.Second
+)
+
+// certLoader loads a certificate and reloads it when the certificate
+// or the key change on disk, without restarting listeners.
+type certLoader struct {
+	certPath string
+	keyPath  string
+	parent   logger.Writer
+
+	certWatcher *confwatcher.ConfWatcher
+	keyWatcher  *confwatcher.ConfWatcher
+	certMutex   sync.RWMutex
+	cert        *tls.Certificate
+
+	// out
+	done chan struct{}
+}
+
+func newCertLoader(
+	certPath string,
+	keyPath string,
+	parent logger.Writer,
+) (*certLoader, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	certWatcher, err := confwatcher.New(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyWatcher, err := confwatcher.New(keyPath)
+	if err != nil {
+		certWatcher.Close()
+		return nil, err
+	}
+
+	cl := &certLoader{
+		certPath:    certPath,
+		keyPath:     keyPath,
+		parent:      parent,
+		certWatcher: certWatcher,
+		keyWatcher:  keyWatcher,
+		cert:        &cert,
+		done:        make(chan struct{}),
+	}
+
+	go cl.run()
+
+	return cl, nil
+}
+
+func (cl *certLoader) close() {
+	cl.certWatcher.Close()
+	cl.keyWatcher.Close()
+	<-cl.done
+}
+
+func (cl *certLoader) run() {
+	defer close(cl.done)
+
+	// when a reload fails, it is retried periodically, since the event
+	// that completes the writing of the files may be merged with previous ones.
+	var retry <-chan time.Time
+
+	for {
+		select {
+		case _, ok := <-cl.certWatcher.Watch():
+			if !ok {
+				return
+			}
+
+		case _, ok := <-cl.keyWatcher.Watch():
+			if !ok {
+				return
+			}
+
+		case <-retry:
+		}
+
+		cert, err := tls.LoadX509KeyPair(cl.certPath, cl.keyPath)
+		if err != nil {
+			// certificate and key may be written in separate moments;
+			// keep the previous certificate until both are valid.
+			if retry == nil {
+				cl.parent.Log(logger.Warn, "unable to reload certificate, retrying: %v", err)
+			}
+			retry = time.After(certLoaderRetryPause)
+			continue
+		}
+
+		retry = nil
+
+		cl.certMutex.Lock()
+		cl.cert = &cert
+		cl.certMutex.Unlock()
+
+		cl.parent.Log(logger.Info, "certificate reloaded")
+	}
+}
+
+// getCertificate is called by tls.Config.
+func (cl *certLoader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cl.certMutex.RLock()
+	defer cl.certMutex.RUnlock()
+	return cl.cert, nil
+}
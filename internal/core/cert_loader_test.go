@@ -0,0 +1,75 @@
+package core
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/confwatcher"
+)
+
+func TestCertLoaderRetry(t *testing.T) {
+	certFpath, err := writeTempFile(serverCert)
+	require.NoError(t, err)
+	defer os.Remove(certFpath)
+
+	keyFpath, err := writeTempFile(serverKey)
+	require.NoError(t, err)
+	defer os.Remove(keyFpath)
+
+	// watch another file, in order to control the events received by the loader
+	triggerFpath, err := writeTempFile([]byte("a"))
+	require.NoError(t, err)
+	defer os.Remove(triggerFpath)
+
+	cert, err := tls.LoadX509KeyPair(certFpath, keyFpath)
+	require.NoError(t, err)
+
+	certWatcher, err := confwatcher.New(triggerFpath)
+	require.NoError(t, err)
+
+	keyWatcher, err := confwatcher.New(triggerFpath)
+	require.NoError(t, err)
+
+	cl := &certLoader{
+		certPath:    certFpath,
+		keyPath:     keyFpath,
+		parent:      testLogger{},
+		certWatcher: certWatcher,
+		keyWatcher:  keyWatcher,
+		cert:        &cert,
+		done:        make(chan struct{}),
+	}
+	go cl.run()
+	defer cl.close()
+
+	newCert, newKey := generateServerCert(t)
+
+	// the key is partially written when the event is received
+	err = os.WriteFile(certFpath, newCert, 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(keyFpath, newKey[:len(newKey)/2], 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(triggerFpath, []byte("b"), 0o644)
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	// the write that completes the key doesn't generate any event
+	err = os.WriteFile(keyFpath, newKey, 0o644)
+	require.NoError(t, err)
+
+	time.Sleep(certLoaderRetryPause + 500*time.Millisecond)
+
+	expected, err := tls.X509KeyPair(newCert, newKey)
+	require.NoError(t, err)
+
+	loaded, err := cl.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, expected.Certificate, loaded.Certificate)
+}
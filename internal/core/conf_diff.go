@@ -0,0 +1,125 @@
+package core
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+type confDiffPath struct {
+	Name          string   `json:"name"`
+	ChangedFields []string `json:"changedFields"`
+	HotUpdate     bool     `json:"hotUpdate"`
+}
+
+// confDiff describes the differences between two configurations
+// and the effects of applying the new one.
+type confDiff struct {
+	Valid               bool           `json:"valid"`
+	Error               string         `json:"error,omitempty"`
+	ChangedFields       []string       `json:"changedFields"`
+	RestartedComponents []string       `json:"restartedComponents"`
+	AddedPaths          []string       `json:"addedPaths"`
+	RemovedPaths        []string       `json:"removedPaths"`
+	ChangedPaths        []confDiffPath `json:"changedPaths"`
+}
+
+func changedFields(a interface{}, b interface{}) []string {
+	ret := []string{}
+
+	ra := reflect.ValueOf(a).Elem()
+	rb := reflect.ValueOf(b).Elem()
+	rt := ra.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		key := strings.Split(f.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" || key == "paths" || !f.IsExported() {
+			continue
+		}
+
+		if !reflect.DeepEqual(ra.Field(i).Interface(), rb.Field(i).Interface()) {
+			ret = append(ret, key)
+		}
+	}
+
+	return ret
+}
+
+func restartedComponents(changes confChanges) []string {
+	ret := []string{}
+
+	for _, c := range []struct {
+		name    string
+		restart bool
+	}{
+		{"logger", changes.closeLogger},
+		{"metrics", changes.closeMetrics},
+		{"webhookSender", changes.closeWebhookSender},
+		{"pprof", changes.closePPROF},
+		{"pathManager", changes.closePathManager},
+		{"rtspServer", changes.closeRTSPServer},
+		{"rtspsServer", changes.closeRTSPSServer},
+		{"rtmpServer", changes.closeRTMPServer},
+		{"rtmpsServer", changes.closeRTMPSServer},
+		{"hlsServer", changes.closeHLSServer},
+		{"webrtcServer", changes.closeWebRTCServer},
+		{"api", changes.closeAPI},
+	} {
+		if c.restart {
+			ret = append(ret, c.name)
+		}
+	}
+
+	return ret
+}
+
+// newConfDiff computes the differences between the running configuration and a new one.
+// Paths can be hot updated when pathConfCanBeUpdated() allows it and the path manager
+// is not restarted; otherwise they are recreated.
+func newConfDiff(oldConf *conf.Conf, newConf *conf.Conf) *confDiff {
+	changes := newConfChanges(oldConf, newConf)
+
+	d := &confDiff{
+		Valid:               true,
+		ChangedFields:       changedFields(oldConf, newConf),
+		RestartedComponents: restartedComponents(changes),
+		AddedPaths:          []string{},
+		RemovedPaths:        []string{},
+		ChangedPaths:        []confDiffPath{},
+	}
+
+	for name := range newConf.Paths {
+		if _, ok := oldConf.Paths[name]; !ok {
+			d.AddedPaths = append(d.AddedPaths, name)
+		}
+	}
+	sort.Strings(d.AddedPaths)
+
+	for name := range oldConf.Paths {
+		if _, ok := newConf.Paths[name]; !ok {
+			d.RemovedPaths = append(d.RemovedPaths, name)
+		}
+	}
+	sort.Strings(d.RemovedPaths)
+
+	for name, newPathConf := range newConf.Paths {
+		oldPathConf, ok := oldConf.Paths[name]
+		if !ok || newPathConf.Equal(oldPathConf) {
+			continue
+		}
+
+		d.ChangedPaths = append(d.ChangedPaths, confDiffPath{
+			Name:          name,
+			ChangedFields: changedFields(oldPathConf, newPathConf),
+			HotUpdate:     !changes.closePathManager && pathConfCanBeUpdated(oldPathConf, newPathConf),
+		})
+	}
+	sort.Slice(d.ChangedPaths, func(i, j int) bool {
+		return d.ChangedPaths[i].Name < d.ChangedPaths[j].Name
+	})
+
+	return d
+}
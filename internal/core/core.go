@@ -3,21 +3,27 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/bluenviron/gortsplib/v3"
 	"github.com/gin-gonic/gin"
 
 	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/conf/decrypt"
 	"github.com/aler9/mediamtx/internal/confwatcher"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
 	"github.com/aler9/mediamtx/internal/rlimit"
 	"github.com/aler9/mediamtx/internal/rpicamera"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 var version = "v0.0.0"
@@ -31,7 +37,11 @@ type Core struct {
 	confFound       bool
 	logger          *logger.Logger
 	externalCmdPool *externalcmd.Pool
+	eventBus        *eventBus
+	usageMeter      *usageMeter
+	tracer          *telemetry.Tracer
 	metrics         *metrics
+	webhookSender   *webhookSender
 	pprof           *pprof
 	pathManager     *pathManager
 	rtspServer      *rtspServer
@@ -44,15 +54,92 @@ type Core struct {
 	confWatcher     *confwatcher.ConfWatcher
 
 	// in
-	chAPIConfigSet chan *conf.Conf
+	chAPIConfigSet chan coreAPIConfigSetReq
 
 	// out
 	done chan struct{}
 }
 
 var cli struct {
-	Version  bool   `help:"print version"`
-	Confpath string `arg:"" default:"mediamtx.yml"`
+	Version bool `help:"print version"`
+
+	Run struct {
+		Confpath string `arg:"" default:"mediamtx.yml"`
+	} `cmd:"" default:"withargs" help:"run the server (default)"`
+
+	Check struct {
+		Confpath string `arg:""`
+		Current  string `default:"mediamtx.yml" help:"path to the configuration file in use, that is compared with the new one."`
+	} `cmd:"" help:"check a configuration file and print differences with the one in use"`
+
+	Conf struct {
+		Encrypt struct {
+			Key  string `required:"" env:"MTX_CONFKEY" help:"encryption key."`
+			File string `arg:"" optional:"" help:"file to encrypt. If omitted, the standard input is used."`
+		} `cmd:"" help:"encrypt a configuration file and print it, in the format read when MTX_CONFKEY is set"`
+
+		Decrypt struct {
+			Key  string `required:"" env:"MTX_CONFKEY" help:"encryption key."`
+			File string `arg:"" optional:"" help:"file to decrypt. If omitted, the standard input is used."`
+		} `cmd:"" help:"decrypt a configuration file and print it"`
+	} `cmd:"" help:"encrypt or decrypt configuration files"`
+
+	HashPassword struct {
+		Algorithm string `enum:"sha256,argon2id,bcrypt" default:"argon2id" help:"hash algorithm (sha256, argon2id, bcrypt)."`
+		Password  string `arg:"" optional:"" help:"credential to hash. If omitted, it is read from the standard input."`
+	} `cmd:"" help:"hash a username or password, in order to store it in the configuration"`
+}
+
+func readFileOrStdin(fpath string) ([]byte, error) {
+	if fpath != "" {
+		return os.ReadFile(fpath)
+	}
+	return io.ReadAll(os.Stdin)
+}
+
+// cryptConf encrypts or decrypts a configuration file.
+func cryptConf(encrypt bool, key string, fpath string) ([]byte, error) {
+	byts, err := readFileOrStdin(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypt {
+		return decrypt.Encrypt(key, byts)
+	}
+	return decrypt.Decrypt(key, byts)
+}
+
+// hashPassword hashes a credential provided as argument or through the standard input.
+func hashPassword(algorithm string, password string) (string, error) {
+	if password == "" {
+		byts, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		password = strings.TrimRight(string(byts), "\r\n")
+	}
+
+	if password == "" {
+		return "", fmt.Errorf("password is empty")
+	}
+
+	return hashCredential(algorithm, password)
+}
+
+// checkConf loads a configuration file and compares it with the one in use.
+func checkConf(confPath string, currentPath string) *confDiff {
+	newConf, _, err := conf.Load(confPath)
+	if err != nil {
+		return &confDiff{Error: err.Error()}
+	}
+
+	currentConf, _, err := conf.Load(currentPath)
+	if err != nil {
+		return &confDiff{Error: "unable to load the current configuration: " + err.Error()}
+	}
+
+	return newConfDiff(currentConf, newConf)
 }
 
 // New allocates a core.
@@ -73,7 +160,7 @@ func New(args []string) (*Core, bool) {
 		panic(err)
 	}
 
-	_, err = parser.Parse(args)
+	kctx, err := parser.Parse(args)
 	parser.FatalIfErrorf(err)
 
 	if cli.Version {
@@ -81,13 +168,48 @@ func New(args []string) (*Core, bool) {
 		os.Exit(0)
 	}
 
+	switch {
+	case strings.HasPrefix(kctx.Command(), "check"):
+		d := checkConf(cli.Check.Confpath, cli.Check.Current)
+		enc, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Println(string(enc))
+		if !d.Valid {
+			os.Exit(1)
+		}
+		os.Exit(0)
+
+	case strings.HasPrefix(kctx.Command(), "conf encrypt"),
+		strings.HasPrefix(kctx.Command(), "conf decrypt"):
+		var byts []byte
+		if strings.HasPrefix(kctx.Command(), "conf encrypt") {
+			byts, err = cryptConf(true, cli.Conf.Encrypt.Key, cli.Conf.Encrypt.File)
+		} else {
+			byts, err = cryptConf(false, cli.Conf.Decrypt.Key, cli.Conf.Decrypt.File)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERR: %s\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(byts)
+		os.Exit(0)
+
+	case strings.HasPrefix(kctx.Command(), "hash-password"):
+		hash, err := hashPassword(cli.HashPassword.Algorithm, cli.HashPassword.Password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERR: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(hash)
+		os.Exit(0)
+	}
+
 	ctx, ctxCancel := context.WithCancel(context.Background())
 
 	p := &Core{
 		ctx:            ctx,
 		ctxCancel:      ctxCancel,
-		confPath:       cli.Confpath,
-		chAPIConfigSet: make(chan *conf.Conf),
+		confPath:       cli.Run.Confpath,
+		chAPIConfigSet: make(chan coreAPIConfigSetReq),
 		done:           make(chan struct{}),
 	}
 
@@ -132,18 +254,17 @@ func (p *Core) Log(level logger.Level, format string, args ...interface{}) {
 func (p *Core) run() {
 	defer close(p.done)
 
-	confChanged := func() chan struct{} {
-		if p.confWatcher != nil {
-			return p.confWatcher.Watch()
-		}
-		return make(chan struct{})
-	}()
-
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
 
 outer:
 	for {
+		// the watcher is recreated when included files change
+		var confChanged chan struct{}
+		if p.confWatcher != nil {
+			confChanged = p.confWatcher.Watch()
+		}
+
 		select {
 		case <-confChanged:
 			p.Log(logger.Info, "reloading configuration (file changed)")
@@ -160,10 +281,22 @@ outer:
 				break outer
 			}
 
-		case newConf := <-p.chAPIConfigSet:
+		case req := <-p.chAPIConfigSet:
+			// the configuration is persisted before being applied,
+			// in order to return persist errors to the caller.
+			if req.conf.APIPersistConfig {
+				err := p.persistConf(req.conf)
+				if err != nil {
+					p.Log(logger.Error, "unable to persist the configuration: %s", err)
+					req.res <- err
+					continue
+				}
+			}
+			req.res <- nil
+
 			p.Log(logger.Info, "reloading configuration (API request)")
 
-			err := p.reloadConf(newConf, true)
+			err := p.reloadConf(req.conf, true)
 			if err != nil {
 				p.Log(logger.Error, "%s", err)
 				break outer
@@ -187,10 +320,24 @@ func (p *Core) createResources(initial bool) error {
 	var err error
 
 	if p.logger == nil {
+		location, _ := p.conf.LogLocation()
+
+		componentLevels := make(map[string]logger.Level)
+		for component, level := range p.conf.LogLevels {
+			componentLevels[component] = logger.Level(level)
+		}
+
 		p.logger, err = logger.New(
 			logger.Level(p.conf.LogLevel),
+			componentLevels,
 			p.conf.LogDestinations,
 			p.conf.LogFile,
+			uint64(p.conf.LogFileMaxSize),
+			time.Duration(p.conf.LogFileRotationInterval),
+			p.conf.LogFileMaxBackups,
+			p.conf.LogFileCompress,
+			logger.Format(p.conf.LogFormat),
+			location,
 		)
 		if err != nil {
 			return err
@@ -211,13 +358,31 @@ func (p *Core) createResources(initial bool) error {
 		gin.SetMode(gin.ReleaseMode)
 
 		p.externalCmdPool = externalcmd.NewPool()
+		p.eventBus = newEventBus()
+		p.usageMeter = newUsageMeter(p.conf.UsageQuotas, p)
+		p.tracer = telemetry.NewTracer()
 	}
 
-	if p.conf.Metrics {
+	if p.conf.Metrics || p.conf.OTLP {
 		if p.metrics == nil {
+			metricsAddress := ""
+			if p.conf.Metrics {
+				metricsAddress = p.conf.MetricsAddress
+			}
+
+			otlpEndpoint := ""
+			if p.conf.OTLP {
+				otlpEndpoint = p.conf.OTLPEndpoint
+			}
+
 			p.metrics, err = newMetrics(
-				p.conf.MetricsAddress,
+				metricsAddress,
 				p.conf.ReadTimeout,
+				otlpEndpoint,
+				p.conf.OTLPInterval,
+				p.conf.OTLPHeaders,
+				p.tracer,
+				p.usageMeter,
 				p,
 			)
 			if err != nil {
@@ -226,6 +391,16 @@ func (p *Core) createResources(initial bool) error {
 		}
 	}
 
+	if p.webhookSender == nil {
+		p.webhookSender = newWebhookSender(
+			p.conf.WebhookTimeout,
+			p.conf.WebhookMaxRetries,
+			p.conf.WebhookSecret,
+			p.metrics,
+			p,
+		)
+	}
+
 	if p.conf.PPROF {
 		if p.pprof == nil {
 			p.pprof, err = newPPROF(
@@ -251,7 +426,10 @@ func (p *Core) createResources(initial bool) error {
 			p.conf.UDPMaxPayloadSize,
 			p.conf.Paths,
 			p.externalCmdPool,
+			p.webhookSender,
 			p.metrics,
+			p.eventBus,
+			p.usageMeter,
 			p,
 		)
 	}
@@ -279,12 +457,21 @@ func (p *Core) createResources(initial bool) error {
 				false,
 				"",
 				"",
+				"",
 				p.conf.RTSPAddress,
 				p.conf.Protocols,
+				p.conf.RTSPMaxConnections,
 				p.conf.RunOnConnect,
 				p.conf.RunOnConnectRestart,
+				p.conf.RunOnDisconnect,
+				p.conf.OnConnectWebhook,
+				p.conf.OnDisconnectWebhook,
 				p.externalCmdPool,
+				p.webhookSender,
 				p.metrics,
+				p.tracer,
+				p.eventBus,
+				p.usageMeter,
 				p.pathManager,
 				p,
 			)
@@ -315,12 +502,21 @@ func (p *Core) createResources(initial bool) error {
 				true,
 				p.conf.ServerCert,
 				p.conf.ServerKey,
+				p.conf.ClientCA,
 				p.conf.RTSPAddress,
 				p.conf.Protocols,
+				p.conf.RTSPMaxConnections,
 				p.conf.RunOnConnect,
 				p.conf.RunOnConnectRestart,
+				p.conf.RunOnDisconnect,
+				p.conf.OnConnectWebhook,
+				p.conf.OnDisconnectWebhook,
 				p.externalCmdPool,
+				p.webhookSender,
 				p.metrics,
+				p.tracer,
+				p.eventBus,
+				p.usageMeter,
 				p.pathManager,
 				p,
 			)
@@ -340,14 +536,23 @@ func (p *Core) createResources(initial bool) error {
 				p.conf.ReadTimeout,
 				p.conf.WriteTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.RTMPMaxConnections,
 				false,
 				"",
 				"",
+				"",
 				p.conf.RTSPAddress,
 				p.conf.RunOnConnect,
 				p.conf.RunOnConnectRestart,
+				p.conf.RunOnDisconnect,
+				p.conf.OnConnectWebhook,
+				p.conf.OnDisconnectWebhook,
 				p.externalCmdPool,
+				p.webhookSender,
 				p.metrics,
+				p.tracer,
+				p.eventBus,
+				p.usageMeter,
 				p.pathManager,
 				p,
 			)
@@ -367,14 +572,23 @@ func (p *Core) createResources(initial bool) error {
 				p.conf.ReadTimeout,
 				p.conf.WriteTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.RTMPMaxConnections,
 				true,
 				p.conf.RTMPServerCert,
 				p.conf.RTMPServerKey,
+				p.conf.RTMPClientCA,
 				p.conf.RTSPAddress,
 				p.conf.RunOnConnect,
 				p.conf.RunOnConnectRestart,
+				p.conf.RunOnDisconnect,
+				p.conf.OnConnectWebhook,
+				p.conf.OnDisconnectWebhook,
 				p.externalCmdPool,
+				p.webhookSender,
 				p.metrics,
+				p.tracer,
+				p.eventBus,
+				p.usageMeter,
 				p.pathManager,
 				p,
 			)
@@ -392,6 +606,7 @@ func (p *Core) createResources(initial bool) error {
 				p.conf.HLSEncryption,
 				p.conf.HLSServerKey,
 				p.conf.HLSServerCert,
+				p.conf.HLSClientCA,
 				p.conf.ExternalAuthenticationURL,
 				p.conf.HLSAlwaysRemux,
 				p.conf.HLSVariant,
@@ -404,8 +619,12 @@ func (p *Core) createResources(initial bool) error {
 				p.conf.HLSDirectory,
 				p.conf.ReadTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.HLSMaxConnections,
 				p.pathManager,
 				p.metrics,
+				p.tracer,
+				p.eventBus,
+				p.usageMeter,
 				p,
 			)
 			if err != nil {
@@ -422,13 +641,18 @@ func (p *Core) createResources(initial bool) error {
 				p.conf.WebRTCEncryption,
 				p.conf.WebRTCServerKey,
 				p.conf.WebRTCServerCert,
+				p.conf.WebRTCClientCA,
 				p.conf.WebRTCAllowOrigin,
 				p.conf.WebRTCTrustedProxies,
 				p.conf.WebRTCICEServers,
 				p.conf.ReadTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.WebRTCMaxConnections,
 				p.pathManager,
 				p.metrics,
+				p.tracer,
+				p.eventBus,
+				p.usageMeter,
 				p,
 				p.conf.WebRTCICEHostNAT1To1IPs,
 				p.conf.WebRTCICEUDPMuxAddress,
@@ -453,6 +677,8 @@ func (p *Core) createResources(initial bool) error {
 				p.rtmpsServer,
 				p.hlsServer,
 				p.webRTCServer,
+				p.eventBus,
+				p.usageMeter,
 				p,
 			)
 			if err != nil {
@@ -461,8 +687,8 @@ func (p *Core) createResources(initial bool) error {
 		}
 	}
 
-	if initial && p.confFound {
-		p.confWatcher, err = confwatcher.New(p.confPath)
+	if p.confFound && p.confWatcher == nil {
+		p.confWatcher, err = confwatcher.New(p.confPath, p.conf.IncludedPaths(p.confPath)...)
 		if err != nil {
 			return err
 		}
@@ -471,142 +697,199 @@ func (p *Core) createResources(initial bool) error {
 	return nil
 }
 
-func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
+// confChanges contains the components that have to be closed
+// and recreated in order to apply a new configuration.
+type confChanges struct {
+	closeLogger        bool
+	closeMetrics       bool
+	closeWebhookSender bool
+	closePPROF         bool
+	closePathManager   bool
+	closeRTSPServer    bool
+	closeRTSPSServer   bool
+	closeRTMPServer    bool
+	closeRTMPSServer   bool
+	closeHLSServer     bool
+	closeWebRTCServer  bool
+	closeAPI           bool
+}
+
+// newConfChanges computes the components that have to be recreated.
+// When newConf is nil, all components are closed.
+func newConfChanges(oldConf *conf.Conf, newConf *conf.Conf) confChanges {
 	closeLogger := newConf == nil ||
-		!reflect.DeepEqual(newConf.LogDestinations, p.conf.LogDestinations) ||
-		newConf.LogFile != p.conf.LogFile
+		newConf.LogLevel != oldConf.LogLevel ||
+		!reflect.DeepEqual(newConf.LogLevels, oldConf.LogLevels) ||
+		!reflect.DeepEqual(newConf.LogDestinations, oldConf.LogDestinations) ||
+		newConf.LogFile != oldConf.LogFile ||
+		newConf.LogFileMaxSize != oldConf.LogFileMaxSize ||
+		newConf.LogFileRotationInterval != oldConf.LogFileRotationInterval ||
+		newConf.LogFileMaxBackups != oldConf.LogFileMaxBackups ||
+		newConf.LogFileCompress != oldConf.LogFileCompress ||
+		newConf.LogFormat != oldConf.LogFormat ||
+		newConf.LogTimezone != oldConf.LogTimezone
 
 	closeMetrics := newConf == nil ||
-		newConf.Metrics != p.conf.Metrics ||
-		newConf.MetricsAddress != p.conf.MetricsAddress ||
-		newConf.ReadTimeout != p.conf.ReadTimeout
+		newConf.Metrics != oldConf.Metrics ||
+		newConf.MetricsAddress != oldConf.MetricsAddress ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.OTLP != oldConf.OTLP ||
+		newConf.OTLPEndpoint != oldConf.OTLPEndpoint ||
+		newConf.OTLPInterval != oldConf.OTLPInterval ||
+		!reflect.DeepEqual(newConf.OTLPHeaders, oldConf.OTLPHeaders)
+
+	closeWebhookSender := newConf == nil ||
+		newConf.WebhookTimeout != oldConf.WebhookTimeout ||
+		newConf.WebhookMaxRetries != oldConf.WebhookMaxRetries ||
+		newConf.WebhookSecret != oldConf.WebhookSecret ||
+		closeMetrics
 
 	closePPROF := newConf == nil ||
-		newConf.PPROF != p.conf.PPROF ||
-		newConf.PPROFAddress != p.conf.PPROFAddress ||
-		newConf.ReadTimeout != p.conf.ReadTimeout
+		newConf.PPROF != oldConf.PPROF ||
+		newConf.PPROFAddress != oldConf.PPROFAddress ||
+		newConf.ReadTimeout != oldConf.ReadTimeout
 
 	closePathManager := newConf == nil ||
-		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
-		newConf.RTSPAddress != p.conf.RTSPAddress ||
-		!reflect.DeepEqual(newConf.AuthMethods, p.conf.AuthMethods) ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.WriteTimeout != p.conf.WriteTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
-		newConf.UDPMaxPayloadSize != p.conf.UDPMaxPayloadSize ||
-		closeMetrics
-	if !closePathManager && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
-		p.pathManager.confReload(newConf.Paths)
-	}
+		newConf.ExternalAuthenticationURL != oldConf.ExternalAuthenticationURL ||
+		newConf.RTSPAddress != oldConf.RTSPAddress ||
+		!reflect.DeepEqual(newConf.AuthMethods, oldConf.AuthMethods) ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.WriteTimeout != oldConf.WriteTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
+		newConf.UDPMaxPayloadSize != oldConf.UDPMaxPayloadSize ||
+		closeMetrics ||
+		closeWebhookSender
 
 	closeRTSPServer := newConf == nil ||
-		newConf.RTSPDisable != p.conf.RTSPDisable ||
-		newConf.Encryption != p.conf.Encryption ||
-		newConf.RTSPAddress != p.conf.RTSPAddress ||
-		!reflect.DeepEqual(newConf.AuthMethods, p.conf.AuthMethods) ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.WriteTimeout != p.conf.WriteTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
-		!reflect.DeepEqual(newConf.Protocols, p.conf.Protocols) ||
-		newConf.RTPAddress != p.conf.RTPAddress ||
-		newConf.RTCPAddress != p.conf.RTCPAddress ||
-		newConf.MulticastIPRange != p.conf.MulticastIPRange ||
-		newConf.MulticastRTPPort != p.conf.MulticastRTPPort ||
-		newConf.MulticastRTCPPort != p.conf.MulticastRTCPPort ||
-		newConf.RTSPAddress != p.conf.RTSPAddress ||
-		!reflect.DeepEqual(newConf.Protocols, p.conf.Protocols) ||
-		newConf.RunOnConnect != p.conf.RunOnConnect ||
-		newConf.RunOnConnectRestart != p.conf.RunOnConnectRestart ||
+		newConf.RTSPDisable != oldConf.RTSPDisable ||
+		newConf.Encryption != oldConf.Encryption ||
+		newConf.RTSPAddress != oldConf.RTSPAddress ||
+		!reflect.DeepEqual(newConf.AuthMethods, oldConf.AuthMethods) ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.WriteTimeout != oldConf.WriteTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
+		!reflect.DeepEqual(newConf.Protocols, oldConf.Protocols) ||
+		newConf.RTPAddress != oldConf.RTPAddress ||
+		newConf.RTCPAddress != oldConf.RTCPAddress ||
+		newConf.MulticastIPRange != oldConf.MulticastIPRange ||
+		newConf.MulticastRTPPort != oldConf.MulticastRTPPort ||
+		newConf.MulticastRTCPPort != oldConf.MulticastRTCPPort ||
+		newConf.RTSPAddress != oldConf.RTSPAddress ||
+		!reflect.DeepEqual(newConf.Protocols, oldConf.Protocols) ||
+		newConf.RTSPMaxConnections != oldConf.RTSPMaxConnections ||
+		newConf.RunOnConnect != oldConf.RunOnConnect ||
+		newConf.RunOnConnectRestart != oldConf.RunOnConnectRestart ||
+		newConf.RunOnDisconnect != oldConf.RunOnDisconnect ||
+		newConf.OnConnectWebhook != oldConf.OnConnectWebhook ||
+		newConf.OnDisconnectWebhook != oldConf.OnDisconnectWebhook ||
 		closeMetrics ||
 		closePathManager
 
 	closeRTSPSServer := newConf == nil ||
-		newConf.RTSPDisable != p.conf.RTSPDisable ||
-		newConf.Encryption != p.conf.Encryption ||
-		newConf.RTSPSAddress != p.conf.RTSPSAddress ||
-		!reflect.DeepEqual(newConf.AuthMethods, p.conf.AuthMethods) ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.WriteTimeout != p.conf.WriteTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
-		newConf.ServerCert != p.conf.ServerCert ||
-		newConf.ServerKey != p.conf.ServerKey ||
-		newConf.RTSPAddress != p.conf.RTSPAddress ||
-		!reflect.DeepEqual(newConf.Protocols, p.conf.Protocols) ||
-		newConf.RunOnConnect != p.conf.RunOnConnect ||
-		newConf.RunOnConnectRestart != p.conf.RunOnConnectRestart ||
+		newConf.RTSPDisable != oldConf.RTSPDisable ||
+		newConf.Encryption != oldConf.Encryption ||
+		newConf.RTSPSAddress != oldConf.RTSPSAddress ||
+		!reflect.DeepEqual(newConf.AuthMethods, oldConf.AuthMethods) ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.WriteTimeout != oldConf.WriteTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
+		newConf.ServerCert != oldConf.ServerCert ||
+		newConf.ServerKey != oldConf.ServerKey ||
+		newConf.ClientCA != oldConf.ClientCA ||
+		newConf.RTSPAddress != oldConf.RTSPAddress ||
+		!reflect.DeepEqual(newConf.Protocols, oldConf.Protocols) ||
+		newConf.RTSPMaxConnections != oldConf.RTSPMaxConnections ||
+		newConf.RunOnConnect != oldConf.RunOnConnect ||
+		newConf.RunOnConnectRestart != oldConf.RunOnConnectRestart ||
+		newConf.RunOnDisconnect != oldConf.RunOnDisconnect ||
+		newConf.OnConnectWebhook != oldConf.OnConnectWebhook ||
+		newConf.OnDisconnectWebhook != oldConf.OnDisconnectWebhook ||
 		closeMetrics ||
 		closePathManager
 
 	closeRTMPServer := newConf == nil ||
-		newConf.RTMPDisable != p.conf.RTMPDisable ||
-		newConf.RTMPEncryption != p.conf.RTMPEncryption ||
-		newConf.RTMPAddress != p.conf.RTMPAddress ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.WriteTimeout != p.conf.WriteTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
-		newConf.RTSPAddress != p.conf.RTSPAddress ||
-		newConf.RunOnConnect != p.conf.RunOnConnect ||
-		newConf.RunOnConnectRestart != p.conf.RunOnConnectRestart ||
+		newConf.RTMPDisable != oldConf.RTMPDisable ||
+		newConf.RTMPEncryption != oldConf.RTMPEncryption ||
+		newConf.RTMPAddress != oldConf.RTMPAddress ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.WriteTimeout != oldConf.WriteTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
+		newConf.RTMPMaxConnections != oldConf.RTMPMaxConnections ||
+		newConf.RTSPAddress != oldConf.RTSPAddress ||
+		newConf.RunOnConnect != oldConf.RunOnConnect ||
+		newConf.RunOnConnectRestart != oldConf.RunOnConnectRestart ||
+		newConf.RunOnDisconnect != oldConf.RunOnDisconnect ||
+		newConf.OnConnectWebhook != oldConf.OnConnectWebhook ||
+		newConf.OnDisconnectWebhook != oldConf.OnDisconnectWebhook ||
 		closeMetrics ||
 		closePathManager
 
 	closeRTMPSServer := newConf == nil ||
-		newConf.RTMPDisable != p.conf.RTMPDisable ||
-		newConf.RTMPEncryption != p.conf.RTMPEncryption ||
-		newConf.RTMPSAddress != p.conf.RTMPSAddress ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.WriteTimeout != p.conf.WriteTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
-		newConf.RTMPServerCert != p.conf.RTMPServerCert ||
-		newConf.RTMPServerKey != p.conf.RTMPServerKey ||
-		newConf.RTSPAddress != p.conf.RTSPAddress ||
-		newConf.RunOnConnect != p.conf.RunOnConnect ||
-		newConf.RunOnConnectRestart != p.conf.RunOnConnectRestart ||
+		newConf.RTMPDisable != oldConf.RTMPDisable ||
+		newConf.RTMPEncryption != oldConf.RTMPEncryption ||
+		newConf.RTMPSAddress != oldConf.RTMPSAddress ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.WriteTimeout != oldConf.WriteTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
+		newConf.RTMPMaxConnections != oldConf.RTMPMaxConnections ||
+		newConf.RTMPServerCert != oldConf.RTMPServerCert ||
+		newConf.RTMPServerKey != oldConf.RTMPServerKey ||
+		newConf.RTMPClientCA != oldConf.RTMPClientCA ||
+		newConf.RTSPAddress != oldConf.RTSPAddress ||
+		newConf.RunOnConnect != oldConf.RunOnConnect ||
+		newConf.RunOnConnectRestart != oldConf.RunOnConnectRestart ||
+		newConf.RunOnDisconnect != oldConf.RunOnDisconnect ||
+		newConf.OnConnectWebhook != oldConf.OnConnectWebhook ||
+		newConf.OnDisconnectWebhook != oldConf.OnDisconnectWebhook ||
 		closeMetrics ||
 		closePathManager
 
 	closeHLSServer := newConf == nil ||
-		newConf.HLSDisable != p.conf.HLSDisable ||
-		newConf.HLSAddress != p.conf.HLSAddress ||
-		newConf.HLSEncryption != p.conf.HLSEncryption ||
-		newConf.HLSServerKey != p.conf.HLSServerKey ||
-		newConf.HLSServerCert != p.conf.HLSServerCert ||
-		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
-		newConf.HLSAlwaysRemux != p.conf.HLSAlwaysRemux ||
-		newConf.HLSVariant != p.conf.HLSVariant ||
-		newConf.HLSSegmentCount != p.conf.HLSSegmentCount ||
-		newConf.HLSSegmentDuration != p.conf.HLSSegmentDuration ||
-		newConf.HLSPartDuration != p.conf.HLSPartDuration ||
-		newConf.HLSSegmentMaxSize != p.conf.HLSSegmentMaxSize ||
-		newConf.HLSAllowOrigin != p.conf.HLSAllowOrigin ||
-		!reflect.DeepEqual(newConf.HLSTrustedProxies, p.conf.HLSTrustedProxies) ||
-		newConf.HLSDirectory != p.conf.HLSDirectory ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
+		newConf.HLSDisable != oldConf.HLSDisable ||
+		newConf.HLSAddress != oldConf.HLSAddress ||
+		newConf.HLSEncryption != oldConf.HLSEncryption ||
+		newConf.HLSServerKey != oldConf.HLSServerKey ||
+		newConf.HLSServerCert != oldConf.HLSServerCert ||
+		newConf.HLSClientCA != oldConf.HLSClientCA ||
+		newConf.ExternalAuthenticationURL != oldConf.ExternalAuthenticationURL ||
+		newConf.HLSAlwaysRemux != oldConf.HLSAlwaysRemux ||
+		newConf.HLSVariant != oldConf.HLSVariant ||
+		newConf.HLSSegmentCount != oldConf.HLSSegmentCount ||
+		newConf.HLSSegmentDuration != oldConf.HLSSegmentDuration ||
+		newConf.HLSPartDuration != oldConf.HLSPartDuration ||
+		newConf.HLSSegmentMaxSize != oldConf.HLSSegmentMaxSize ||
+		newConf.HLSAllowOrigin != oldConf.HLSAllowOrigin ||
+		!reflect.DeepEqual(newConf.HLSTrustedProxies, oldConf.HLSTrustedProxies) ||
+		newConf.HLSDirectory != oldConf.HLSDirectory ||
+		newConf.HLSMaxConnections != oldConf.HLSMaxConnections ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
 		closePathManager ||
 		closeMetrics
 
 	closeWebRTCServer := newConf == nil ||
-		newConf.WebRTCDisable != p.conf.WebRTCDisable ||
-		newConf.WebRTCAddress != p.conf.WebRTCAddress ||
-		newConf.WebRTCEncryption != p.conf.WebRTCEncryption ||
-		newConf.WebRTCServerKey != p.conf.WebRTCServerKey ||
-		newConf.WebRTCServerCert != p.conf.WebRTCServerCert ||
-		newConf.WebRTCAllowOrigin != p.conf.WebRTCAllowOrigin ||
-		!reflect.DeepEqual(newConf.WebRTCTrustedProxies, p.conf.WebRTCTrustedProxies) ||
-		!reflect.DeepEqual(newConf.WebRTCICEServers, p.conf.WebRTCICEServers) ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
+		newConf.WebRTCDisable != oldConf.WebRTCDisable ||
+		newConf.WebRTCAddress != oldConf.WebRTCAddress ||
+		newConf.WebRTCEncryption != oldConf.WebRTCEncryption ||
+		newConf.WebRTCServerKey != oldConf.WebRTCServerKey ||
+		newConf.WebRTCServerCert != oldConf.WebRTCServerCert ||
+		newConf.WebRTCClientCA != oldConf.WebRTCClientCA ||
+		newConf.WebRTCAllowOrigin != oldConf.WebRTCAllowOrigin ||
+		!reflect.DeepEqual(newConf.WebRTCTrustedProxies, oldConf.WebRTCTrustedProxies) ||
+		!reflect.DeepEqual(newConf.WebRTCICEServers, oldConf.WebRTCICEServers) ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
+		newConf.ReadBufferCount != oldConf.ReadBufferCount ||
 		closeMetrics ||
 		closePathManager ||
-		!reflect.DeepEqual(newConf.WebRTCICEHostNAT1To1IPs, p.conf.WebRTCICEHostNAT1To1IPs) ||
-		newConf.WebRTCICEUDPMuxAddress != p.conf.WebRTCICEUDPMuxAddress ||
-		newConf.WebRTCICETCPMuxAddress != p.conf.WebRTCICETCPMuxAddress
+		!reflect.DeepEqual(newConf.WebRTCICEHostNAT1To1IPs, oldConf.WebRTCICEHostNAT1To1IPs) ||
+		newConf.WebRTCICEUDPMuxAddress != oldConf.WebRTCICEUDPMuxAddress ||
+		newConf.WebRTCICETCPMuxAddress != oldConf.WebRTCICETCPMuxAddress ||
+		newConf.WebRTCMaxConnections != oldConf.WebRTCMaxConnections
 
 	closeAPI := newConf == nil ||
-		newConf.API != p.conf.API ||
-		newConf.APIAddress != p.conf.APIAddress ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
+		newConf.API != oldConf.API ||
+		newConf.APIAddress != oldConf.APIAddress ||
+		newConf.ReadTimeout != oldConf.ReadTimeout ||
 		closePathManager ||
 		closeRTSPServer ||
 		closeRTSPSServer ||
@@ -614,13 +897,39 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeHLSServer ||
 		closeWebRTCServer
 
-	if newConf == nil && p.confWatcher != nil {
+	return confChanges{
+		closeLogger:        closeLogger,
+		closeMetrics:       closeMetrics,
+		closeWebhookSender: closeWebhookSender,
+		closePPROF:         closePPROF,
+		closePathManager:   closePathManager,
+		closeRTSPServer:    closeRTSPServer,
+		closeRTSPSServer:   closeRTSPSServer,
+		closeRTMPServer:    closeRTMPServer,
+		closeRTMPSServer:   closeRTMPSServer,
+		closeHLSServer:     closeHLSServer,
+		closeWebRTCServer:  closeWebRTCServer,
+		closeAPI:           closeAPI,
+	}
+}
+
+func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
+	changes := newConfChanges(p.conf, newConf)
+
+	if !changes.closePathManager && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.pathManager.confReload(newConf.Paths)
+	}
+
+	closeConfWatcher := newConf == nil ||
+		!reflect.DeepEqual(newConf.IncludedPaths(p.confPath), p.conf.IncludedPaths(p.confPath))
+
+	if closeConfWatcher && p.confWatcher != nil {
 		p.confWatcher.Close()
 		p.confWatcher = nil
 	}
 
 	if p.api != nil {
-		if closeAPI {
+		if changes.closeAPI {
 			p.api.close()
 			p.api = nil
 		} else if !calledByAPI { // avoid a loop
@@ -628,51 +937,60 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		}
 	}
 
-	if closeRTSPSServer && p.rtspsServer != nil {
+	if changes.closeRTSPSServer && p.rtspsServer != nil {
 		p.rtspsServer.close()
 		p.rtspsServer = nil
 	}
 
-	if closeRTSPServer && p.rtspServer != nil {
+	if changes.closeRTSPServer && p.rtspServer != nil {
 		p.rtspServer.close()
 		p.rtspServer = nil
 	}
 
-	if closePathManager && p.pathManager != nil {
+	if changes.closePathManager && p.pathManager != nil {
 		p.pathManager.close()
 		p.pathManager = nil
 	}
 
-	if closeWebRTCServer && p.webRTCServer != nil {
+	if changes.closeWebRTCServer && p.webRTCServer != nil {
 		p.webRTCServer.close()
 		p.webRTCServer = nil
 	}
 
-	if closeHLSServer && p.hlsServer != nil {
+	if changes.closeHLSServer && p.hlsServer != nil {
 		p.hlsServer.close()
 		p.hlsServer = nil
 	}
 
-	if closeRTMPSServer && p.rtmpsServer != nil {
+	if changes.closeRTMPSServer && p.rtmpsServer != nil {
 		p.rtmpsServer.close()
 		p.rtmpsServer = nil
 	}
 
-	if closeRTMPServer && p.rtmpServer != nil {
+	if changes.closeRTMPServer && p.rtmpServer != nil {
 		p.rtmpServer.close()
 		p.rtmpServer = nil
 	}
 
-	if closePPROF && p.pprof != nil {
+	if changes.closeWebhookSender && p.webhookSender != nil {
+		p.webhookSender.close()
+		p.webhookSender = nil
+	}
+
+	if changes.closePPROF && p.pprof != nil {
 		p.pprof.close()
 		p.pprof = nil
 	}
 
-	if closeMetrics && p.metrics != nil {
+	if changes.closeMetrics && p.metrics != nil {
 		p.metrics.close()
 		p.metrics = nil
 	}
 
+	if newConf == nil && p.usageMeter != nil {
+		p.usageMeter.close()
+	}
+
 	if newConf == nil && p.externalCmdPool != nil {
 		p.Log(logger.Info, "waiting for external commands")
 		p.externalCmdPool.Close()
@@ -682,7 +1000,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		rpicamera.Cleanup()
 	}
 
-	if closeLogger {
+	if changes.closeLogger {
 		p.logger.Close()
 		p.logger = nil
 	}
@@ -691,13 +1009,72 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 func (p *Core) reloadConf(newConf *conf.Conf, calledByAPI bool) error {
 	p.closeResources(newConf, calledByAPI)
 	p.conf = newConf
-	return p.createResources(false)
+	p.usageMeter.setQuotas(newConf.UsageQuotas)
+
+	err := p.createResources(false)
+	if err != nil {
+		return err
+	}
+
+	p.eventBus.publish(&event{Type: eventTypeConfReload})
+
+	return nil
+}
+
+// persistConf writes a configuration into the configuration file.
+// The watcher is closed during the write, in order not to trigger a reload.
+func (p *Core) persistConf(newConf *conf.Conf) error {
+	if p.confWatcher != nil {
+		p.confWatcher.Close()
+		p.confWatcher = nil
+	}
+
+	watched := p.conf
+
+	err := newConf.Persist(p.confPath)
+	if err == nil {
+		p.confFound = true
+		watched = newConf
+	}
+
+	if p.confFound {
+		var err2 error
+		p.confWatcher, err2 = confwatcher.New(p.confPath, watched.IncludedPaths(p.confPath)...)
+		if err2 != nil {
+			p.Log(logger.Error, "%s", err2)
+		}
+	}
+
+	return err
+}
+
+type coreAPIConfigSetReq struct {
+	conf *conf.Conf
+	res  chan error
 }
 
 // apiConfigSet is called by api.
-func (p *Core) apiConfigSet(conf *conf.Conf) {
+// It returns after the configuration has been persisted,
+// while the configuration is applied asynchronously,
+// since reloading the configuration can cause the shutdown of the API.
+func (p *Core) apiConfigSet(ctx context.Context, conf *conf.Conf) error {
+	req := coreAPIConfigSetReq{
+		conf: conf,
+		res:  make(chan error, 1),
+	}
+
 	select {
-	case p.chAPIConfigSet <- conf:
+	case p.chAPIConfigSet <- req:
+	case <-ctx.Done():
+		return fmt.Errorf("terminated")
 	case <-p.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+
+	select {
+	case err := <-req.res:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("terminated")
 	}
 }
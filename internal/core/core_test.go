@@ -2,8 +2,15 @@ package core
 
 import (
 	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,6 +24,9 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/sdp"
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
 )
 
 var serverCert = []byte(`-----BEGIN CERTIFICATE-----
@@ -355,6 +365,122 @@ func TestCorePathRunOnReady(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestCorePathRunOnNotReady(t *testing.T) {
+	doneFile := filepath.Join(os.TempDir(), "onnotready_done")
+	defer os.Remove(doneFile)
+
+	p, ok := newInstance(fmt.Sprintf("rtmpDisable: yes\n"+
+		"hlsDisable: yes\n"+
+		"webrtcDisable: yes\n"+
+		"paths:\n"+
+		"  test:\n"+
+		"    runOnNotReady: sh -c 'echo $RTSP_PATH $MTX_SOURCE_TYPE > %s'\n",
+		doneFile))
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	c := gortsplib.Client{}
+
+	err := c.StartRecording(
+		"rtsp://localhost:8554/test",
+		media.Medias{testMediaH264})
+	require.NoError(t, err)
+	c.Close()
+
+	for i := 0; ; i++ {
+		byts, err := os.ReadFile(doneFile)
+		if err == nil && len(byts) != 0 {
+			require.Equal(t, "test rtspSession\n", string(byts))
+			break
+		}
+
+		require.Less(t, i, 20)
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestCorePathWebhooks(t *testing.T) {
+	type webhookReq struct {
+		event     string
+		signature string
+		body      []byte
+	}
+
+	reqs := make(chan webhookReq, 10)
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byts, _ := io.ReadAll(r.Body)
+		reqs <- webhookReq{
+			event:     r.Header.Get("X-Webhook-Event"),
+			signature: r.Header.Get("X-Webhook-Signature"),
+			body:      byts,
+		}
+	}))
+	defer hs.Close()
+
+	p, ok := newInstance(fmt.Sprintf("rtmpDisable: yes\n"+
+		"hlsDisable: yes\n"+
+		"webrtcDisable: yes\n"+
+		"webhookSecret: mysecret\n"+
+		"paths:\n"+
+		"  test:\n"+
+		"    onReadyWebhook: %s\n"+
+		"    onNotReadyWebhook: %s\n",
+		hs.URL, hs.URL))
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	c := gortsplib.Client{}
+
+	err := c.StartRecording(
+		"rtsp://localhost:8554/test",
+		media.Medias{testMediaH264})
+	require.NoError(t, err)
+
+	for _, event := range []string{"ready", "notReady"} {
+		req := <-reqs
+		require.Equal(t, event, req.event)
+
+		var body map[string]string
+		err = json.Unmarshal(req.body, &body)
+		require.NoError(t, err)
+		require.Equal(t, "test", body["RTSP_PATH"])
+		require.Equal(t, "8554", body["RTSP_PORT"])
+		require.Equal(t, "rtspSession", body["MTX_SOURCE_TYPE"])
+
+		mac := hmac.New(sha256.New, []byte("mysecret"))
+		mac.Write(req.body)
+		require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), req.signature)
+
+		if event == "ready" {
+			c.Close()
+		}
+	}
+}
+
+type testLogger struct{}
+
+func (testLogger) Log(logger.Level, string, ...interface{}) {}
+
+func TestCoreWebhookSenderClose(t *testing.T) {
+	done := make(chan struct{})
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hs.Close()
+	defer close(done)
+
+	s := newWebhookSender(conf.StringDuration(10*time.Second), 3, "", nil, testLogger{})
+	s.send(hs.URL, "ready", nil)
+
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	s.close()
+	require.Less(t, time.Since(start), 2*time.Second)
+}
+
 func TestCoreHotReloading(t *testing.T) {
 	confPath := filepath.Join(os.TempDir(), "rtsp-conf")
 
@@ -394,3 +520,62 @@ func TestCoreHotReloading(t *testing.T) {
 		defer conn.Close()
 	}()
 }
+
+func TestCoreLogJSON(t *testing.T) {
+	logPath := filepath.Join(os.TempDir(), "rtsp-log.json")
+	defer os.Remove(logPath)
+
+	p, ok := newInstance("logLevel: debug\n" +
+		"logDestinations: [file]\n" +
+		"logFile: " + logPath + "\n" +
+		"logFormat: json\n" +
+		"logTimezone: UTC\n" +
+		"rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+
+	func() {
+		source := gortsplib.Client{}
+		err := source.StartRecording("rtsp://localhost:8554/mypath", media.Medias{testMediaH264})
+		require.NoError(t, err)
+		defer source.Close()
+	}()
+
+	p.Close()
+
+	f, err := os.Open(logPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+
+	find := func(component string, message string) map[string]interface{} {
+		for _, entry := range entries {
+			if entry["component"] == component && entry["message"] == message {
+				return entry
+			}
+		}
+		return nil
+	}
+
+	entry := find("rtsp", "opened")
+	require.NotNil(t, entry)
+	require.Equal(t, "info", entry["level"])
+	require.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`, entry["time"])
+	require.Regexp(t, `^[0-9a-f-]{36}$`, entry["id"])
+	require.Regexp(t, `^127\.0\.0\.1:\d+$`, entry["remoteAddr"])
+
+	entry = find("path", "created")
+	require.NotNil(t, entry)
+	require.Equal(t, "mypath", entry["path"])
+}
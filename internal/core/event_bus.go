@@ -0,0 +1,88 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	eventBusSubscriberQueueSize = 256
+)
+
+type eventType string
+
+const (
+	eventTypePathCreated    eventType = "pathCreated"
+	eventTypePathClosed     eventType = "pathClosed"
+	eventTypeSourceReady    eventType = "sourceReady"
+	eventTypeSourceNotReady eventType = "sourceNotReady"
+	eventTypePathUnhealthy  eventType = "pathUnhealthy"
+	eventTypePathHealthy    eventType = "pathHealthy"
+	eventTypeReaderAdded    eventType = "readerAdded"
+	eventTypeReaderRemoved  eventType = "readerRemoved"
+	eventTypeConnOpened     eventType = "connOpened"
+	eventTypeConnClosed     eventType = "connClosed"
+	eventTypeAuthFailed     eventType = "authFailed"
+	eventTypeConfReload     eventType = "confReload"
+)
+
+type event struct {
+	Type       eventType   `json:"type"`
+	Time       time.Time   `json:"time"`
+	Path       string      `json:"path,omitempty"`
+	Protocol   string      `json:"protocol,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	RemoteAddr string      `json:"remoteAddr,omitempty"`
+	Source     interface{} `json:"source,omitempty"`
+	Reader     interface{} `json:"reader,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Issues     []string    `json:"issues,omitempty"`
+}
+
+// eventBus dispatches server events to subscribers.
+// Events are dropped when a subscriber is too slow to consume them,
+// in order not to block the emitter.
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[chan *event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[chan *event]struct{}),
+	}
+}
+
+// publish is called by any entity that emits events.
+func (b *eventBus) publish(evt *event) {
+	evt.Time = time.Now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+// subscribe is called by api.
+func (b *eventBus) subscribe() chan *event {
+	ch := make(chan *event, eventBusSubscriberQueueSize)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe is called by api.
+func (b *eventBus) unsubscribe(ch chan *event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.subscribers, ch)
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// apiDescribeTypeAndID returns type and ID contained into the output
+// of apiSourceDescribe() or apiReaderDescribe().
+func apiDescribeTypeAndID(d interface{}) (string, string) {
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Struct {
+		return "", ""
+	}
+
+	var typ string
+	if f := v.FieldByName("Type"); f.IsValid() {
+		typ = f.String()
+	}
+
+	var id string
+	if f := v.FieldByName("ID"); f.IsValid() {
+		id = f.String()
+	}
+
+	return typ, id
+}
+
+// externalCmdDuration encodes a duration in seconds, in the format used by external commands.
+func externalCmdDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,747 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	gomp4 "github.com/abema/go-mp4"
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+)
+
+const (
+	// duration of the last unit of a file, when it can't be computed.
+	fileDefaultUnitDuration = 40 * time.Millisecond
+
+	// maximum size of the moov box of MP4 files, that is entirely loaded in memory.
+	fileDemuxerMP4MaxMoovSize = 64 * 1024 * 1024
+
+	// maximum number of samples of a MP4 track.
+	fileDemuxerMP4MaxSamples = 4 * 1024 * 1024
+
+	// maximum size of a MP4 sample.
+	fileDemuxerMP4MaxSampleSize = 16 * 1024 * 1024
+)
+
+// fileUnit is a unit read from a file.
+type fileUnit struct {
+	media *media.Media
+	dts   time.Duration
+	unit  formatprocessor.Unit
+
+	// duration of the unit, when it is stored into the file.
+	duration time.Duration
+}
+
+// fileDemuxer reads units from a MP4 or MPEG-TS file.
+type fileDemuxer interface {
+	close()
+	medias() media.Medias
+
+	// read returns the next unit, or io.EOF when the end of the file is reached.
+	read() (*fileUnit, error)
+
+	// rewind moves the demuxer to the beginning of the file.
+	rewind() error
+}
+
+func openFileDemuxer(fpath string) (fileDemuxer, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var magic [1]byte
+	_, err = io.ReadFull(f, magic[:])
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var d fileDemuxer
+
+	// MPEG-TS packets start with a sync byte
+	if magic[0] == 0x47 {
+		d, err = newFileDemuxerMPEGTS(f)
+	} else {
+		d, err = newFileDemuxerMP4(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// fileTrackEnd keeps track of the end of a track of a file.
+type fileTrackEnd struct {
+	lastDTS  time.Duration
+	duration time.Duration
+}
+
+// end returns the DTS of the unit that would follow the last one of the track.
+func (e *fileTrackEnd) end() time.Duration {
+	if e.duration <= 0 {
+		return e.lastDTS + fileDefaultUnitDuration
+	}
+	return e.lastDTS + e.duration
+}
+
+// update is called for every unit of the track.
+// When the duration of a unit is not stored into the file,
+// it is computed from the difference between the last two units.
+func (e *fileTrackEnd) update(u *fileUnit) {
+	if u.dts > e.lastDTS {
+		e.duration = u.dts - e.lastDTS
+		e.lastDTS = u.dts
+	}
+	if u.duration > 0 {
+		e.duration = u.duration
+	}
+}
+
+// fileDemuxerRun reads units from a demuxer in real time.
+// When loop is true, the file is read again from the beginning once its end is reached,
+// and timestamps keep increasing.
+func fileDemuxerRun(ctx context.Context, d fileDemuxer, loop bool, cb func(*fileUnit)) error {
+	start := time.Now()
+	var loopOffset time.Duration
+	trackEnds := make(map[*media.Media]*fileTrackEnd)
+
+	for {
+		u, err := d.read()
+		if err == io.EOF {
+			if !loop {
+				return err
+			}
+
+			if len(trackEnds) == 0 {
+				return fmt.Errorf("file doesn't contain any unit")
+			}
+
+			// the next loop starts when all tracks have ended
+			var end time.Duration
+			for _, te := range trackEnds {
+				if te.end() > end {
+					end = te.end()
+				}
+			}
+
+			loopOffset += end
+			trackEnds = make(map[*media.Media]*fileTrackEnd)
+
+			err = d.rewind()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		te, ok := trackEnds[u.media]
+		if !ok {
+			te = &fileTrackEnd{lastDTS: u.dts}
+			trackEnds[u.media] = te
+		}
+		te.update(u)
+
+		wait := time.Until(start.Add(loopOffset + u.dts))
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return fmt.Errorf("terminated")
+			}
+		}
+
+		pts, _ := unitPTS(u.unit)
+		unitSetPTS(u.unit, loopOffset+pts)
+
+		cb(u)
+	}
+}
+
+type fileDemuxerMPEGTS struct {
+	f           *os.File
+	dem         *astits.Demuxer
+	meds        media.Medias
+	mediasByPID map[uint16]*mpegtsMedia
+	timedec     *mpegts.TimeDecoder
+	queue       []*fileUnit
+}
+
+func newFileDemuxerMPEGTS(f *os.File) (*fileDemuxerMPEGTS, error) {
+	d := &fileDemuxerMPEGTS{
+		f: f,
+	}
+
+	err := d.rewind()
+	if err != nil {
+		return nil, err
+	}
+
+	tracks, err := mpegts.FindTracks(d.dem)
+	if err != nil {
+		return nil, err
+	}
+
+	d.meds, d.mediasByPID = mpegtsMedias(tracks)
+
+	err = d.rewind()
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *fileDemuxerMPEGTS) close() {
+	d.f.Close()
+}
+
+func (d *fileDemuxerMPEGTS) medias() media.Medias {
+	return d.meds
+}
+
+func (d *fileDemuxerMPEGTS) rewind() error {
+	_, err := d.f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	d.dem = astits.NewDemuxer(context.Background(), d.f, astits.DemuxerOptPacketSize(188))
+	d.timedec = nil
+	d.queue = nil
+	return nil
+}
+
+func (d *fileDemuxerMPEGTS) read() (*fileUnit, error) {
+	for {
+		if len(d.queue) != 0 {
+			u := d.queue[0]
+			d.queue = d.queue[1:]
+			return u, nil
+		}
+
+		data, err := d.dem.NextData()
+		if err != nil {
+			if err == astits.ErrNoMorePackets {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+
+		if data.PES == nil {
+			continue
+		}
+
+		mm, ok := d.mediasByPID[data.PID]
+		if !ok {
+			continue
+		}
+
+		oh := data.PES.Header.OptionalHeader
+		if oh == nil ||
+			oh.PTSDTSIndicator == astits.PTSDTSIndicatorNoPTSOrDTS ||
+			oh.PTSDTSIndicator == astits.PTSDTSIndicatorIsForbidden {
+			return nil, fmt.Errorf("PTS is missing")
+		}
+
+		rawDTS := oh.PTS.Base
+		if oh.PTSDTSIndicator == astits.PTSDTSIndicatorBothPresent {
+			rawDTS = oh.DTS.Base
+		}
+
+		if d.timedec == nil {
+			d.timedec = mpegts.NewTimeDecoder(rawDTS)
+		}
+
+		dts := d.timedec.Decode(rawDTS)
+		pts := d.timedec.Decode(oh.PTS.Base)
+
+		units, err := mm.decode(pts, data.PES.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, unit := range units {
+			d.queue = append(d.queue, &fileUnit{
+				media: mm.media,
+				dts:   dts,
+				unit:  unit,
+			})
+		}
+	}
+}
+
+type fileDemuxerMP4Sample struct {
+	offset    uint64
+	size      uint32
+	dts       int64
+	duration  int64
+	ptsOffset int64
+}
+
+type fileDemuxerMP4Track struct {
+	media     *media.Media
+	timeScale uint32
+	samples   []*fileDemuxerMP4Sample
+	pos       int
+	decode    func(pts time.Duration, data []byte) (formatprocessor.Unit, error)
+}
+
+func (t *fileDemuxerMP4Track) duration(v int64) time.Duration {
+	// avoid an int64 overflow and preserve resolution by splitting division into two parts
+	secs := v / int64(t.timeScale)
+	dec := v % int64(t.timeScale)
+	return time.Duration(secs)*time.Second + time.Duration(dec)*time.Second/time.Duration(t.timeScale)
+}
+
+func (t *fileDemuxerMP4Track) nextDTS() time.Duration {
+	return t.duration(t.samples[t.pos].dts)
+}
+
+func h265ConfigNALU(arrays []gomp4.HEVCNaluArray, typ h265.NALUType) []byte {
+	for _, array := range arrays {
+		if array.NaluType == byte(typ) && len(array.Nalus) != 0 {
+			return array.Nalus[0].NALUnit
+		}
+	}
+	return nil
+}
+
+func mpeg4AudioConfigFromESDS(esds *gomp4.Esds) (*mpeg4audio.Config, error) {
+	for _, desc := range esds.Descriptors {
+		if desc.Tag == gomp4.DecSpecificInfoTag {
+			var conf mpeg4audio.Config
+			err := conf.Unmarshal(desc.Data)
+			if err != nil {
+				return nil, err
+			}
+			return &conf, nil
+		}
+	}
+	return nil, fmt.Errorf("MPEG-4 audio configuration is missing")
+}
+
+func fileDemuxerMP4StblPath(types ...gomp4.BoxType) gomp4.BoxPath {
+	return append(gomp4.BoxPath{gomp4.BoxTypeMdia(), gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl()}, types...)
+}
+
+// fileDemuxerMP4CheckTables checks that the entry count of sample tables is consistent
+// with the size of their box, since the entry count is used to allocate tables.
+func fileDemuxerMP4CheckTables(r io.ReadSeeker, trak *gomp4.BoxInfo) error {
+	entrySizes := map[gomp4.BoxType]uint64{
+		gomp4.BoxTypeStco(): 4,
+		gomp4.BoxTypeCo64(): 8,
+		gomp4.BoxTypeStts(): 8,
+		gomp4.BoxTypeCtts(): 8,
+		gomp4.BoxTypeStsc(): 12,
+		gomp4.BoxTypeStsz(): 4,
+	}
+
+	var paths []gomp4.BoxPath
+	for typ := range entrySizes {
+		paths = append(paths, fileDemuxerMP4StblPath(typ))
+	}
+
+	boxes, err := gomp4.ExtractBoxes(r, trak, paths)
+	if err != nil {
+		return err
+	}
+
+	for _, box := range boxes {
+		_, err := box.SeekToPayload(r)
+		if err != nil {
+			return err
+		}
+
+		// version, flags and entry count
+		// (sample size and sample count in case of stsz)
+		var header [8]byte
+		_, err = io.ReadFull(r, header[:])
+		if err != nil {
+			return err
+		}
+		headerSize := uint64(len(header))
+		count := binary.BigEndian.Uint32(header[4:])
+
+		if box.Type == gomp4.BoxTypeStsz() {
+			// entries are present only when samples don't have a fixed size
+			if count != 0 {
+				continue
+			}
+
+			var buf [4]byte
+			_, err = io.ReadFull(r, buf[:])
+			if err != nil {
+				return err
+			}
+			headerSize += uint64(len(buf))
+			count = binary.BigEndian.Uint32(buf[:])
+		}
+
+		if box.Size < box.HeaderSize+headerSize ||
+			uint64(count)*entrySizes[box.Type] > box.Size-box.HeaderSize-headerSize {
+			return fmt.Errorf("sample table is missing or invalid")
+		}
+	}
+
+	return nil
+}
+
+func newFileDemuxerMP4Track(r io.ReadSeeker, fileSize uint64, trak *gomp4.BoxInfo) (*fileDemuxerMP4Track, error) {
+	stbl := fileDemuxerMP4StblPath
+
+	err := fileDemuxerMP4CheckTables(r, trak)
+	if err != nil {
+		return nil, err
+	}
+
+	bips, err := gomp4.ExtractBoxesWithPayload(r, trak, []gomp4.BoxPath{
+		{gomp4.BoxTypeMdia(), gomp4.BoxTypeMdhd()},
+		stbl(gomp4.BoxTypeStsd(), gomp4.BoxTypeAvc1(), gomp4.BoxTypeAvcC()),
+		stbl(gomp4.BoxTypeStsd(), gomp4.BoxTypeHev1(), gomp4.BoxTypeHvcC()),
+		stbl(gomp4.BoxTypeStsd(), gomp4.BoxTypeHvc1(), gomp4.BoxTypeHvcC()),
+		stbl(gomp4.BoxTypeStsd(), gomp4.BoxTypeMp4a(), gomp4.BoxTypeEsds()),
+		stbl(gomp4.BoxTypeStco()),
+		stbl(gomp4.BoxTypeCo64()),
+		stbl(gomp4.BoxTypeStts()),
+		stbl(gomp4.BoxTypeCtts()),
+		stbl(gomp4.BoxTypeStsc()),
+		stbl(gomp4.BoxTypeStsz()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := &fileDemuxerMP4Track{}
+	var chunkOffsets []uint64
+	var stts *gomp4.Stts
+	var ctts *gomp4.Ctts
+	var stsc *gomp4.Stsc
+	var stsz *gomp4.Stsz
+
+	for _, bip := range bips {
+		switch box := bip.Payload.(type) {
+		case *gomp4.Mdhd:
+			t.timeScale = box.Timescale
+
+		case *gomp4.AVCDecoderConfiguration:
+			forma := &formats.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}
+			if len(box.SequenceParameterSets) != 0 && len(box.PictureParameterSets) != 0 {
+				forma.SPS = box.SequenceParameterSets[0].NALUnit
+				forma.PPS = box.PictureParameterSets[0].NALUnit
+			}
+
+			t.media = &media.Media{
+				Type:    media.TypeVideo,
+				Formats: []formats.Format{forma},
+			}
+			t.decode = func(pts time.Duration, data []byte) (formatprocessor.Unit, error) {
+				au, err := h264.AVCCUnmarshal(data)
+				if err != nil {
+					return nil, err
+				}
+
+				return &formatprocessor.UnitH264{
+					PTS: pts,
+					AU:  au,
+					NTP: time.Now(),
+				}, nil
+			}
+
+		case *gomp4.HvcC:
+			t.media = &media.Media{
+				Type: media.TypeVideo,
+				Formats: []formats.Format{&formats.H265{
+					PayloadTyp: 96,
+					VPS:        h265ConfigNALU(box.NaluArrays, h265.NALUType_VPS_NUT),
+					SPS:        h265ConfigNALU(box.NaluArrays, h265.NALUType_SPS_NUT),
+					PPS:        h265ConfigNALU(box.NaluArrays, h265.NALUType_PPS_NUT),
+				}},
+			}
+			t.decode = func(pts time.Duration, data []byte) (formatprocessor.Unit, error) {
+				au, err := h264.AVCCUnmarshal(data)
+				if err != nil {
+					return nil, err
+				}
+
+				return &formatprocessor.UnitH265{
+					PTS: pts,
+					AU:  au,
+					NTP: time.Now(),
+				}, nil
+			}
+
+		case *gomp4.Esds:
+			conf, err := mpeg4AudioConfigFromESDS(box)
+			if err != nil {
+				return nil, err
+			}
+
+			t.media = &media.Media{
+				Type: media.TypeAudio,
+				Formats: []formats.Format{&formats.MPEG4Audio{
+					PayloadTyp:       96,
+					SizeLength:       13,
+					IndexLength:      3,
+					IndexDeltaLength: 3,
+					Config:           conf,
+				}},
+			}
+			t.decode = func(pts time.Duration, data []byte) (formatprocessor.Unit, error) {
+				return &formatprocessor.UnitMPEG4Audio{
+					PTS: pts,
+					AUs: [][]byte{data},
+					NTP: time.Now(),
+				}, nil
+			}
+
+		case *gomp4.Stco:
+			for _, v := range box.ChunkOffset {
+				chunkOffsets = append(chunkOffsets, uint64(v))
+			}
+
+		case *gomp4.Co64:
+			chunkOffsets = append(chunkOffsets, box.ChunkOffset...)
+
+		case *gomp4.Stts:
+			stts = box
+
+		case *gomp4.Ctts:
+			ctts = box
+
+		case *gomp4.Stsc:
+			stsc = box
+
+		case *gomp4.Stsz:
+			stsz = box
+		}
+	}
+
+	// unsupported codec
+	if t.media == nil {
+		return nil, nil
+	}
+
+	if t.timeScale == 0 || stts == nil || stsc == nil || stsz == nil || chunkOffsets == nil {
+		return nil, fmt.Errorf("sample table is missing or invalid")
+	}
+
+	if stsz.SampleCount > fileDemuxerMP4MaxSamples {
+		return nil, fmt.Errorf("track has too many samples (%d, maximum is %d)",
+			stsz.SampleCount, fileDemuxerMP4MaxSamples)
+	}
+
+	t.samples = make([]*fileDemuxerMP4Sample, stsz.SampleCount)
+	for i := range t.samples {
+		t.samples[i] = &fileDemuxerMP4Sample{
+			size: stsz.SampleSize,
+		}
+		if stsz.SampleSize == 0 && i < len(stsz.EntrySize) {
+			t.samples[i].size = stsz.EntrySize[i]
+		}
+
+		if t.samples[i].size > fileDemuxerMP4MaxSampleSize {
+			return nil, fmt.Errorf("sample size is too big (%d, maximum is %d)",
+				t.samples[i].size, fileDemuxerMP4MaxSampleSize)
+		}
+	}
+
+	i := 0
+	var dts int64
+	for _, entry := range stts.Entries {
+		for j := uint32(0); j < entry.SampleCount && i < len(t.samples); j++ {
+			t.samples[i].dts = dts
+			t.samples[i].duration = int64(entry.SampleDelta)
+			dts += int64(entry.SampleDelta)
+			i++
+		}
+	}
+
+	if ctts != nil {
+		i = 0
+		for ei, entry := range ctts.Entries {
+			for j := uint32(0); j < entry.SampleCount && i < len(t.samples); j++ {
+				t.samples[i].ptsOffset = ctts.GetSampleOffset(ei)
+				i++
+			}
+		}
+	}
+
+	i = 0
+	for ei, entry := range stsc.Entries {
+		if entry.FirstChunk == 0 {
+			return nil, fmt.Errorf("sample table is missing or invalid")
+		}
+
+		end := len(chunkOffsets)
+		if ei != len(stsc.Entries)-1 && int(stsc.Entries[ei+1].FirstChunk)-1 < end {
+			end = int(stsc.Entries[ei+1].FirstChunk) - 1
+		}
+
+		for ci := int(entry.FirstChunk) - 1; ci < end; ci++ {
+			offset := chunkOffsets[ci]
+			for j := uint32(0); j < entry.SamplesPerChunk && i < len(t.samples); j++ {
+				t.samples[i].offset = offset
+				offset += uint64(t.samples[i].size)
+				if offset > fileSize {
+					return nil, fmt.Errorf("sample table is missing or invalid")
+				}
+				i++
+			}
+		}
+	}
+
+	if i != len(t.samples) {
+		return nil, fmt.Errorf("sample table is missing or invalid")
+	}
+
+	return t, nil
+}
+
+type fileDemuxerMP4 struct {
+	f      *os.File
+	meds   media.Medias
+	tracks []*fileDemuxerMP4Track
+}
+
+func newFileDemuxerMP4(f *os.File) (*fileDemuxerMP4, error) {
+	d := &fileDemuxerMP4{
+		f: f,
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := uint64(fi.Size())
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	boxes, err := gomp4.ExtractBoxes(f, nil, []gomp4.BoxPath{
+		{gomp4.BoxTypeMoov()},
+		{gomp4.BoxTypeMoov(), gomp4.BoxTypeMvex()},
+		{gomp4.BoxTypeMoof()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var moov *gomp4.BoxInfo
+
+	for _, box := range boxes {
+		switch box.Type {
+		case gomp4.BoxTypeMvex(), gomp4.BoxTypeMoof():
+			return nil, fmt.Errorf("fragmented MP4 files are not supported")
+
+		case gomp4.BoxTypeMoov():
+			moov = box
+		}
+	}
+
+	if moov == nil {
+		return nil, fmt.Errorf("moov box is missing")
+	}
+
+	if moov.Size > fileDemuxerMP4MaxMoovSize {
+		return nil, fmt.Errorf("moov box is too big (%d, maximum is %d)",
+			moov.Size, fileDemuxerMP4MaxMoovSize)
+	}
+
+	traks, err := gomp4.ExtractBox(f, moov, gomp4.BoxPath{gomp4.BoxTypeTrak()})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trak := range traks {
+		track, err := newFileDemuxerMP4Track(f, fileSize, trak)
+		if err != nil {
+			return nil, err
+		}
+
+		if track != nil && len(track.samples) != 0 {
+			d.tracks = append(d.tracks, track)
+			d.meds = append(d.meds, track.media)
+		}
+	}
+
+	if d.tracks == nil {
+		return nil, fmt.Errorf("no supported tracks found")
+	}
+
+	return d, nil
+}
+
+func (d *fileDemuxerMP4) close() {
+	d.f.Close()
+}
+
+func (d *fileDemuxerMP4) medias() media.Medias {
+	return d.meds
+}
+
+func (d *fileDemuxerMP4) rewind() error {
+	for _, t := range d.tracks {
+		t.pos = 0
+	}
+	return nil
+}
+
+func (d *fileDemuxerMP4) read() (*fileUnit, error) {
+	// read samples of all tracks in decoding order
+	var next *fileDemuxerMP4Track
+	for _, t := range d.tracks {
+		if t.pos < len(t.samples) && (next == nil || t.nextDTS() < next.nextDTS()) {
+			next = t
+		}
+	}
+
+	if next == nil {
+		return nil, io.EOF
+	}
+
+	sample := next.samples[next.pos]
+	next.pos++
+
+	buf := make([]byte, sample.size)
+	_, err := d.f.ReadAt(buf, int64(sample.offset))
+	if err != nil {
+		return nil, err
+	}
+
+	unit, err := next.decode(next.duration(sample.dts+sample.ptsOffset), buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileUnit{
+		media:    next.media,
+		dts:      next.duration(sample.dts),
+		unit:     unit,
+		duration: next.duration(sample.duration),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type fileSourceParent interface {
+	logger.Writer
+	sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes
+	sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq)
+}
+
+type fileSource struct {
+	parent fileSourceParent
+}
+
+func newFileSource(
+	parent fileSourceParent,
+) *fileSource {
+	return &fileSource{
+		parent: parent,
+	}
+}
+
+func (s *fileSource) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[file source] "+format, logger.WithField("source", "file", args)...)
+}
+
+// run implements sourceStaticImpl.
+func (s *fileSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan *conf.PathConf) error {
+	fpath := cnf.Source[len("file://"):]
+
+	s.Log(logger.Debug, "opening %s", fpath)
+
+	demuxer, err := openFileDemuxer(fpath)
+	if err != nil {
+		return err
+	}
+	defer demuxer.close()
+
+	res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
+		medias:             demuxer.medias(),
+		generateRTPPackets: true,
+	})
+	if res.err != nil {
+		return res.err
+	}
+
+	s.Log(logger.Info, "ready: %s", sourceMediaInfo(demuxer.medias()))
+
+	err = fileDemuxerRun(ctx, demuxer, cnf.SourceFileLoop, func(u *fileUnit) {
+		res.stream.writeUnit(u.media, u.media.Formats[0], u.unit)
+	})
+
+	s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
+
+	if err != io.EOF {
+		return err
+	}
+
+	// the file has been played once, do not restart it
+	s.Log(logger.Info, "end of file reached")
+	<-ctx.Done()
+	return fmt.Errorf("terminated")
+}
+
+// apiSourceDescribe implements sourceStaticImpl.
+func (*fileSource) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"fileSource"}
+}
@@ -0,0 +1,373 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gomp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestMPEGTS writes a MPEG-TS file with a H264 track
+// that contains an IDR every 40ms.
+func writeTestMPEGTS(fpath string, count int) error {
+	var buf bytes.Buffer
+	w := mpegts.NewWriter(&mpegts.Track{Codec: &mpegts.CodecH264{}}, nil)
+	w.SetByteWriter(&buf)
+
+	for i := 0; i < count; i++ {
+		ts := time.Duration(i) * 40 * time.Millisecond
+		err := w.WriteH264(ts, ts, ts, true, [][]byte{
+			testFormatH264.SPS,
+			testFormatH264.PPS,
+			{0x05, byte(i)}, // IDR
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(fpath, buf.Bytes(), 0o644)
+}
+
+// writeTestMP4 writes a MP4 file with a H264 track
+// that contains an IDR every 40ms.
+func writeTestMP4(fpath string, count int) error {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := gomp4.NewWriter(f)
+
+	writeBox := func(box gomp4.IImmutableBox, children ...func() error) error {
+		_, err := w.StartBox(&gomp4.BoxInfo{Type: box.GetType()})
+		if err != nil {
+			return err
+		}
+
+		_, err = gomp4.Marshal(w, box, gomp4.Context{})
+		if err != nil {
+			return err
+		}
+
+		for _, child := range children {
+			err := child()
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = w.EndBox()
+		return err
+	}
+
+	var mdat []byte
+	sizes := make([]uint32, count)
+
+	for i := 0; i < count; i++ {
+		enc, err := h264.AVCCMarshal([][]byte{{0x05, byte(i)}}) // IDR
+		if err != nil {
+			return err
+		}
+
+		mdat = append(mdat, enc...)
+		sizes[i] = uint32(len(enc))
+	}
+
+	err = writeBox(&gomp4.Ftyp{
+		MajorBrand: [4]byte{'i', 's', 'o', 'm'},
+		CompatibleBrands: []gomp4.CompatibleBrandElem{
+			{CompatibleBrand: [4]byte{'i', 's', 'o', 'm'}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	mdatOffset, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	err = writeBox(&gomp4.Mdat{Data: mdat})
+	if err != nil {
+		return err
+	}
+
+	sps := testFormatH264.SPS
+	pps := testFormatH264.PPS
+
+	return writeBox(&gomp4.Moov{}, func() error {
+		return writeBox(&gomp4.Trak{}, func() error {
+			return writeBox(&gomp4.Mdia{}, func() error {
+				return writeBox(&gomp4.Mdhd{Timescale: 90000})
+			}, func() error {
+				return writeBox(&gomp4.Minf{}, func() error {
+					return writeBox(&gomp4.Stbl{}, func() error {
+						return writeBox(&gomp4.Stsd{EntryCount: 1}, func() error {
+							return writeBox(&gomp4.VisualSampleEntry{
+								SampleEntry: gomp4.SampleEntry{
+									AnyTypeBox:         gomp4.AnyTypeBox{Type: gomp4.BoxTypeAvc1()},
+									DataReferenceIndex: 1,
+								},
+								Width:           1920,
+								Height:          1080,
+								Horizresolution: 4718592,
+								Vertresolution:  4718592,
+								FrameCount:      1,
+								Depth:           24,
+								PreDefined3:     -1,
+							}, func() error {
+								return writeBox(&gomp4.AVCDecoderConfiguration{
+									AnyTypeBox:                 gomp4.AnyTypeBox{Type: gomp4.BoxTypeAvcC()},
+									ConfigurationVersion:       1,
+									Profile:                    sps[1],
+									ProfileCompatibility:       sps[2],
+									Level:                      sps[3],
+									Reserved:                   63,
+									LengthSizeMinusOne:         3,
+									Reserved2:                  7,
+									NumOfSequenceParameterSets: 1,
+									SequenceParameterSets: []gomp4.AVCParameterSet{
+										{Length: uint16(len(sps)), NALUnit: sps},
+									},
+									NumOfPictureParameterSets: 1,
+									PictureParameterSets: []gomp4.AVCParameterSet{
+										{Length: uint16(len(pps)), NALUnit: pps},
+									},
+								})
+							})
+						})
+					}, func() error {
+						return writeBox(&gomp4.Stts{
+							EntryCount: 1,
+							Entries:    []gomp4.SttsEntry{{SampleCount: uint32(count), SampleDelta: 3600}},
+						})
+					}, func() error {
+						return writeBox(&gomp4.Stsc{
+							EntryCount: 1,
+							Entries: []gomp4.StscEntry{{
+								FirstChunk:             1,
+								SamplesPerChunk:        uint32(count),
+								SampleDescriptionIndex: 1,
+							}},
+						})
+					}, func() error {
+						return writeBox(&gomp4.Stsz{
+							SampleCount: uint32(count),
+							EntrySize:   sizes,
+						})
+					}, func() error {
+						return writeBox(&gomp4.Stco{
+							EntryCount:  1,
+							ChunkOffset: []uint32{uint32(mdatOffset) + 8},
+						})
+					})
+				})
+			})
+		})
+	})
+}
+
+func TestFileSource(t *testing.T) {
+	for _, ca := range []string{
+		"mp4",
+		"mpegts",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			fpath := filepath.Join(t.TempDir(), "test."+ca)
+
+			var err error
+			if ca == "mp4" {
+				err = writeTestMP4(fpath, 5)
+			} else {
+				err = writeTestMPEGTS(fpath, 5)
+			}
+			require.NoError(t, err)
+
+			p, ok := newInstance("rtmpDisable: yes\n" +
+				"hlsDisable: yes\n" +
+				"webrtcDisable: yes\n" +
+				"paths:\n" +
+				"  proxied:\n" +
+				"    source: file://" + fpath + "\n" +
+				"    sourceFileLoop: yes\n")
+			require.Equal(t, true, ok)
+			defer p.Close()
+
+			time.Sleep(500 * time.Millisecond)
+
+			received := make(chan *rtp.Packet, 1024)
+
+			c := gortsplib.Client{
+				Transport: func() *gortsplib.Transport {
+					v := gortsplib.TransportTCP
+					return &v
+				}(),
+			}
+
+			u, err := url.Parse("rtsp://localhost:8554/proxied")
+			require.NoError(t, err)
+
+			err = c.Start(u.Scheme, u.Host)
+			require.NoError(t, err)
+			defer c.Close()
+
+			medias, baseURL, _, err := c.Describe(u)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(medias))
+			require.IsType(t, &formats.H264{}, medias[0].Formats[0])
+
+			err = c.SetupAll(medias, baseURL)
+			require.NoError(t, err)
+
+			c.OnPacketRTP(medias[0], medias[0].Formats[0], func(pkt *rtp.Packet) {
+				received <- pkt
+			})
+
+			_, err = c.Play(nil)
+			require.NoError(t, err)
+
+			// the file is looped and timestamps keep increasing with the same pace
+			var prev *rtp.Packet
+			for i := 0; i < 12; i++ {
+				select {
+				case pkt := <-received:
+					if prev != nil {
+						require.Equal(t, prev.SequenceNumber+1, pkt.SequenceNumber)
+						// timestamps are converted from durations, therefore they can be truncated by a tick
+						require.InDelta(t, 3600, pkt.Timestamp-prev.Timestamp, 1)
+					}
+					prev = pkt
+
+				case <-time.After(2 * time.Second):
+					t.Errorf("packet not received")
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestFileDemuxerMP4Invalid(t *testing.T) {
+	box := func(typ string, payload ...[]byte) []byte {
+		byts := []byte{0, 0, 0, 0, typ[0], typ[1], typ[2], typ[3]}
+		for _, p := range payload {
+			byts = append(byts, p...)
+		}
+		binary.BigEndian.PutUint32(byts, uint32(len(byts)))
+		return byts
+	}
+
+	ftyp := box("ftyp", []byte{'i', 's', 'o', 'm', 0, 0, 0, 0})
+
+	for _, ca := range []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{
+			"fragmented",
+			append(ftyp, box("moov", box("mvex"))...),
+			"fragmented MP4 files are not supported",
+		},
+		{
+			"table too big",
+			append(ftyp, box("moov", box("trak", box("mdia", box("minf", box("stbl",
+				box("stts", []byte{0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF}))))))...),
+			"sample table is missing or invalid",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			fpath := filepath.Join(t.TempDir(), "test.mp4")
+			err := os.WriteFile(fpath, ca.file, 0o644)
+			require.NoError(t, err)
+
+			_, err = openFileDemuxer(fpath)
+			require.EqualError(t, err, ca.err)
+		})
+	}
+}
+
+func TestFileDemuxerRunLoopAV(t *testing.T) {
+	// the audio track ends after the video track
+	var buf bytes.Buffer
+	w := mpegts.NewWriter(
+		&mpegts.Track{Codec: &mpegts.CodecH264{}},
+		&mpegts.Track{Codec: &mpegts.CodecMPEG4Audio{Config: mpeg4audio.Config{
+			Type:         2,
+			SampleRate:   44100,
+			ChannelCount: 2,
+		}}})
+	w.SetByteWriter(&buf)
+
+	for i := 0; i < 5; i++ {
+		ts := time.Duration(i) * 40 * time.Millisecond
+		err := w.WriteH264(ts, ts, ts, true, [][]byte{
+			testFormatH264.SPS,
+			testFormatH264.PPS,
+			{0x05, byte(i)}, // IDR
+		})
+		require.NoError(t, err)
+
+		if i < 5 {
+			ts := time.Duration(i) * 50 * time.Millisecond
+			err = w.WriteAAC(ts, ts, []byte{1, 2, 3, 4})
+			require.NoError(t, err)
+		}
+	}
+
+	fpath := filepath.Join(t.TempDir(), "test.ts")
+	err := os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	d, err := openFileDemuxer(fpath)
+	require.NoError(t, err)
+	defer d.close()
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	ptss := make(map[*media.Media][]time.Duration)
+	count := 0
+
+	fileDemuxerRun(ctx, d, true, func(u *fileUnit) { //nolint:errcheck
+		pts, _ := unitPTS(u.unit)
+		ptss[u.media] = append(ptss[u.media], pts)
+		count++
+		if count == 18 {
+			ctxCancel()
+		}
+	})
+
+	// the next loop starts when all tracks have ended, without overlaps
+	for _, medi := range d.medias() {
+		period := 40 * time.Millisecond
+		if medi.Type == media.TypeAudio {
+			period = 50 * time.Millisecond
+		}
+
+		require.GreaterOrEqual(t, len(ptss[medi]), 6)
+		require.InDelta(t, 250*time.Millisecond, ptss[medi][5], float64(time.Millisecond))
+
+		for i := 1; i < len(ptss[medi]); i++ {
+			require.GreaterOrEqual(t, ptss[medi][i]-ptss[medi][i-1], period-time.Millisecond)
+		}
+	}
+}
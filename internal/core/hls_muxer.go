@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,13 +19,13 @@ import (
 	"github.com/bluenviron/gohlslib/pkg/codecs"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
-	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
 	"github.com/gin-gonic/gin"
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 	"github.com/bluenviron/gohlslib"
 )
 
@@ -40,19 +41,32 @@ var hlsIndex []byte
 type responseWriterWithCounter struct {
 	http.ResponseWriter
 	bytesSent *uint64
+
+	// bytes sent in response to the current request
+	requestBytesSent uint64
 }
 
 func (w *responseWriterWithCounter) Write(p []byte) (int, error) {
 	n, err := w.ResponseWriter.Write(p)
 	atomic.AddUint64(w.bytesSent, uint64(n))
+	w.requestBytesSent += uint64(n)
 	return n, err
 }
 
+// hlsFileContainsFrames checks whether a file served by the muxer is a segment or a part.
+func hlsFileContainsFrames(fname string) bool {
+	fname = filepath.Base(fname)
+	return (strings.HasSuffix(fname, ".ts") || strings.HasSuffix(fname, ".mp4")) && fname != "init.mp4"
+}
+
 type hlsMuxerRequest struct {
 	path     string
 	file     string
 	clientIP string
 	res      chan *hlsMuxer
+
+	// err is filled when the muxer can't serve the request.
+	err error
 }
 
 type hlsMuxerPathManager interface {
@@ -77,18 +91,23 @@ type hlsMuxer struct {
 	readBufferCount           int
 	wg                        *sync.WaitGroup
 	pathName                  string
+	eventBus                  *eventBus
+	usageMeter                *usageMeter
 	pathManager               hlsMuxerPathManager
 	parent                    hlsMuxerParent
 
 	ctx             context.Context
 	ctxCancel       func()
 	created         time.Time
+	span            *telemetry.Span
+	firstFrameSpan  *telemetry.Span
 	path            *path
-	ringBuffer      *ringbuffer.RingBuffer
+	ringBuffer      *readerBuffer
 	lastRequestTime *int64
 	muxer           *gohlslib.Muxer
 	requests        []*hlsMuxerRequest
 	bytesSent       *uint64
+	unitsDropped    *uint64
 
 	// in
 	chRequest          chan *hlsMuxerRequest
@@ -109,6 +128,9 @@ func newHLSMuxer(
 	readBufferCount int,
 	wg *sync.WaitGroup,
 	pathName string,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	pathManager hlsMuxerPathManager,
 	parent hlsMuxerParent,
 ) *hlsMuxer {
@@ -127,6 +149,8 @@ func newHLSMuxer(
 		readBufferCount:           readBufferCount,
 		wg:                        wg,
 		pathName:                  pathName,
+		eventBus:                  eventBus,
+		usageMeter:                usageMeter,
 		pathManager:               pathManager,
 		parent:                    parent,
 		ctx:                       ctx,
@@ -137,10 +161,14 @@ func newHLSMuxer(
 			return &v
 		}(),
 		bytesSent:          new(uint64),
+		unitsDropped:       new(uint64),
 		chRequest:          make(chan *hlsMuxerRequest),
 		chAPIHLSMuxersList: make(chan hlsServerAPIMuxersListSubReq),
 	}
 
+	m.span = tracer.Start("hls muxer", "path", pathName)
+	m.firstFrameSpan = m.span.Child("first frame sent", "path", pathName)
+
 	m.Log(logger.Info, "created %s", func() string {
 		if remoteAddr == "" {
 			return "automatically"
@@ -159,7 +187,8 @@ func (m *hlsMuxer) close() {
 }
 
 func (m *hlsMuxer) Log(level logger.Level, format string, args ...interface{}) {
-	m.parent.Log(level, "[muxer %s] "+format, append([]interface{}{m.pathName}, args...)...)
+	m.parent.Log(level, "[muxer %s] "+format, logger.WithField("path", m.pathName,
+		append([]interface{}{m.pathName}, args...))...)
 }
 
 // PathName returns the path name.
@@ -213,11 +242,7 @@ func (m *hlsMuxer) run() {
 				}
 
 			case req := <-m.chAPIHLSMuxersList:
-				req.data.Items[m.pathName] = hlsServerAPIMuxersListItem{
-					Created:     m.created,
-					LastRequest: time.Unix(0, atomic.LoadInt64(m.lastRequestTime)),
-					BytesSent:   atomic.LoadUint64(m.bytesSent),
-				}
+				req.data.Items[m.pathName] = m.apiItem()
 				close(req.res)
 
 			case <-innerReady:
@@ -232,7 +257,7 @@ func (m *hlsMuxer) run() {
 
 				if m.alwaysRemux {
 					m.Log(logger.Info, "ERR: %v", err)
-					m.clearQueuedRequests()
+					m.clearQueuedRequests(err)
 					isReady = false
 					isRecreating = true
 					recreateTimer = time.NewTimer(hlsMuxerRecreatePause)
@@ -249,15 +274,21 @@ func (m *hlsMuxer) run() {
 
 	m.ctxCancel()
 
-	m.clearQueuedRequests()
+	m.clearQueuedRequests(err)
 
 	m.parent.muxerClose(m)
 
+	m.firstFrameSpan.SetError(fmt.Errorf("no frames have been sent"))
+	m.firstFrameSpan.End()
+	m.span.SetAttribute("closeReason", err.Error())
+	m.span.End()
+
 	m.Log(logger.Info, "destroyed (%v)", err)
 }
 
-func (m *hlsMuxer) clearQueuedRequests() {
+func (m *hlsMuxer) clearQueuedRequests(err error) {
 	for _, req := range m.requests {
+		req.err = err
 		req.res <- nil
 	}
 	m.requests = nil
@@ -279,7 +310,7 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 		m.path.readerRemove(pathReaderRemoveReq{author: m})
 	}()
 
-	m.ringBuffer, _ = ringbuffer.New(uint64(m.readBufferCount))
+	m.ringBuffer, _ = newReaderBuffer(m.readBufferCount, m.unitsDropped)
 
 	var medias media.Medias
 
@@ -343,7 +374,7 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 			if m.remoteAddr != "" {
 				t := time.Unix(0, atomic.LoadInt64(m.lastRequestTime))
 				if time.Since(t) >= closeAfterInactivity {
-					m.ringBuffer.Close()
+					m.ringBuffer.close()
 					<-writerDone
 					return fmt.Errorf("not used anymore")
 				}
@@ -353,7 +384,7 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 			return err
 
 		case <-innerCtx.Done():
-			m.ringBuffer.Close()
+			m.ringBuffer.close()
 			<-writerDone
 			return fmt.Errorf("terminated")
 		}
@@ -369,7 +400,7 @@ func (m *hlsMuxer) createVideoTrack(stream *stream) (*media.Media, *gohlslib.Tra
 		var videoStartPTS time.Duration
 
 		stream.readerAdd(m, videoMedia, videoFormatH265, func(unit formatprocessor.Unit) {
-			m.ringBuffer.Push(func() error {
+			m.ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitH265)
 
 				if tunit.AU == nil {
@@ -410,7 +441,7 @@ func (m *hlsMuxer) createVideoTrack(stream *stream) (*media.Media, *gohlslib.Tra
 		var videoStartPTS time.Duration
 
 		stream.readerAdd(m, videoMedia, videoFormatH264, func(unit formatprocessor.Unit) {
-			m.ringBuffer.Push(func() error {
+			m.ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitH264)
 
 				if tunit.AU == nil {
@@ -454,7 +485,7 @@ func (m *hlsMuxer) createAudioTrack(stream *stream) (*media.Media, *gohlslib.Tra
 		var audioStartPTS time.Duration
 
 		stream.readerAdd(m, audioMedia, audioFormatMPEG4Audio, func(unit formatprocessor.Unit) {
-			m.ringBuffer.Push(func() error {
+			m.ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitMPEG4Audio)
 
 				if tunit.AUs == nil {
@@ -497,7 +528,7 @@ func (m *hlsMuxer) createAudioTrack(stream *stream) (*media.Media, *gohlslib.Tra
 		var audioStartPTS time.Duration
 
 		stream.readerAdd(m, audioMedia, audioFormatOpus, func(unit formatprocessor.Unit) {
-			m.ringBuffer.Push(func() error {
+			m.ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitOpus)
 
 				if !audioStartPTSFilled {
@@ -535,7 +566,7 @@ func (m *hlsMuxer) createAudioTrack(stream *stream) (*media.Media, *gohlslib.Tra
 
 func (m *hlsMuxer) runWriter() error {
 	for {
-		item, ok := m.ringBuffer.Pull()
+		item, ok := m.ringBuffer.pull()
 		if !ok {
 			return fmt.Errorf("terminated")
 		}
@@ -557,19 +588,22 @@ func (m *hlsMuxer) handleRequest(ctx *gin.Context) {
 
 	user, pass, hasCredentials := ctx.Request.BasicAuth()
 
-	err := authenticate(
+	credentials := authCredentials{
+		query: ctx.Request.URL.RawQuery,
+		ip:    net.ParseIP(ctx.ClientIP()),
+		user:  user,
+		pass:  pass,
+		proto: authProtocolHLS,
+		cert:  tlsPeerCertificate(ctx.Request.TLS),
+	}
+
+	user, err := authenticate(
 		m.externalAuthenticationURL,
 		nil,
 		m.pathName,
 		m.path.safeConf(),
 		false,
-		authCredentials{
-			query: ctx.Request.URL.RawQuery,
-			ip:    net.ParseIP(ctx.ClientIP()),
-			user:  user,
-			pass:  pass,
-			proto: authProtocolHLS,
-		},
+		credentials,
 	)
 	if err != nil {
 		if !hasCredentials {
@@ -579,10 +613,30 @@ func (m *hlsMuxer) handleRequest(ctx *gin.Context) {
 		}
 
 		m.Log(logger.Info, "authentication error: %s", err)
+
+		m.eventBus.publish(&event{
+			Type:       eventTypeAuthFailed,
+			Path:       m.pathName,
+			Protocol:   "hls",
+			RemoteAddr: ctx.ClientIP(),
+			Error:      err.Error(),
+		})
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
+	// quotas are enforced on verified users only
+	err = m.usageMeter.check(user)
+	if err != nil {
+		m.Log(logger.Info, "request rejected since %v", err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	defer func() {
+		m.usageMeter.add(user, w.requestBytesSent)
+	}()
+
 	if ctx.Request.URL.Path == "" {
 		ctx.Header("Content-Type", `text/html`)
 		w.WriteHeader(http.StatusOK)
@@ -591,6 +645,10 @@ func (m *hlsMuxer) handleRequest(ctx *gin.Context) {
 	}
 
 	m.muxer.Handle(w, ctx.Request)
+
+	if ctx.Writer.Status() == http.StatusOK && hlsFileContainsFrames(ctx.Request.URL.Path) {
+		m.firstFrameSpan.End()
+	}
 }
 
 // processRequest is called by hlsserver.Server (forwarded from ServeHTTP).
@@ -602,6 +660,15 @@ func (m *hlsMuxer) processRequest(req *hlsMuxerRequest) {
 	}
 }
 
+func (m *hlsMuxer) apiItem() hlsServerAPIMuxersListItem {
+	return hlsServerAPIMuxersListItem{
+		Created:      m.created,
+		LastRequest:  time.Unix(0, atomic.LoadInt64(m.lastRequestTime)),
+		BytesSent:    atomic.LoadUint64(m.bytesSent),
+		UnitsDropped: atomic.LoadUint64(m.unitsDropped),
+	}
+}
+
 // apiMuxersList is called by api.
 func (m *hlsMuxer) apiMuxersList(req hlsServerAPIMuxersListSubReq) {
 	req.res = make(chan struct{})
@@ -613,6 +680,11 @@ func (m *hlsMuxer) apiMuxersList(req hlsServerAPIMuxersListSubReq) {
 	}
 }
 
+// protocol implements reader.
+func (m *hlsMuxer) protocol() string {
+	return "hls"
+}
+
 // apiReaderDescribe implements reader.
 func (m *hlsMuxer) apiReaderDescribe() interface{} {
 	return struct {
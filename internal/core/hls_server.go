@@ -16,6 +16,7 @@ import (
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 type nilWriter struct{}
@@ -25,9 +26,10 @@ func (nilWriter) Write(p []byte) (int, error) {
 }
 
 type hlsServerAPIMuxersListItem struct {
-	Created     time.Time `json:"created"`
-	LastRequest time.Time `json:"lastRequest"`
-	BytesSent   uint64    `json:"bytesSent"`
+	Created      time.Time `json:"created"`
+	LastRequest  time.Time `json:"lastRequest"`
+	BytesSent    uint64    `json:"bytesSent"`
+	UnitsDropped uint64    `json:"unitsDropped"`
 }
 
 type hlsServerAPIMuxersListData struct {
@@ -49,6 +51,16 @@ type hlsServerAPIMuxersListSubReq struct {
 	res  chan struct{}
 }
 
+type hlsServerAPIMuxersGetRes struct {
+	data *hlsServerAPIMuxersListItem
+	err  error
+}
+
+type hlsServerAPIMuxersGetReq struct {
+	name string
+	res  chan *hlsMuxer
+}
+
 type hlsServerParent interface {
 	logger.Writer
 }
@@ -64,16 +76,22 @@ type hlsServer struct {
 	allowOrigin               string
 	directory                 string
 	readBufferCount           int
+	maxConnections            int
 	pathManager               *pathManager
 	metrics                   *metrics
+	tracer                    *telemetry.Tracer
+	eventBus                  *eventBus
+	usageMeter                *usageMeter
 	parent                    hlsServerParent
 
-	ctx        context.Context
-	ctxCancel  func()
-	wg         sync.WaitGroup
-	ln         net.Listener
-	httpServer *http.Server
-	muxers     map[string]*hlsMuxer
+	ctx         context.Context
+	ctxCancel   func()
+	wg          sync.WaitGroup
+	ln          net.Listener
+	certLoader  *certLoader
+	connLimiter *httpConnLimiter
+	httpServer  *http.Server
+	muxers      map[string]*hlsMuxer
 
 	// in
 	chPathSourceReady    chan *path
@@ -81,6 +99,7 @@ type hlsServer struct {
 	request              chan *hlsMuxerRequest
 	chMuxerClose         chan *hlsMuxer
 	chAPIMuxerList       chan hlsServerAPIMuxersListReq
+	chAPIMuxerGet        chan hlsServerAPIMuxersGetReq
 }
 
 func newHLSServer(
@@ -89,6 +108,7 @@ func newHLSServer(
 	encryption bool,
 	serverKey string,
 	serverCert string,
+	clientCA string,
 	externalAuthenticationURL string,
 	alwaysRemux bool,
 	variant conf.HLSVariant,
@@ -101,8 +121,12 @@ func newHLSServer(
 	directory string,
 	readTimeout conf.StringDuration,
 	readBufferCount int,
+	maxConnections int,
 	pathManager *pathManager,
 	metrics *metrics,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	parent hlsServerParent,
 ) (*hlsServer, error) {
 	ln, err := net.Listen(restrictNetwork("tcp", address))
@@ -110,19 +134,6 @@ func newHLSServer(
 		return nil, err
 	}
 
-	var tlsConfig *tls.Config
-	if encryption {
-		crt, err := tls.LoadX509KeyPair(serverCert, serverKey)
-		if err != nil {
-			ln.Close()
-			return nil, err
-		}
-
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{crt},
-		}
-	}
-
 	ctx, ctxCancel := context.WithCancel(parentCtx)
 
 	s := &hlsServer{
@@ -136,9 +147,13 @@ func newHLSServer(
 		allowOrigin:               allowOrigin,
 		directory:                 directory,
 		readBufferCount:           readBufferCount,
+		maxConnections:            maxConnections,
 		pathManager:               pathManager,
 		parent:                    parent,
 		metrics:                   metrics,
+		tracer:                    tracer,
+		eventBus:                  eventBus,
+		usageMeter:                usageMeter,
 		ctx:                       ctx,
 		ctxCancel:                 ctxCancel,
 		ln:                        ln,
@@ -148,18 +163,42 @@ func newHLSServer(
 		request:                   make(chan *hlsMuxerRequest),
 		chMuxerClose:              make(chan *hlsMuxer),
 		chAPIMuxerList:            make(chan hlsServerAPIMuxersListReq),
+		chAPIMuxerGet:             make(chan hlsServerAPIMuxersGetReq),
+	}
+
+	var tlsConfig *tls.Config
+	if encryption {
+		s.certLoader, err = newCertLoader(serverCert, serverKey, s)
+		if err != nil {
+			ctxCancel()
+			ln.Close()
+			return nil, err
+		}
+
+		tlsConfig, err = newServerTLSConfig(s.certLoader, clientCA)
+		if err != nil {
+			s.certLoader.close()
+			ctxCancel()
+			ln.Close()
+			return nil, err
+		}
 	}
 
 	router := gin.New()
 	httpSetTrustedProxies(router, trustedProxies)
 
-	router.NoRoute(httpLoggerMiddleware(s), httpServerHeaderMiddleware, s.onRequest)
+	s.connLimiter = newHTTPConnLimiter(maxConnections, s)
+
+	router.NoRoute(httpLoggerMiddleware(s), httpServerHeaderMiddleware, s.connLimiter.mw, s.onRequest)
 
 	s.httpServer = &http.Server{
 		Handler:           router,
 		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: time.Duration(readTimeout),
+		IdleTimeout:       time.Duration(readTimeout),
 		ErrorLog:          log.New(&nilWriter{}, "", 0),
+		ConnContext:       s.connLimiter.connContext,
+		ConnState:         s.connLimiter.connState,
 	}
 
 	s.Log(logger.Info, "listener opened on "+address)
@@ -178,13 +217,17 @@ func newHLSServer(
 
 // Log is the main logging function.
 func (s *hlsServer) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[HLS] "+format, append([]interface{}{}, args...)...)
+	s.parent.Log(level, "[HLS] "+format, logger.WithField("component", "hls", args)...)
 }
 
 func (s *hlsServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	s.wg.Wait()
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *hlsServer) run() {
@@ -244,6 +287,9 @@ outer:
 				muxers: muxers,
 			}
 
+		case req := <-s.chAPIMuxerGet:
+			req.res <- s.muxers[req.name]
+
 		case <-s.ctx.Done():
 			break outer
 		}
@@ -321,6 +367,11 @@ func (s *hlsServer) onRequest(ctx *gin.Context) {
 		if muxer != nil {
 			ctx.Request.URL.Path = fname
 			muxer.handleRequest(ctx)
+			return
+		}
+
+		if _, ok := hreq.err.(pathErrTooManyReaders); ok {
+			ctx.Writer.WriteHeader(http.StatusServiceUnavailable)
 		}
 
 	case <-s.ctx.Done():
@@ -342,6 +393,9 @@ func (s *hlsServer) createMuxer(pathName string, remoteAddr string) *hlsMuxer {
 		s.readBufferCount,
 		&s.wg,
 		pathName,
+		s.tracer,
+		s.eventBus,
+		s.usageMeter,
 		s.pathManager,
 		s)
 	s.muxers[pathName] = r
@@ -396,3 +450,25 @@ func (s *hlsServer) apiMuxersList() hlsServerAPIMuxersListRes {
 		return hlsServerAPIMuxersListRes{err: fmt.Errorf("terminated")}
 	}
 }
+
+// apiMuxersGet is called by api.
+func (s *hlsServer) apiMuxersGet(name string) hlsServerAPIMuxersGetRes {
+	req := hlsServerAPIMuxersGetReq{
+		name: name,
+		res:  make(chan *hlsMuxer),
+	}
+
+	select {
+	case s.chAPIMuxerGet <- req:
+		m := <-req.res
+		if m == nil {
+			return hlsServerAPIMuxersGetRes{err: apiErrNotFound{"muxer", name}}
+		}
+
+		item := m.apiItem()
+		return hlsServerAPIMuxersGetRes{data: &item}
+
+	case <-s.ctx.Done():
+		return hlsServerAPIMuxersGetRes{err: fmt.Errorf("terminated")}
+	}
+}
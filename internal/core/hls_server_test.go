@@ -114,6 +114,68 @@ func TestHLSServerNotFound(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, res.StatusCode)
 }
 
+func TestHLSServerMaxConnections(t *testing.T) {
+	p, ok := newInstance("hlsMaxConnections: 1\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	// each client uses a dedicated connection, that is kept open after the request
+	var statusCodes []int
+
+	for i := 0; i < 2; i++ {
+		tr := &http.Transport{}
+		defer tr.CloseIdleConnections()
+		hc := &http.Client{Transport: tr}
+
+		res, err := hc.Get("http://127.0.0.1:8888/stream/")
+		require.NoError(t, err)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		statusCodes = append(statusCodes, res.StatusCode)
+	}
+
+	require.Equal(t, []int{http.StatusNotFound, http.StatusTooManyRequests}, statusCodes)
+}
+
+func TestHLSServerMaxConnectionsIdle(t *testing.T) {
+	p, ok := newInstance("readTimeout: 1s\n" +
+		"hlsMaxConnections: 1\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	get := func() int {
+		tr := &http.Transport{}
+		defer tr.CloseIdleConnections()
+		hc := &http.Client{Transport: tr}
+
+		res, err := hc.Get("http://127.0.0.1:8888/stream/")
+		require.NoError(t, err)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		return res.StatusCode
+	}
+
+	// the connection is kept open after the request, without being used
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	res, err := hc.Get("http://127.0.0.1:8888/stream/")
+	require.NoError(t, err)
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	require.Equal(t, http.StatusTooManyRequests, get())
+
+	// the idle connection is closed by the server and frees its slot
+	time.Sleep(1500 * time.Millisecond)
+
+	require.Equal(t, http.StatusNotFound, get())
+}
+
 func TestHLSServer(t *testing.T) {
 	p, ok := newInstance("hlsAlwaysRemux: yes\n" +
 		"paths:\n" +
@@ -39,7 +39,7 @@ func newHLSSource(
 }
 
 func (s *hlsSource) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[hls source] "+format, args...)
+	s.parent.Log(level, "[hls source] "+format, logger.WithField("source", "hls", args)...)
 }
 
 // run implements sourceStaticImpl.
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type httpConnLimiterCtxKey struct{}
+
+type httpConnLimiterParent interface {
+	logger.Writer
+}
+
+// httpConnLimiter limits the number of connections of a HTTP server.
+// Requests received from connections that exceed the limit are rejected with 429.
+type httpConnLimiter struct {
+	maxConnections int
+	parent         httpConnLimiterParent
+
+	mutex    sync.Mutex
+	accepted map[net.Conn]struct{}
+}
+
+func newHTTPConnLimiter(maxConnections int, parent httpConnLimiterParent) *httpConnLimiter {
+	return &httpConnLimiter{
+		maxConnections: maxConnections,
+		parent:         parent,
+		accepted:       make(map[net.Conn]struct{}),
+	}
+}
+
+// connContext is called by http.Server when a connection is accepted.
+func (l *httpConnLimiter) connContext(ctx context.Context, c net.Conn) context.Context {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	rejected := l.maxConnections != 0 && len(l.accepted) >= l.maxConnections
+	if !rejected {
+		l.accepted[c] = struct{}{}
+	}
+
+	return context.WithValue(ctx, httpConnLimiterCtxKey{}, rejected)
+}
+
+// connState is called by http.Server when the state of a connection changes.
+func (l *httpConnLimiter) connState(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		l.mutex.Lock()
+		delete(l.accepted, c)
+		l.mutex.Unlock()
+	}
+}
+
+func (l *httpConnLimiter) mw(ctx *gin.Context) {
+	if rejected, _ := ctx.Request.Context().Value(httpConnLimiterCtxKey{}).(bool); rejected {
+		l.parent.Log(logger.Warn, "[conn %v] request rejected since the maximum number of connections (%d) has been reached",
+			ctx.Request.RemoteAddr, l.maxConnections)
+		ctx.Header("Connection", "close")
+		ctx.AbortWithStatus(http.StatusTooManyRequests)
+		return
+	}
+
+	ctx.Next()
+}
@@ -2,56 +2,100 @@ package core
 
 import (
 	"context"
-	"io"
 	"log"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
-func metric(key string, tags string, value int64) string {
-	return key + tags + " " + strconv.FormatInt(value, 10) + "\n"
-}
-
 type metricsParent interface {
 	logger.Writer
 }
 
+// metrics collects metrics into a registry, that is exposed in the Prometheus format
+// and/or sent to an OpenTelemetry collector together with traces.
 type metrics struct {
-	parent metricsParent
-
-	ln           net.Listener
-	httpServer   *http.Server
-	mutex        sync.Mutex
-	pathManager  apiPathManager
-	rtspServer   apiRTSPServer
-	rtspsServer  apiRTSPServer
-	rtmpServer   apiRTMPServer
-	hlsServer    apiHLSServer
-	webRTCServer apiWebRTCServer
+	tracer     *telemetry.Tracer
+	usageMeter *usageMeter
+	parent     metricsParent
+
+	registry      *telemetry.Registry
+	ln            net.Listener
+	httpServer    *http.Server
+	otlpExporter  *telemetry.OTLPExporter
+	mutex         sync.Mutex
+	pathManager   apiPathManager
+	rtspServer    apiRTSPServer
+	rtspsServer   apiRTSPServer
+	rtmpServer    apiRTMPServer
+	hlsServer     apiHLSServer
+	webRTCServer  apiWebRTCServer
+	webhookSender *webhookSender
 }
 
+// newMetrics allocates a metrics.
+// An empty address disables the Prometheus listener,
+// an empty otlpEndpoint disables the OTLP exporter.
 func newMetrics(
 	address string,
 	readTimeout conf.StringDuration,
+	otlpEndpoint string,
+	otlpInterval conf.StringDuration,
+	otlpHeaders map[string]string,
+	tracer *telemetry.Tracer,
+	usageMeter *usageMeter,
 	parent metricsParent,
 ) (*metrics, error) {
+	m := &metrics{
+		tracer:     tracer,
+		usageMeter: usageMeter,
+		parent:     parent,
+		registry:   telemetry.NewRegistry(),
+	}
+
+	m.registry.Register(m.collect)
+
+	if address != "" {
+		err := m.openListener(address, readTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if otlpEndpoint != "" {
+		m.otlpExporter = telemetry.NewOTLPExporter(
+			otlpEndpoint,
+			otlpHeaders,
+			time.Duration(otlpInterval),
+			m.registry,
+			version,
+			m,
+		)
+		m.tracer.SetExporter(m.otlpExporter)
+
+		m.Log(logger.Info, "exporting metrics and traces to "+otlpEndpoint)
+	}
+
+	return m, nil
+}
+
+func (m *metrics) openListener(address string, readTimeout conf.StringDuration) error {
 	ln, err := net.Listen(restrictNetwork("tcp", address))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	m := &metrics{
-		parent: parent,
-		ln:     ln,
-	}
+	m.ln = ln
 
 	router := gin.New()
 	router.SetTrustedProxies(nil)
@@ -70,22 +114,33 @@ func newMetrics(
 
 	go m.httpServer.Serve(m.ln)
 
-	return m, nil
+	return nil
 }
 
 func (m *metrics) close() {
-	m.Log(logger.Info, "listener is closing")
-	m.httpServer.Shutdown(context.Background())
-	m.ln.Close() // in case Shutdown() is called before Serve()
+	if m.otlpExporter != nil {
+		m.tracer.SetExporter(nil)
+		m.otlpExporter.Close()
+	}
+
+	if m.ln != nil {
+		m.Log(logger.Info, "listener is closing")
+		m.httpServer.Shutdown(context.Background())
+		m.ln.Close() // in case Shutdown() is called before Serve()
+	}
 }
 
 func (m *metrics) Log(level logger.Level, format string, args ...interface{}) {
-	m.parent.Log(level, "[metrics] "+format, args...)
+	m.parent.Log(level, "[metrics] "+format, logger.WithField("component", "metrics", args)...)
 }
 
 func (m *metrics) onMetrics(ctx *gin.Context) {
-	out := ""
+	ctx.Writer.WriteHeader(http.StatusOK)
+	m.registry.WritePrometheus(ctx.Writer) //nolint:errcheck
+}
 
+// collect is called by the registry.
+func (m *metrics) collect(b *telemetry.Batch) {
 	res := m.pathManager.apiPathsList()
 	if res.err == nil && len(res.data.Items) != 0 {
 		for name, i := range res.data.Items {
@@ -96,109 +151,74 @@ func (m *metrics) onMetrics(ctx *gin.Context) {
 				state = "notReady"
 			}
 
-			tags := "{name=\"" + name + "\",state=\"" + state + "\"}"
-			out += metric("paths", tags, 1)
-			out += metric("paths_bytes_received", tags, int64(i.BytesReceived))
+			b.Gauge("paths", 1, "name", name, "state", state)
+			b.Counter("paths_bytes_received", float64(i.BytesReceived), "name", name, "state", state)
+			b.Counter("paths_packets_lost", float64(i.PacketsLost), "name", name, "state", state)
+			b.Counter("paths_decode_errors", float64(i.DecodeErrors), "name", name, "state", state)
+
+			readers := i.readersByProtocol
+			protocols := make([]string, 0, len(readers))
+			for protocol := range readers {
+				protocols = append(protocols, protocol)
+			}
+			sort.Strings(protocols)
+
+			for _, protocol := range protocols {
+				b.Gauge("paths_readers", float64(readers[protocol]), "name", name, "protocol", protocol)
+			}
+
+			for _, ts := range i.TracksStats {
+				tags := []string{"path", name, "track", strconv.FormatInt(int64(ts.Track), 10), "codec", ts.Codec}
+				b.Counter("tracks_bytes_received", float64(ts.BytesReceived), tags...)
+				b.Counter("tracks_frames", float64(ts.Frames), tags...)
+				b.Counter("tracks_key_frames", float64(ts.KeyFrames), tags...)
+				b.Gauge("tracks_fps", ts.FPS, tags...)
+				b.Gauge("tracks_bitrate", float64(ts.Bitrate), tags...)
+				b.Gauge("tracks_gop_duration_seconds", ts.GOPDuration, tags...)
+				b.Counter("tracks_timestamp_discontinuities", float64(ts.TimestampDiscontinuities), tags...)
+			}
 		}
 	} else {
-		out += metric("paths", "", 0)
+		b.Gauge("paths", 0)
 	}
 
 	if !interfaceIsEmpty(m.hlsServer) {
 		res := m.hlsServer.apiMuxersList()
 		if res.err == nil && len(res.data.Items) != 0 {
 			for name, i := range res.data.Items {
-				tags := "{name=\"" + name + "\"}"
-				out += metric("hls_muxers", tags, 1)
-				out += metric("hls_muxers_bytes_sent", tags, int64(i.BytesSent))
+				b.Gauge("hls_muxers", 1, "name", name)
+				b.Counter("hls_muxers_bytes_sent", float64(i.BytesSent), "name", name)
+				b.Counter("hls_muxers_units_dropped", float64(i.UnitsDropped), "name", name)
 			}
 		} else {
-			out += metric("hls_muxers", "", 0)
-			out += metric("hls_muxers_bytes_sent", "", 0)
+			b.Gauge("hls_muxers", 0)
+			b.Counter("hls_muxers_bytes_sent", 0)
+			b.Counter("hls_muxers_units_dropped", 0)
 		}
 	}
 
-	if !interfaceIsEmpty(m.rtspServer) { //nolint:dupl
-		func() {
-			res := m.rtspServer.apiConnsList()
-			if res.err == nil && len(res.data.Items) != 0 {
-				for id, i := range res.data.Items {
-					tags := "{id=\"" + id + "\"}"
-					out += metric("rtsp_conns", tags, 1)
-					out += metric("rtsp_conns_bytes_received", tags, int64(i.BytesReceived))
-					out += metric("rtsp_conns_bytes_sent", tags, int64(i.BytesSent))
-				}
-			} else {
-				out += metric("rtsp_conns", "", 0)
-				out += metric("rtsp_conns_bytes_received", "", 0)
-				out += metric("rtsp_conns_bytes_sent", "", 0)
-			}
-		}()
-
-		func() {
-			res := m.rtspServer.apiSessionsList()
-			if res.err == nil && len(res.data.Items) != 0 {
-				for id, i := range res.data.Items {
-					tags := "{id=\"" + id + "\",state=\"" + i.State + "\"}"
-					out += metric("rtsp_sessions", tags, 1)
-					out += metric("rtsp_sessions_bytes_received", tags, int64(i.BytesReceived))
-					out += metric("rtsp_sessions_bytes_sent", tags, int64(i.BytesSent))
-				}
-			} else {
-				out += metric("rtsp_sessions", "", 0)
-				out += metric("rtsp_sessions_bytes_received", "", 0)
-				out += metric("rtsp_sessions_bytes_sent", "", 0)
-			}
-		}()
+	if !interfaceIsEmpty(m.rtspServer) {
+		collectRTSP(b, "rtsp", m.rtspServer)
 	}
 
-	if !interfaceIsEmpty(m.rtspsServer) { //nolint:dupl
-		func() {
-			res := m.rtspsServer.apiConnsList()
-			if res.err == nil && len(res.data.Items) != 0 {
-				for id, i := range res.data.Items {
-					tags := "{id=\"" + id + "\"}"
-					out += metric("rtsps_conns", tags, 1)
-					out += metric("rtsps_conns_bytes_received", tags, int64(i.BytesReceived))
-					out += metric("rtsps_conns_bytes_sent", tags, int64(i.BytesSent))
-				}
-			} else {
-				out += metric("rtsps_conns", "", 0)
-				out += metric("rtsps_conns_bytes_received", "", 0)
-				out += metric("rtsps_conns_bytes_sent", "", 0)
-			}
-		}()
-
-		func() {
-			res := m.rtspsServer.apiSessionsList()
-			if res.err == nil && len(res.data.Items) != 0 {
-				for id, i := range res.data.Items {
-					tags := "{id=\"" + id + "\",state=\"" + i.State + "\"}"
-					out += metric("rtsps_sessions", tags, 1)
-					out += metric("rtsps_sessions_bytes_received", tags, int64(i.BytesReceived))
-					out += metric("rtsps_sessions_bytes_sent", tags, int64(i.BytesSent))
-				}
-			} else {
-				out += metric("rtsps_sessions", "", 0)
-				out += metric("rtsps_sessions_bytes_received", "", 0)
-				out += metric("rtsps_sessions_bytes_sent", "", 0)
-			}
-		}()
+	if !interfaceIsEmpty(m.rtspsServer) {
+		collectRTSP(b, "rtsps", m.rtspsServer)
 	}
 
 	if !interfaceIsEmpty(m.rtmpServer) {
 		res := m.rtmpServer.apiConnsList()
 		if res.err == nil && len(res.data.Items) != 0 {
 			for id, i := range res.data.Items {
-				tags := "{id=\"" + id + "\",state=\"" + i.State + "\"}"
-				out += metric("rtmp_conns", tags, 1)
-				out += metric("rtmp_conns_bytes_received", tags, int64(i.BytesReceived))
-				out += metric("rtmp_conns_bytes_sent", tags, int64(i.BytesSent))
+				b.Gauge("rtmp_conns", 1, "id", id, "state", i.State)
+				b.Counter("rtmp_conns_bytes_received", float64(i.BytesReceived), "id", id, "state", i.State)
+				b.Counter("rtmp_conns_bytes_sent", float64(i.BytesSent), "id", id, "state", i.State)
+				b.Counter("rtmp_conns_units_dropped", float64(i.UnitsDropped), "id", id, "state", i.State)
 			}
 		} else {
-			out += metric("rtmp_conns", "", 0)
-			out += metric("rtmp_conns_bytes_received", "", 0)
-			out += metric("rtmp_conns_bytes_sent", "", 0)
+			b.Gauge("rtmp_conns", 0)
+			b.Counter("rtmp_conns_bytes_received", 0)
+			b.Counter("rtmp_conns_bytes_sent", 0)
+			b.Counter("rtmp_conns_units_dropped", 0)
 		}
 	}
 
@@ -206,20 +226,67 @@ func (m *metrics) onMetrics(ctx *gin.Context) {
 		res := m.webRTCServer.apiConnsList()
 		if res.err == nil && len(res.data.Items) != 0 {
 			for id, i := range res.data.Items {
-				tags := "{id=\"" + id + "\"}"
-				out += metric("webrtc_conns", tags, 1)
-				out += metric("webrtc_conns_bytes_received", tags, int64(i.BytesReceived))
-				out += metric("webrtc_conns_bytes_sent", tags, int64(i.BytesSent))
+				b.Gauge("webrtc_conns", 1, "id", id)
+				b.Counter("webrtc_conns_bytes_received", float64(i.BytesReceived), "id", id)
+				b.Counter("webrtc_conns_bytes_sent", float64(i.BytesSent), "id", id)
+				b.Counter("webrtc_conns_units_dropped", float64(i.UnitsDropped), "id", id)
 			}
 		} else {
-			out += metric("webrtc_conns", "", 0)
-			out += metric("webrtc_conns_bytes_received", "", 0)
-			out += metric("webrtc_conns_bytes_sent", "", 0)
+			b.Gauge("webrtc_conns", 0)
+			b.Counter("webrtc_conns_bytes_received", 0)
+			b.Counter("webrtc_conns_bytes_sent", 0)
+			b.Counter("webrtc_conns_units_dropped", 0)
 		}
 	}
 
-	ctx.Writer.WriteHeader(http.StatusOK)
-	io.WriteString(ctx.Writer, out)
+	usage := m.usageMeter.apiUsageList()
+	users := make([]string, 0, len(usage.Items))
+	for user := range usage.Items {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	for _, user := range users {
+		i := usage.Items[user]
+		b.Counter("usage_bytes_sent", float64(i.BytesSent), "user", user)
+		b.Gauge("usage_daily_bytes_sent", float64(i.DailyBytesSent), "user", user)
+		b.Gauge("usage_monthly_bytes_sent", float64(i.MonthlyBytesSent), "user", user)
+		b.Gauge("usage_readers", float64(i.Readers), "user", user)
+	}
+
+	if m.webhookSender != nil {
+		b.Counter("webhook_deliveries", float64(atomic.LoadUint64(m.webhookSender.deliveries)))
+		b.Counter("webhook_delivery_failures", float64(atomic.LoadUint64(m.webhookSender.failures)))
+	}
+}
+
+// collectRTSP collects metrics of a RTSP server. prefix is either rtsp or rtsps.
+func collectRTSP(b *telemetry.Batch, prefix string, s apiRTSPServer) {
+	res := s.apiConnsList()
+	if res.err == nil && len(res.data.Items) != 0 {
+		for id, i := range res.data.Items {
+			b.Gauge(prefix+"_conns", 1, "id", id)
+			b.Counter(prefix+"_conns_bytes_received", float64(i.BytesReceived), "id", id)
+			b.Counter(prefix+"_conns_bytes_sent", float64(i.BytesSent), "id", id)
+		}
+	} else {
+		b.Gauge(prefix+"_conns", 0)
+		b.Counter(prefix+"_conns_bytes_received", 0)
+		b.Counter(prefix+"_conns_bytes_sent", 0)
+	}
+
+	res2 := s.apiSessionsList()
+	if res2.err == nil && len(res2.data.Items) != 0 {
+		for id, i := range res2.data.Items {
+			b.Gauge(prefix+"_sessions", 1, "id", id, "state", i.State)
+			b.Counter(prefix+"_sessions_bytes_received", float64(i.BytesReceived), "id", id, "state", i.State)
+			b.Counter(prefix+"_sessions_bytes_sent", float64(i.BytesSent), "id", id, "state", i.State)
+		}
+	} else {
+		b.Gauge(prefix+"_sessions", 0)
+		b.Counter(prefix+"_sessions_bytes_received", 0)
+		b.Counter(prefix+"_sessions_bytes_sent", 0)
+	}
 }
 
 // pathManagerSet is called by pathManager.
@@ -257,6 +324,13 @@ func (m *metrics) rtmpServerSet(s apiRTMPServer) {
 	m.rtmpServer = s
 }
 
+// webhookSenderSet is called by webhookSender.
+func (m *metrics) webhookSenderSet(s *webhookSender) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.webhookSender = s
+}
+
 // webRTCServerSet is called by webRTCServer.
 func (m *metrics) webRTCServerSet(s apiWebRTCServer) {
 	m.mutex.Lock()
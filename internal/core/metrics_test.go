@@ -2,17 +2,27 @@ package core
 
 import (
 	"crypto/tls"
+	"encoding/json"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	rtspurl "github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/require"
 
 	"github.com/aler9/mediamtx/internal/rtmp"
+	"github.com/aler9/mediamtx/internal/rtmp/message"
 )
 
 func TestMetrics(t *testing.T) {
@@ -42,6 +52,7 @@ func TestMetrics(t *testing.T) {
 	require.Equal(t, `paths 0
 hls_muxers 0
 hls_muxers_bytes_sent 0
+hls_muxers_units_dropped 0
 rtsp_conns 0
 rtsp_conns_bytes_received 0
 rtsp_conns_bytes_sent 0
@@ -57,9 +68,13 @@ rtsps_sessions_bytes_sent 0
 rtmp_conns 0
 rtmp_conns_bytes_received 0
 rtmp_conns_bytes_sent 0
+rtmp_conns_units_dropped 0
 webrtc_conns 0
 webrtc_conns_bytes_received 0
 webrtc_conns_bytes_sent 0
+webrtc_conns_units_dropped 0
+webhook_deliveries 0
+webhook_delivery_failures 0
 `, string(bo))
 
 	medi := testMediaH264
@@ -101,22 +116,36 @@ webrtc_conns_bytes_sent 0
 	err = conn.WriteTracks(videoTrack, nil)
 	require.NoError(t, err)
 
+	// wait for the RTMP path to be ready
+	time.Sleep(500 * time.Millisecond)
+
 	bo, err = httpPullFile("http://localhost:9998/metrics")
 	require.NoError(t, err)
 
+	pathMetrics := `paths\{name=".*?",state="ready"\} 1` + "\n" +
+		`paths_bytes_received\{name=".*?",state="ready"\} 0` + "\n" +
+		`paths_packets_lost\{name=".*?",state="ready"\} 0` + "\n" +
+		`paths_decode_errors\{name=".*?",state="ready"\} 0` + "\n" +
+		`paths_readers\{name=".*?",protocol="hls"\} 1` + "\n" +
+		`tracks_bytes_received\{path=".*?",track="0",codec="H264"\} 0` + "\n" +
+		`tracks_frames\{path=".*?",track="0",codec="H264"\} 0` + "\n" +
+		`tracks_key_frames\{path=".*?",track="0",codec="H264"\} 0` + "\n" +
+		`tracks_fps\{path=".*?",track="0",codec="H264"\} 0` + "\n" +
+		`tracks_bitrate\{path=".*?",track="0",codec="H264"\} 0` + "\n" +
+		`tracks_gop_duration_seconds\{path=".*?",track="0",codec="H264"\} 0` + "\n" +
+		`tracks_timestamp_discontinuities\{path=".*?",track="0",codec="H264"\} 0` + "\n"
+
 	require.Regexp(t,
-		`^paths\{name=".*?",state="ready"\} 1`+"\n"+
-			`paths_bytes_received\{name=".*?",state="ready"\} 0`+"\n"+
-			`paths\{name=".*?",state="ready"\} 1`+"\n"+
-			`paths_bytes_received\{name=".*?",state="ready"\} 0`+"\n"+
-			`paths\{name=".*?",state="ready"\} 1`+"\n"+
-			`paths_bytes_received\{name=".*?",state="ready"\} 0`+"\n"+
+		`^`+pathMetrics+pathMetrics+pathMetrics+
 			`hls_muxers\{name=".*?"\} 1`+"\n"+
 			`hls_muxers_bytes_sent\{name=".*?"\} [0-9]+`+"\n"+
+			`hls_muxers_units_dropped\{name=".*?"\} 0`+"\n"+
 			`hls_muxers\{name=".*?"\} 1`+"\n"+
 			`hls_muxers_bytes_sent\{name=".*?"\} [0-9]+`+"\n"+
+			`hls_muxers_units_dropped\{name=".*?"\} 0`+"\n"+
 			`hls_muxers\{name=".*?"\} 1`+"\n"+
 			`hls_muxers_bytes_sent\{name=".*?"\} [0-9]+`+"\n"+
+			`hls_muxers_units_dropped\{name=".*?"\} 0`+"\n"+
 			`rtsp_conns\{id=".*?"\} 1`+"\n"+
 			`rtsp_conns_bytes_received\{id=".*?"\} [0-9]+`+"\n"+
 			`rtsp_conns_bytes_sent\{id=".*?"\} [0-9]+`+"\n"+
@@ -132,9 +161,340 @@ webrtc_conns_bytes_sent 0
 			`rtmp_conns\{id=".*?",state="publish"\} 1`+"\n"+
 			`rtmp_conns_bytes_received\{id=".*?",state="publish"\} [0-9]+`+"\n"+
 			`rtmp_conns_bytes_sent\{id=".*?",state="publish"\} [0-9]+`+"\n"+
+			`rtmp_conns_units_dropped\{id=".*?",state="publish"\} 0`+"\n"+
 			`webrtc_conns 0`+"\n"+
 			`webrtc_conns_bytes_received 0`+"\n"+
 			`webrtc_conns_bytes_sent 0`+"\n"+
+			`webrtc_conns_units_dropped 0`+"\n"+
+			`webhook_deliveries 0`+"\n"+
+			`webhook_delivery_failures 0`+"\n"+
 			"$",
 		string(bo))
 }
+
+type testOTLPSpan struct {
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Status       struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+type testOTLPCollector struct {
+	s *httptest.Server
+
+	mutex       sync.Mutex
+	metricNames map[string]struct{}
+	spans       []testOTLPSpan
+}
+
+func newTestOTLPCollector() *testOTLPCollector {
+	c := &testOTLPCollector{
+		metricNames: make(map[string]struct{}),
+	}
+	c.s = httptest.NewServer(http.HandlerFunc(c.onRequest))
+	return c
+}
+
+func (c *testOTLPCollector) close() {
+	c.s.Close()
+}
+
+func (c *testOTLPCollector) onRequest(w http.ResponseWriter, r *http.Request) {
+	byts, _ := io.ReadAll(r.Body)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/metrics":
+		var body struct {
+			ResourceMetrics []struct {
+				ScopeMetrics []struct {
+					Metrics []struct {
+						Name string `json:"name"`
+					} `json:"metrics"`
+				} `json:"scopeMetrics"`
+			} `json:"resourceMetrics"`
+		}
+		json.Unmarshal(byts, &body) //nolint:errcheck
+
+		for _, rm := range body.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					c.metricNames[m.Name] = struct{}{}
+				}
+			}
+		}
+
+	case "/v1/traces":
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []testOTLPSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.Unmarshal(byts, &body) //nolint:errcheck
+
+		for _, rs := range body.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+	}
+}
+
+func (c *testOTLPCollector) spanNames() map[string]struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := make(map[string]struct{})
+	for _, s := range c.spans {
+		ret[s.Name] = struct{}{}
+	}
+	return ret
+}
+
+// childSpan returns the first span with the given name whose parent has the given name.
+func (c *testOTLPCollector) childSpan(parentName string, name string) (testOTLPSpan, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, parent := range c.spans {
+		if parent.Name != parentName {
+			continue
+		}
+
+		for _, s := range c.spans {
+			if s.Name == name && s.ParentSpanID == parent.SpanID {
+				return s, true
+			}
+		}
+	}
+
+	return testOTLPSpan{}, false
+}
+
+func TestMetricsOTLP(t *testing.T) {
+	collector := newTestOTLPCollector()
+	defer collector.close()
+
+	p, ok := newInstance("rtspDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"otlp: yes\n" +
+		"otlpEndpoint: " + collector.s.URL + "\n" +
+		"otlpInterval: 100ms\n" +
+		"paths:\n" +
+		"  all:\n" +
+		"    readUser: myuser\n" +
+		"    readPass: mypass\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	// the Prometheus listener is disabled
+	_, err := httpPullFile("http://localhost:9998/metrics")
+	require.Error(t, err)
+
+	u, err := url.Parse("rtmp://127.0.0.1:1935/mystream")
+	require.NoError(t, err)
+
+	nconn1, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	defer nconn1.Close()
+	conn1 := rtmp.NewConn(nconn1)
+
+	err = conn1.InitializeClient(u, true)
+	require.NoError(t, err)
+
+	videoTrack := &formats.H264{
+		PayloadTyp: 96,
+		SPS: []byte{ // 1920x1080 baseline
+			0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+			0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+			0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+		},
+		PPS:               []byte{0x08, 0x06, 0x07, 0x08},
+		PacketizationMode: 1,
+	}
+
+	err = conn1.WriteTracks(videoTrack, nil)
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	u2, err := url.Parse("rtmp://127.0.0.1:1935/mystream?user=myuser&pass=mypass")
+	require.NoError(t, err)
+
+	nconn2, err := net.Dial("tcp", u2.Host)
+	require.NoError(t, err)
+	defer nconn2.Close()
+	conn2 := rtmp.NewConn(nconn2)
+
+	err = conn2.InitializeClient(u2, false)
+	require.NoError(t, err)
+
+	_, _, err = conn2.ReadTracks()
+	require.NoError(t, err)
+
+	err = conn1.WriteMessage(&message.Video{
+		ChunkStreamID:   message.VideoChunkStreamID,
+		MessageStreamID: 0x1000000,
+		Codec:           message.CodecH264,
+		IsKeyFrame:      true,
+		Type:            message.VideoTypeAU,
+		Payload: []byte{
+			0x00, 0x00, 0x00, 0x04, 0x05, 0x02, 0x03, 0x04, // IDR
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = conn2.ReadMessage()
+	require.NoError(t, err)
+
+	// spans are exported when connections are closed.
+	// closing the publisher closes the reader too.
+	nconn1.Close()
+	time.Sleep(500 * time.Millisecond)
+
+	collector.mutex.Lock()
+	metricNames := collector.metricNames
+	collector.mutex.Unlock()
+
+	for _, name := range []string{"paths", "paths_bytes_received", "rtmp_conns", "rtmp_conns_bytes_sent"} {
+		require.Contains(t, metricNames, name)
+	}
+
+	spanNames := collector.spanNames()
+
+	for _, name := range []string{"rtmp conn", "handshake", "path lookup", "authentication", "first frame sent"} {
+		require.Contains(t, spanNames, name)
+	}
+}
+
+func TestMetricsOTLPRTSPSession(t *testing.T) {
+	collector := newTestOTLPCollector()
+	defer collector.close()
+
+	p, ok := newInstance("rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"otlp: yes\n" +
+		"otlpEndpoint: " + collector.s.URL + "\n" +
+		"otlpInterval: 100ms\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/mystream",
+		media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	received := make(chan struct{}, 16)
+
+	c := gortsplib.Client{}
+
+	u, err := rtspurl.Parse("rtsp://localhost:8554/mystream")
+	require.NoError(t, err)
+
+	err = c.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer c.Close()
+
+	medias, baseURL, _, err := c.Describe(u)
+	require.NoError(t, err)
+
+	err = c.SetupAll(medias, baseURL)
+	require.NoError(t, err)
+
+	c.OnPacketRTP(medias[0], medias[0].Formats[0], func(pkt *rtp.Packet) {
+		received <- struct{}{}
+	})
+
+	_, err = c.Play(nil)
+	require.NoError(t, err)
+
+	err = source.WritePacketRTP(testMediaH264, &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         true,
+			PayloadType:    96,
+			SequenceNumber: 123,
+			Timestamp:      45343,
+			SSRC:           563423,
+		},
+		Payload: []byte{0x05, 0x02, 0x03, 0x04}, // IDR
+	})
+	require.NoError(t, err)
+
+	<-received
+
+	// spans are exported when sessions are closed.
+	c.Close()
+	p.Close()
+
+	span, ok := collector.childSpan("rtsp session", "first frame sent")
+	require.Equal(t, true, ok)
+	require.Equal(t, 0, span.Status.Code)
+}
+
+func TestMetricsOTLPHLSMuxer(t *testing.T) {
+	collector := newTestOTLPCollector()
+	defer collector.close()
+
+	p, ok := newInstance("rtmpDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"hlsAlwaysRemux: yes\n" +
+		"otlp: yes\n" +
+		"otlpEndpoint: " + collector.s.URL + "\n" +
+		"otlpInterval: 100ms\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/mystream",
+		media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		err = source.WritePacketRTP(testMediaH264, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         true,
+				PayloadType:    96,
+				SequenceNumber: 123 + uint16(i),
+				Timestamp:      45343 + uint32(i*90000),
+				SSRC:           563423,
+			},
+			Payload: []byte{0x05, 0x02, 0x03, 0x04}, // IDR
+		})
+		require.NoError(t, err)
+	}
+
+	// playlists do not contain frames
+	cnt, err := httpPullFile("http://localhost:8888/mystream/stream.m3u8")
+	require.NoError(t, err)
+
+	part := regexp.MustCompile(`URI="(part[0-9]+\.mp4)"`).FindStringSubmatch(string(cnt))
+	require.NotNil(t, part)
+
+	_, err = httpPullFile("http://localhost:8888/mystream/" + part[1])
+	require.NoError(t, err)
+
+	// spans are exported when muxers are closed.
+	p.Close()
+
+	span, ok := collector.childSpan("hls muxer", "first frame sent")
+	require.Equal(t, true, ok)
+	require.Equal(t, 0, span.Status.Code)
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+)
+
+// mpegtsMedia is a media read from a MPEG-TS stream.
+type mpegtsMedia struct {
+	media *media.Media
+
+	// decode converts the payload of a PES into units.
+	decode func(pts time.Duration, data []byte) ([]formatprocessor.Unit, error)
+}
+
+// mpegtsMedias converts MPEG-TS tracks into medias, indexed by PID.
+func mpegtsMedias(tracks []*mpegts.Track) (media.Medias, map[uint16]*mpegtsMedia) {
+	var medias media.Medias
+	byPID := make(map[uint16]*mpegtsMedia, len(tracks))
+
+	for _, track := range tracks {
+		var mm *mpegtsMedia
+
+		switch tcodec := track.Codec.(type) {
+		case *mpegts.CodecH264:
+			mm = &mpegtsMedia{
+				media: &media.Media{
+					Type: media.TypeVideo,
+					Formats: []formats.Format{&formats.H264{
+						PayloadTyp:        96,
+						PacketizationMode: 1,
+					}},
+				},
+				decode: func(pts time.Duration, data []byte) ([]formatprocessor.Unit, error) {
+					au, err := h264.AnnexBUnmarshal(data)
+					if err != nil {
+						return nil, err
+					}
+
+					return []formatprocessor.Unit{&formatprocessor.UnitH264{
+						PTS: pts,
+						AU:  au,
+						NTP: time.Now(),
+					}}, nil
+				},
+			}
+
+		case *mpegts.CodecH265:
+			mm = &mpegtsMedia{
+				media: &media.Media{
+					Type: media.TypeVideo,
+					Formats: []formats.Format{&formats.H265{
+						PayloadTyp: 96,
+					}},
+				},
+				decode: func(pts time.Duration, data []byte) ([]formatprocessor.Unit, error) {
+					au, err := h264.AnnexBUnmarshal(data)
+					if err != nil {
+						return nil, err
+					}
+
+					return []formatprocessor.Unit{&formatprocessor.UnitH265{
+						PTS: pts,
+						AU:  au,
+						NTP: time.Now(),
+					}}, nil
+				},
+			}
+
+		case *mpegts.CodecMPEG4Audio:
+			mm = &mpegtsMedia{
+				media: &media.Media{
+					Type: media.TypeAudio,
+					Formats: []formats.Format{&formats.MPEG4Audio{
+						PayloadTyp:       96,
+						SizeLength:       13,
+						IndexLength:      3,
+						IndexDeltaLength: 3,
+						Config:           &tcodec.Config,
+					}},
+				},
+				decode: func(pts time.Duration, data []byte) ([]formatprocessor.Unit, error) {
+					var pkts mpeg4audio.ADTSPackets
+					err := pkts.Unmarshal(data)
+					if err != nil {
+						return nil, err
+					}
+
+					aus := make([][]byte, len(pkts))
+					for i, pkt := range pkts {
+						aus[i] = pkt.AU
+					}
+
+					return []formatprocessor.Unit{&formatprocessor.UnitMPEG4Audio{
+						PTS: pts,
+						AUs: aus,
+						NTP: time.Now(),
+					}}, nil
+				},
+			}
+
+		case *mpegts.CodecOpus:
+			mm = &mpegtsMedia{
+				media: &media.Media{
+					Type: media.TypeAudio,
+					Formats: []formats.Format{&formats.Opus{
+						PayloadTyp: 96,
+						IsStereo:   (tcodec.Channels == 2),
+					}},
+				},
+				decode: func(pts time.Duration, data []byte) ([]formatprocessor.Unit, error) {
+					var units []formatprocessor.Unit
+					pos := 0
+
+					for {
+						var au mpegts.OpusAccessUnit
+						n, err := au.Unmarshal(data[pos:])
+						if err != nil {
+							return units, err
+						}
+						pos += n
+
+						units = append(units, &formatprocessor.UnitOpus{
+							PTS:   pts,
+							Frame: au.Frame,
+							NTP:   time.Now(),
+						})
+
+						if len(data[pos:]) == 0 {
+							break
+						}
+
+						pts += opusGetPacketDuration(au.Frame)
+					}
+
+					return units, nil
+				},
+			}
+		}
+
+		medias = append(medias, mm.media)
+		byPID[track.ES.ElementaryPID] = mm
+	}
+
+	return medias, byPID
+}
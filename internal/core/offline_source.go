@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type offlineSourceParent interface {
+	logger.Writer
+}
+
+// offlineSource feeds the stream of a path with a local file
+// while the source of the path is not ready.
+type offlineSource struct {
+	fpath  string
+	parent offlineSourceParent
+
+	ctx       context.Context
+	ctxCancel func()
+	demuxer   fileDemuxer
+
+	// out
+	done chan struct{}
+}
+
+func newOfflineSource(
+	parentCtx context.Context,
+	fpath string,
+	parent offlineSourceParent,
+) (*offlineSource, error) {
+	demuxer, err := openFileDemuxer(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	return &offlineSource{
+		fpath:     fpath,
+		parent:    parent,
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		demuxer:   demuxer,
+	}, nil
+}
+
+// Log is the main logging function.
+func (s *offlineSource) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[offline source] "+format, logger.WithField("source", "offline", args)...)
+}
+
+func (s *offlineSource) medias() media.Medias {
+	return s.demuxer.medias()
+}
+
+// start starts writing the file into the stream.
+func (s *offlineSource) start(stream *stream) {
+	s.Log(logger.Info, "started: %s", sourceMediaInfo(s.medias()))
+	s.done = make(chan struct{})
+	go s.run(stream)
+}
+
+func (s *offlineSource) close() {
+	s.ctxCancel()
+
+	if s.done != nil {
+		<-s.done
+		s.Log(logger.Info, "stopped")
+	}
+
+	s.demuxer.close()
+}
+
+func (s *offlineSource) run(stream *stream) {
+	defer close(s.done)
+
+	err := fileDemuxerRun(s.ctx, s.demuxer, true, func(u *fileUnit) {
+		stream.writeUnit(u.media, u.media.Formats[0], u.unit)
+	})
+
+	if s.ctx.Err() == nil {
+		s.Log(logger.Error, "unable to read '%s': %v", s.fpath, err)
+	}
+}
+
+// apiSourceDescribe implements source.
+func (*offlineSource) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"offlineSource"}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 func newEmptyTimer() *time.Timer {
@@ -42,6 +43,15 @@ func (e pathErrNoOnePublishing) Error() string {
 	return fmt.Sprintf("no one is publishing to path '%s'", e.pathName)
 }
 
+type pathErrTooManyReaders struct {
+	pathName string
+}
+
+// Error implements the error interface.
+func (e pathErrTooManyReaders) Error() string {
+	return fmt.Sprintf("path '%s' has reached the maximum number of readers", e.pathName)
+}
+
 type pathParent interface {
 	logger.Writer
 	pathSourceReady(*path)
@@ -85,6 +95,7 @@ type pathPublisherRemoveReq struct {
 
 type pathGetPathConfRes struct {
 	conf *conf.PathConf
+	user string // verified user
 	err  error
 }
 
@@ -105,12 +116,14 @@ type pathDescribeReq struct {
 	pathName    string
 	url         *url.URL
 	credentials authCredentials
+	span        *telemetry.Span
 	res         chan pathDescribeRes
 }
 
 type pathReaderSetupPlayRes struct {
 	path   *path
 	stream *stream
+	user   string // verified user
 	err    error
 }
 
@@ -119,6 +132,7 @@ type pathReaderAddReq struct {
 	pathName    string
 	skipAuth    bool
 	credentials authCredentials
+	span        *telemetry.Span
 	res         chan pathReaderSetupPlayRes
 }
 
@@ -131,6 +145,7 @@ type pathPublisherAddReq struct {
 	author      publisher
 	pathName    string
 	credentials authCredentials
+	span        *telemetry.Span
 	res         chan pathPublisherAnnounceRes
 }
 
@@ -152,13 +167,20 @@ type pathPublisherStopReq struct {
 }
 
 type pathAPIPathsListItem struct {
-	ConfName      string         `json:"confName"`
-	Conf          *conf.PathConf `json:"conf"`
-	Source        interface{}    `json:"source"`
-	SourceReady   bool           `json:"sourceReady"`
-	Tracks        []string       `json:"tracks"`
-	BytesReceived uint64         `json:"bytesReceived"`
-	Readers       []interface{}  `json:"readers"`
+	ConfName      string              `json:"confName"`
+	Conf          interface{}         `json:"conf"`
+	Source        interface{}         `json:"source"`
+	SourceReady   bool                `json:"sourceReady"`
+	Tracks        []string            `json:"tracks"`
+	BytesReceived uint64              `json:"bytesReceived"`
+	PacketsLost   uint64              `json:"packetsLost"`
+	DecodeErrors  uint64              `json:"decodeErrors"`
+	TracksStats   []streamFormatStats `json:"tracksStats"`
+	Health        pathHealth          `json:"health"`
+	Readers       []interface{}       `json:"readers"`
+
+	// used by metrics
+	readersByProtocol map[string]int64
 }
 
 type pathAPIPathsListData struct {
@@ -180,6 +202,21 @@ type pathAPIPathsListSubReq struct {
 	res  chan struct{}
 }
 
+type pathAPIPathsGetRes struct {
+	path *path
+	data *pathAPIPathsListItem
+	err  error
+}
+
+type pathAPIPathsGetReq struct {
+	name string
+	res  chan pathAPIPathsGetRes
+}
+
+type pathAPIPathsGetSubReq struct {
+	res chan *pathAPIPathsListItem
+}
+
 type path struct {
 	rtspAddress       string
 	readTimeout       conf.StringDuration
@@ -192,6 +229,8 @@ type path struct {
 	matches           []string
 	wg                *sync.WaitGroup
 	externalCmdPool   *externalcmd.Pool
+	webhookSender     *webhookSender
+	eventBus          *eventBus
 	parent            pathParent
 
 	ctx                            context.Context
@@ -199,12 +238,21 @@ type path struct {
 	confMutex                      sync.RWMutex
 	source                         source
 	bytesReceived                  *uint64
+	packetsLost                    *uint64
+	decodeErrors                   *uint64
 	stream                         *stream
+	offlineSource                  *offlineSource
+	publisherReplacing             bool
 	readers                        map[reader]struct{}
 	describeRequestsOnHold         []pathDescribeReq
 	readerAddRequestsOnHold        []pathReaderAddReq
 	onDemandCmd                    *externalcmd.Cmd
 	onReadyCmd                     *externalcmd.Cmd
+	readyTime                      time.Time
+	sourceTime                     time.Time
+	readyBytesReceived             uint64
+	healthIssues                   []string
+	maxBitrateExceededSince        time.Time
 	onDemandStaticSourceState      pathOnDemandState
 	onDemandStaticSourceReadyTimer *time.Timer
 	onDemandStaticSourceCloseTimer *time.Timer
@@ -224,6 +272,7 @@ type path struct {
 	chReaderAdd               chan pathReaderAddReq
 	chReaderRemove            chan pathReaderRemoveReq
 	chAPIPathsList            chan pathAPIPathsListSubReq
+	chAPIPathsGet             chan pathAPIPathsGetSubReq
 
 	// out
 	done chan struct{}
@@ -242,6 +291,8 @@ func newPath(
 	matches []string,
 	wg *sync.WaitGroup,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
+	eventBus *eventBus,
 	parent pathParent,
 ) *path {
 	ctx, ctxCancel := context.WithCancel(parentCtx)
@@ -258,10 +309,14 @@ func newPath(
 		matches:                        matches,
 		wg:                             wg,
 		externalCmdPool:                externalCmdPool,
+		webhookSender:                  webhookSender,
+		eventBus:                       eventBus,
 		parent:                         parent,
 		ctx:                            ctx,
 		ctxCancel:                      ctxCancel,
 		bytesReceived:                  new(uint64),
+		packetsLost:                    new(uint64),
+		decodeErrors:                   new(uint64),
 		readers:                        make(map[reader]struct{}),
 		onDemandStaticSourceReadyTimer: newEmptyTimer(),
 		onDemandStaticSourceCloseTimer: newEmptyTimer(),
@@ -278,6 +333,7 @@ func newPath(
 		chReaderAdd:                    make(chan pathReaderAddReq),
 		chReaderRemove:                 make(chan pathReaderRemoveReq),
 		chAPIPathsList:                 make(chan pathAPIPathsListSubReq),
+		chAPIPathsGet:                  make(chan pathAPIPathsGetSubReq),
 		done:                           make(chan struct{}),
 	}
 
@@ -299,7 +355,10 @@ func (pa *path) wait() {
 
 // Log is the main logging function.
 func (pa *path) Log(level logger.Level, format string, args ...interface{}) {
-	pa.parent.Log(level, "[path "+pa.name+"] "+format, args...)
+	pa.parent.Log(level, "[path "+pa.name+"] "+format, logger.WithFields([]logger.Field{
+		{Key: "component", Value: "path"},
+		{Key: "path", Value: pa.name},
+	}, args)...)
 }
 
 func (pa *path) safeConf() *conf.PathConf {
@@ -327,6 +386,10 @@ func (pa *path) run() {
 		}
 	}
 
+	if pa.conf.OfflineSource != "" {
+		pa.offlineSourceStart()
+	}
+
 	var onInitCmd *externalcmd.Cmd
 	if pa.conf.RunOnInit != "" {
 		pa.Log(logger.Info, "runOnInit command started")
@@ -340,9 +403,16 @@ func (pa *path) run() {
 			})
 	}
 
+	healthTicker := time.NewTicker(pathHealthCheckPeriod)
+	defer healthTicker.Stop()
+
 	err := func() error {
 		for {
 			select {
+			case <-healthTicker.C:
+				pa.checkHealth()
+				pa.checkMaxBitrate()
+
 			case <-pa.onDemandStaticSourceReadyTimer.C:
 				for _, req := range pa.describeRequestsOnHold {
 					req.res <- pathDescribeRes{err: fmt.Errorf("source of path '%s' has timed out", pa.name)}
@@ -402,6 +472,17 @@ func (pa *path) run() {
 				pa.confMutex.Unlock()
 
 			case req := <-pa.chSourceStaticSetReady:
+				if pa.isSourceReady() {
+					// the static source switched to another URL
+					err := pa.sourceRebind(req.medias, req.generateRTPPackets)
+					if err != nil {
+						req.res <- pathSourceStaticSetReadyRes{err: err}
+					} else {
+						req.res <- pathSourceStaticSetReadyRes{stream: pa.stream}
+					}
+					continue
+				}
+
 				err := pa.sourceSetReady(req.medias, req.generateRTPPackets)
 				if err != nil {
 					req.res <- pathSourceStaticSetReadyRes{err: err}
@@ -483,6 +564,9 @@ func (pa *path) run() {
 			case req := <-pa.chAPIPathsList:
 				pa.handleAPIPathsList(req)
 
+			case req := <-pa.chAPIPathsGet:
+				pa.handleAPIPathsGet(req)
+
 			case <-pa.ctx.Done():
 				return fmt.Errorf("terminated")
 			}
@@ -512,10 +596,16 @@ func (pa *path) run() {
 		req.res <- pathReaderSetupPlayRes{err: fmt.Errorf("terminated")}
 	}
 
-	if pa.stream != nil {
+	if pa.isSourceReady() || pa.publisherReplacing {
+		pa.publisherReplacing = false
 		pa.sourceSetNotReady()
 	}
 
+	if pa.offlineSource != nil {
+		pa.offlineSourceStop()
+		pa.streamClose()
+	}
+
 	if pa.source != nil {
 		if source, ok := pa.source.(*sourceStatic); ok {
 			source.close()
@@ -556,6 +646,18 @@ func (pa *path) externalCmdEnv() externalcmd.Environment {
 	return env
 }
 
+// sourceExternalCmdEnv returns the environment of external commands,
+// enriched with informations about the source.
+func (pa *path) sourceExternalCmdEnv() externalcmd.Environment {
+	env := pa.externalCmdEnv()
+
+	if pa.source != nil {
+		env["MTX_SOURCE_TYPE"], env["MTX_SOURCE_ID"] = apiDescribeTypeAndID(pa.source.apiSourceDescribe())
+	}
+
+	return env
+}
+
 func (pa *path) onDemandStaticSourceStart() {
 	pa.source.(*sourceStatic).start()
 
@@ -628,19 +730,47 @@ func (pa *path) onDemandPublisherStop() {
 	}
 }
 
+// isSourceReady returns whether the stream is fed by the path source.
+// The path is not ready while a publisher is being replaced by another one.
+func (pa *path) isSourceReady() bool {
+	return pa.stream != nil && pa.offlineSource == nil && !pa.publisherReplacing
+}
+
 func (pa *path) sourceSetReady(medias media.Medias, allocateEncoder bool) error {
-	stream, err := newStream(
-		pa.udpMaxPayloadSize,
-		medias,
-		allocateEncoder,
-		pa.bytesReceived,
-		pa.source,
-	)
-	if err != nil {
-		return err
+	if pa.offlineSource != nil {
+		pa.offlineSourceStop()
+
+		err := pa.stream.rebind(medias, allocateEncoder, pa.source)
+		if err != nil {
+			pa.Log(logger.Info, "source is not compatible with the offline source (%v), readers will be disconnected", err)
+			pa.streamClose()
+		} else {
+			pa.Log(logger.Info, "offline source replaced by the source, readers have been kept")
+		}
+	}
+
+	if pa.stream == nil {
+		stream, err := newStream(
+			pa.udpMaxPayloadSize,
+			medias,
+			allocateEncoder,
+			pa.bytesReceived,
+			pa.packetsLost,
+			pa.decodeErrors,
+			pa.source,
+		)
+		if err != nil {
+			return err
+		}
+
+		pa.stream = stream
 	}
 
-	pa.stream = stream
+	pa.readyTime = time.Now()
+	pa.sourceTime = pa.readyTime
+	pa.readyBytesReceived = atomic.LoadUint64(pa.bytesReceived)
+
+	env := pa.sourceExternalCmdEnv()
 
 	if pa.conf.RunOnReady != "" {
 		pa.Log(logger.Info, "runOnReady command started")
@@ -648,43 +778,267 @@ func (pa *path) sourceSetReady(medias media.Medias, allocateEncoder bool) error
 			pa.externalCmdPool,
 			pa.conf.RunOnReady,
 			pa.conf.RunOnReadyRestart,
-			pa.externalCmdEnv(),
+			env,
 			func(co int) {
 				pa.Log(logger.Info, "runOnReady command exited with code %d", co)
 			})
 	}
 
+	pa.webhookSender.send(pa.conf.OnReadyWebhook, "ready", env)
+
 	pa.parent.pathSourceReady(pa)
 
+	pa.eventBus.publish(&event{
+		Type:   eventTypeSourceReady,
+		Path:   pa.name,
+		Source: pa.source.apiSourceDescribe(),
+	})
+
+	return nil
+}
+
+// sourceRebind replaces the source of the stream. Readers are kept when tracks are compatible.
+func (pa *path) sourceRebind(medias media.Medias, allocateEncoder bool) error {
+	err := pa.stream.rebind(medias, allocateEncoder, pa.source)
+	if err != nil {
+		pa.Log(logger.Info, "new source is not compatible with the previous one (%v), readers will be disconnected", err)
+		pa.sourceSetNotReady()
+		return pa.sourceSetReady(medias, allocateEncoder)
+	}
+
+	pa.Log(logger.Info, "stream source replaced, readers have been kept")
+	pa.sourceTime = time.Now()
+	pa.healthIssues = nil
 	return nil
 }
 
 func (pa *path) sourceSetNotReady() {
 	pa.parent.pathSourceNotReady(pa)
 
-	for r := range pa.readers {
-		pa.doReaderRemove(r)
-		r.close()
+	env := pa.sourceExternalCmdEnv()
+	env["MTX_DURATION"] = externalCmdDuration(time.Since(pa.readyTime))
+	env["MTX_BYTES_RECEIVED"] = strconv.FormatUint(atomic.LoadUint64(pa.bytesReceived)-pa.readyBytesReceived, 10)
+
+	if pa.conf.RunOnNotReady != "" {
+		pa.Log(logger.Info, "runOnNotReady command launched")
+		externalcmd.RunOnce(
+			pa.externalCmdPool,
+			pa.conf.RunOnNotReady,
+			env,
+			func(co int) {
+				pa.Log(logger.Info, "runOnNotReady command exited with code %d", co)
+			})
 	}
 
+	pa.webhookSender.send(pa.conf.OnNotReadyWebhook, "notReady", env)
+
+	pa.eventBus.publish(&event{
+		Type: eventTypeSourceNotReady,
+		Path: pa.name,
+	})
+
 	if pa.onReadyCmd != nil {
 		pa.onReadyCmd.Close()
 		pa.onReadyCmd = nil
 		pa.Log(logger.Info, "runOnReady command stopped")
 	}
 
+	pa.healthIssues = nil
+
+	if pa.conf.OfflineSource != "" && pa.ctx.Err() == nil {
+		pa.offlineSourceStart()
+		return
+	}
+
+	pa.streamClose()
+}
+
+// streamClose disconnects readers and closes the stream.
+func (pa *path) streamClose() {
+	for r := range pa.readers {
+		pa.doReaderRemove(r)
+		r.close()
+	}
+
 	if pa.stream != nil {
 		pa.stream.close()
 		pa.stream = nil
 	}
 }
 
+// offlineSourceStart feeds the stream with the offline source.
+// Readers are kept when tracks of the offline source are compatible with the ones of the stream.
+func (pa *path) offlineSourceStart() {
+	offlineSource, err := newOfflineSource(pa.ctx, pa.conf.OfflineSource, pa)
+	if err != nil {
+		pa.Log(logger.Error, "unable to open offline source '%s': %v", pa.conf.OfflineSource, err)
+		pa.streamClose()
+		return
+	}
+
+	if pa.stream != nil {
+		err := pa.stream.rebind(offlineSource.medias(), true, offlineSource)
+		if err != nil {
+			pa.Log(logger.Info, "offline source is not compatible with the previous source (%v), "+
+				"readers will be disconnected", err)
+			pa.streamClose()
+		}
+	}
+
+	if pa.stream == nil {
+		pa.stream, err = newStream(
+			pa.udpMaxPayloadSize,
+			offlineSource.medias(),
+			true,
+			pa.bytesReceived,
+			pa.packetsLost,
+			pa.decodeErrors,
+			offlineSource,
+		)
+		if err != nil {
+			pa.Log(logger.Error, "unable to use offline source '%s': %v", pa.conf.OfflineSource, err)
+			offlineSource.close()
+			return
+		}
+	}
+
+	pa.offlineSource = offlineSource
+	pa.offlineSource.start(pa.stream)
+}
+
+func (pa *path) offlineSourceStop() {
+	pa.offlineSource.close()
+	pa.offlineSource = nil
+}
+
+func (pa *path) checkHealth() {
+	if !pa.isSourceReady() || !pa.conf.HasHealthRules() {
+		return
+	}
+
+	issues := streamHealthIssues(pa.conf, pa.stream.tracksStats(), pa.sourceTime, time.Now())
+	wasHealthy := len(pa.healthIssues) == 0
+	pa.healthIssues = issues
+
+	if len(issues) == 0 {
+		if !wasHealthy {
+			pa.Log(logger.Info, "stream is healthy again")
+
+			pa.eventBus.publish(&event{
+				Type: eventTypePathHealthy,
+				Path: pa.name,
+			})
+		}
+		return
+	}
+
+	// alarms are triggered once, when the stream becomes unhealthy
+	if !wasHealthy {
+		return
+	}
+
+	pa.Log(logger.Warn, "stream is unhealthy: %s", strings.Join(issues, ", "))
+
+	env := pa.sourceExternalCmdEnv()
+	env["MTX_HEALTH_ISSUES"] = strings.Join(issues, "; ")
+
+	if pa.conf.RunOnUnhealthy != "" {
+		pa.Log(logger.Info, "runOnUnhealthy command launched")
+		externalcmd.RunOnce(
+			pa.externalCmdPool,
+			pa.conf.RunOnUnhealthy,
+			env,
+			func(co int) {
+				pa.Log(logger.Info, "runOnUnhealthy command exited with code %d", co)
+			})
+	}
+
+	pa.webhookSender.send(pa.conf.OnUnhealthyWebhook, "unhealthy", env)
+
+	pa.eventBus.publish(&event{
+		Type:   eventTypePathUnhealthy,
+		Path:   pa.name,
+		Issues: issues,
+	})
+
+	switch source := pa.source.(type) {
+	case *sourceStatic:
+		// with failover sources, an unhealthy stream causes a switch to the next source
+		if pa.conf.HealthCloseSource || len(pa.conf.SourceFailover) != 0 {
+			pa.Log(logger.Info, "closing source since stream is unhealthy")
+			source.restart()
+		}
+
+	case publisher:
+		if pa.conf.HealthCloseSource {
+			pa.Log(logger.Info, "closing source since stream is unhealthy")
+			source.close()
+		}
+	}
+}
+
+// checkMaxBitrate closes the publisher when its bitrate
+// exceeds maxBitrate for longer than maxBitrateGracePeriod.
+func (pa *path) checkMaxBitrate() {
+	if pa.conf.MaxBitrate == 0 || !pa.isSourceReady() {
+		return
+	}
+
+	source, ok := pa.source.(publisher)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	// the bitrate is checked once the sliding window is full,
+	// in order not to take into account previous publishers.
+	if now.Sub(pa.sourceTime) < streamFormatStatsWindow*time.Second {
+		pa.maxBitrateExceededSince = time.Time{}
+		return
+	}
+
+	bitrate := streamBitrate(pa.stream.tracksStats())
+
+	if bitrate <= uint64(pa.conf.MaxBitrate) {
+		pa.maxBitrateExceededSince = time.Time{}
+		return
+	}
+
+	if pa.maxBitrateExceededSince.IsZero() {
+		pa.Log(logger.Warn, "bitrate is %d bit/s, more than %d bit/s", bitrate, pa.conf.MaxBitrate)
+		pa.maxBitrateExceededSince = now
+	}
+
+	if now.Sub(pa.maxBitrateExceededSince) >= time.Duration(pa.conf.MaxBitrateGracePeriod) {
+		pa.Log(logger.Info, "closing publisher since bitrate has been more than %d bit/s for %v",
+			pa.conf.MaxBitrate, now.Sub(pa.maxBitrateExceededSince).Truncate(time.Second))
+		pa.maxBitrateExceededSince = time.Time{}
+		source.close()
+	}
+}
+
+func (pa *path) health() pathHealth {
+	if len(pa.healthIssues) == 0 {
+		return pathHealth{Healthy: true, Issues: []string{}}
+	}
+	return pathHealth{Healthy: false, Issues: pa.healthIssues}
+}
+
 func (pa *path) doReaderRemove(r reader) {
 	delete(pa.readers, r)
+
+	pa.eventBus.publish(&event{
+		Type:   eventTypeReaderRemoved,
+		Path:   pa.name,
+		Reader: r.apiReaderDescribe(),
+	})
 }
 
 func (pa *path) doPublisherRemove() {
-	if pa.stream != nil {
+	if pa.isSourceReady() || pa.publisherReplacing {
+		pa.publisherReplacing = false
+
 		if pa.conf.HasOnDemandPublisher() && pa.onDemandPublisherState != pathOnDemandStateInitial {
 			pa.onDemandPublisherStop()
 		} else {
@@ -703,6 +1057,11 @@ func (pa *path) handleDescribe(req pathDescribeReq) {
 		return
 	}
 
+	if pa.publisherReplacing {
+		req.res <- pathDescribeRes{err: pathErrNoOnePublishing{pathName: pa.name}}
+		return
+	}
+
 	if pa.stream != nil {
 		req.res <- pathDescribeRes{
 			stream: pa.stream,
@@ -769,7 +1128,17 @@ func (pa *path) handlePublisherAdd(req pathPublisherAddReq) {
 
 		pa.Log(logger.Info, "closing existing publisher")
 		pa.source.(publisher).close()
-		pa.doPublisherRemove()
+
+		if pa.isSourceReady() || pa.publisherReplacing {
+			// readers are kept until the new publisher starts publishing,
+			// and are disconnected only if tracks are not compatible.
+			// In the meanwhile, the path is not ready and new readers are refused.
+			pa.stream.unbind()
+			pa.sourceTime = time.Now()
+			pa.publisherReplacing = true
+		} else {
+			pa.doPublisherRemove()
+		}
 	}
 
 	pa.source = req.author
@@ -783,6 +1152,20 @@ func (pa *path) handlePublisherStart(req pathPublisherStartReq) {
 		return
 	}
 
+	// the publisher replaced a previous one
+	if pa.publisherReplacing {
+		pa.publisherReplacing = false
+
+		err := pa.sourceRebind(req.medias, req.generateRTPPackets)
+		if err != nil {
+			req.res <- pathPublisherRecordRes{err: err}
+			return
+		}
+
+		req.res <- pathPublisherRecordRes{stream: pa.stream}
+		return
+	}
+
 	err := pa.sourceSetReady(req.medias, req.generateRTPPackets)
 	if err != nil {
 		req.res <- pathPublisherRecordRes{err: err}
@@ -812,7 +1195,7 @@ func (pa *path) handlePublisherStart(req pathPublisherStartReq) {
 }
 
 func (pa *path) handlePublisherStop(req pathPublisherStopReq) {
-	if req.author == pa.source && pa.stream != nil {
+	if req.author == pa.source && pa.isSourceReady() {
 		if pa.conf.HasOnDemandPublisher() && pa.onDemandPublisherState != pathOnDemandStateInitial {
 			pa.onDemandPublisherStop()
 		} else {
@@ -842,6 +1225,11 @@ func (pa *path) handleReaderRemove(req pathReaderRemoveReq) {
 }
 
 func (pa *path) handleReaderAdd(req pathReaderAddReq) {
+	if pa.publisherReplacing {
+		req.res <- pathReaderSetupPlayRes{err: pathErrNoOnePublishing{pathName: pa.name}}
+		return
+	}
+
 	if pa.stream != nil {
 		pa.handleReaderAddPost(req)
 		return
@@ -867,8 +1255,19 @@ func (pa *path) handleReaderAdd(req pathReaderAddReq) {
 }
 
 func (pa *path) handleReaderAddPost(req pathReaderAddReq) {
+	if pa.conf.MaxReaders != 0 && len(pa.readers) >= pa.conf.MaxReaders {
+		req.res <- pathReaderSetupPlayRes{err: pathErrTooManyReaders{pathName: pa.name}}
+		return
+	}
+
 	pa.readers[req.author] = struct{}{}
 
+	pa.eventBus.publish(&event{
+		Type:   eventTypeReaderAdded,
+		Path:   pa.name,
+		Reader: req.author.apiReaderDescribe(),
+	})
+
 	if pa.conf.HasOnDemandStaticSource() {
 		if pa.onDemandStaticSourceState == pathOnDemandStateClosing {
 			pa.onDemandStaticSourceState = pathOnDemandStateReady
@@ -889,8 +1288,8 @@ func (pa *path) handleReaderAddPost(req pathReaderAddReq) {
 	}
 }
 
-func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
-	req.data.Items[pa.name] = pathAPIPathsListItem{
+func (pa *path) apiItem() pathAPIPathsListItem {
+	return pathAPIPathsListItem{
 		ConfName: pa.confName,
 		Conf:     pa.conf,
 		Source: func() interface{} {
@@ -899,7 +1298,7 @@ func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
 			}
 			return pa.source.apiSourceDescribe()
 		}(),
-		SourceReady: pa.stream != nil,
+		SourceReady: pa.isSourceReady(),
 		Tracks: func() []string {
 			if pa.stream == nil {
 				return []string{}
@@ -907,6 +1306,15 @@ func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
 			return mediasDescription(pa.stream.medias())
 		}(),
 		BytesReceived: atomic.LoadUint64(pa.bytesReceived),
+		PacketsLost:   atomic.LoadUint64(pa.packetsLost),
+		DecodeErrors:  atomic.LoadUint64(pa.decodeErrors),
+		TracksStats: func() []streamFormatStats {
+			if pa.stream == nil {
+				return []streamFormatStats{}
+			}
+			return pa.stream.tracksStats()
+		}(),
+		Health: pa.health(),
 		Readers: func() []interface{} {
 			ret := []interface{}{}
 			for r := range pa.readers {
@@ -914,10 +1322,26 @@ func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
 			}
 			return ret
 		}(),
+		readersByProtocol: func() map[string]int64 {
+			ret := make(map[string]int64)
+			for r := range pa.readers {
+				ret[r.protocol()]++
+			}
+			return ret
+		}(),
 	}
+}
+
+func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
+	req.data.Items[pa.name] = pa.apiItem()
 	close(req.res)
 }
 
+func (pa *path) handleAPIPathsGet(req pathAPIPathsGetSubReq) {
+	item := pa.apiItem()
+	req.res <- &item
+}
+
 // reloadConf is called by pathManager.
 func (pa *path) reloadConf(newConf *conf.PathConf) {
 	select {
@@ -1039,3 +1463,18 @@ func (pa *path) apiPathsList(req pathAPIPathsListSubReq) {
 	case <-pa.ctx.Done():
 	}
 }
+
+// apiPathsGet is called by api.
+func (pa *path) apiPathsGet() *pathAPIPathsListItem {
+	req := pathAPIPathsGetSubReq{
+		res: make(chan *pathAPIPathsListItem),
+	}
+
+	select {
+	case pa.chAPIPathsGet <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+const (
+	// period of health checks.
+	pathHealthCheckPeriod = 1 * time.Second
+)
+
+// pathHealth is the health of a path.
+type pathHealth struct {
+	Healthy bool     `json:"healthy"`
+	Issues  []string `json:"issues"`
+}
+
+// streamBitrate returns the sum of the bitrates of all tracks.
+func streamBitrate(stats []streamFormatStats) uint64 {
+	var bitrate uint64
+	for _, st := range stats {
+		bitrate += st.Bitrate
+	}
+	return bitrate
+}
+
+// streamHealthIssues evaluates the health rules of a path against the statistics of its stream.
+// sourceTime is the time at which the current source became ready, and is used as reference
+// when no unit or key frame has been received from it yet.
+func streamHealthIssues(
+	pathConf *conf.PathConf,
+	stats []streamFormatStats,
+	sourceTime time.Time,
+	now time.Time,
+) []string {
+	var issues []string
+
+	since := func(t time.Time) time.Duration {
+		if t.Before(sourceTime) {
+			return now.Sub(sourceTime)
+		}
+		return now.Sub(t)
+	}
+
+	if pathConf.HealthMaxUnitAge != 0 {
+		var lastUnit time.Time
+		for _, st := range stats {
+			if st.lastUnit.After(lastUnit) {
+				lastUnit = st.lastUnit
+			}
+		}
+
+		if since(lastUnit) > time.Duration(pathConf.HealthMaxUnitAge) {
+			issues = append(issues, fmt.Sprintf("no units received for more than %v",
+				time.Duration(pathConf.HealthMaxUnitAge)))
+		}
+	}
+
+	if pathConf.HealthMaxKeyFrameAge != 0 {
+		var lastKeyFrame time.Time
+		hasKeyFrames := false
+
+		for _, st := range stats {
+			if st.hasKeyFrames {
+				hasKeyFrames = true
+				if st.lastKeyFrame.After(lastKeyFrame) {
+					lastKeyFrame = st.lastKeyFrame
+				}
+			}
+		}
+
+		// the rule is ignored when no track has key frames (i.e. audio-only streams)
+		if hasKeyFrames && since(lastKeyFrame) > time.Duration(pathConf.HealthMaxKeyFrameAge) {
+			issues = append(issues, fmt.Sprintf("no key frames received for more than %v",
+				time.Duration(pathConf.HealthMaxKeyFrameAge)))
+		}
+	}
+
+	// the bitrate is checked once the sliding window is full, in order to avoid false alarms
+	if pathConf.HealthMinBitrate != 0 && now.Sub(sourceTime) >= streamFormatStatsWindow*time.Second {
+		bitrate := streamBitrate(stats)
+
+		if bitrate < uint64(pathConf.HealthMinBitrate) {
+			issues = append(issues, fmt.Sprintf("bitrate is %d bit/s, less than %d bit/s",
+				bitrate, pathConf.HealthMinBitrate))
+		}
+	}
+
+	for _, codec := range pathConf.HealthExpectedTracks {
+		found := false
+		var lastUnit time.Time
+
+		for _, st := range stats {
+			if strings.EqualFold(st.Codec, codec) {
+				found = true
+				if st.lastUnit.After(lastUnit) {
+					lastUnit = st.lastUnit
+				}
+			}
+		}
+
+		switch {
+		case !found:
+			issues = append(issues, fmt.Sprintf("expected track '%s' is missing", codec))
+
+		// a track that is declared but doesn't receive anything is considered missing too
+		case pathConf.HealthMaxUnitAge != 0 && since(lastUnit) > time.Duration(pathConf.HealthMaxUnitAge):
+			issues = append(issues, fmt.Sprintf("expected track '%s' is not receiving units", codec))
+		}
+	}
+
+	return issues
+}
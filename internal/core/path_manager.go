@@ -8,6 +8,7 @@ import (
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 func pathConfCanBeUpdated(oldPathConf *conf.PathConf, newPathConf *conf.PathConf) bool {
@@ -48,7 +49,10 @@ type pathManager struct {
 	udpMaxPayloadSize         int
 	pathConfs                 map[string]*conf.PathConf
 	externalCmdPool           *externalcmd.Pool
+	webhookSender             *webhookSender
 	metrics                   *metrics
+	eventBus                  *eventBus
+	usageMeter                *usageMeter
 	parent                    pathManagerParent
 
 	ctx         context.Context
@@ -69,6 +73,7 @@ type pathManager struct {
 	chPublisherAdd       chan pathPublisherAddReq
 	chHLSServerSet       chan pathManagerHLSServer
 	chAPIPathsList       chan pathAPIPathsListReq
+	chAPIPathsGet        chan pathAPIPathsGetReq
 }
 
 func newPathManager(
@@ -82,7 +87,10 @@ func newPathManager(
 	udpMaxPayloadSize int,
 	pathConfs map[string]*conf.PathConf,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
 	metrics *metrics,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	parent pathManagerParent,
 ) *pathManager {
 	ctx, ctxCancel := context.WithCancel(parentCtx)
@@ -97,7 +105,10 @@ func newPathManager(
 		udpMaxPayloadSize:         udpMaxPayloadSize,
 		pathConfs:                 pathConfs,
 		externalCmdPool:           externalCmdPool,
+		webhookSender:             webhookSender,
 		metrics:                   metrics,
+		eventBus:                  eventBus,
+		usageMeter:                usageMeter,
 		parent:                    parent,
 		ctx:                       ctx,
 		ctxCancel:                 ctxCancel,
@@ -113,6 +124,7 @@ func newPathManager(
 		chPublisherAdd:            make(chan pathPublisherAddReq),
 		chHLSServerSet:            make(chan pathManagerHLSServer),
 		chAPIPathsList:            make(chan pathAPIPathsListReq),
+		chAPIPathsGet:             make(chan pathAPIPathsGetReq),
 	}
 
 	for pathConfName, pathConf := range pm.pathConfs {
@@ -209,13 +221,13 @@ outer:
 				continue
 			}
 
-			err = authenticate(pm.externalAuthenticationURL, pm.authMethods, req.name, pathConf, false, req.credentials)
+			user, err := pm.authenticate(nil, req.name, pathConf, false, req.credentials)
 			if err != nil {
 				req.res <- pathGetPathConfRes{err: pathErrAuth{wrapped: err}}
 				continue
 			}
 
-			req.res <- pathGetPathConfRes{conf: pathConf}
+			req.res <- pathGetPathConfRes{conf: pathConf, user: user}
 
 		case req := <-pm.chDescribe:
 			pathConfName, pathConf, pathMatches, err := pm.getPathConfInternal(req.pathName)
@@ -224,7 +236,7 @@ outer:
 				continue
 			}
 
-			err = authenticate(pm.externalAuthenticationURL, pm.authMethods, req.pathName, pathConf, false, req.credentials)
+			_, err = pm.authenticate(req.span, req.pathName, pathConf, false, req.credentials)
 			if err != nil {
 				req.res <- pathDescribeRes{err: pathErrAuth{wrapped: err}}
 				continue
@@ -244,8 +256,10 @@ outer:
 				continue
 			}
 
+			// when authentication is skipped, the user has already been verified
+			user := req.credentials.user
 			if !req.skipAuth {
-				err = authenticate(pm.externalAuthenticationURL, pm.authMethods, req.pathName, pathConf, false, req.credentials)
+				user, err = pm.authenticate(req.span, req.pathName, pathConf, false, req.credentials)
 				if err != nil {
 					req.res <- pathReaderSetupPlayRes{err: pathErrAuth{wrapped: err}}
 					continue
@@ -257,7 +271,7 @@ outer:
 				pm.createPath(pathConfName, pathConf, req.pathName, pathMatches)
 			}
 
-			req.res <- pathReaderSetupPlayRes{path: pm.paths[req.pathName]}
+			req.res <- pathReaderSetupPlayRes{path: pm.paths[req.pathName], user: user}
 
 		case req := <-pm.chPublisherAdd:
 			pathConfName, pathConf, pathMatches, err := pm.getPathConfInternal(req.pathName)
@@ -266,7 +280,7 @@ outer:
 				continue
 			}
 
-			err = authenticate(pm.externalAuthenticationURL, pm.authMethods, req.pathName, pathConf, true, req.credentials)
+			_, err = pm.authenticate(req.span, req.pathName, pathConf, true, req.credentials)
 			if err != nil {
 				req.res <- pathPublisherAnnounceRes{err: pathErrAuth{wrapped: err}}
 				continue
@@ -282,6 +296,9 @@ outer:
 		case s := <-pm.chHLSServerSet:
 			pm.hlsServer = s
 
+		case req := <-pm.chAPIPathsGet:
+			req.res <- pathAPIPathsGetRes{path: pm.paths[req.name]}
+
 		case req := <-pm.chAPIPathsList:
 			paths := make(map[string]*path)
 
@@ -305,6 +322,21 @@ outer:
 	}
 }
 
+// authenticate authenticates a request, tracing the operation as a child of span.
+func (pm *pathManager) authenticate(
+	span *telemetry.Span,
+	pathName string,
+	pathConf *conf.PathConf,
+	isPublish bool,
+	credentials authCredentials,
+) (string, error) {
+	authSpan := span.Child("authentication")
+	user, err := authenticate(pm.externalAuthenticationURL, pm.authMethods, pathName, pathConf, isPublish, credentials)
+	authSpan.SetError(err)
+	authSpan.End()
+	return user, err
+}
+
 func (pm *pathManager) createPath(
 	pathConfName string,
 	pathConf *conf.PathConf,
@@ -324,6 +356,8 @@ func (pm *pathManager) createPath(
 		matches,
 		&pm.wg,
 		pm.externalCmdPool,
+		pm.webhookSender,
+		pm.eventBus,
 		pm)
 
 	pm.paths[name] = pa
@@ -332,6 +366,11 @@ func (pm *pathManager) createPath(
 		pm.pathsByConf[pathConfName] = make(map[*path]struct{})
 	}
 	pm.pathsByConf[pathConfName][pa] = struct{}{}
+
+	pm.eventBus.publish(&event{
+		Type: eventTypePathCreated,
+		Path: name,
+	})
 }
 
 func (pm *pathManager) removePath(pa *path) {
@@ -340,6 +379,11 @@ func (pm *pathManager) removePath(pa *path) {
 		delete(pm.pathsByConf, pa.confName)
 	}
 	delete(pm.paths, pa.name)
+
+	pm.eventBus.publish(&event{
+		Type: eventTypePathClosed,
+		Path: pa.name,
+	})
 }
 
 func (pm *pathManager) getPathConfInternal(name string) (string, *conf.PathConf, []string, error) {
@@ -414,7 +458,13 @@ func (pm *pathManager) getPathConf(req pathGetPathConfReq) pathGetPathConfRes {
 }
 
 // describe is called by a reader or publisher.
-func (pm *pathManager) describe(req pathDescribeReq) pathDescribeRes {
+func (pm *pathManager) describe(req pathDescribeReq) (res pathDescribeRes) {
+	req.span = req.span.Child("path lookup", "path", req.pathName)
+	defer func() {
+		req.span.SetError(res.err)
+		req.span.End()
+	}()
+
 	req.res = make(chan pathDescribeRes)
 	select {
 	case pm.chDescribe <- req:
@@ -437,11 +487,17 @@ func (pm *pathManager) describe(req pathDescribeReq) pathDescribeRes {
 }
 
 // publisherAnnounce is called by a publisher.
-func (pm *pathManager) publisherAdd(req pathPublisherAddReq) pathPublisherAnnounceRes {
+func (pm *pathManager) publisherAdd(req pathPublisherAddReq) (res pathPublisherAnnounceRes) {
+	req.span = req.span.Child("path lookup", "path", req.pathName)
+	defer func() {
+		req.span.SetError(res.err)
+		req.span.End()
+	}()
+
 	req.res = make(chan pathPublisherAnnounceRes)
 	select {
 	case pm.chPublisherAdd <- req:
-		res := <-req.res
+		res = <-req.res
 		if res.err != nil {
 			return res
 		}
@@ -454,16 +510,30 @@ func (pm *pathManager) publisherAdd(req pathPublisherAddReq) pathPublisherAnnoun
 }
 
 // readerSetupPlay is called by a reader.
-func (pm *pathManager) readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes {
+func (pm *pathManager) readerAdd(req pathReaderAddReq) (res pathReaderSetupPlayRes) {
+	req.span = req.span.Child("path lookup", "path", req.pathName)
+	defer func() {
+		req.span.SetError(res.err)
+		req.span.End()
+	}()
+
 	req.res = make(chan pathReaderSetupPlayRes)
 	select {
 	case pm.chReaderAdd <- req:
-		res := <-req.res
+		res = <-req.res
 		if res.err != nil {
 			return res
 		}
 
-		return res.path.readerAdd(req)
+		// quotas are enforced after authentication, on verified users only
+		err := pm.usageMeter.check(res.user)
+		if err != nil {
+			return pathReaderSetupPlayRes{err: err}
+		}
+
+		res2 := res.path.readerAdd(req)
+		res2.user = res.user
+		return res2
 
 	case <-pm.ctx.Done():
 		return pathReaderSetupPlayRes{err: fmt.Errorf("terminated")}
@@ -502,3 +572,30 @@ func (pm *pathManager) apiPathsList() pathAPIPathsListRes {
 		return pathAPIPathsListRes{err: fmt.Errorf("terminated")}
 	}
 }
+
+// apiPathsGet is called by api.
+func (pm *pathManager) apiPathsGet(name string) pathAPIPathsGetRes {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+
+		if res.path != nil {
+			res.data = res.path.apiPathsGet()
+		}
+
+		// the path may have been closed in the meanwhile
+		if res.data == nil {
+			return pathAPIPathsGetRes{err: apiErrNotFound{"path", name}}
+		}
+
+		return res
+
+	case <-pm.ctx.Done():
+		return pathAPIPathsGetRes{err: fmt.Errorf("terminated")}
+	}
+}
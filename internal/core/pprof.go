@@ -60,5 +60,5 @@ func (pp *pprof) close() {
 }
 
 func (pp *pprof) Log(level logger.Level, format string, args ...interface{}) {
-	pp.parent.Log(level, "[pprof] "+format, args...)
+	pp.parent.Log(level, "[pprof] "+format, logger.WithField("component", "pprof", args)...)
 }
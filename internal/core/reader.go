@@ -1,7 +1,61 @@
 package core
 
+import (
+	"sync/atomic"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
+)
+
 // reader is an entity that can read a stream.
 type reader interface {
 	close()
+	protocol() string
 	apiReaderDescribe() interface{}
 }
+
+// readerBuffer is the buffer that decouples a stream from the routine that
+// writes its data to a reader. When the reader is too slow and the buffer is full,
+// items are dropped and counted.
+type readerBuffer struct {
+	rb      *ringbuffer.RingBuffer
+	size    int64
+	queued  int64
+	dropped *uint64
+}
+
+func newReaderBuffer(size int, dropped *uint64) (*readerBuffer, error) {
+	rb, err := ringbuffer.New(uint64(size))
+	if err != nil {
+		return nil, err
+	}
+
+	return &readerBuffer{
+		rb:      rb,
+		size:    int64(size),
+		dropped: dropped,
+	}, nil
+}
+
+func (b *readerBuffer) close() {
+	b.rb.Close()
+}
+
+// push adds an item to the buffer. It returns false when the buffer is full.
+func (b *readerBuffer) push(item interface{}) bool {
+	if atomic.AddInt64(&b.queued, 1) > b.size {
+		atomic.AddInt64(&b.queued, -1)
+		atomic.AddUint64(b.dropped, 1)
+		return false
+	}
+
+	b.rb.Push(item)
+	return true
+}
+
+func (b *readerBuffer) pull() (interface{}, bool) {
+	item, ok := b.rb.Pull()
+	if ok {
+		atomic.AddInt64(&b.queued, -1)
+	}
+	return item, ok
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderBufferDrop(t *testing.T) {
+	dropped := new(uint64)
+
+	b, err := newReaderBuffer(4, dropped)
+	require.NoError(t, err)
+	defer b.close()
+
+	for i := 0; i < 6; i++ {
+		b.push(i)
+	}
+	require.Equal(t, uint64(2), *dropped)
+
+	for i := 0; i < 4; i++ {
+		item, ok := b.pull()
+		require.Equal(t, true, ok)
+		require.Equal(t, i, item)
+	}
+
+	require.Equal(t, true, b.push(6))
+	item, ok := b.pull()
+	require.Equal(t, true, ok)
+	require.Equal(t, 6, item)
+	require.Equal(t, uint64(2), *dropped)
+}
@@ -68,7 +68,7 @@ func newRPICameraSource(
 }
 
 func (s *rpiCameraSource) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[rpicamera source] "+format, args...)
+	s.parent.Log(level, "[rpicamera source] "+format, logger.WithField("source", "rpicamera", args)...)
 }
 
 // run implements sourceStaticImpl.
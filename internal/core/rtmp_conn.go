@@ -2,17 +2,19 @@ package core
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
-	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
 	"github.com/bluenviron/mediacommon/pkg/codecs/av1"
 	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg2audio"
@@ -27,6 +29,7 @@ import (
 	"github.com/aler9/mediamtx/internal/rtmp"
 	"github.com/aler9/mediamtx/internal/rtmp/h264conf"
 	"github.com/aler9/mediamtx/internal/rtmp/message"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 const (
@@ -203,19 +206,29 @@ type rtmpConn struct {
 	readBufferCount     int
 	runOnConnect        string
 	runOnConnectRestart bool
+	runOnDisconnect     string
+	onConnectWebhook    string
+	onDisconnectWebhook string
+	rejected            bool
 	wg                  *sync.WaitGroup
 	conn                *rtmp.Conn
 	nconn               net.Conn
 	externalCmdPool     *externalcmd.Pool
+	webhookSender       *webhookSender
+	tracer              *telemetry.Tracer
+	eventBus            *eventBus
+	usageMeter          *usageMeter
 	pathManager         rtmpConnPathManager
 	parent              rtmpConnParent
 
-	ctx        context.Context
-	ctxCancel  func()
-	uuid       uuid.UUID
-	created    time.Time
-	state      rtmpConnState
-	stateMutex sync.Mutex
+	ctx          context.Context
+	ctxCancel    func()
+	uuid         uuid.UUID
+	created      time.Time
+	span         *telemetry.Span
+	state        rtmpConnState
+	stateMutex   sync.Mutex
+	unitsDropped *uint64
 }
 
 func newRTMPConn(
@@ -227,9 +240,17 @@ func newRTMPConn(
 	readBufferCount int,
 	runOnConnect string,
 	runOnConnectRestart bool,
+	runOnDisconnect string,
+	onConnectWebhook string,
+	onDisconnectWebhook string,
+	rejected bool,
 	wg *sync.WaitGroup,
 	nconn net.Conn,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	pathManager rtmpConnPathManager,
 	parent rtmpConnParent,
 ) *rtmpConn {
@@ -243,19 +264,43 @@ func newRTMPConn(
 		readBufferCount:     readBufferCount,
 		runOnConnect:        runOnConnect,
 		runOnConnectRestart: runOnConnectRestart,
+		runOnDisconnect:     runOnDisconnect,
+		onConnectWebhook:    onConnectWebhook,
+		onDisconnectWebhook: onDisconnectWebhook,
+		rejected:            rejected,
 		wg:                  wg,
 		conn:                rtmp.NewConn(nconn),
 		nconn:               nconn,
 		externalCmdPool:     externalCmdPool,
+		webhookSender:       webhookSender,
+		tracer:              tracer,
+		eventBus:            eventBus,
+		usageMeter:          usageMeter,
 		pathManager:         pathManager,
 		parent:              parent,
 		ctx:                 ctx,
 		ctxCancel:           ctxCancel,
 		uuid:                uuid.New(),
 		created:             time.Now(),
+		unitsDropped:        new(uint64),
 	}
 
-	c.Log(logger.Info, "opened")
+	c.span = c.tracer.Start(c.protocol()+" conn",
+		"id", c.uuid.String(),
+		"remoteAddr", c.remoteAddr().String())
+
+	if c.rejected {
+		c.Log(logger.Warn, "connection rejected since the maximum number of connections has been reached")
+	} else {
+		c.Log(logger.Info, "opened")
+
+		c.eventBus.publish(&event{
+			Type:       eventTypeConnOpened,
+			Protocol:   c.protocol(),
+			ID:         c.uuid.String(),
+			RemoteAddr: c.remoteAddr().String(),
+		})
+	}
 
 	c.wg.Add(1)
 	go c.run()
@@ -271,14 +316,90 @@ func (c *rtmpConn) remoteAddr() net.Addr {
 	return c.nconn.RemoteAddr()
 }
 
+func (c *rtmpConn) apiItem() rtmpServerAPIConnsListItem {
+	return rtmpServerAPIConnsListItem{
+		Created:    c.created,
+		RemoteAddr: c.remoteAddr().String(),
+		State: func() string {
+			switch c.safeState() {
+			case rtmpConnStateRead:
+				return "read"
+
+			case rtmpConnStatePublish:
+				return "publish"
+			}
+			return "idle"
+		}(),
+		BytesReceived: c.conn.BytesReceived(),
+		BytesSent:     c.conn.BytesSent(),
+		UnitsDropped:  atomic.LoadUint64(c.unitsDropped),
+	}
+}
+
 func (c *rtmpConn) Log(level logger.Level, format string, args ...interface{}) {
-	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.nconn.RemoteAddr()}, args...)...)
+	c.parent.Log(level, "[conn %v] "+format, logger.WithFields([]logger.Field{
+		{Key: "id", Value: c.uuid},
+		{Key: "remoteAddr", Value: c.nconn.RemoteAddr()},
+	}, append([]interface{}{c.nconn.RemoteAddr()}, args...))...)
+}
+
+func (c *rtmpConn) externalCmdEnv() externalcmd.Environment {
+	_, port, _ := net.SplitHostPort(c.rtspAddress)
+	return externalcmd.Environment{
+		"RTSP_PATH":     "",
+		"RTSP_PORT":     port,
+		"MTX_CONN_TYPE": c.protocol() + "Conn",
+		"MTX_CONN_ID":   c.uuid.String(),
+		"MTX_PROTOCOL":  c.protocol(),
+		"MTX_REMOTE_IP": c.ip().String(),
+	}
+}
+
+func (c *rtmpConn) readerExternalCmdEnv(path *path, user string, rawQuery string) externalcmd.Environment {
+	env := path.externalCmdEnv()
+	env["MTX_READER_TYPE"], env["MTX_READER_ID"] = apiDescribeTypeAndID(c.apiReaderDescribe())
+	env["MTX_PROTOCOL"] = c.protocol()
+	env["MTX_REMOTE_IP"] = c.ip().String()
+	env["MTX_USER"] = user
+	env["MTX_QUERY"] = rawQuery
+	return env
+}
+
+func (c *rtmpConn) onDisconnect() {
+	env := c.externalCmdEnv()
+	env["MTX_DURATION"] = externalCmdDuration(time.Since(c.created))
+	env["MTX_BYTES_RECEIVED"] = strconv.FormatUint(c.conn.BytesReceived(), 10)
+	env["MTX_BYTES_SENT"] = strconv.FormatUint(c.conn.BytesSent(), 10)
+
+	if c.runOnDisconnect != "" {
+		c.Log(logger.Info, "runOnDisconnect command launched")
+		externalcmd.RunOnce(
+			c.externalCmdPool,
+			c.runOnDisconnect,
+			env,
+			func(co int) {
+				c.Log(logger.Info, "runOnDisconnect command exited with code %d", co)
+			})
+	}
+
+	c.webhookSender.send(c.onDisconnectWebhook, "disconnect", env)
+}
+
+func (c *rtmpConn) protocol() string {
+	if c.isTLS {
+		return "rtmps"
+	}
+	return "rtmp"
 }
 
 func (c *rtmpConn) ip() net.IP {
 	return c.nconn.RemoteAddr().(*net.TCPAddr).IP
 }
 
+func (c *rtmpConn) peerCert() *x509.Certificate {
+	return tlsConnPeerCertificate(c.nconn)
+}
+
 func (c *rtmpConn) safeState() rtmpConnState {
 	c.stateMutex.Lock()
 	defer c.stateMutex.Unlock()
@@ -288,25 +409,28 @@ func (c *rtmpConn) safeState() rtmpConnState {
 func (c *rtmpConn) run() {
 	defer c.wg.Done()
 
-	if c.runOnConnect != "" {
-		c.Log(logger.Info, "runOnConnect command started")
-		_, port, _ := net.SplitHostPort(c.rtspAddress)
-		onConnectCmd := externalcmd.NewCmd(
-			c.externalCmdPool,
-			c.runOnConnect,
-			c.runOnConnectRestart,
-			externalcmd.Environment{
-				"RTSP_PATH": "",
-				"RTSP_PORT": port,
-			},
-			func(co int) {
-				c.Log(logger.Info, "runOnConnect command exited with code %d", co)
-			})
+	// rejected connections do not trigger hooks and events,
+	// in order not to amplify connection floods.
+	if !c.rejected {
+		c.webhookSender.send(c.onConnectWebhook, "connect", c.externalCmdEnv())
+		defer c.onDisconnect()
+
+		if c.runOnConnect != "" {
+			c.Log(logger.Info, "runOnConnect command started")
+			onConnectCmd := externalcmd.NewCmd(
+				c.externalCmdPool,
+				c.runOnConnect,
+				c.runOnConnectRestart,
+				c.externalCmdEnv(),
+				func(co int) {
+					c.Log(logger.Info, "runOnConnect command exited with code %d", co)
+				})
 
-		defer func() {
-			onConnectCmd.Close()
-			c.Log(logger.Info, "runOnConnect command stopped")
-		}()
+			defer func() {
+				onConnectCmd.Close()
+				c.Log(logger.Info, "runOnConnect command stopped")
+			}()
+		}
 	}
 
 	ctx, cancel := context.WithCancel(c.ctx)
@@ -330,7 +454,22 @@ func (c *rtmpConn) run() {
 
 	c.parent.connClose(c)
 
+	c.span.SetAttribute("closeReason", err.Error())
+	c.span.End()
+
+	if c.rejected {
+		return
+	}
+
 	c.Log(logger.Info, "closed (%v)", err)
+
+	c.eventBus.publish(&event{
+		Type:       eventTypeConnClosed,
+		Protocol:   c.protocol(),
+		ID:         c.uuid.String(),
+		RemoteAddr: c.remoteAddr().String(),
+		Error:      err.Error(),
+	})
 }
 
 func (c *rtmpConn) runInner(ctx context.Context) error {
@@ -341,39 +480,73 @@ func (c *rtmpConn) runInner(ctx context.Context) error {
 
 	c.nconn.SetReadDeadline(time.Now().Add(time.Duration(c.readTimeout)))
 	c.nconn.SetWriteDeadline(time.Now().Add(time.Duration(c.writeTimeout)))
+	handshakeSpan := c.span.Child("handshake")
 	u, publish, err := c.conn.InitializeServer()
+	handshakeSpan.SetError(err)
+	handshakeSpan.End()
 	if err != nil {
 		return err
 	}
 
+	if c.rejected {
+		code := "NetStream.Play.Failed"
+		if publish {
+			code = "NetStream.Publish.Denied"
+		}
+		c.conn.WriteStatusError(code, "too many connections")
+		return fmt.Errorf("connection rejected since the maximum number of connections has been reached")
+	}
+
 	if !publish {
 		return c.runRead(ctx, u)
 	}
 	return c.runPublish(ctx, u)
 }
 
+func (c *rtmpConn) publishAuthFailed(pathName string, authErr error) {
+	c.eventBus.publish(&event{
+		Type:       eventTypeAuthFailed,
+		Path:       pathName,
+		Protocol:   c.protocol(),
+		ID:         c.uuid.String(),
+		RemoteAddr: c.remoteAddr().String(),
+		Error:      authErr.Error(),
+	})
+}
+
 func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 	pathName, query, rawQuery := pathNameAndQuery(u)
 
+	credentials := authCredentials{
+		query: rawQuery,
+		ip:    c.ip(),
+		user:  query.Get("user"),
+		pass:  query.Get("pass"),
+		proto: authProtocolRTMP,
+		id:    &c.uuid,
+		cert:  c.peerCert(),
+	}
+
 	res := c.pathManager.readerAdd(pathReaderAddReq{
-		author:   c,
-		pathName: pathName,
-		credentials: authCredentials{
-			query: rawQuery,
-			ip:    c.ip(),
-			user:  query.Get("user"),
-			pass:  query.Get("pass"),
-			proto: authProtocolRTMP,
-			id:    &c.uuid,
-		},
+		author:      c,
+		pathName:    pathName,
+		credentials: credentials,
+		span:        c.span,
 	})
 
 	if res.err != nil {
 		if terr, ok := res.err.(pathErrAuth); ok {
+			c.publishAuthFailed(pathName, terr.wrapped)
+
 			// wait some seconds to stop brute force attacks
 			<-time.After(rtmpConnPauseAfterAuthError)
 			return terr.wrapped
 		}
+
+		switch res.err.(type) {
+		case pathErrTooManyReaders, usageErrQuotaExceeded:
+			c.conn.WriteStatusError("NetStream.Play.Failed", res.err.Error())
+		}
 		return res.err
 	}
 
@@ -383,14 +556,25 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 		path.readerRemove(pathReaderRemoveReq{author: c})
 	}()
 
+	usage := c.usageMeter.readerAdd(res.user, c.conn.BytesSent, c.close)
+	defer c.usageMeter.readerRemove(usage)
+
 	c.stateMutex.Lock()
 	c.state = rtmpConnStateRead
 	c.stateMutex.Unlock()
 
-	ringBuffer, _ := ringbuffer.New(uint64(c.readBufferCount))
+	firstFrameSpan := c.span.Child("first frame sent", "path", path.name)
+	defer func() {
+		if firstFrameSpan != nil {
+			firstFrameSpan.SetError(fmt.Errorf("no frames have been sent"))
+			firstFrameSpan.End()
+		}
+	}()
+
+	ringBuffer, _ := newReaderBuffer(c.readBufferCount, c.unitsDropped)
 	go func() {
 		<-ctx.Done()
-		ringBuffer.Close()
+		ringBuffer.close()
 	}()
 
 	var medias media.Medias
@@ -420,6 +604,9 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 		path.name, sourceMediaInfo(medias))
 
 	pathConf := path.safeConf()
+	env := c.readerExternalCmdEnv(path, query.Get("user"), rawQuery)
+	readStart := time.Now()
+	readStartBytesSent := c.conn.BytesSent()
 
 	if pathConf.RunOnRead != "" {
 		c.Log(logger.Info, "runOnRead command started")
@@ -427,7 +614,7 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 			c.externalCmdPool,
 			pathConf.RunOnRead,
 			pathConf.RunOnReadRestart,
-			path.externalCmdEnv(),
+			env,
 			func(co int) {
 				c.Log(logger.Info, "runOnRead command exited with code %d", co)
 			})
@@ -437,6 +624,27 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 		}()
 	}
 
+	c.webhookSender.send(pathConf.OnReadWebhook, "read", env)
+
+	defer func() {
+		env := c.readerExternalCmdEnv(path, query.Get("user"), rawQuery)
+		env["MTX_DURATION"] = externalCmdDuration(time.Since(readStart))
+		env["MTX_BYTES_SENT"] = strconv.FormatUint(c.conn.BytesSent()-readStartBytesSent, 10)
+
+		if pathConf.RunOnUnread != "" {
+			c.Log(logger.Info, "runOnUnread command launched")
+			externalcmd.RunOnce(
+				c.externalCmdPool,
+				pathConf.RunOnUnread,
+				env,
+				func(co int) {
+					c.Log(logger.Info, "runOnUnread command exited with code %d", co)
+				})
+		}
+
+		c.webhookSender.send(pathConf.OnUnreadWebhook, "unread", env)
+	}()
+
 	err := c.conn.WriteTracks(videoFormat, audioFormat)
 	if err != nil {
 		return err
@@ -445,8 +653,12 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 	// disable read deadline
 	c.nconn.SetReadDeadline(time.Time{})
 
+	// units are discarded until a key frame is received,
+	// therefore the first frame is sent when something is written after the tracks.
+	tracksBytesSent := c.conn.BytesSent()
+
 	for {
-		item, ok := ringBuffer.Pull()
+		item, ok := ringBuffer.pull()
 		if !ok {
 			return fmt.Errorf("terminated")
 		}
@@ -455,10 +667,15 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 		if err != nil {
 			return err
 		}
+
+		if firstFrameSpan != nil && c.conn.BytesSent() != tracksBytesSent {
+			firstFrameSpan.End()
+			firstFrameSpan = nil
+		}
 	}
 }
 
-func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBuffer,
+func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *readerBuffer,
 	videoFirstIDRFound *bool, videoStartDTS *time.Duration,
 ) (*media.Media, formats.Format) {
 	var videoFormatH264 *formats.H264
@@ -470,7 +687,7 @@ func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 		var videoDTSExtractor *h264.DTSExtractor
 
 		stream.readerAdd(c, videoMedia, videoFormatH264, func(unit formatprocessor.Unit) {
-			ringBuffer.Push(func() error {
+			ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitH264)
 
 				if tunit.AU == nil {
@@ -562,7 +779,7 @@ func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 	return nil, nil
 }
 
-func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *ringbuffer.RingBuffer,
+func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *readerBuffer,
 	videoFormat formats.Format, videoFirstIDRFound *bool, videoStartDTS *time.Duration,
 ) (*media.Media, formats.Format) {
 	var audioFormatMPEG4 *formats.MPEG4Audio
@@ -573,7 +790,7 @@ func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 		var audioStartPTS time.Duration
 
 		stream.readerAdd(c, audioMedia, audioFormatMPEG4, func(unit formatprocessor.Unit) {
-			ringBuffer.Push(func() error {
+			ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitMPEG4Audio)
 
 				if tunit.AUs == nil {
@@ -631,7 +848,7 @@ func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 		var audioStartPTS time.Duration
 
 		stream.readerAdd(c, audioMedia, audioFormatMPEG2, func(unit formatprocessor.Unit) {
-			ringBuffer.Push(func() error {
+			ringBuffer.push(func() error {
 				tunit := unit.(*formatprocessor.UnitMPEG2Audio)
 
 				if !audioStartPTSFilled {
@@ -721,11 +938,15 @@ func (c *rtmpConn) runPublish(ctx context.Context, u *url.URL) error {
 			pass:  query.Get("pass"),
 			proto: authProtocolRTMP,
 			id:    &c.uuid,
+			cert:  c.peerCert(),
 		},
+		span: c.span,
 	})
 
 	if res.err != nil {
 		if terr, ok := res.err.(pathErrAuth); ok {
+			c.publishAuthFailed(pathName, terr.wrapped)
+
 			// wait some seconds to stop brute force attacks
 			<-time.After(rtmpConnPauseAfterAuthError)
 			return terr.wrapped
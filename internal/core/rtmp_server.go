@@ -5,12 +5,14 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 type rtmpServerAPIConnsListItem struct {
@@ -19,6 +21,7 @@ type rtmpServerAPIConnsListItem struct {
 	State         string    `json:"state"`
 	BytesReceived uint64    `json:"bytesReceived"`
 	BytesSent     uint64    `json:"bytesSent"`
+	UnitsDropped  uint64    `json:"unitsDropped"`
 }
 
 type rtmpServerAPIConnsListData struct {
@@ -34,6 +37,16 @@ type rtmpServerAPIConnsListReq struct {
 	res chan rtmpServerAPIConnsListRes
 }
 
+type rtmpServerAPIConnsGetRes struct {
+	data *rtmpServerAPIConnsListItem
+	err  error
+}
+
+type rtmpServerAPIConnsGetReq struct {
+	id  string
+	res chan rtmpServerAPIConnsGetRes
+}
+
 type rtmpServerAPIConnsKickRes struct {
 	err error
 }
@@ -51,24 +64,34 @@ type rtmpServer struct {
 	readTimeout         conf.StringDuration
 	writeTimeout        conf.StringDuration
 	readBufferCount     int
+	maxConnections      int
 	isTLS               bool
 	rtspAddress         string
 	runOnConnect        string
 	runOnConnectRestart bool
+	runOnDisconnect     string
+	onConnectWebhook    string
+	onDisconnectWebhook string
 	externalCmdPool     *externalcmd.Pool
+	webhookSender       *webhookSender
 	metrics             *metrics
+	tracer              *telemetry.Tracer
+	eventBus            *eventBus
+	usageMeter          *usageMeter
 	pathManager         *pathManager
 	parent              rtmpServerParent
 
-	ctx       context.Context
-	ctxCancel func()
-	wg        sync.WaitGroup
-	ln        net.Listener
-	conns     map[*rtmpConn]struct{}
+	ctx        context.Context
+	ctxCancel  func()
+	wg         sync.WaitGroup
+	ln         net.Listener
+	certLoader *certLoader
+	conns      map[*rtmpConn]struct{}
 
 	// in
 	chConnClose    chan *rtmpConn
 	chAPIConnsList chan rtmpServerAPIConnsListReq
+	chAPIConnsGet  chan rtmpServerAPIConnsGetReq
 	chAPIConnsKick chan rtmpServerAPIConnsKickReq
 }
 
@@ -78,57 +101,89 @@ func newRTMPServer(
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
 	readBufferCount int,
+	maxConnections int,
 	isTLS bool,
 	serverCert string,
 	serverKey string,
+	clientCA string,
 	rtspAddress string,
 	runOnConnect string,
 	runOnConnectRestart bool,
+	runOnDisconnect string,
+	onConnectWebhook string,
+	onDisconnectWebhook string,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
 	metrics *metrics,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	pathManager *pathManager,
 	parent rtmpServerParent,
 ) (*rtmpServer, error) {
-	ln, err := func() (net.Listener, error) {
-		if !isTLS {
-			return net.Listen(restrictNetwork("tcp", address))
-		}
-
-		cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
-		if err != nil {
-			return nil, err
-		}
-
-		network, address := restrictNetwork("tcp", address)
-		return tls.Listen(network, address, &tls.Config{Certificates: []tls.Certificate{cert}})
-	}()
-	if err != nil {
-		return nil, err
-	}
-
 	ctx, ctxCancel := context.WithCancel(parentCtx)
 
 	s := &rtmpServer{
 		readTimeout:         readTimeout,
 		writeTimeout:        writeTimeout,
 		readBufferCount:     readBufferCount,
+		maxConnections:      maxConnections,
 		rtspAddress:         rtspAddress,
 		runOnConnect:        runOnConnect,
 		runOnConnectRestart: runOnConnectRestart,
+		runOnDisconnect:     runOnDisconnect,
+		onConnectWebhook:    onConnectWebhook,
+		onDisconnectWebhook: onDisconnectWebhook,
 		isTLS:               isTLS,
 		externalCmdPool:     externalCmdPool,
+		webhookSender:       webhookSender,
 		metrics:             metrics,
+		tracer:              tracer,
+		eventBus:            eventBus,
+		usageMeter:          usageMeter,
 		pathManager:         pathManager,
 		parent:              parent,
 		ctx:                 ctx,
 		ctxCancel:           ctxCancel,
-		ln:                  ln,
 		conns:               make(map[*rtmpConn]struct{}),
 		chConnClose:         make(chan *rtmpConn),
 		chAPIConnsList:      make(chan rtmpServerAPIConnsListReq),
+		chAPIConnsGet:       make(chan rtmpServerAPIConnsGetReq),
 		chAPIConnsKick:      make(chan rtmpServerAPIConnsKickReq),
 	}
 
+	var err error
+	s.ln, err = func() (net.Listener, error) {
+		if !isTLS {
+			return net.Listen(restrictNetwork("tcp", address))
+		}
+
+		var err error
+		s.certLoader, err = newCertLoader(serverCert, serverKey, s)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err := newServerTLSConfig(s.certLoader, clientCA)
+		if err != nil {
+			s.certLoader.close()
+			return nil, err
+		}
+
+		network, address := restrictNetwork("tcp", address)
+		ln, err := tls.Listen(network, address, tlsConfig)
+		if err != nil {
+			s.certLoader.close()
+			return nil, err
+		}
+
+		return ln, nil
+	}()
+	if err != nil {
+		ctxCancel()
+		return nil, err
+	}
+
 	s.Log(logger.Info, "listener opened on %s", address)
 
 	if s.metrics != nil {
@@ -148,13 +203,18 @@ func (s *rtmpServer) Log(level logger.Level, format string, args ...interface{})
 		}
 		return "RTMP"
 	}()
-	s.parent.Log(level, "[%s] "+format, append([]interface{}{label}, args...)...)
+	s.parent.Log(level, "[%s] "+format, logger.WithField("component", strings.ToLower(label),
+		append([]interface{}{label}, args...))...)
 }
 
 func (s *rtmpServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	s.wg.Wait()
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *rtmpServer) run() {
@@ -194,6 +254,8 @@ outer:
 			break outer
 
 		case nconn := <-connNew:
+			rejected := s.maxConnections != 0 && len(s.conns) >= s.maxConnections
+
 			c := newRTMPConn(
 				s.ctx,
 				s.isTLS,
@@ -203,12 +265,24 @@ outer:
 				s.readBufferCount,
 				s.runOnConnect,
 				s.runOnConnectRestart,
+				s.runOnDisconnect,
+				s.onConnectWebhook,
+				s.onDisconnectWebhook,
+				rejected,
 				&s.wg,
 				nconn,
 				s.externalCmdPool,
+				s.webhookSender,
+				s.tracer,
+				s.eventBus,
+				s.usageMeter,
 				s.pathManager,
 				s)
-			s.conns[c] = struct{}{}
+
+			// rejected connections are not counted, and are closed after notifying the client.
+			if !rejected {
+				s.conns[c] = struct{}{}
+			}
 
 		case c := <-s.chConnClose:
 			delete(s.conns, c)
@@ -219,26 +293,22 @@ outer:
 			}
 
 			for c := range s.conns {
-				data.Items[c.uuid.String()] = rtmpServerAPIConnsListItem{
-					Created:    c.created,
-					RemoteAddr: c.remoteAddr().String(),
-					State: func() string {
-						switch c.safeState() {
-						case rtmpConnStateRead:
-							return "read"
-
-						case rtmpConnStatePublish:
-							return "publish"
-						}
-						return "idle"
-					}(),
-					BytesReceived: c.conn.BytesReceived(),
-					BytesSent:     c.conn.BytesSent(),
-				}
+				data.Items[c.uuid.String()] = c.apiItem()
 			}
 
 			req.res <- rtmpServerAPIConnsListRes{data: data}
 
+		case req := <-s.chAPIConnsGet:
+			res := rtmpServerAPIConnsGetRes{err: apiErrNotFound{"connection", req.id}}
+			for c := range s.conns {
+				if c.uuid.String() == req.id {
+					item := c.apiItem()
+					res = rtmpServerAPIConnsGetRes{data: &item}
+					break
+				}
+			}
+			req.res <- res
+
 		case req := <-s.chAPIConnsKick:
 			res := func() bool {
 				for c := range s.conns {
@@ -293,6 +363,22 @@ func (s *rtmpServer) apiConnsList() rtmpServerAPIConnsListRes {
 	}
 }
 
+// apiConnsGet is called by api.
+func (s *rtmpServer) apiConnsGet(id string) rtmpServerAPIConnsGetRes {
+	req := rtmpServerAPIConnsGetReq{
+		id:  id,
+		res: make(chan rtmpServerAPIConnsGetRes),
+	}
+
+	select {
+	case s.chAPIConnsGet <- req:
+		return <-req.res
+
+	case <-s.ctx.Done():
+		return rtmpServerAPIConnsGetRes{err: fmt.Errorf("terminated")}
+	}
+}
+
 // apiConnsKick is called by api.
 func (s *rtmpServer) apiConnsKick(id string) rtmpServerAPIConnsKickRes {
 	req := rtmpServerAPIConnsKickReq{
@@ -10,6 +10,7 @@ import (
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/notedit/rtmp/format/flv/flvio"
 	"github.com/stretchr/testify/require"
 
 	"github.com/aler9/mediamtx/internal/rtmp"
@@ -433,3 +434,71 @@ func TestRTMPServerAuthFail(t *testing.T) {
 		require.EqualError(t, err, "EOF")
 	})
 }
+
+func TestRTMPServerMaxConnections(t *testing.T) {
+	f, err := os.CreateTemp(os.TempDir(), "rtmp-maxconnections-")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	p, ok := newInstance("rtmpMaxConnections: 1\n" +
+		"runOnConnect: sh -c 'echo aa >> " + f.Name() + "'\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	u, err := url.Parse("rtmp://127.0.0.1:1935/teststream")
+	require.NoError(t, err)
+
+	nconn1, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	defer nconn1.Close()
+	conn1 := rtmp.NewConn(nconn1)
+
+	err = conn1.InitializeClient(u, true)
+	require.NoError(t, err)
+
+	nconn2, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	defer nconn2.Close()
+	conn2 := rtmp.NewConn(nconn2)
+
+	err = conn2.InitializeClient(u, false)
+	require.NoError(t, err)
+
+	// skip status messages sent before the rejection
+	var msg message.Message
+	for {
+		msg, err = conn2.ReadMessage()
+		require.NoError(t, err)
+
+		cmd, ok := msg.(*message.CommandAMF0)
+		require.Equal(t, true, ok)
+		if cmd.Arguments[1].(flvio.AMFMap)[0].V == "error" {
+			break
+		}
+	}
+
+	require.Equal(t, &message.CommandAMF0{
+		ChunkStreamID:   5,
+		MessageStreamID: 0x1000000,
+		Name:            "onStatus",
+		CommandID:       0,
+		Arguments: []interface{}{
+			nil,
+			flvio.AMFMap{
+				{K: "level", V: "error"},
+				{K: "code", V: "NetStream.Play.Failed"},
+				{K: "description", V: "too many connections"},
+			},
+		},
+	}, msg)
+
+	time.Sleep(500 * time.Millisecond)
+
+	// runOnConnect is launched by the accepted connection only
+	byts, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, "aa\n", string(byts))
+}
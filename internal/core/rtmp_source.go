@@ -45,7 +45,7 @@ func newRTMPSource(
 }
 
 func (s *rtmpSource) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[rtmp source] "+format, args...)
+	s.parent.Log(level, "[rtmp source] "+format, logger.WithField("source", "rtmp", args)...)
 }
 
 // run implements sourceStaticImpl.
@@ -1,8 +1,10 @@
 package core
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v3"
@@ -14,6 +16,7 @@ import (
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 const (
@@ -26,40 +29,67 @@ type rtspConnParent interface {
 
 type rtspConn struct {
 	rtspAddress         string
+	isTLS               bool
 	authMethods         []headers.AuthMethod
 	readTimeout         conf.StringDuration
 	runOnConnect        string
 	runOnConnectRestart bool
+	runOnDisconnect     string
+	onConnectWebhook    string
+	onDisconnectWebhook string
 	externalCmdPool     *externalcmd.Pool
+	webhookSender       *webhookSender
+	tracer              *telemetry.Tracer
+	eventBus            *eventBus
 	pathManager         *pathManager
 	conn                *gortsplib.ServerConn
 	parent              rtspConnParent
 
 	uuid         uuid.UUID
 	created      time.Time
+	span         *telemetry.Span
 	onConnectCmd *externalcmd.Cmd
 	authNonce    string
 	authFailures int
+
+	// rejected is set when the connection exceeds rtspMaxConnections.
+	rejected bool
 }
 
 func newRTSPConn(
 	rtspAddress string,
+	isTLS bool,
 	authMethods []headers.AuthMethod,
 	readTimeout conf.StringDuration,
 	runOnConnect string,
 	runOnConnectRestart bool,
+	runOnDisconnect string,
+	onConnectWebhook string,
+	onDisconnectWebhook string,
+	rejected bool,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
 	pathManager *pathManager,
 	conn *gortsplib.ServerConn,
 	parent rtspConnParent,
 ) *rtspConn {
 	c := &rtspConn{
 		rtspAddress:         rtspAddress,
+		isTLS:               isTLS,
 		authMethods:         authMethods,
 		readTimeout:         readTimeout,
 		runOnConnect:        runOnConnect,
 		runOnConnectRestart: runOnConnectRestart,
+		runOnDisconnect:     runOnDisconnect,
+		onConnectWebhook:    onConnectWebhook,
+		onDisconnectWebhook: onDisconnectWebhook,
+		rejected:            rejected,
 		externalCmdPool:     externalCmdPool,
+		webhookSender:       webhookSender,
+		tracer:              tracer,
+		eventBus:            eventBus,
 		pathManager:         pathManager,
 		conn:                conn,
 		parent:              parent,
@@ -67,29 +97,47 @@ func newRTSPConn(
 		created:             time.Now(),
 	}
 
+	c.span = c.tracer.Start(c.protocol()+" conn",
+		"id", c.uuid.String(),
+		"remoteAddr", c.remoteAddr().String())
+
+	// rejected connections do not trigger hooks and events,
+	// in order not to amplify connection floods.
+	if c.rejected {
+		return c
+	}
+
 	c.Log(logger.Info, "opened")
 
+	c.eventBus.publish(&event{
+		Type:       eventTypeConnOpened,
+		Protocol:   c.protocol(),
+		ID:         c.uuid.String(),
+		RemoteAddr: c.remoteAddr().String(),
+	})
+
 	if c.runOnConnect != "" {
 		c.Log(logger.Info, "runOnConnect command started")
-		_, port, _ := net.SplitHostPort(c.rtspAddress)
 		c.onConnectCmd = externalcmd.NewCmd(
 			c.externalCmdPool,
 			c.runOnConnect,
 			c.runOnConnectRestart,
-			externalcmd.Environment{
-				"RTSP_PATH": "",
-				"RTSP_PORT": port,
-			},
+			c.externalCmdEnv(),
 			func(co int) {
 				c.Log(logger.Info, "runOnInit command exited with code %d", co)
 			})
 	}
 
+	c.webhookSender.send(c.onConnectWebhook, "connect", c.externalCmdEnv())
+
 	return c
 }
 
 func (c *rtspConn) Log(level logger.Level, format string, args ...interface{}) {
-	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.conn.NetConn().RemoteAddr()}, args...)...)
+	c.parent.Log(level, "[conn %v] "+format, logger.WithFields([]logger.Field{
+		{Key: "id", Value: c.uuid},
+		{Key: "remoteAddr", Value: c.conn.NetConn().RemoteAddr()},
+	}, append([]interface{}{c.conn.NetConn().RemoteAddr()}, args...))...)
 }
 
 // Conn returns the RTSP connection.
@@ -101,18 +149,83 @@ func (c *rtspConn) remoteAddr() net.Addr {
 	return c.conn.NetConn().RemoteAddr()
 }
 
+func (c *rtspConn) apiItem() rtspServerAPIConnsListItem {
+	return rtspServerAPIConnsListItem{
+		Created:       c.created,
+		RemoteAddr:    c.remoteAddr().String(),
+		BytesReceived: c.conn.BytesReceived(),
+		BytesSent:     c.conn.BytesSent(),
+	}
+}
+
+func (c *rtspConn) externalCmdEnv() externalcmd.Environment {
+	_, port, _ := net.SplitHostPort(c.rtspAddress)
+	return externalcmd.Environment{
+		"RTSP_PATH":     "",
+		"RTSP_PORT":     port,
+		"MTX_CONN_TYPE": c.protocol() + "Conn",
+		"MTX_CONN_ID":   c.uuid.String(),
+		"MTX_PROTOCOL":  c.protocol(),
+		"MTX_REMOTE_IP": c.ip().String(),
+	}
+}
+
+func (c *rtspConn) protocol() string {
+	if c.isTLS {
+		return "rtsps"
+	}
+	return "rtsp"
+}
+
 func (c *rtspConn) ip() net.IP {
 	return c.conn.NetConn().RemoteAddr().(*net.TCPAddr).IP
 }
 
+func (c *rtspConn) peerCert() *x509.Certificate {
+	return tlsConnPeerCertificate(c.conn.NetConn())
+}
+
 // onClose is called by rtspServer.
 func (c *rtspConn) onClose(err error) {
+	c.span.SetAttribute("closeReason", err.Error())
+	c.span.End()
+
+	if c.rejected {
+		return
+	}
+
 	c.Log(logger.Info, "closed (%v)", err)
 
+	c.eventBus.publish(&event{
+		Type:       eventTypeConnClosed,
+		Protocol:   c.protocol(),
+		ID:         c.uuid.String(),
+		RemoteAddr: c.remoteAddr().String(),
+		Error:      err.Error(),
+	})
+
 	if c.onConnectCmd != nil {
 		c.onConnectCmd.Close()
 		c.Log(logger.Info, "runOnConnect command stopped")
 	}
+
+	env := c.externalCmdEnv()
+	env["MTX_DURATION"] = externalCmdDuration(time.Since(c.created))
+	env["MTX_BYTES_RECEIVED"] = strconv.FormatUint(c.conn.BytesReceived(), 10)
+	env["MTX_BYTES_SENT"] = strconv.FormatUint(c.conn.BytesSent(), 10)
+
+	if c.runOnDisconnect != "" {
+		c.Log(logger.Info, "runOnDisconnect command launched")
+		externalcmd.RunOnce(
+			c.externalCmdPool,
+			c.runOnDisconnect,
+			env,
+			func(co int) {
+				c.Log(logger.Info, "runOnDisconnect command exited with code %d", co)
+			})
+	}
+
+	c.webhookSender.send(c.onDisconnectWebhook, "disconnect", env)
 }
 
 // onRequest is called by rtspServer.
@@ -125,6 +238,13 @@ func (c *rtspConn) OnResponse(res *base.Response) {
 	c.Log(logger.Debug, "[s->c] %v", res)
 }
 
+// rejectedResponse is the response sent to connections that exceed rtspMaxConnections.
+func (c *rtspConn) rejectedResponse() (*base.Response, error) {
+	return &base.Response{
+		StatusCode: base.StatusServiceUnavailable,
+	}, fmt.Errorf("too many connections")
+}
+
 // onDescribe is called by rtspServer.
 func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 ) (*base.Response, *gortsplib.ServerStream, error) {
@@ -142,6 +262,7 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 	res := c.pathManager.describe(pathDescribeReq{
 		pathName: ctx.Path,
 		url:      ctx.Request.URL,
+		span:     c.span,
 		credentials: authCredentials{
 			query:       ctx.Query,
 			ip:          c.ip(),
@@ -149,13 +270,14 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 			id:          &c.uuid,
 			rtspRequest: ctx.Request,
 			rtspNonce:   c.authNonce,
+			cert:        c.peerCert(),
 		},
 	})
 
 	if res.err != nil {
 		switch terr := res.err.(type) {
 		case pathErrAuth:
-			res, err := c.handleAuthError(terr.wrapped)
+			res, err := c.handleAuthError(ctx.Path, terr.wrapped)
 			return res, nil, err
 
 		case pathErrNoOnePublishing:
@@ -184,7 +306,7 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 	}, res.stream.rtspStream, nil
 }
 
-func (c *rtspConn) handleAuthError(authErr error) (*base.Response, error) {
+func (c *rtspConn) handleAuthError(pathName string, authErr error) (*base.Response, error) {
 	c.authFailures++
 
 	// VLC with login prompt sends 4 requests:
@@ -202,6 +324,15 @@ func (c *rtspConn) handleAuthError(authErr error) (*base.Response, error) {
 		}, nil
 	}
 
+	c.eventBus.publish(&event{
+		Type:       eventTypeAuthFailed,
+		Path:       pathName,
+		Protocol:   c.protocol(),
+		ID:         c.uuid.String(),
+		RemoteAddr: c.remoteAddr().String(),
+		Error:      authErr.Error(),
+	})
+
 	// wait some seconds to stop brute force attacks
 	<-time.After(rtspConnPauseAfterAuthError)
 
@@ -2,7 +2,6 @@ package core
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"strings"
 	"sync"
@@ -16,6 +15,7 @@ import (
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 type rtspServerAPIConnsListItem struct {
@@ -51,6 +51,16 @@ type rtspServerAPISessionsListRes struct {
 	err  error
 }
 
+type rtspServerAPIConnsGetRes struct {
+	data *rtspServerAPIConnsListItem
+	err  error
+}
+
+type rtspServerAPISessionsGetRes struct {
+	data *rtspServerAPISessionsListItem
+	err  error
+}
+
 type rtspServerAPISessionsKickRes struct {
 	err error
 }
@@ -81,20 +91,30 @@ type rtspServer struct {
 	isTLS               bool
 	rtspAddress         string
 	protocols           map[conf.Protocol]struct{}
+	maxConnections      int
 	runOnConnect        string
 	runOnConnectRestart bool
+	runOnDisconnect     string
+	onConnectWebhook    string
+	onDisconnectWebhook string
 	externalCmdPool     *externalcmd.Pool
+	webhookSender       *webhookSender
 	metrics             *metrics
+	tracer              *telemetry.Tracer
+	eventBus            *eventBus
+	usageMeter          *usageMeter
 	pathManager         *pathManager
 	parent              rtspServerParent
 
-	ctx       context.Context
-	ctxCancel func()
-	wg        sync.WaitGroup
-	srv       *gortsplib.Server
-	mutex     sync.RWMutex
-	conns     map[*gortsplib.ServerConn]*rtspConn
-	sessions  map[*gortsplib.ServerSession]*rtspSession
+	ctx          context.Context
+	ctxCancel    func()
+	wg           sync.WaitGroup
+	srv          *gortsplib.Server
+	certLoader   *certLoader
+	mutex        sync.RWMutex
+	conns        map[*gortsplib.ServerConn]*rtspConn
+	pendingConns int
+	sessions     map[*gortsplib.ServerSession]*rtspSession
 }
 
 func newRTSPServer(
@@ -114,12 +134,21 @@ func newRTSPServer(
 	isTLS bool,
 	serverCert string,
 	serverKey string,
+	clientCA string,
 	rtspAddress string,
 	protocols map[conf.Protocol]struct{},
+	maxConnections int,
 	runOnConnect string,
 	runOnConnectRestart bool,
+	runOnDisconnect string,
+	onConnectWebhook string,
+	onDisconnectWebhook string,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
 	metrics *metrics,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	pathManager *pathManager,
 	parent rtspServerParent,
 ) (*rtspServer, error) {
@@ -131,10 +160,18 @@ func newRTSPServer(
 		isTLS:               isTLS,
 		rtspAddress:         rtspAddress,
 		protocols:           protocols,
+		maxConnections:      maxConnections,
 		runOnConnect:        runOnConnect,
 		runOnConnectRestart: runOnConnectRestart,
+		runOnDisconnect:     runOnDisconnect,
+		onConnectWebhook:    onConnectWebhook,
+		onDisconnectWebhook: onDisconnectWebhook,
 		externalCmdPool:     externalCmdPool,
+		webhookSender:       webhookSender,
 		metrics:             metrics,
+		tracer:              tracer,
+		eventBus:            eventBus,
+		usageMeter:          usageMeter,
 		pathManager:         pathManager,
 		parent:              parent,
 		ctx:                 ctx,
@@ -164,16 +201,24 @@ func newRTSPServer(
 	}
 
 	if isTLS {
-		cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+		var err error
+		s.certLoader, err = newCertLoader(serverCert, serverKey, s)
 		if err != nil {
 			return nil, err
 		}
 
-		s.srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		s.srv.TLSConfig, err = newServerTLSConfig(s.certLoader, clientCA)
+		if err != nil {
+			s.certLoader.close()
+			return nil, err
+		}
 	}
 
 	err := s.srv.Start()
 	if err != nil {
+		if s.certLoader != nil {
+			s.certLoader.close()
+		}
 		return nil, err
 	}
 
@@ -200,13 +245,18 @@ func (s *rtspServer) Log(level logger.Level, format string, args ...interface{})
 		}
 		return "RTSP"
 	}()
-	s.parent.Log(level, "[%s] "+format, append([]interface{}{label}, args...)...)
+	s.parent.Log(level, "[%s] "+format, logger.WithField("component", strings.ToLower(label),
+		append([]interface{}{label}, args...))...)
 }
 
 func (s *rtspServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	s.wg.Wait()
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *rtspServer) run() {
@@ -242,27 +292,53 @@ outer:
 
 // OnConnOpen implements gortsplib.ServerHandlerOnConnOpen.
 func (s *rtspServer) OnConnOpen(ctx *gortsplib.ServerHandlerOnConnOpenCtx) {
+	// the limit is checked before creating the connection,
+	// since connection hooks must not be triggered by rejected connections.
+	// The slot is reserved, in order not to hold the mutex while the connection is created.
+	s.mutex.Lock()
+	rejected := s.maxConnections != 0 && len(s.conns)+s.pendingConns >= s.maxConnections
+	if !rejected {
+		s.pendingConns++
+	}
+	s.mutex.Unlock()
+
 	c := newRTSPConn(
 		s.rtspAddress,
+		s.isTLS,
 		s.authMethods,
 		s.readTimeout,
 		s.runOnConnect,
 		s.runOnConnectRestart,
+		s.runOnDisconnect,
+		s.onConnectWebhook,
+		s.onDisconnectWebhook,
+		rejected,
 		s.externalCmdPool,
+		s.webhookSender,
+		s.tracer,
+		s.eventBus,
 		s.pathManager,
 		ctx.Conn,
 		s)
+
+	ctx.Conn.SetUserData(c)
+
+	if rejected {
+		c.Log(logger.Warn, "connection rejected since the maximum number of connections (%d) has been reached",
+			s.maxConnections)
+		return
+	}
+
 	s.mutex.Lock()
+	s.pendingConns--
 	s.conns[ctx.Conn] = c
 	s.mutex.Unlock()
-
-	ctx.Conn.SetUserData(c)
 }
 
 // OnConnClose implements gortsplib.ServerHandlerOnConnClose.
 func (s *rtspServer) OnConnClose(ctx *gortsplib.ServerHandlerOnConnCloseCtx) {
+	c := ctx.Conn.UserData().(*rtspConn)
 	s.mutex.Lock()
-	c := s.conns[ctx.Conn]
 	delete(s.conns, ctx.Conn)
 	s.mutex.Unlock()
 	c.onClose(ctx.Error)
@@ -287,7 +363,10 @@ func (s *rtspServer) OnSessionOpen(ctx *gortsplib.ServerHandlerOnSessionOpenCtx)
 		s.protocols,
 		ctx.Session,
 		ctx.Conn,
+		ctx.Conn.UserData().(*rtspConn).span,
 		s.externalCmdPool,
+		s.webhookSender,
+		s.usageMeter,
 		s.pathManager,
 		s)
 	s.mutex.Lock()
@@ -312,12 +391,19 @@ func (s *rtspServer) OnSessionClose(ctx *gortsplib.ServerHandlerOnSessionCloseCt
 func (s *rtspServer) OnDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 ) (*base.Response, *gortsplib.ServerStream, error) {
 	c := ctx.Conn.UserData().(*rtspConn)
+	if c.rejected {
+		res, err := c.rejectedResponse()
+		return res, nil, err
+	}
 	return c.onDescribe(ctx)
 }
 
 // OnAnnounce implements gortsplib.ServerHandlerOnAnnounce.
 func (s *rtspServer) OnAnnounce(ctx *gortsplib.ServerHandlerOnAnnounceCtx) (*base.Response, error) {
 	c := ctx.Conn.UserData().(*rtspConn)
+	if c.rejected {
+		return c.rejectedResponse()
+	}
 	se := ctx.Session.UserData().(*rtspSession)
 	return se.onAnnounce(c, ctx)
 }
@@ -325,6 +411,10 @@ func (s *rtspServer) OnAnnounce(ctx *gortsplib.ServerHandlerOnAnnounceCtx) (*bas
 // OnSetup implements gortsplib.ServerHandlerOnSetup.
 func (s *rtspServer) OnSetup(ctx *gortsplib.ServerHandlerOnSetupCtx) (*base.Response, *gortsplib.ServerStream, error) {
 	c := ctx.Conn.UserData().(*rtspConn)
+	if c.rejected {
+		res, err := c.rejectedResponse()
+		return res, nil, err
+	}
 	se := ctx.Session.UserData().(*rtspSession)
 	return se.onSetup(c, ctx)
 }
@@ -375,17 +465,33 @@ func (s *rtspServer) apiConnsList() rtspServerAPIConnsListRes {
 	}
 
 	for _, c := range s.conns {
-		data.Items[c.uuid.String()] = rtspServerAPIConnsListItem{
-			Created:       c.created,
-			RemoteAddr:    c.remoteAddr().String(),
-			BytesReceived: c.conn.BytesReceived(),
-			BytesSent:     c.conn.BytesSent(),
-		}
+		data.Items[c.uuid.String()] = c.apiItem()
 	}
 
 	return rtspServerAPIConnsListRes{data: data}
 }
 
+// apiConnsGet is called by api.
+func (s *rtspServer) apiConnsGet(id string) rtspServerAPIConnsGetRes {
+	select {
+	case <-s.ctx.Done():
+		return rtspServerAPIConnsGetRes{err: fmt.Errorf("terminated")}
+	default:
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, c := range s.conns {
+		if c.uuid.String() == id {
+			item := c.apiItem()
+			return rtspServerAPIConnsGetRes{data: &item}
+		}
+	}
+
+	return rtspServerAPIConnsGetRes{err: apiErrNotFound{"connection", id}}
+}
+
 // apiSessionsList is called by api and metrics.
 func (s *rtspServer) apiSessionsList() rtspServerAPISessionsListRes {
 	select {
@@ -402,29 +508,33 @@ func (s *rtspServer) apiSessionsList() rtspServerAPISessionsListRes {
 	}
 
 	for _, s := range s.sessions {
-		data.Items[s.uuid.String()] = rtspServerAPISessionsListItem{
-			Created:    s.created,
-			RemoteAddr: s.remoteAddr().String(),
-			State: func() string {
-				switch s.safeState() {
-				case gortsplib.ServerSessionStatePrePlay,
-					gortsplib.ServerSessionStatePlay:
-					return "read"
-
-				case gortsplib.ServerSessionStatePreRecord,
-					gortsplib.ServerSessionStateRecord:
-					return "publish"
-				}
-				return "idle"
-			}(),
-			BytesReceived: s.session.BytesReceived(),
-			BytesSent:     s.session.BytesSent(),
-		}
+		data.Items[s.uuid.String()] = s.apiItem()
 	}
 
 	return rtspServerAPISessionsListRes{data: data}
 }
 
+// apiSessionsGet is called by api.
+func (s *rtspServer) apiSessionsGet(id string) rtspServerAPISessionsGetRes {
+	select {
+	case <-s.ctx.Done():
+		return rtspServerAPISessionsGetRes{err: fmt.Errorf("terminated")}
+	default:
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, se := range s.sessions {
+		if se.uuid.String() == id {
+			item := se.apiItem()
+			return rtspServerAPISessionsGetRes{data: &item}
+		}
+	}
+
+	return rtspServerAPISessionsGetRes{err: apiErrNotFound{"session", id}}
+}
+
 // apiSessionsKick is called by api.
 func (s *rtspServer) apiSessionsKick(id string) rtspServerAPISessionsKickRes {
 	select {
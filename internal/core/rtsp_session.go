@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/auth"
 	"github.com/bluenviron/gortsplib/v3/pkg/base"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/headers"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/google/uuid"
@@ -20,6 +22,7 @@ import (
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 )
 
 type rtspWriteFunc func(*rtp.Packet)
@@ -107,16 +110,25 @@ type rtspSession struct {
 	session         *gortsplib.ServerSession
 	author          *gortsplib.ServerConn
 	externalCmdPool *externalcmd.Pool
+	webhookSender   *webhookSender
+	usageMeter      *usageMeter
 	pathManager     rtspSessionPathManager
 	parent          rtspSessionParent
 
-	uuid       uuid.UUID
-	created    time.Time
-	path       *path
-	stream     *stream
-	state      gortsplib.ServerSessionState
-	stateMutex sync.Mutex
-	onReadCmd  *externalcmd.Cmd // read
+	uuid           uuid.UUID
+	created        time.Time
+	span           *telemetry.Span
+	firstFrameSpan *telemetry.Span
+	path           *path
+	stream         *stream
+	state          gortsplib.ServerSessionState
+	stateMutex     sync.Mutex
+	onReadCmd      *externalcmd.Cmd  // read
+	readUser       string            // read
+	usageUser      string            // read
+	readUsage      *usageMeterReader // read
+	readQuery      string            // read
+	readStart      time.Time         // read
 }
 
 func newRTSPSession(
@@ -124,7 +136,10 @@ func newRTSPSession(
 	protocols map[conf.Protocol]struct{},
 	session *gortsplib.ServerSession,
 	sc *gortsplib.ServerConn,
+	connSpan *telemetry.Span,
 	externalCmdPool *externalcmd.Pool,
+	webhookSender *webhookSender,
+	usageMeter *usageMeter,
 	pathManager rtspSessionPathManager,
 	parent rtspSessionParent,
 ) *rtspSession {
@@ -134,12 +149,17 @@ func newRTSPSession(
 		session:         session,
 		author:          sc,
 		externalCmdPool: externalCmdPool,
+		webhookSender:   webhookSender,
+		usageMeter:      usageMeter,
 		pathManager:     pathManager,
 		parent:          parent,
 		uuid:            uuid.New(),
 		created:         time.Now(),
 	}
 
+	// a session is traced as part of the connection that created it
+	s.span = connSpan.Child(s.protocol()+" session", "id", s.uuid.String())
+
 	s.Log(logger.Info, "created by %v", s.author.NetConn().RemoteAddr())
 
 	return s
@@ -160,19 +180,82 @@ func (s *rtspSession) remoteAddr() net.Addr {
 	return s.author.NetConn().RemoteAddr()
 }
 
+func (s *rtspSession) apiItem() rtspServerAPISessionsListItem {
+	return rtspServerAPISessionsListItem{
+		Created:    s.created,
+		RemoteAddr: s.remoteAddr().String(),
+		State: func() string {
+			switch s.safeState() {
+			case gortsplib.ServerSessionStatePrePlay,
+				gortsplib.ServerSessionStatePlay:
+				return "read"
+
+			case gortsplib.ServerSessionStatePreRecord,
+				gortsplib.ServerSessionStateRecord:
+				return "publish"
+			}
+			return "idle"
+		}(),
+		BytesReceived: s.session.BytesReceived(),
+		BytesSent:     s.session.BytesSent(),
+	}
+}
+
+func (s *rtspSession) protocol() string {
+	if s.isTLS {
+		return "rtsps"
+	}
+	return "rtsp"
+}
+
+func (s *rtspSession) readerExternalCmdEnv() externalcmd.Environment {
+	env := s.path.externalCmdEnv()
+	env["MTX_READER_TYPE"], env["MTX_READER_ID"] = apiDescribeTypeAndID(s.apiReaderDescribe())
+	env["MTX_PROTOCOL"] = s.protocol()
+	env["MTX_REMOTE_IP"] = s.remoteAddr().(*net.TCPAddr).IP.String()
+	env["MTX_USER"] = s.readUser
+	env["MTX_QUERY"] = s.readQuery
+	return env
+}
+
 func (s *rtspSession) Log(level logger.Level, format string, args ...interface{}) {
 	id := hex.EncodeToString(s.uuid[:4])
-	s.parent.Log(level, "[session %s] "+format, append([]interface{}{id}, args...)...)
+	s.parent.Log(level, "[session %s] "+format, logger.WithFields([]logger.Field{
+		{Key: "id", Value: s.uuid},
+		{Key: "remoteAddr", Value: s.remoteAddr()},
+	}, append([]interface{}{id}, args...))...)
 }
 
 // onClose is called by rtspServer.
 func (s *rtspSession) onClose(err error) {
 	if s.session.State() == gortsplib.ServerSessionStatePlay {
+		s.usageMeter.readerRemove(s.readUsage)
+		s.readUsage = nil
+
 		if s.onReadCmd != nil {
 			s.onReadCmd.Close()
 			s.onReadCmd = nil
 			s.Log(logger.Info, "runOnRead command stopped")
 		}
+
+		pathConf := s.path.safeConf()
+
+		env := s.readerExternalCmdEnv()
+		env["MTX_DURATION"] = externalCmdDuration(time.Since(s.readStart))
+		env["MTX_BYTES_SENT"] = strconv.FormatUint(s.session.BytesSent(), 10)
+
+		if pathConf.RunOnUnread != "" {
+			s.Log(logger.Info, "runOnUnread command launched")
+			externalcmd.RunOnce(
+				s.externalCmdPool,
+				pathConf.RunOnUnread,
+				env,
+				func(co int) {
+					s.Log(logger.Info, "runOnUnread command exited with code %d", co)
+				})
+		}
+
+		s.webhookSender.send(pathConf.OnUnreadWebhook, "unread", env)
 	}
 
 	switch s.session.State() {
@@ -186,6 +269,11 @@ func (s *rtspSession) onClose(err error) {
 	s.path = nil
 	s.stream = nil
 
+	s.firstFrameSpan.SetError(fmt.Errorf("no frames have been sent"))
+	s.firstFrameSpan.End()
+	s.span.SetAttribute("closeReason", err.Error())
+	s.span.End()
+
 	s.Log(logger.Info, "destroyed (%v)", err)
 }
 
@@ -213,13 +301,15 @@ func (s *rtspSession) onAnnounce(c *rtspConn, ctx *gortsplib.ServerHandlerOnAnno
 			rtspRequest: ctx.Request,
 			rtspBaseURL: nil,
 			rtspNonce:   c.authNonce,
+			cert:        c.peerCert(),
 		},
+		span: s.span,
 	})
 
 	if res.err != nil {
 		switch terr := res.err.(type) {
 		case pathErrAuth:
-			return c.handleAuthError(terr.wrapped)
+			return c.handleAuthError(ctx.Path, terr.wrapped)
 
 		default:
 			return &base.Response{
@@ -280,24 +370,28 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 			c.authNonce = auth.GenerateNonce()
 		}
 
+		credentials := authCredentials{
+			query:       ctx.Query,
+			ip:          c.ip(),
+			proto:       authProtocolRTSP,
+			id:          &c.uuid,
+			rtspRequest: ctx.Request,
+			rtspBaseURL: baseURL,
+			rtspNonce:   c.authNonce,
+			cert:        c.peerCert(),
+		}
+
 		res := s.pathManager.readerAdd(pathReaderAddReq{
-			author:   s,
-			pathName: ctx.Path,
-			credentials: authCredentials{
-				query:       ctx.Query,
-				ip:          c.ip(),
-				proto:       authProtocolRTSP,
-				id:          &c.uuid,
-				rtspRequest: ctx.Request,
-				rtspBaseURL: baseURL,
-				rtspNonce:   c.authNonce,
-			},
+			author:      s,
+			pathName:    ctx.Path,
+			credentials: credentials,
+			span:        s.span,
 		})
 
 		if res.err != nil {
 			switch terr := res.err.(type) {
 			case pathErrAuth:
-				res, err := c.handleAuthError(terr.wrapped)
+				res, err := c.handleAuthError(ctx.Path, terr.wrapped)
 				return res, nil, err
 
 			case pathErrNoOnePublishing:
@@ -305,6 +399,16 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 					StatusCode: base.StatusNotFound,
 				}, nil, res.err
 
+			case pathErrTooManyReaders:
+				return &base.Response{
+					StatusCode: base.StatusServiceUnavailable,
+				}, nil, res.err
+
+			case usageErrQuotaExceeded:
+				return &base.Response{
+					StatusCode: base.StatusForbidden,
+				}, nil, res.err
+
 			default:
 				return &base.Response{
 					StatusCode: base.StatusBadRequest,
@@ -314,6 +418,9 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 
 		s.path = res.path
 		s.stream = res.stream
+		s.readUser = credentials.authUser()
+		s.usageUser = res.user
+		s.readQuery = ctx.Query
 
 		s.stateMutex.Lock()
 		s.state = gortsplib.ServerSessionStatePrePlay
@@ -341,6 +448,8 @@ func (s *rtspSession) onPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Respo
 			sourceMediaInfo(s.session.SetuppedMedias()))
 
 		pathConf := s.path.safeConf()
+		s.readStart = time.Now()
+		s.readUsage = s.usageMeter.readerAdd(s.usageUser, s.session.BytesSent, s.close)
 
 		if pathConf.RunOnRead != "" {
 			s.Log(logger.Info, "runOnRead command started")
@@ -348,12 +457,17 @@ func (s *rtspSession) onPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Respo
 				s.externalCmdPool,
 				pathConf.RunOnRead,
 				pathConf.RunOnReadRestart,
-				s.path.externalCmdEnv(),
+				s.readerExternalCmdEnv(),
 				func(co int) {
 					s.Log(logger.Info, "runOnRead command exited with code %d", co)
 				})
 		}
 
+		s.webhookSender.send(pathConf.OnReadWebhook, "read", s.readerExternalCmdEnv())
+
+		s.firstFrameSpan = s.span.Child("first frame sent", "path", s.path.name)
+		s.stream.onNextRTSPPacket(s.firstFrameSpan.End)
+
 		s.stateMutex.Lock()
 		s.state = gortsplib.ServerSessionStatePlay
 		s.stateMutex.Unlock()
@@ -413,6 +527,9 @@ func (s *rtspSession) onPause(ctx *gortsplib.ServerHandlerOnPauseCtx) (*base.Res
 			s.onReadCmd.Close()
 		}
 
+		s.usageMeter.readerRemove(s.readUsage)
+		s.readUsage = nil
+
 		s.stateMutex.Lock()
 		s.state = gortsplib.ServerSessionStatePrePlay
 		s.stateMutex.Unlock()
@@ -430,6 +547,24 @@ func (s *rtspSession) onPause(ctx *gortsplib.ServerHandlerOnPauseCtx) (*base.Res
 	}, nil
 }
 
+// rtspRequestUser returns the user that is contained into the Authorization header of a request.
+func rtspRequestUser(req *base.Request) string {
+	var h headers.Authorization
+	err := h.Unmarshal(req.Header["Authorization"])
+	if err != nil {
+		return ""
+	}
+
+	if h.Method == headers.AuthBasic {
+		return h.BasicUser
+	}
+
+	if h.DigestValues.Username != nil {
+		return *h.DigestValues.Username
+	}
+	return ""
+}
+
 // apiReaderDescribe implements reader.
 func (s *rtspSession) apiReaderDescribe() interface{} {
 	var typ string
@@ -462,10 +597,16 @@ func (s *rtspSession) apiSourceDescribe() interface{} {
 
 // onPacketLost is called by rtspServer.
 func (s *rtspSession) onPacketLost(ctx *gortsplib.ServerHandlerOnPacketLostCtx) {
+	if s.stream != nil {
+		s.stream.onPacketsLost(packetsLostFromError(ctx.Error))
+	}
 	s.Log(logger.Warn, ctx.Error.Error())
 }
 
 // onDecodeError is called by rtspServer.
 func (s *rtspSession) onDecodeError(ctx *gortsplib.ServerHandlerOnDecodeErrorCtx) {
+	if s.stream != nil {
+		s.stream.onDecodeError()
+	}
 	s.Log(logger.Warn, ctx.Error.Error())
 }
@@ -46,7 +46,7 @@ func newRTSPSource(
 }
 
 func (s *rtspSource) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[rtsp source] "+format, args...)
+	s.parent.Log(level, "[rtsp source] "+format, logger.WithField("source", "rtsp", args)...)
 }
 
 // run implements sourceStaticImpl.
@@ -73,6 +73,9 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 		}
 	}
 
+	// set when the source is ready, before receiving packets
+	var readyStream *stream
+
 	c := &gortsplib.Client{
 		Transport:       cnf.SourceProtocol.Transport,
 		TLSConfig:       tlsConfig,
@@ -90,9 +93,15 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 			s.Log(logger.Warn, err.Error())
 		},
 		OnPacketLost: func(err error) {
+			if readyStream != nil {
+				readyStream.onPacketsLost(packetsLostFromError(err))
+			}
 			s.Log(logger.Warn, err.Error())
 		},
 		OnDecodeError: func(err error) {
+			if readyStream != nil {
+				readyStream.onDecodeError()
+			}
 			s.Log(logger.Warn, err.Error())
 		},
 	}
@@ -131,6 +140,8 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 
 			s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
 
+			readyStream = res.stream
+
 			defer func() {
 				s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
 			}()
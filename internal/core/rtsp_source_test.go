@@ -229,3 +229,150 @@ func TestRTSPSourceNoPassword(t *testing.T) {
 
 	<-done
 }
+
+func TestRTSPSourceFailover(t *testing.T) {
+	startSource := func(address string, id byte) func() {
+		medi := testMediaH264
+		stream := gortsplib.NewServerStream(media.Medias{medi})
+
+		s := gortsplib.Server{
+			Handler: &testServer{
+				onDescribe: func(ctx *gortsplib.ServerHandlerOnDescribeCtx,
+				) (*base.Response, *gortsplib.ServerStream, error) {
+					return &base.Response{
+						StatusCode: base.StatusOK,
+					}, stream, nil
+				},
+				onSetup: func(ctx *gortsplib.ServerHandlerOnSetupCtx) (*base.Response, *gortsplib.ServerStream, error) {
+					return &base.Response{
+						StatusCode: base.StatusOK,
+					}, stream, nil
+				},
+				onPlay: func(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+					return &base.Response{
+						StatusCode: base.StatusOK,
+					}, nil
+				},
+			},
+			RTSPAddress: address,
+		}
+		err := s.Start()
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		terminated := make(chan struct{})
+
+		go func() {
+			defer close(terminated)
+
+			t := time.NewTicker(50 * time.Millisecond)
+			defer t.Stop()
+
+			for i := 0; ; i++ {
+				select {
+				case <-t.C:
+					stream.WritePacketRTP(medi, &rtp.Packet{
+						Header: rtp.Header{
+							Version:        2,
+							PayloadType:    96,
+							SequenceNumber: uint16(1000*int(id) + i),
+							Timestamp:      uint32(i * 4500),
+							SSRC:           uint32(id),
+							Marker:         true,
+						},
+						Payload: []byte{0x05, id}, // IDR
+					})
+
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		return func() {
+			close(done)
+			<-terminated
+			s.Close()
+			s.Wait() //nolint:errcheck
+			stream.Close()
+		}
+	}
+
+	closeSource1 := startSource("127.0.0.1:8555", 1)
+	closeSource2 := startSource("127.0.0.1:8556", 2)
+	defer closeSource2()
+
+	p, ok := newInstance("rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  proxied:\n" +
+		"    source: rtsp://127.0.0.1:8555/teststream\n" +
+		"    sourceFailover: [rtsp://127.0.0.1:8556/teststream]\n" +
+		"    sourceFailbackPeriod: 1s\n" +
+		"    sourceProtocol: tcp\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	time.Sleep(1 * time.Second)
+
+	type receivedPacket struct {
+		source byte
+		seq    uint16
+		ssrc   uint32
+	}
+	received := make(chan receivedPacket, 1024)
+
+	v := gortsplib.TransportTCP
+	c := gortsplib.Client{
+		Transport: &v,
+	}
+
+	u, err := url.Parse("rtsp://127.0.0.1:8554/proxied")
+	require.NoError(t, err)
+
+	err = c.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer c.Close()
+
+	medias, baseURL, _, err := c.Describe(u)
+	require.NoError(t, err)
+
+	err = c.SetupAll(medias, baseURL)
+	require.NoError(t, err)
+
+	c.OnPacketRTP(medias[0], medias[0].Formats[0], func(pkt *rtp.Packet) {
+		received <- receivedPacket{source: pkt.Payload[1], seq: pkt.SequenceNumber, ssrc: pkt.SSRC}
+	})
+
+	_, err = c.Play(nil)
+	require.NoError(t, err)
+
+	var last receivedPacket
+
+	waitSource := func(id byte) {
+		for {
+			pkt := <-received
+			if last.ssrc != 0 {
+				require.Equal(t, last.ssrc, pkt.ssrc)
+				require.Equal(t, last.seq+1, pkt.seq)
+			}
+			last = pkt
+
+			if pkt.source == id {
+				return
+			}
+		}
+	}
+
+	waitSource(1)
+
+	// the stream switches to the failover source, without disconnecting readers
+	closeSource1()
+	waitSource(2)
+
+	// the stream switches back to the main source when it is available again
+	closeSource1 = startSource("127.0.0.1:8555", 1)
+	defer closeSource1()
+	waitSource(1)
+}
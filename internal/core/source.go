@@ -19,6 +19,17 @@ type source interface {
 	apiSourceDescribe() interface{}
 }
 
+// packetsLostFromError extracts the number of lost packets from the errors
+// passed to OnPacketLost(), that are in the format "N RTP packets lost".
+func packetsLostFromError(err error) uint64 {
+	var n uint64
+	_, serr := fmt.Sscanf(err.Error(), "%d ", &n)
+	if serr != nil || n == 0 {
+		return 1
+	}
+	return n
+}
+
 func mediaDescription(media *media.Media) string {
 	ret := make([]string, len(media.Formats))
 	for i, forma := range media.Formats {
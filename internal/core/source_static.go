@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aler9/mediamtx/internal/conf"
@@ -26,20 +27,161 @@ type sourceStaticParent interface {
 	sourceStaticSetNotReady(context.Context, pathSourceStaticSetNotReadyReq)
 }
 
+// sourceStaticCandidate is one of the URLs of a static source.
+type sourceStaticCandidate struct {
+	index  int
+	url    string
+	parent *sourceStatic
+	impl   sourceStaticImpl
+
+	ctx        context.Context
+	ctxCancel  func()
+	reloadConf chan *conf.PathConf
+	err        chan error
+}
+
+type sourceStaticCandidateSetReadyReq struct {
+	c   *sourceStaticCandidate
+	req pathSourceStaticSetReadyReq
+}
+
+type sourceStaticCandidateSetNotReadyReq struct {
+	c   *sourceStaticCandidate
+	req pathSourceStaticSetNotReadyReq
+}
+
+func (c *sourceStaticCandidate) conf(cnf *conf.PathConf) *conf.PathConf {
+	if c.index == 0 {
+		return cnf
+	}
+
+	ccnf := *cnf
+	ccnf.Source = c.url
+	return &ccnf
+}
+
+func (c *sourceStaticCandidate) start(cnf *conf.PathConf) {
+	c.ctx, c.ctxCancel = context.WithCancel(context.Background())
+	c.reloadConf = make(chan *conf.PathConf)
+	c.err = make(chan error)
+
+	ctx := c.ctx
+	reloadConf := c.reloadConf
+	errChan := c.err
+	ccnf := c.conf(cnf)
+
+	go func() {
+		errChan <- c.impl.run(ctx, ccnf, reloadConf)
+	}()
+}
+
+// stop stops the candidate and waits for its termination.
+func (c *sourceStaticCandidate) stop() {
+	c.ctxCancel()
+	<-c.err
+}
+
+func (c *sourceStaticCandidate) reload(cnf *conf.PathConf) {
+	ctx := c.ctx
+	reloadConf := c.reloadConf
+	ccnf := c.conf(cnf)
+
+	go func() {
+		select {
+		case reloadConf <- ccnf:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Log implements logger.Writer.
+func (c *sourceStaticCandidate) Log(level logger.Level, format string, args ...interface{}) {
+	c.parent.Log(level, format, args...)
+}
+
+// sourceStaticImplSetReady is called by a sourceStaticImpl.
+func (c *sourceStaticCandidate) sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes {
+	req.res = make(chan pathSourceStaticSetReadyRes, 1)
+	select {
+	case c.parent.chCandidateSetReady <- sourceStaticCandidateSetReadyReq{c: c, req: req}:
+		return <-req.res
+	case <-c.ctx.Done():
+		return pathSourceStaticSetReadyRes{err: fmt.Errorf("terminated")}
+	}
+}
+
+// sourceStaticImplSetNotReady is called by a sourceStaticImpl.
+func (c *sourceStaticCandidate) sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq) {
+	req.res = make(chan struct{})
+	select {
+	case c.parent.chCandidateSetNotReady <- sourceStaticCandidateSetNotReadyReq{c: c, req: req}:
+		<-req.res
+	case <-c.ctx.Done():
+	}
+}
+
+func newSourceStaticImpl(
+	source string,
+	readTimeout conf.StringDuration,
+	writeTimeout conf.StringDuration,
+	readBufferCount int,
+	parent *sourceStaticCandidate,
+) sourceStaticImpl {
+	switch {
+	case strings.HasPrefix(source, "rtsp://") ||
+		strings.HasPrefix(source, "rtsps://"):
+		return newRTSPSource(
+			readTimeout,
+			writeTimeout,
+			readBufferCount,
+			parent)
+
+	case strings.HasPrefix(source, "rtmp://") ||
+		strings.HasPrefix(source, "rtmps://"):
+		return newRTMPSource(
+			readTimeout,
+			writeTimeout,
+			parent)
+
+	case strings.HasPrefix(source, "http://") ||
+		strings.HasPrefix(source, "https://"):
+		return newHLSSource(
+			parent)
+
+	case strings.HasPrefix(source, "udp://"):
+		return newUDPSource(
+			readTimeout,
+			parent)
+
+	case strings.HasPrefix(source, "file://"):
+		return newFileSource(
+			parent)
+
+	default: // rpiCamera
+		return newRPICameraSource(
+			parent)
+	}
+}
+
 // sourceStatic is a static source.
+// When failover sources are provided, they are used in order of priority,
+// and the stream is kept while switching between them.
 type sourceStatic struct {
 	conf   *conf.PathConf
 	parent sourceStaticParent
 
-	ctx       context.Context
-	ctxCancel func()
-	impl      sourceStaticImpl
-	running   bool
+	ctx        context.Context
+	ctxCancel  func()
+	candidates []*sourceStaticCandidate
+	implMutex  sync.Mutex
+	impl       sourceStaticImpl
+	running    bool
 
 	// in
-	chReloadConf                  chan *conf.PathConf
-	chSourceStaticImplSetReady    chan pathSourceStaticSetReadyReq
-	chSourceStaticImplSetNotReady chan pathSourceStaticSetNotReadyReq
+	chReloadConf           chan *conf.PathConf
+	chRestart              chan struct{}
+	chCandidateSetReady    chan sourceStaticCandidateSetReadyReq
+	chCandidateSetNotReady chan sourceStaticCandidateSetNotReadyReq
 
 	// out
 	done chan struct{}
@@ -53,44 +195,25 @@ func newSourceStatic(
 	parent sourceStaticParent,
 ) *sourceStatic {
 	s := &sourceStatic{
-		conf:                          cnf,
-		parent:                        parent,
-		chReloadConf:                  make(chan *conf.PathConf),
-		chSourceStaticImplSetReady:    make(chan pathSourceStaticSetReadyReq),
-		chSourceStaticImplSetNotReady: make(chan pathSourceStaticSetNotReadyReq),
+		conf:                   cnf,
+		parent:                 parent,
+		chReloadConf:           make(chan *conf.PathConf),
+		chCandidateSetReady:    make(chan sourceStaticCandidateSetReadyReq),
+		chCandidateSetNotReady: make(chan sourceStaticCandidateSetNotReadyReq),
 	}
 
-	switch {
-	case strings.HasPrefix(cnf.Source, "rtsp://") ||
-		strings.HasPrefix(cnf.Source, "rtsps://"):
-		s.impl = newRTSPSource(
-			readTimeout,
-			writeTimeout,
-			readBufferCount,
-			s)
-
-	case strings.HasPrefix(cnf.Source, "rtmp://") ||
-		strings.HasPrefix(cnf.Source, "rtmps://"):
-		s.impl = newRTMPSource(
-			readTimeout,
-			writeTimeout,
-			s)
-
-	case strings.HasPrefix(cnf.Source, "http://") ||
-		strings.HasPrefix(cnf.Source, "https://"):
-		s.impl = newHLSSource(
-			s)
-
-	case strings.HasPrefix(cnf.Source, "udp://"):
-		s.impl = newUDPSource(
-			readTimeout,
-			s)
-
-	case cnf.Source == "rpiCamera":
-		s.impl = newRPICameraSource(
-			s)
+	for i, source := range cnf.StaticSources() {
+		c := &sourceStaticCandidate{
+			index:  i,
+			url:    source,
+			parent: s,
+		}
+		c.impl = newSourceStaticImpl(source, readTimeout, writeTimeout, readBufferCount, c)
+		s.candidates = append(s.candidates, c)
 	}
 
+	s.impl = s.candidates[0].impl
+
 	return s
 }
 
@@ -106,9 +229,10 @@ func (s *sourceStatic) start() {
 	}
 
 	s.running = true
-	s.impl.Log(logger.Info, "started")
+	s.currentImpl().Log(logger.Info, "started")
 
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.chRestart = make(chan struct{}, 1)
 	s.done = make(chan struct{})
 
 	go s.run()
@@ -120,7 +244,7 @@ func (s *sourceStatic) stop() {
 	}
 
 	s.running = false
-	s.impl.Log(logger.Info, "stopped")
+	s.currentImpl().Log(logger.Info, "stopped")
 
 	s.ctxCancel()
 
@@ -132,96 +256,194 @@ func (s *sourceStatic) Log(level logger.Level, format string, args ...interface{
 	s.parent.Log(level, format, args...)
 }
 
+func (s *sourceStatic) currentImpl() sourceStaticImpl {
+	s.implMutex.Lock()
+	defer s.implMutex.Unlock()
+	return s.impl
+}
+
+func candidateErr(c *sourceStaticCandidate) chan error {
+	if c == nil {
+		return nil
+	}
+	return c.err
+}
+
 func (s *sourceStatic) run() {
 	defer close(s.done)
 
-	var innerCtx context.Context
-	var innerCtxCancel func()
-	implErr := make(chan error)
-	innerReloadConf := make(chan *conf.PathConf)
+	var active *sourceStaticCandidate // nil while waiting to retry
+	var probe *sourceStaticCandidate  // candidate with higher priority that is being tested
+	pathReady := false
+	failed := 0 // candidates that failed in a row without becoming ready
+	next := 0   // candidate started after the retry pause
+	retryTimer := newEmptyTimer()
+	failbackTimer := newEmptyTimer()
 
-	recreate := func() {
-		innerCtx, innerCtxCancel = context.WithCancel(context.Background())
-		go func() {
-			implErr <- s.impl.run(innerCtx, s.conf, innerReloadConf)
-		}()
+	setActive := func(c *sourceStaticCandidate) {
+		s.implMutex.Lock()
+		s.impl = c.impl
+		s.implMutex.Unlock()
+
+		active = c
 	}
 
-	recreate()
+	setReady := func(req pathSourceStaticSetReadyReq) {
+		res := make(chan pathSourceStaticSetReadyRes, 1)
+		s.parent.sourceStaticSetReady(s.ctx, pathSourceStaticSetReadyReq{
+			medias:             req.medias,
+			generateRTPPackets: req.generateRTPPackets,
+			res:                res,
+		})
+		r := <-res
+		req.res <- r
+
+		if r.err == nil {
+			pathReady = true
+			failed = 0
+
+			if active.index != 0 && s.conf.SourceFailbackPeriod != 0 {
+				failbackTimer = time.NewTimer(time.Duration(s.conf.SourceFailbackPeriod))
+			}
+		}
+	}
 
-	recreating := false
-	recreateTimer := newEmptyTimer()
+	setNotReady := func() {
+		if pathReady {
+			pathReady = false
+			s.parent.sourceStaticSetNotReady(s.ctx, pathSourceStaticSetNotReadyReq{res: make(chan struct{})})
+		}
+	}
+
+	setActive(s.candidates[0])
+	active.start(s.conf)
 
 	for {
 		select {
-		case err := <-implErr:
-			innerCtxCancel()
-			s.impl.Log(logger.Info, "ERR: %v", err)
-			recreating = true
-			recreateTimer = time.NewTimer(sourceStaticRetryPause)
+		case req := <-s.chCandidateSetReady:
+			switch {
+			case req.c.ctx.Err() != nil || (req.c != active && req.c != probe):
+				req.req.res <- pathSourceStaticSetReadyRes{err: fmt.Errorf("terminated")}
+
+			case req.c == probe:
+				s.Log(logger.Info, "source #%d is available again, switching to it", probe.index+1)
+				failbackTimer.Stop()
+				active.stop()
+				setActive(probe)
+				probe = nil
+				setReady(req.req)
+
+			default:
+				setReady(req.req)
+			}
 
-		case newConf := <-s.chReloadConf:
-			s.conf = newConf
-			if !recreating {
-				cReloadConf := innerReloadConf
-				cInnerCtx := innerCtx
-				go func() {
-					select {
-					case cReloadConf <- newConf:
-					case <-cInnerCtx.Done():
-					}
-				}()
+		case req := <-s.chCandidateSetNotReady:
+			// with failover sources, the stream is kept until all sources have failed
+			if req.c == active && req.c.ctx.Err() == nil && len(s.candidates) == 1 && pathReady {
+				pathReady = false
+				s.parent.sourceStaticSetNotReady(s.ctx, req.req)
+			} else {
+				close(req.req.res)
 			}
 
-		case req := <-s.chSourceStaticImplSetReady:
-			s.parent.sourceStaticSetReady(s.ctx, req)
+		case err := <-candidateErr(active):
+			active.ctxCancel()
+			if err != nil {
+				active.impl.Log(logger.Info, "ERR: %v", err)
+			}
 
-		case req := <-s.chSourceStaticImplSetNotReady:
-			s.parent.sourceStaticSetNotReady(s.ctx, req)
+			failbackTimer.Stop()
+			if probe != nil {
+				probe.stop()
+				probe = nil
+			}
 
-		case <-recreateTimer.C:
-			recreate()
-			recreating = false
+			failed++
+			index := active.index
+			active = nil
+
+			if failed >= len(s.candidates) {
+				setNotReady()
+				failed = 0
+				next = 0
+				retryTimer = time.NewTimer(sourceStaticRetryPause)
+			} else {
+				next = (index + 1) % len(s.candidates)
+				s.Log(logger.Info, "switching to source #%d", next+1)
+				setActive(s.candidates[next])
+				active.start(s.conf)
+			}
+
+		case err := <-candidateErr(probe):
+			probe.ctxCancel()
+			probe.impl.Log(logger.Debug, "source #%d is still not available: %v", probe.index+1, err)
+
+			index := probe.index + 1
+			probe = nil
+
+			if index < active.index {
+				probe = s.candidates[index]
+				probe.start(s.conf)
+			} else {
+				failbackTimer = time.NewTimer(time.Duration(s.conf.SourceFailbackPeriod))
+			}
+
+		case <-failbackTimer.C:
+			if active != nil && probe == nil {
+				probe = s.candidates[0]
+				probe.start(s.conf)
+			}
+
+		case <-s.chRestart:
+			if active != nil {
+				// the candidate is replaced or recreated
+				// when the implementation returns
+				active.ctxCancel()
+			}
+
+		case newConf := <-s.chReloadConf:
+			s.conf = newConf
+
+			if active != nil {
+				active.reload(newConf)
+			}
+			if probe != nil {
+				probe.reload(newConf)
+			}
+
+		case <-retryTimer.C:
+			setActive(s.candidates[next])
+			active.start(s.conf)
 
 		case <-s.ctx.Done():
-			if !recreating {
-				innerCtxCancel()
-				<-implErr
+			if active != nil {
+				active.stop()
+			}
+			if probe != nil {
+				probe.stop()
 			}
 			return
 		}
 	}
 }
 
-func (s *sourceStatic) reloadConf(newConf *conf.PathConf) {
+// restart closes the current connection, that is then recreated,
+// or replaced with the next failover source. It doesn't block.
+func (s *sourceStatic) restart() {
 	select {
-	case s.chReloadConf <- newConf:
-	case <-s.ctx.Done():
+	case s.chRestart <- struct{}{}:
+	default:
 	}
 }
 
-// apiSourceDescribe implements source.
-func (s *sourceStatic) apiSourceDescribe() interface{} {
-	return s.impl.apiSourceDescribe()
-}
-
-// sourceStaticImplSetReady is called by a sourceStaticImpl.
-func (s *sourceStatic) sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes {
-	req.res = make(chan pathSourceStaticSetReadyRes)
+func (s *sourceStatic) reloadConf(newConf *conf.PathConf) {
 	select {
-	case s.chSourceStaticImplSetReady <- req:
-		return <-req.res
+	case s.chReloadConf <- newConf:
 	case <-s.ctx.Done():
-		return pathSourceStaticSetReadyRes{err: fmt.Errorf("terminated")}
 	}
 }
 
-// sourceStaticImplSetNotReady is called by a sourceStaticImpl.
-func (s *sourceStatic) sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq) {
-	req.res = make(chan struct{})
-	select {
-	case s.chSourceStaticImplSetNotReady <- req:
-		<-req.res
-	case <-s.ctx.Done():
-	}
+// apiSourceDescribe implements source.
+func (s *sourceStatic) apiSourceDescribe() interface{} {
+	return s.currentImpl().apiSourceDescribe()
 }
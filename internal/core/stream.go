@@ -1,6 +1,10 @@
 package core
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/bluenviron/gortsplib/v3"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
@@ -9,10 +13,29 @@ import (
 )
 
 type stream struct {
-	bytesReceived *uint64
+	udpMaxPayloadSize int
+	bytesReceived     *uint64
+	packetsLost       *uint64
+	decodeErrors      *uint64
 
 	rtspStream *gortsplib.ServerStream
 	smedias    map[*media.Media]*streamMedia
+
+	// medias and formats of the current source, mapped to the ones of the stream
+	linksMutex   sync.RWMutex
+	mediaLinks   map[*media.Media]*media.Media
+	formatLinks  map[formats.Format]formats.Format
+	ptsMutex     sync.Mutex
+	ptsOffset    time.Duration
+	ptsRebase    bool
+	lastPTS      time.Duration
+	lastPTSTime  time.Time
+	lastPTSIsSet bool
+
+	// callbacks called after the next RTP packet has been written to RTSP readers
+	rtspWrittenMutex     sync.Mutex
+	rtspWrittenCallbacks []func()
+	rtspWrittenCount     int32
 }
 
 func newStream(
@@ -20,11 +43,18 @@ func newStream(
 	medias media.Medias,
 	generateRTPPackets bool,
 	bytesReceived *uint64,
+	packetsLost *uint64,
+	decodeErrors *uint64,
 	source source,
 ) (*stream, error) {
 	s := &stream{
-		bytesReceived: bytesReceived,
-		rtspStream:    gortsplib.NewServerStream(medias),
+		udpMaxPayloadSize: udpMaxPayloadSize,
+		bytesReceived:     bytesReceived,
+		packetsLost:       packetsLost,
+		decodeErrors:      decodeErrors,
+		rtspStream:        gortsplib.NewServerStream(medias),
+		mediaLinks:        make(map[*media.Media]*media.Media),
+		formatLinks:       make(map[formats.Format]formats.Format),
 	}
 
 	s.smedias = make(map[*media.Media]*streamMedia)
@@ -35,6 +65,11 @@ func newStream(
 		if err != nil {
 			return nil, err
 		}
+
+		s.mediaLinks[media] = media
+		for _, forma := range media.Formats {
+			s.formatLinks[forma] = forma
+		}
 	}
 
 	return s, nil
@@ -54,6 +89,31 @@ func (s *stream) readerAdd(r reader, medi *media.Media, forma formats.Format, cb
 	sf.readerAdd(r, cb)
 }
 
+// onNextRTSPPacket calls cb after the next RTP packet has been written to RTSP readers.
+func (s *stream) onNextRTSPPacket(cb func()) {
+	s.rtspWrittenMutex.Lock()
+	defer s.rtspWrittenMutex.Unlock()
+	s.rtspWrittenCallbacks = append(s.rtspWrittenCallbacks, cb)
+	atomic.StoreInt32(&s.rtspWrittenCount, int32(len(s.rtspWrittenCallbacks)))
+}
+
+// rtspPacketWritten is called by streamFormat after RTP packets have been written to RTSP readers.
+func (s *stream) rtspPacketWritten() {
+	if atomic.LoadInt32(&s.rtspWrittenCount) == 0 {
+		return
+	}
+
+	s.rtspWrittenMutex.Lock()
+	cbs := s.rtspWrittenCallbacks
+	s.rtspWrittenCallbacks = nil
+	atomic.StoreInt32(&s.rtspWrittenCount, 0)
+	s.rtspWrittenMutex.Unlock()
+
+	for _, cb := range cbs {
+		cb()
+	}
+}
+
 func (s *stream) readerRemove(r reader) {
 	for _, sm := range s.smedias {
 		for _, sf := range sm.formats {
@@ -62,8 +122,137 @@ func (s *stream) readerRemove(r reader) {
 	}
 }
 
+// rebind replaces the source of the stream with another one, without disconnecting readers.
+// Medias of the new source must be compatible with the ones of the stream.
+func (s *stream) rebind(medias media.Medias, generateRTPPackets bool, source source) error {
+	err := mediasCompatible(s.medias(), medias)
+	if err != nil {
+		return err
+	}
+
+	mediaLinks := make(map[*media.Media]*media.Media)
+	formatLinks := make(map[formats.Format]formats.Format)
+	procs := make(map[formats.Format]formatprocessor.Processor)
+
+	for i, medi := range s.medias() {
+		newMedi := medias[i]
+		mediaLinks[newMedi] = medi
+
+		for j, forma := range medi.Formats {
+			newForma := newMedi.Formats[j]
+			formatLinks[newForma] = forma
+
+			procs[forma], err = formatprocessor.New(s.udpMaxPayloadSize, newForma, generateRTPPackets, source)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.linksMutex.Lock()
+	defer s.linksMutex.Unlock()
+
+	s.mediaLinks = mediaLinks
+	s.formatLinks = formatLinks
+
+	for i, medi := range s.medias() {
+		for j, forma := range medi.Formats {
+			s.smedias[medi].formats[forma].rebind(medias[i].Formats[j], procs[forma], source)
+		}
+	}
+
+	s.ptsMutex.Lock()
+	s.ptsRebase = true
+	s.ptsMutex.Unlock()
+
+	return nil
+}
+
+// unbind detaches the current source. Units are discarded until rebind() is called.
+func (s *stream) unbind() {
+	s.linksMutex.Lock()
+	defer s.linksMutex.Unlock()
+
+	s.mediaLinks = make(map[*media.Media]*media.Media)
+	s.formatLinks = make(map[formats.Format]formats.Format)
+}
+
 func (s *stream) writeUnit(medi *media.Media, forma formats.Format, data formatprocessor.Unit) {
-	sm := s.smedias[medi]
-	sf := sm.formats[forma]
-	sf.writeUnit(s, medi, data)
+	s.linksMutex.RLock()
+	defer s.linksMutex.RUnlock()
+
+	streamMedi, ok := s.mediaLinks[medi]
+	if !ok {
+		// unit of a source that has been replaced
+		return
+	}
+
+	sm := s.smedias[streamMedi]
+	sf := sm.formats[s.formatLinks[forma]]
+	sf.writeUnit(s, streamMedi, data)
+}
+
+// rebasePTS makes timestamps of decoded units continuous across source changes.
+// It is called for every unit, even when there are no non-RTSP readers, in order to keep
+// the offset updated in case a non-RTSP reader is added later.
+func (s *stream) rebasePTS(data formatprocessor.Unit) {
+	s.ptsMutex.Lock()
+	defer s.ptsMutex.Unlock()
+
+	now := time.Now()
+
+	pts, ok := unitPTS(data)
+	if !ok {
+		// the unit has not been decoded: keep track of elapsed time,
+		// and compute the offset when the first decoded unit is received.
+		if s.lastPTSIsSet {
+			s.lastPTS += now.Sub(s.lastPTSTime)
+			s.lastPTSTime = now
+		}
+		return
+	}
+
+	if s.ptsRebase {
+		s.ptsRebase = false
+		if s.lastPTSIsSet {
+			s.ptsOffset = s.lastPTS + now.Sub(s.lastPTSTime) - pts
+		}
+	}
+
+	pts += s.ptsOffset
+	unitSetPTS(data, pts)
+
+	if !s.lastPTSIsSet || pts > s.lastPTS {
+		s.lastPTS = pts
+		s.lastPTSTime = now
+		s.lastPTSIsSet = true
+	}
+}
+
+// onPacketsLost is called by sources.
+func (s *stream) onPacketsLost(n uint64) {
+	atomic.AddUint64(s.packetsLost, n)
+}
+
+// onDecodeError is called by sources.
+func (s *stream) onDecodeError() {
+	atomic.AddUint64(s.decodeErrors, 1)
+}
+
+// tracksStats returns the statistics of all tracks, in the same order of medias().
+func (s *stream) tracksStats() []streamFormatStats {
+	ret := []streamFormatStats{}
+
+	for i, medi := range s.medias() {
+		sm := s.smedias[medi]
+
+		for _, forma := range medi.Formats {
+			st := sm.formats[forma].stats()
+			st.Track = i
+			st.Codec = forma.String()
+			ret = append(ret, st)
+		}
+	}
+
+	return ret
 }
@@ -3,19 +3,96 @@ package core
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/pion/rtp"
 
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+const (
+	// duration of the sliding window used to compute bitrate and FPS, in seconds.
+	streamFormatStatsWindow = 5
+
+	// maximum jump of RTP timestamps between two frames.
+	// Timestamps can go backwards when B-frames are in use, therefore
+	// a small negative jump is allowed too.
+	streamFormatMaxTimestampJump = 10 * time.Second
+	streamFormatMaxTimestampBack = 1 * time.Second
+)
+
+type streamFormatStatsBucket struct {
+	second int64
+	bytes  uint64
+	frames uint64
+}
+
+// streamFormatStats are the statistics of a track.
+type streamFormatStats struct {
+	Track                    int     `json:"track"`
+	Codec                    string  `json:"codec"`
+	BytesReceived            uint64  `json:"bytesReceived"`
+	Frames                   uint64  `json:"frames"`
+	KeyFrames                uint64  `json:"keyFrames"`
+	FPS                      float64 `json:"fps"`
+	Bitrate                  uint64  `json:"bitrate"`
+	GOPDuration              float64 `json:"gopDuration"`
+	TimestampDiscontinuities uint64  `json:"timestampDiscontinuities"`
+
+	// used by health rules
+	hasKeyFrames bool
+	lastUnit     time.Time
+	lastKeyFrame time.Time
+}
+
+// formatHasKeyFrames checks whether key frames of a format can be detected.
+func formatHasKeyFrames(forma formats.Format) bool {
+	switch forma.(type) {
+	case *formats.H264, *formats.H265, *formats.VP8, *formats.VP9, *formats.AV1:
+		return true
+	}
+	return false
+}
+
 type streamFormat struct {
 	source         source
+	format         formats.Format
 	proc           formatprocessor.Processor
+	clockRate      int
+	hasKeyFrames   bool
 	mutex          sync.RWMutex
 	nonRTSPReaders map[reader]func(formatprocessor.Unit)
+
+	// state used to replace the source without disconnecting readers
+	rtpMutex        sync.Mutex
+	sourceFormat    formats.Format
+	rtpRebase       bool
+	waitingKeyFrame bool
+	ssrc            uint32
+	seqOffset       uint16
+	timestampOffset uint32
+	lastSeq         uint16
+	lastRTPTS       uint32
+	lastRTPTime     time.Time
+	lastRTPIsSet    bool
+
+	statsMutex               sync.Mutex
+	created                  time.Time
+	buckets                  [streamFormatStatsWindow + 1]streamFormatStatsBucket
+	bytesReceived            uint64
+	frames                   uint64
+	keyFrames                uint64
+	timestampDiscontinuities uint64
+	lastTimestamp            uint32
+	lastTimestampSet         bool
+	lastKeyFrameTimestamp    uint32
+	lastKeyFrameTimestampSet bool
+	gopDuration              time.Duration
+	lastUnit                 time.Time
+	lastKeyFrame             time.Time
 }
 
 func newStreamFormat(
@@ -31,8 +108,12 @@ func newStreamFormat(
 
 	sf := &streamFormat{
 		source:         source,
+		format:         forma,
 		proc:           proc,
+		clockRate:      forma.ClockRate(),
+		hasKeyFrames:   formatHasKeyFrames(forma),
 		nonRTSPReaders: make(map[reader]func(formatprocessor.Unit)),
+		created:        time.Now(),
 	}
 
 	return sf, nil
@@ -50,6 +131,81 @@ func (sf *streamFormat) readerRemove(r reader) {
 	delete(sf.nonRTSPReaders, r)
 }
 
+// rebind replaces the source of the format. It is called by stream.
+func (sf *streamFormat) rebind(sourceFormat formats.Format, proc formatprocessor.Processor, source source) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	sf.source = source
+	sf.proc = proc
+
+	sf.rtpMutex.Lock()
+	defer sf.rtpMutex.Unlock()
+
+	syncFormatParams(sf.format, sourceFormat)
+	sf.sourceFormat = sourceFormat
+	sf.rtpRebase = true
+	sf.waitingKeyFrame = sf.hasKeyFrames
+}
+
+// rebaseRTP makes RTP packets of the current source continuous with the ones of previous sources.
+// It returns false when the unit must be discarded.
+func (sf *streamFormat) rebaseRTP(pkts []*rtp.Packet, isKeyFrame bool) bool {
+	if len(pkts) == 0 {
+		return true
+	}
+
+	sf.rtpMutex.Lock()
+	defer sf.rtpMutex.Unlock()
+
+	// readers can't decode anything until a key frame is received
+	if sf.waitingKeyFrame {
+		if !isKeyFrame {
+			return false
+		}
+		sf.waitingKeyFrame = false
+	}
+
+	now := time.Now()
+
+	if sf.rtpRebase {
+		sf.rtpRebase = false
+
+		if sf.lastRTPIsSet {
+			sf.seqOffset = sf.lastSeq + 1 - pkts[0].SequenceNumber
+			sf.timestampOffset = sf.lastRTPTS - pkts[0].Timestamp
+			if sf.clockRate > 0 {
+				sf.timestampOffset += uint32(now.Sub(sf.lastRTPTime).Seconds() * float64(sf.clockRate))
+			}
+		}
+	}
+
+	if !sf.lastRTPIsSet {
+		sf.ssrc = pkts[0].SSRC
+	}
+
+	if sf.sourceFormat != nil {
+		// parameters may be sent in-band together with key frames
+		if isKeyFrame {
+			syncFormatParams(sf.format, sf.sourceFormat)
+		}
+
+		for _, pkt := range pkts {
+			pkt.SSRC = sf.ssrc
+			pkt.PayloadType = sf.format.PayloadType()
+			pkt.SequenceNumber += sf.seqOffset
+			pkt.Timestamp += sf.timestampOffset
+		}
+	}
+
+	sf.lastSeq = pkts[len(pkts)-1].SequenceNumber
+	sf.lastRTPTS = pkts[len(pkts)-1].Timestamp
+	sf.lastRTPTime = now
+	sf.lastRTPIsSet = true
+
+	return true
+}
+
 func (sf *streamFormat) writeUnit(s *stream, medi *media.Media, data formatprocessor.Unit) {
 	sf.mutex.RLock()
 	defer sf.mutex.RUnlock()
@@ -62,14 +218,130 @@ func (sf *streamFormat) writeUnit(s *stream, medi *media.Media, data formatproce
 		return
 	}
 
+	isKeyFrame := sf.proc.ContainsKeyFrame(data)
+
+	if !sf.rebaseRTP(data.GetRTPPackets(), isKeyFrame) {
+		return
+	}
+
+	s.rebasePTS(data)
+
+	sf.updateStats(data.GetRTPPackets(), isKeyFrame)
+
 	// forward RTP packets to RTSP readers
 	for _, pkt := range data.GetRTPPackets() {
 		atomic.AddUint64(s.bytesReceived, uint64(pkt.MarshalSize()))
 		s.rtspStream.WritePacketRTPWithNTP(medi, pkt, data.GetNTP())
 	}
 
+	if len(data.GetRTPPackets()) > 0 {
+		s.rtspPacketWritten()
+	}
+
 	// forward decoded frames to non-RTSP readers
 	for _, cb := range sf.nonRTSPReaders {
 		cb(data)
 	}
 }
+
+func (sf *streamFormat) timestampDiff(a uint32, b uint32) time.Duration {
+	return time.Duration(int32(a-b)) * time.Second / time.Duration(sf.clockRate)
+}
+
+func (sf *streamFormat) updateStats(pkts []*rtp.Packet, isKeyFrame bool) {
+	if len(pkts) == 0 {
+		return
+	}
+
+	sf.statsMutex.Lock()
+	defer sf.statsMutex.Unlock()
+
+	now := time.Now()
+	sf.lastUnit = now
+
+	var bytes uint64
+	var frames uint64
+
+	for _, pkt := range pkts {
+		bytes += uint64(pkt.MarshalSize())
+
+		// frames are counted by looking at timestamp changes
+		if !sf.lastTimestampSet || pkt.Timestamp != sf.lastTimestamp {
+			if sf.lastTimestampSet && sf.clockRate > 0 {
+				diff := sf.timestampDiff(pkt.Timestamp, sf.lastTimestamp)
+				if diff > streamFormatMaxTimestampJump || diff < -streamFormatMaxTimestampBack {
+					sf.timestampDiscontinuities++
+				}
+			}
+
+			frames++
+			sf.lastTimestamp = pkt.Timestamp
+			sf.lastTimestampSet = true
+		}
+	}
+
+	// a key frame can be split into multiple units, that share the same timestamp
+	if isKeyFrame && (!sf.lastKeyFrameTimestampSet || sf.lastKeyFrameTimestamp != pkts[0].Timestamp) {
+		if sf.lastKeyFrameTimestampSet && sf.clockRate > 0 {
+			sf.gopDuration = sf.timestampDiff(pkts[0].Timestamp, sf.lastKeyFrameTimestamp)
+		}
+
+		sf.keyFrames++
+		sf.lastKeyFrame = now
+		sf.lastKeyFrameTimestamp = pkts[0].Timestamp
+		sf.lastKeyFrameTimestampSet = true
+	}
+
+	sf.bytesReceived += bytes
+	sf.frames += frames
+
+	second := now.Unix()
+	b := &sf.buckets[second%int64(len(sf.buckets))]
+	if b.second != second {
+		*b = streamFormatStatsBucket{second: second}
+	}
+	b.bytes += bytes
+	b.frames += frames
+}
+
+// stats returns the statistics of the track.
+// Bitrate and FPS are computed on the last completed seconds.
+func (sf *streamFormat) stats() streamFormatStats {
+	sf.statsMutex.Lock()
+	defer sf.statsMutex.Unlock()
+
+	ret := streamFormatStats{
+		BytesReceived:            sf.bytesReceived,
+		Frames:                   sf.frames,
+		KeyFrames:                sf.keyFrames,
+		GOPDuration:              sf.gopDuration.Seconds(),
+		TimestampDiscontinuities: sf.timestampDiscontinuities,
+		hasKeyFrames:             sf.hasKeyFrames,
+		lastUnit:                 sf.lastUnit,
+		lastKeyFrame:             sf.lastKeyFrame,
+	}
+
+	now := time.Now().Unix()
+
+	window := now - sf.created.Unix()
+	if window > streamFormatStatsWindow {
+		window = streamFormatStatsWindow
+	}
+
+	if window > 0 {
+		var bytes uint64
+		var frames uint64
+
+		for _, b := range sf.buckets {
+			if age := now - b.second; age >= 1 && age <= window {
+				bytes += b.bytes
+				frames += b.frames
+			}
+		}
+
+		ret.Bitrate = bytes * 8 / uint64(window)
+		ret.FPS = float64(frames) / float64(window)
+	}
+
+	return ret
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+)
+
+func formatsCompatible(a formats.Format, b formats.Format) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || a.ClockRate() != b.ClockRate() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *formats.MPEG4Audio:
+		return reflect.DeepEqual(a.Config, b.(*formats.MPEG4Audio).Config)
+
+	case *formats.Opus:
+		return a.IsStereo == b.(*formats.Opus).IsStereo
+
+	case *formats.Generic:
+		return a.RTPMap() == b.(*formats.Generic).RTPMap()
+	}
+
+	return true
+}
+
+// mediasCompatible checks whether a source with the given medias can replace
+// the source of a stream, without disconnecting readers.
+func mediasCompatible(cur media.Medias, next media.Medias) error {
+	if len(cur) != len(next) {
+		return fmt.Errorf("the number of medias changed from %d to %d", len(cur), len(next))
+	}
+
+	for i, medi := range cur {
+		if len(medi.Formats) != len(next[i].Formats) {
+			return fmt.Errorf("the number of formats of media %d changed", i)
+		}
+
+		for j, forma := range medi.Formats {
+			if !formatsCompatible(forma, next[i].Formats[j]) {
+				return fmt.Errorf("format of media %d changed from %s to %s",
+					i, forma.String(), next[i].Formats[j].String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// syncFormatParams copies parameter sets from the format of a source to the format of the stream,
+// in order to allow new readers to decode the stream.
+func syncFormatParams(dest formats.Format, src formats.Format) {
+	switch dest := dest.(type) {
+	case *formats.H264:
+		sps, pps := src.(*formats.H264).SafeParams()
+		if sps != nil && pps != nil {
+			dest.SafeSetParams(sps, pps)
+		}
+
+	case *formats.H265:
+		vps, sps, pps := src.(*formats.H265).SafeParams()
+		if vps != nil && sps != nil && pps != nil {
+			dest.SafeSetParams(vps, sps, pps)
+		}
+	}
+}
+
+// unitPTS returns the PTS of a unit, if the unit has been decoded.
+func unitPTS(data formatprocessor.Unit) (time.Duration, bool) {
+	switch tdata := data.(type) {
+	case *formatprocessor.UnitH264:
+		return tdata.PTS, tdata.AU != nil
+
+	case *formatprocessor.UnitH265:
+		return tdata.PTS, tdata.AU != nil
+
+	case *formatprocessor.UnitVP8:
+		return tdata.PTS, tdata.Frame != nil
+
+	case *formatprocessor.UnitVP9:
+		return tdata.PTS, tdata.Frame != nil
+
+	case *formatprocessor.UnitAV1:
+		return tdata.PTS, tdata.OBUs != nil
+
+	case *formatprocessor.UnitMPEG2Audio:
+		return tdata.PTS, tdata.Frames != nil
+
+	case *formatprocessor.UnitMPEG4Audio:
+		return tdata.PTS, tdata.AUs != nil
+
+	case *formatprocessor.UnitOpus:
+		return tdata.PTS, tdata.Frame != nil
+	}
+
+	return 0, false
+}
+
+func unitSetPTS(data formatprocessor.Unit, pts time.Duration) {
+	switch tdata := data.(type) {
+	case *formatprocessor.UnitH264:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitH265:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitVP8:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitVP9:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitAV1:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitMPEG2Audio:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitMPEG4Audio:
+		tdata.PTS = pts
+
+	case *formatprocessor.UnitOpus:
+		tdata.PTS = pts
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+)
+
+// newServerTLSConfig allocates the TLS configuration of a server.
+// The server certificate is provided by certLoader, in order to allow hot reloading.
+// When clientCA is filled, client certificates are requested and,
+// if provided, verified against the given CA bundle.
+func newServerTLSConfig(certLoader *certLoader, clientCA string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: certLoader.getCertificate,
+	}
+
+	if clientCA != "" {
+		byts, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(byts) {
+			return nil, fmt.Errorf("unable to load client CA bundle '%s'", clientCA)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// tlsPeerCertificate returns the verified client certificate of a TLS connection, if any.
+func tlsPeerCertificate(cs *tls.ConnectionState) *x509.Certificate {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	return cs.PeerCertificates[0]
+}
+
+// tlsConnPeerCertificate returns the verified client certificate of a connection, if any.
+func tlsConnPeerCertificate(nconn net.Conn) *x509.Certificate {
+	tconn, ok := nconn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	cs := tconn.ConnectionState()
+	return tlsPeerCertificate(&cs)
+}
+
+// certIdentities returns the identities contained into a client certificate,
+// in the same format used by path credentials (i.e. "CN=cam-042", "DNS=cam.local").
+func certIdentities(cert *x509.Certificate) []string {
+	var ret []string
+
+	if cert.Subject.CommonName != "" {
+		ret = append(ret, "CN="+cert.Subject.CommonName)
+	}
+
+	for _, name := range cert.DNSNames {
+		ret = append(ret, "DNS="+name)
+	}
+
+	for _, email := range cert.EmailAddresses {
+		ret = append(ret, "EMAIL="+email)
+	}
+
+	for _, ip := range cert.IPAddresses {
+		ret = append(ret, "IP="+ip.String())
+	}
+
+	return ret
+}
+
+// certFingerprint returns the SHA256 fingerprint of a certificate,
+// in the same format used by sourceFingerprint.
+func certFingerprint(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(h[:])
+}
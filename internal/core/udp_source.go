@@ -7,15 +7,10 @@ import (
 	"time"
 
 	"github.com/asticode/go-astits"
-	"github.com/bluenviron/gortsplib/v3/pkg/formats"
-	"github.com/bluenviron/gortsplib/v3/pkg/media"
-	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
-	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
 	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
 	"golang.org/x/net/ipv4"
 
 	"github.com/aler9/mediamtx/internal/conf"
-	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
@@ -118,7 +113,7 @@ func newUDPSource(
 }
 
 func (s *udpSource) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[udp source] "+format, args...)
+	s.parent.Log(level, "[udp source] "+format, logger.WithField("source", "udp", args)...)
 }
 
 // run implements sourceStaticImpl.
@@ -172,129 +167,7 @@ func (s *udpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan
 				return err
 			}
 
-			var medias media.Medias
-			mediaCallbacks := make(map[uint16]func(time.Duration, []byte), len(tracks))
-			var stream *stream
-
-			for _, track := range tracks {
-				var medi *media.Media
-
-				switch tcodec := track.Codec.(type) {
-				case *mpegts.CodecH264:
-					medi = &media.Media{
-						Type: media.TypeVideo,
-						Formats: []formats.Format{&formats.H264{
-							PayloadTyp:        96,
-							PacketizationMode: 1,
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						au, err := h264.AnnexBUnmarshal(data)
-						if err != nil {
-							s.Log(logger.Warn, "%v", err)
-							return
-						}
-
-						stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
-							PTS: pts,
-							AU:  au,
-							NTP: time.Now(),
-						})
-					}
-
-				case *mpegts.CodecH265:
-					medi = &media.Media{
-						Type: media.TypeVideo,
-						Formats: []formats.Format{&formats.H265{
-							PayloadTyp: 96,
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						au, err := h264.AnnexBUnmarshal(data)
-						if err != nil {
-							s.Log(logger.Warn, "%v", err)
-							return
-						}
-
-						stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH265{
-							PTS: pts,
-							AU:  au,
-							NTP: time.Now(),
-						})
-					}
-
-				case *mpegts.CodecMPEG4Audio:
-					medi = &media.Media{
-						Type: media.TypeAudio,
-						Formats: []formats.Format{&formats.MPEG4Audio{
-							PayloadTyp:       96,
-							SizeLength:       13,
-							IndexLength:      3,
-							IndexDeltaLength: 3,
-							Config:           &tcodec.Config,
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						var pkts mpeg4audio.ADTSPackets
-						err := pkts.Unmarshal(data)
-						if err != nil {
-							s.Log(logger.Warn, "%v", err)
-							return
-						}
-
-						aus := make([][]byte, len(pkts))
-						for i, pkt := range pkts {
-							aus[i] = pkt.AU
-						}
-
-						stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitMPEG4Audio{
-							PTS: pts,
-							AUs: aus,
-							NTP: time.Now(),
-						})
-					}
-
-				case *mpegts.CodecOpus:
-					medi = &media.Media{
-						Type: media.TypeAudio,
-						Formats: []formats.Format{&formats.Opus{
-							PayloadTyp: 96,
-							IsStereo:   (tcodec.Channels == 2),
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						pos := 0
-
-						for {
-							var au mpegts.OpusAccessUnit
-							n, err := au.Unmarshal(data[pos:])
-							if err != nil {
-								s.Log(logger.Warn, "%v", err)
-								return
-							}
-							pos += n
-
-							stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitOpus{
-								PTS:   pts,
-								Frame: au.Frame,
-								NTP:   time.Now(),
-							})
-
-							if len(data[pos:]) == 0 {
-								break
-							}
-
-							pts += opusGetPacketDuration(au.Frame)
-						}
-					}
-				}
-
-				medias = append(medias, medi)
-			}
+			medias, mediasByPID := mpegtsMedias(tracks)
 
 			res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
 				medias:             medias,
@@ -310,7 +183,7 @@ func (s *udpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan
 
 			s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
 
-			stream = res.stream
+			stream := res.stream
 			var timedec *mpegts.TimeDecoder
 
 			for {
@@ -338,12 +211,18 @@ func (s *udpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan
 					pts = timedec.Decode(data.PES.Header.OptionalHeader.PTS.Base)
 				}
 
-				cb, ok := mediaCallbacks[data.PID]
+				mm, ok := mediasByPID[data.PID]
 				if !ok {
 					continue
 				}
 
-				cb(pts, data.PES.Data)
+				units, err := mm.decode(pts, data.PES.Data)
+				for _, u := range units {
+					stream.writeUnit(mm.media, mm.media.Formats[0], u)
+				}
+				if err != nil {
+					s.Log(logger.Warn, "%v", err)
+				}
 			}
 		}()
 	}()
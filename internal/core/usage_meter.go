@@ -0,0 +1,361 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	usageMeterPeriod = 1 * time.Second
+)
+
+type usageErrQuotaExceeded struct {
+	user   string
+	period string
+}
+
+// Error implements the error interface.
+func (e usageErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("user '%s' has exceeded its %s quota", e.user, e.period)
+}
+
+type usageAPIUser struct {
+	BytesSent        uint64 `json:"bytesSent"`
+	DailyBytesSent   uint64 `json:"dailyBytesSent"`
+	MonthlyBytesSent uint64 `json:"monthlyBytesSent"`
+	DailyQuota       uint64 `json:"dailyQuota"`
+	MonthlyQuota     uint64 `json:"monthlyQuota"`
+	Readers          int    `json:"readers"`
+}
+
+type usageAPIData struct {
+	Items map[string]usageAPIUser `json:"items"`
+}
+
+type usageMeterUser struct {
+	bytesSent        uint64
+	dailyBytesSent   uint64
+	monthlyBytesSent uint64
+	day              time.Time
+	month            time.Time
+}
+
+func usageDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// account adds bytes to the counters of the user.
+// Daily and monthly counters are reset when a new day or month begins (UTC).
+func (u *usageMeterUser) account(n uint64, now time.Time) {
+	now = now.UTC()
+	day := usageDay(now)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	if !u.day.Equal(day) {
+		u.day = day
+		u.dailyBytesSent = 0
+	}
+
+	if !u.month.Equal(month) {
+		u.month = month
+		u.monthlyBytesSent = 0
+	}
+
+	u.bytesSent += n
+	u.dailyBytesSent += n
+	u.monthlyBytesSent += n
+}
+
+// usageMeterReader is a reader whose sent bytes are attributed to a user.
+type usageMeterReader struct {
+	user      string
+	bytesSent func() uint64
+	close     func()
+
+	last    uint64
+	closing bool
+}
+
+type usageMeterParent interface {
+	logger.Writer
+}
+
+// usageMeter attributes the bytes sent to readers to authenticated users
+// and enforces per-user quotas.
+// Counters are kept in memory and are reset when the server is restarted.
+type usageMeter struct {
+	parent usageMeterParent
+
+	ctx       context.Context
+	ctxCancel func()
+	mutex     sync.Mutex
+	quotas    map[string]conf.UsageQuota
+	users     map[string]*usageMeterUser
+	readers   map[*usageMeterReader]struct{}
+	evictDay  time.Time
+
+	done chan struct{}
+}
+
+func newUsageMeter(
+	quotas map[string]conf.UsageQuota,
+	parent usageMeterParent,
+) *usageMeter {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	m := &usageMeter{
+		parent:    parent,
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		quotas:    quotas,
+		users:     make(map[string]*usageMeterUser),
+		readers:   make(map[*usageMeterReader]struct{}),
+		evictDay:  usageDay(time.Now()),
+		done:      make(chan struct{}),
+	}
+
+	go m.run()
+
+	return m
+}
+
+func (m *usageMeter) close() {
+	m.ctxCancel()
+	<-m.done
+}
+
+func (m *usageMeter) run() {
+	defer close(m.done)
+
+	t := time.NewTicker(usageMeterPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			m.sample()
+
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// sample accounts the bytes sent by readers since the last sample
+// and closes the readers of users that exceeded their quota.
+func (m *usageMeter) sample() {
+	var toClose []*usageMeterReader
+
+	m.mutex.Lock()
+
+	now := time.Now()
+
+	for r := range m.readers {
+		m.sampleReader(r, now)
+	}
+
+	for r := range m.readers {
+		if r.closing {
+			continue
+		}
+
+		if err := m.checkInternal(r.user, now); err != nil {
+			r.closing = true
+			toClose = append(toClose, r)
+			m.parent.Log(logger.Info, "closing reader since %v", err)
+		}
+	}
+
+	if day := usageDay(now); !day.Equal(m.evictDay) {
+		m.evictDay = day
+		m.evictUsers(day)
+	}
+
+	m.mutex.Unlock()
+
+	// readers are closed outside the mutex, since they may call readerRemove().
+	for _, r := range toClose {
+		r.close()
+	}
+}
+
+func (m *usageMeter) sampleReader(r *usageMeterReader, now time.Time) {
+	cur := r.bytesSent()
+	if cur <= r.last {
+		return
+	}
+
+	m.user(r.user).account(cur-r.last, now)
+	r.last = cur
+}
+
+// evictUsers removes users that are not subject to quotas, have no readers
+// and have not sent anything in the current day, in order to bound memory usage.
+func (m *usageMeter) evictUsers(day time.Time) {
+	active := make(map[string]struct{})
+	for r := range m.readers {
+		active[r.user] = struct{}{}
+	}
+
+	for name, u := range m.users {
+		if _, ok := m.quotas[name]; ok {
+			continue
+		}
+
+		if _, ok := active[name]; ok {
+			continue
+		}
+
+		if u.day.Before(day) {
+			delete(m.users, name)
+		}
+	}
+}
+
+func (m *usageMeter) user(name string) *usageMeterUser {
+	u, ok := m.users[name]
+	if !ok {
+		u = &usageMeterUser{}
+		m.users[name] = u
+	}
+	return u
+}
+
+func (m *usageMeter) checkInternal(user string, now time.Time) error {
+	quota, ok := m.quotas[user]
+	if !ok {
+		return nil
+	}
+
+	u, ok := m.users[user]
+	if !ok {
+		return nil
+	}
+
+	// account zero bytes in order to reset expired counters.
+	u.account(0, now)
+
+	if quota.Daily != 0 && u.dailyBytesSent >= uint64(quota.Daily) {
+		return usageErrQuotaExceeded{user: user, period: "daily"}
+	}
+
+	if quota.Monthly != 0 && u.monthlyBytesSent >= uint64(quota.Monthly) {
+		return usageErrQuotaExceeded{user: user, period: "monthly"}
+	}
+
+	return nil
+}
+
+// setQuotas is called by Core when the configuration is reloaded.
+func (m *usageMeter) setQuotas(quotas map[string]conf.UsageQuota) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.quotas = quotas
+}
+
+// check is called by pathManager and hlsMuxer before serving a reader.
+func (m *usageMeter) check(user string) error {
+	if user == "" {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.checkInternal(user, time.Now())
+}
+
+// readerAdd is called by readers when they start sending data.
+// bytesSent must return the total amount of bytes sent by the reader,
+// close is called when the quota of the user is exceeded.
+func (m *usageMeter) readerAdd(user string, bytesSent func() uint64, closeFunc func()) *usageMeterReader {
+	if user == "" {
+		return nil
+	}
+
+	r := &usageMeterReader{
+		user:      user,
+		bytesSent: bytesSent,
+		close:     closeFunc,
+		last:      bytesSent(),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.readers[r] = struct{}{}
+
+	return r
+}
+
+// readerRemove is called by readers when they stop sending data.
+func (m *usageMeter) readerRemove(r *usageMeterReader) {
+	if r == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.sampleReader(r, time.Now())
+	delete(m.readers, r)
+}
+
+// add is called by hlsMuxer after it has sent bytes to a user.
+// Bytes are added once per request, in order not to lock the mutex on every write.
+func (m *usageMeter) add(user string, n uint64) {
+	if user == "" || n == 0 {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.user(user).account(n, time.Now())
+}
+
+// apiUsageList is called by api and metrics.
+func (m *usageMeter) apiUsageList() usageAPIData {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+
+	for r := range m.readers {
+		m.sampleReader(r, now)
+	}
+
+	data := usageAPIData{
+		Items: make(map[string]usageAPIUser),
+	}
+
+	for name, quota := range m.quotas {
+		data.Items[name] = usageAPIUser{
+			DailyQuota:   uint64(quota.Daily),
+			MonthlyQuota: uint64(quota.Monthly),
+		}
+	}
+
+	for name, u := range m.users {
+		u.account(0, now)
+		item := data.Items[name]
+		item.BytesSent = u.bytesSent
+		item.DailyBytesSent = u.dailyBytesSent
+		item.MonthlyBytesSent = u.monthlyBytesSent
+		data.Items[name] = item
+	}
+
+	for r := range m.readers {
+		item := data.Items[r.user]
+		item.Readers++
+		data.Items[r.user] = item
+	}
+
+	return data
+}
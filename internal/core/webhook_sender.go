@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/externalcmd"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	webhookSenderFirstRetryPause = 1 * time.Second
+)
+
+type webhookSenderParent interface {
+	logger.Writer
+}
+
+// webhookSender delivers webhooks in background,
+// retrying with exponential backoff when delivery fails.
+type webhookSender struct {
+	timeout    conf.StringDuration
+	maxRetries int
+	secret     string
+	metrics    *metrics
+	parent     webhookSenderParent
+
+	ctx        context.Context
+	ctxCancel  func()
+	wg         sync.WaitGroup
+	httpClient *http.Client
+	deliveries *uint64
+	failures   *uint64
+}
+
+func newWebhookSender(
+	timeout conf.StringDuration,
+	maxRetries int,
+	secret string,
+	metrics *metrics,
+	parent webhookSenderParent,
+) *webhookSender {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	s := &webhookSender{
+		timeout:    timeout,
+		maxRetries: maxRetries,
+		secret:     secret,
+		metrics:    metrics,
+		parent:     parent,
+		ctx:        ctx,
+		ctxCancel:  ctxCancel,
+		httpClient: &http.Client{
+			Timeout: time.Duration(timeout),
+		},
+		deliveries: new(uint64),
+		failures:   new(uint64),
+	}
+
+	if s.metrics != nil {
+		s.metrics.webhookSenderSet(s)
+	}
+
+	return s
+}
+
+// close stops ongoing deliveries and pending retries.
+func (s *webhookSender) close() {
+	if s.metrics != nil {
+		s.metrics.webhookSenderSet(nil)
+	}
+
+	s.ctxCancel()
+	s.wg.Wait()
+}
+
+func (s *webhookSender) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[webhook] "+format, logger.WithField("component", "webhook", args)...)
+}
+
+// send delivers a webhook in background.
+// The body contains the same fields that are passed to external commands.
+func (s *webhookSender) send(ur string, event string, env externalcmd.Environment) {
+	if ur == "" {
+		return
+	}
+
+	byts, _ := json.Marshal(env)
+
+	s.wg.Add(1)
+	go s.run(ur, event, byts)
+}
+
+func (s *webhookSender) run(ur string, event string, byts []byte) {
+	defer s.wg.Done()
+
+	pause := webhookSenderFirstRetryPause
+
+	for attempt := 0; ; attempt++ {
+		err := s.deliver(ur, event, byts)
+		if err == nil {
+			atomic.AddUint64(s.deliveries, 1)
+			return
+		}
+
+		// the sender has been closed
+		if s.ctx.Err() != nil {
+			atomic.AddUint64(s.failures, 1)
+			return
+		}
+
+		if attempt >= s.maxRetries {
+			atomic.AddUint64(s.failures, 1)
+			s.Log(logger.Warn, "unable to deliver '%s' webhook to %s: %v", event, ur, err)
+			return
+		}
+
+		s.Log(logger.Debug, "unable to deliver '%s' webhook to %s, retrying in %v: %v", event, ur, pause, err)
+
+		select {
+		case <-time.After(pause):
+		case <-s.ctx.Done():
+			atomic.AddUint64(s.failures, 1)
+			return
+		}
+
+		pause *= 2
+	}
+}
+
+func (s *webhookSender) deliver(ur string, event string, byts []byte) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, ur, bytes.NewReader(byts))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", event)
+
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(byts)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
@@ -20,7 +21,6 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/formats/rtpvp8"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats/rtpvp9"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
-	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
 	"github.com/google/uuid"
 	"github.com/pion/ice/v2"
 	"github.com/pion/interceptor"
@@ -28,6 +28,7 @@ import (
 
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 	"github.com/aler9/mediamtx/internal/websocket"
 )
 
@@ -104,21 +105,26 @@ type webRTCConnParent interface {
 type webRTCConn struct {
 	readBufferCount   int
 	pathName          string
+	user              string
 	wsconn            *websocket.ServerConn
 	iceServers        []string
 	wg                *sync.WaitGroup
+	usageMeter        *usageMeter
 	pathManager       webRTCConnPathManager
+	tracer            *telemetry.Tracer
 	parent            webRTCConnParent
 	iceUDPMux         ice.UDPMux
 	iceTCPMux         ice.TCPMux
 	iceHostNAT1To1IPs []string
 
-	ctx       context.Context
-	ctxCancel func()
-	uuid      uuid.UUID
-	created   time.Time
-	curPC     *webrtc.PeerConnection
-	mutex     sync.RWMutex
+	ctx          context.Context
+	ctxCancel    func()
+	uuid         uuid.UUID
+	created      time.Time
+	span         *telemetry.Span
+	curPC        *webrtc.PeerConnection
+	unitsDropped *uint64
+	mutex        sync.RWMutex
 
 	closed chan struct{}
 }
@@ -127,10 +133,13 @@ func newWebRTCConn(
 	parentCtx context.Context,
 	readBufferCount int,
 	pathName string,
+	user string,
 	wsconn *websocket.ServerConn,
 	iceServers []string,
 	wg *sync.WaitGroup,
+	usageMeter *usageMeter,
 	pathManager webRTCConnPathManager,
+	tracer *telemetry.Tracer,
 	parent webRTCConnParent,
 	iceHostNAT1To1IPs []string,
 	iceUDPMux ice.UDPMux,
@@ -141,21 +150,29 @@ func newWebRTCConn(
 	c := &webRTCConn{
 		readBufferCount:   readBufferCount,
 		pathName:          pathName,
+		user:              user,
 		wsconn:            wsconn,
 		iceServers:        iceServers,
 		wg:                wg,
+		usageMeter:        usageMeter,
 		pathManager:       pathManager,
+		tracer:            tracer,
 		parent:            parent,
 		ctx:               ctx,
 		ctxCancel:         ctxCancel,
 		uuid:              uuid.New(),
 		created:           time.Now(),
+		unitsDropped:      new(uint64),
 		iceUDPMux:         iceUDPMux,
 		iceTCPMux:         iceTCPMux,
 		iceHostNAT1To1IPs: iceHostNAT1To1IPs,
 		closed:            make(chan struct{}),
 	}
 
+	c.span = c.tracer.Start("webrtc conn",
+		"id", c.uuid.String(),
+		"remoteAddr", c.remoteAddr().String())
+
 	c.Log(logger.Info, "opened")
 
 	wg.Add(1)
@@ -176,6 +193,19 @@ func (c *webRTCConn) remoteAddr() net.Addr {
 	return c.wsconn.RemoteAddr()
 }
 
+func (c *webRTCConn) apiItem() webRTCServerAPIConnsListItem {
+	return webRTCServerAPIConnsListItem{
+		Created:                   c.created,
+		RemoteAddr:                c.remoteAddr().String(),
+		PeerConnectionEstablished: c.peerConnectionEstablished(),
+		LocalCandidate:            c.localCandidate(),
+		RemoteCandidate:           c.remoteCandidate(),
+		BytesReceived:             c.bytesReceived(),
+		BytesSent:                 c.bytesSent(),
+		UnitsDropped:              atomic.LoadUint64(c.unitsDropped),
+	}
+}
+
 func (c *webRTCConn) peerConnectionEstablished() bool {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -266,7 +296,10 @@ func (c *webRTCConn) bytesSent() uint64 {
 }
 
 func (c *webRTCConn) Log(level logger.Level, format string, args ...interface{}) {
-	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.wsconn.RemoteAddr()}, args...)...)
+	c.parent.Log(level, "[conn %v] "+format, logger.WithFields([]logger.Field{
+		{Key: "id", Value: c.uuid},
+		{Key: "remoteAddr", Value: c.wsconn.RemoteAddr()},
+	}, append([]interface{}{c.wsconn.RemoteAddr()}, args...))...)
 }
 
 func (c *webRTCConn) run() {
@@ -284,6 +317,14 @@ func (c *webRTCConn) run() {
 	case err = <-runErr:
 		innerCtxCancel()
 
+		switch err.(type) {
+		case pathErrTooManyReaders:
+			c.wsconn.WriteClose(websocket.CloseTryAgainLater, "too many readers")
+
+		case usageErrQuotaExceeded:
+			c.wsconn.WriteClose(websocket.ClosePolicyViolation, "quota exceeded")
+		}
+
 	case <-c.ctx.Done():
 		innerCtxCancel()
 		<-runErr
@@ -295,6 +336,9 @@ func (c *webRTCConn) run() {
 	c.parent.connClose(c)
 
 	c.Log(logger.Info, "closed (%v)", err)
+
+	c.span.SetAttribute("closeReason", err.Error())
+	c.span.End()
 }
 
 func (c *webRTCConn) runInner(ctx context.Context) error {
@@ -302,6 +346,10 @@ func (c *webRTCConn) runInner(ctx context.Context) error {
 		author:   c,
 		pathName: c.pathName,
 		skipAuth: true,
+		credentials: authCredentials{
+			user: c.user,
+		},
+		span: c.span,
 	})
 	if res.err != nil {
 		return res.err
@@ -313,6 +361,9 @@ func (c *webRTCConn) runInner(ctx context.Context) error {
 		path.readerRemove(pathReaderRemoveReq{author: c})
 	}()
 
+	usage := c.usageMeter.readerAdd(c.user, c.bytesSent, c.close)
+	defer c.usageMeter.readerRemove(usage)
+
 	var tracks []*webRTCTrack
 
 	videoTrack, err := c.createVideoTrack(res.stream.medias())
@@ -338,6 +389,13 @@ func (c *webRTCConn) runInner(ctx context.Context) error {
 			"the stream doesn't contain any supported codec, which are currently H264, VP8, VP9, G711, G722, Opus")
 	}
 
+	// the handshake includes signaling, ICE and DTLS
+	handshakeSpan := c.span.Child("handshake")
+	defer func() {
+		handshakeSpan.SetError(fmt.Errorf("handshake not completed"))
+		handshakeSpan.End()
+	}()
+
 	err = c.wsconn.WriteJSON(c.genICEServers())
 	if err != nil {
 		return err
@@ -509,6 +567,8 @@ outer:
 		}
 	}
 
+	handshakeSpan.End()
+
 	// Keep WebSocket connection open and use it to notify shutdowns.
 	// This is because pion/webrtc doesn't write yet a WebRTC shutdown
 	// message to clients (like a DTLS close alert or a RTCP BYE),
@@ -522,15 +582,15 @@ outer:
 	c.Log(logger.Info, "peer connection established, local candidate: %v, remote candidate: %v",
 		c.localCandidate(), c.remoteCandidate())
 
-	ringBuffer, _ := ringbuffer.New(uint64(c.readBufferCount))
-	defer ringBuffer.Close()
+	ringBuffer, _ := newReaderBuffer(c.readBufferCount, c.unitsDropped)
+	defer ringBuffer.close()
 
 	writeError := make(chan error)
 
 	for _, track := range tracks {
 		ctrack := track
 		res.stream.readerAdd(c, track.media, track.format, func(unit formatprocessor.Unit) {
-			ringBuffer.Push(func() {
+			ringBuffer.push(func() {
 				ctrack.cb(unit, ctx, writeError)
 			})
 		})
@@ -540,13 +600,20 @@ outer:
 	c.Log(logger.Info, "is reading from path '%s', %s",
 		path.name, sourceMediaInfo(gatherMedias(tracks)))
 
+	firstFrameSpan := c.span.Child("first frame sent", "path", path.name)
+	defer func() {
+		firstFrameSpan.SetError(fmt.Errorf("no frames have been sent"))
+		firstFrameSpan.End()
+	}()
+
 	go func() {
 		for {
-			item, ok := ringBuffer.Pull()
+			item, ok := ringBuffer.pull()
 			if !ok {
 				return
 			}
 			item.(func())()
+			firstFrameSpan.End()
 		}
 	}()
 
@@ -928,6 +995,11 @@ func (c *webRTCConn) readCandidate() (*webrtc.ICECandidateInit, error) {
 	return &candidate, err
 }
 
+// protocol implements reader.
+func (c *webRTCConn) protocol() string {
+	return "webrtc"
+}
+
 // apiReaderDescribe implements reader.
 func (c *webRTCConn) apiReaderDescribe() interface{} {
 	return struct {
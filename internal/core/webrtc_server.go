@@ -11,6 +11,7 @@ import (
 	gopath "path"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/telemetry"
 	"github.com/aler9/mediamtx/internal/websocket"
 )
 
@@ -33,6 +35,7 @@ type webRTCServerAPIConnsListItem struct {
 	RemoteCandidate           string    `json:"remoteCandidate"`
 	BytesReceived             uint64    `json:"bytesReceived"`
 	BytesSent                 uint64    `json:"bytesSent"`
+	UnitsDropped              uint64    `json:"unitsDropped"`
 }
 
 type webRTCServerAPIConnsListData struct {
@@ -48,6 +51,16 @@ type webRTCServerAPIConnsListReq struct {
 	res chan webRTCServerAPIConnsListRes
 }
 
+type webRTCServerAPIConnsGetRes struct {
+	data *webRTCServerAPIConnsListItem
+	err  error
+}
+
+type webRTCServerAPIConnsGetReq struct {
+	id  string
+	res chan webRTCServerAPIConnsGetRes
+}
+
 type webRTCServerAPIConnsKickRes struct {
 	err error
 }
@@ -59,6 +72,7 @@ type webRTCServerAPIConnsKickReq struct {
 
 type webRTCConnNewReq struct {
 	pathName string
+	user     string
 	wsconn   *websocket.ServerConn
 	res      chan *webRTCConn
 }
@@ -72,18 +86,24 @@ type webRTCServer struct {
 	trustedProxies  conf.IPsOrCIDRs
 	iceServers      []string
 	readBufferCount int
+	maxConnections  int
 	pathManager     *pathManager
 	metrics         *metrics
+	tracer          *telemetry.Tracer
+	eventBus        *eventBus
+	usageMeter      *usageMeter
 	parent          webRTCServerParent
 
 	ctx               context.Context
 	ctxCancel         func()
 	ln                net.Listener
+	certLoader        *certLoader
 	requestPool       *httpRequestPool
 	httpServer        *http.Server
 	udpMuxLn          net.PacketConn
 	tcpMuxLn          net.Listener
 	conns             map[*webRTCConn]struct{}
+	wsConnsCount      *int64
 	iceHostNAT1To1IPs []string
 	iceUDPMux         ice.UDPMux
 	iceTCPMux         ice.TCPMux
@@ -92,6 +112,7 @@ type webRTCServer struct {
 	connNew        chan webRTCConnNewReq
 	chConnClose    chan *webRTCConn
 	chAPIConnsList chan webRTCServerAPIConnsListReq
+	chAPIConnsGet  chan webRTCServerAPIConnsGetReq
 	chAPIConnsKick chan webRTCServerAPIConnsKickReq
 
 	// out
@@ -104,13 +125,18 @@ func newWebRTCServer(
 	encryption bool,
 	serverKey string,
 	serverCert string,
+	clientCA string,
 	allowOrigin string,
 	trustedProxies conf.IPsOrCIDRs,
 	iceServers []string,
 	readTimeout conf.StringDuration,
 	readBufferCount int,
+	maxConnections int,
 	pathManager *pathManager,
 	metrics *metrics,
+	tracer *telemetry.Tracer,
+	eventBus *eventBus,
+	usageMeter *usageMeter,
 	parent webRTCServerParent,
 	iceHostNAT1To1IPs []string,
 	iceUDPMuxAddress string,
@@ -121,19 +147,6 @@ func newWebRTCServer(
 		return nil, err
 	}
 
-	var tlsConfig *tls.Config
-	if encryption {
-		crt, err := tls.LoadX509KeyPair(serverCert, serverKey)
-		if err != nil {
-			ln.Close()
-			return nil, err
-		}
-
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{crt},
-		}
-	}
-
 	var iceUDPMux ice.UDPMux
 	var udpMuxLn net.PacketConn
 	if iceUDPMuxAddress != "" {
@@ -161,8 +174,12 @@ func newWebRTCServer(
 		trustedProxies:    trustedProxies,
 		iceServers:        iceServers,
 		readBufferCount:   readBufferCount,
+		maxConnections:    maxConnections,
 		pathManager:       pathManager,
 		metrics:           metrics,
+		tracer:            tracer,
+		eventBus:          eventBus,
+		usageMeter:        usageMeter,
 		parent:            parent,
 		ctx:               ctx,
 		ctxCancel:         ctxCancel,
@@ -173,13 +190,38 @@ func newWebRTCServer(
 		iceTCPMux:         iceTCPMux,
 		iceHostNAT1To1IPs: iceHostNAT1To1IPs,
 		conns:             make(map[*webRTCConn]struct{}),
+		wsConnsCount:      new(int64),
 		connNew:           make(chan webRTCConnNewReq),
 		chConnClose:       make(chan *webRTCConn),
 		chAPIConnsList:    make(chan webRTCServerAPIConnsListReq),
+		chAPIConnsGet:     make(chan webRTCServerAPIConnsGetReq),
 		chAPIConnsKick:    make(chan webRTCServerAPIConnsKickReq),
 		done:              make(chan struct{}),
 	}
 
+	var tlsConfig *tls.Config
+	if encryption {
+		s.certLoader, err = newCertLoader(serverCert, serverKey, s)
+		if err == nil {
+			tlsConfig, err = newServerTLSConfig(s.certLoader, clientCA)
+			if err != nil {
+				s.certLoader.close()
+			}
+		}
+
+		if err != nil {
+			ctxCancel()
+			ln.Close()
+			if udpMuxLn != nil {
+				udpMuxLn.Close()
+			}
+			if tcpMuxLn != nil {
+				tcpMuxLn.Close()
+			}
+			return nil, err
+		}
+	}
+
 	s.requestPool = newHTTPRequestPool()
 
 	router := gin.New()
@@ -191,6 +233,7 @@ func newWebRTCServer(
 		Handler:           router,
 		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: time.Duration(readTimeout),
+		IdleTimeout:       time.Duration(readTimeout),
 		ErrorLog:          log.New(&nilWriter{}, "", 0),
 	}
 
@@ -214,13 +257,17 @@ func newWebRTCServer(
 
 // Log is the main logging function.
 func (s *webRTCServer) Log(level logger.Level, format string, args ...interface{}) {
-	s.parent.Log(level, "[WebRTC] "+format, append([]interface{}{}, args...)...)
+	s.parent.Log(level, "[WebRTC] "+format, logger.WithField("component", "webrtc", args)...)
 }
 
 func (s *webRTCServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	<-s.done
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *webRTCServer) run() {
@@ -242,10 +289,13 @@ outer:
 				s.ctx,
 				s.readBufferCount,
 				req.pathName,
+				req.user,
 				req.wsconn,
 				s.iceServers,
 				&wg,
+				s.usageMeter,
 				s.pathManager,
+				s.tracer,
 				s,
 				s.iceHostNAT1To1IPs,
 				s.iceUDPMux,
@@ -254,28 +304,47 @@ outer:
 			s.conns[c] = struct{}{}
 			req.res <- c
 
+			s.eventBus.publish(&event{
+				Type:       eventTypeConnOpened,
+				Path:       req.pathName,
+				Protocol:   "webrtc",
+				ID:         c.uuid.String(),
+				RemoteAddr: c.remoteAddr().String(),
+			})
+
 		case conn := <-s.chConnClose:
 			delete(s.conns, conn)
 
+			s.eventBus.publish(&event{
+				Type:       eventTypeConnClosed,
+				Path:       conn.pathName,
+				Protocol:   "webrtc",
+				ID:         conn.uuid.String(),
+				RemoteAddr: conn.remoteAddr().String(),
+			})
+
 		case req := <-s.chAPIConnsList:
 			data := &webRTCServerAPIConnsListData{
 				Items: make(map[string]webRTCServerAPIConnsListItem),
 			}
 
 			for c := range s.conns {
-				data.Items[c.uuid.String()] = webRTCServerAPIConnsListItem{
-					Created:                   c.created,
-					RemoteAddr:                c.remoteAddr().String(),
-					PeerConnectionEstablished: c.peerConnectionEstablished(),
-					LocalCandidate:            c.localCandidate(),
-					RemoteCandidate:           c.remoteCandidate(),
-					BytesReceived:             c.bytesReceived(),
-					BytesSent:                 c.bytesSent(),
-				}
+				data.Items[c.uuid.String()] = c.apiItem()
 			}
 
 			req.res <- webRTCServerAPIConnsListRes{data: data}
 
+		case req := <-s.chAPIConnsGet:
+			res := webRTCServerAPIConnsGetRes{err: apiErrNotFound{"connection", req.id}}
+			for c := range s.conns {
+				if c.uuid.String() == req.id {
+					item := c.apiItem()
+					res = webRTCServerAPIConnsGetRes{data: &item}
+					break
+				}
+			}
+			req.res <- res
+
 		case req := <-s.chAPIConnsKick:
 			res := func() bool {
 				for c := range s.conns {
@@ -356,15 +425,18 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 	dir = strings.TrimSuffix(dir, "/")
 	user, pass, hasCredentials := ctx.Request.BasicAuth()
 
+	credentials := authCredentials{
+		query: ctx.Request.URL.RawQuery,
+		ip:    net.ParseIP(ctx.ClientIP()),
+		user:  user,
+		pass:  pass,
+		proto: authProtocolWebRTC,
+		cert:  tlsPeerCertificate(ctx.Request.TLS),
+	}
+
 	res := s.pathManager.getPathConf(pathGetPathConfReq{
-		name: dir,
-		credentials: authCredentials{
-			query: ctx.Request.URL.RawQuery,
-			ip:    net.ParseIP(ctx.ClientIP()),
-			user:  user,
-			pass:  pass,
-			proto: authProtocolWebRTC,
-		},
+		name:        dir,
+		credentials: credentials,
 	})
 	if res.err != nil {
 		if terr, ok := res.err.(pathErrAuth); ok {
@@ -375,6 +447,14 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 			}
 
 			s.Log(logger.Info, "authentication error: %v", terr.wrapped)
+
+			s.eventBus.publish(&event{
+				Type:       eventTypeAuthFailed,
+				Path:       dir,
+				Protocol:   "webrtc",
+				RemoteAddr: ctx.ClientIP(),
+				Error:      terr.wrapped.Error(),
+			})
 			ctx.Writer.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -391,6 +471,18 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 		return
 
 	case "ws":
+		// connections are counted before upgrading to WebSocket,
+		// in order to be able to reject them with a HTTP status code.
+		count := atomic.AddInt64(s.wsConnsCount, 1)
+		defer atomic.AddInt64(s.wsConnsCount, -1)
+
+		if s.maxConnections != 0 && count > int64(s.maxConnections) {
+			s.Log(logger.Warn, "[conn %v] connection rejected since the maximum number of connections (%d) has been reached",
+				ctx.Request.RemoteAddr, s.maxConnections)
+			ctx.Writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
 		wsconn, err := websocket.NewServerConn(ctx.Writer, ctx.Request)
 		if err != nil {
 			return
@@ -399,6 +491,7 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 
 		c := s.newConn(webRTCConnNewReq{
 			pathName: dir,
+			user:     res.user,
 			wsconn:   wsconn,
 		})
 		if c == nil {
@@ -443,6 +536,22 @@ func (s *webRTCServer) apiConnsList() webRTCServerAPIConnsListRes {
 	}
 }
 
+// apiConnsGet is called by api.
+func (s *webRTCServer) apiConnsGet(id string) webRTCServerAPIConnsGetRes {
+	req := webRTCServerAPIConnsGetReq{
+		id:  id,
+		res: make(chan webRTCServerAPIConnsGetRes),
+	}
+
+	select {
+	case s.chAPIConnsGet <- req:
+		return <-req.res
+
+	case <-s.ctx.Done():
+		return webRTCServerAPIConnsGetRes{err: fmt.Errorf("terminated")}
+	}
+}
+
 // apiConnsKick is called by api.
 func (s *webRTCServer) apiConnsKick(id string) webRTCServerAPIConnsKickRes {
 	req := webRTCServerAPIConnsKickReq{
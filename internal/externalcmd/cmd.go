@@ -10,6 +10,15 @@ const (
 	restartPause = 5 * time.Second
 )
 
+// variables that contain values provided by clients.
+// They are passed to the command through the environment only,
+// since replacing them inside the command line would allow clients
+// to inject arguments or shell commands.
+var clientVariables = map[string]struct{}{
+	"MTX_USER":  {},
+	"MTX_QUERY": {},
+}
+
 // Environment is a Cmd environment.
 type Environment map[string]string
 
@@ -18,6 +27,7 @@ type Cmd struct {
 	pool    *Pool
 	cmdstr  string
 	restart bool
+	once    bool
 	env     Environment
 	onExit  func(int)
 
@@ -32,8 +42,33 @@ func NewCmd(
 	restart bool,
 	env Environment,
 	onExit func(int),
+) *Cmd {
+	return newCmd(pool, cmdstr, restart, false, env, onExit)
+}
+
+// RunOnce runs a command once. The command doesn't need to be closed;
+// Pool.Close() waits for it and terminates it if it doesn't exit in time.
+func RunOnce(
+	pool *Pool,
+	cmdstr string,
+	env Environment,
+	onExit func(int),
+) {
+	newCmd(pool, cmdstr, false, true, env, onExit)
+}
+
+func newCmd(
+	pool *Pool,
+	cmdstr string,
+	restart bool,
+	once bool,
+	env Environment,
+	onExit func(int),
 ) *Cmd {
 	for key, val := range env {
+		if _, ok := clientVariables[key]; ok {
+			continue
+		}
 		cmdstr = strings.ReplaceAll(cmdstr, "$"+key, val)
 	}
 
@@ -41,6 +76,7 @@ func NewCmd(
 		pool:      pool,
 		cmdstr:    cmdstr,
 		restart:   restart,
+		once:      once,
 		env:       env,
 		onExit:    onExit,
 		terminate: make(chan struct{}),
@@ -48,6 +84,10 @@ func NewCmd(
 
 	pool.wg.Add(1)
 
+	if once {
+		pool.addOnce(e)
+	}
+
 	go e.run()
 
 	return e
@@ -61,6 +101,10 @@ func (e *Cmd) Close() {
 func (e *Cmd) run() {
 	defer e.pool.wg.Done()
 
+	if e.once {
+		defer e.pool.removeOnce(e)
+	}
+
 	for {
 		ok := func() bool {
 			c, ok := e.runInner()
@@ -70,6 +114,10 @@ func (e *Cmd) run() {
 
 			e.onExit(c)
 
+			if e.once {
+				return false
+			}
+
 			if !e.restart {
 				<-e.terminate
 				return false
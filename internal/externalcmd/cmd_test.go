@@ -0,0 +1,61 @@
+//go:build !windows
+// +build !windows
+
+package externalcmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCmdClientVariables(t *testing.T) {
+	dir, err := os.MkdirTemp("", "externalcmd-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	outPath := filepath.Join(dir, "out")
+	injectedPath := filepath.Join(dir, "injected")
+
+	pool := NewPool()
+
+	done := make(chan int)
+
+	RunOnce(
+		pool,
+		"sh -c 'printf %s \"$MTX_QUERY\" > "+outPath+"; echo $MTX_QUERY $MTX_USER'",
+		Environment{
+			"MTX_QUERY": "a'; touch " + injectedPath + "; echo '",
+			"MTX_USER":  "$(touch " + injectedPath + ")",
+		},
+		func(c int) {
+			done <- c
+		})
+
+	require.Equal(t, 0, <-done)
+	pool.Close()
+
+	byts, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	require.Equal(t, "a'; touch "+injectedPath+"; echo '", string(byts))
+
+	_, err = os.Stat(injectedPath)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestPoolCloseTerminatesOnce(t *testing.T) {
+	pool := NewPool()
+
+	RunOnce(
+		pool,
+		"sleep 60",
+		Environment{},
+		func(c int) {})
+
+	start := time.Now()
+	pool.Close()
+	require.Less(t, time.Since(start), onceCloseTimeout+2*time.Second)
+}
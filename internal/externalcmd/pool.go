@@ -2,19 +2,69 @@ package externalcmd
 
 import (
 	"sync"
+	"time"
+)
+
+const (
+	onceCloseTimeout = 5 * time.Second
 )
 
 // Pool is a pool of external commands.
 type Pool struct {
 	wg sync.WaitGroup
+
+	mutex sync.Mutex
+	once  map[*Cmd]struct{}
 }
 
 // NewPool allocates a Pool.
 func NewPool() *Pool {
-	return &Pool{}
+	return &Pool{
+		once: make(map[*Cmd]struct{}),
+	}
 }
 
 // Close waits for all external commands to exit.
+// Commands launched with RunOnce are terminated if they are still running
+// after a timeout.
 func (p *Pool) Close() {
-	p.wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(onceCloseTimeout):
+	}
+
+	p.mutex.Lock()
+	for e := range p.once {
+		close(e.terminate)
+	}
+	p.once = nil
+	p.mutex.Unlock()
+
+	<-done
+}
+
+func (p *Pool) addOnce(e *Cmd) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.once == nil {
+		// the pool is closing
+		close(e.terminate)
+		return
+	}
+
+	p.once[e] = struct{}{}
+}
+
+func (p *Pool) removeOnce(e *Cmd) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.once, e)
 }
@@ -12,6 +12,16 @@ import (
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+// check whether a RTP packet starts a new coded video sequence, without decoding it
+func rtpAV1ContainsKeyFrame(pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 1 {
+		return false
+	}
+
+	// N bit of the aggregation header
+	return ((pkt.Payload[0] >> 3) & 0x01) == 1
+}
+
 // UnitAV1 is an AV1 data unit.
 type UnitAV1 struct {
 	RTPPackets []*rtp.Packet
@@ -131,3 +141,13 @@ func (t *formatProcessorAV1) Process(unit Unit, hasNonRTSPReaders bool) error {
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorAV1) ContainsKeyFrame(unit Unit) bool {
+	for _, pkt := range unit.GetRTPPackets() {
+		if rtpAV1ContainsKeyFrame(pkt) {
+			return true
+		}
+	}
+	return false
+}
@@ -61,3 +61,8 @@ func (t *formatProcessorGeneric) Process(unit Unit, hasNonRTSPReaders bool) erro
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorGeneric) ContainsKeyFrame(_ Unit) bool {
+	return false
+}
@@ -69,6 +69,47 @@ func rtpH264ExtractSPSPPS(pkt *rtp.Packet) ([]byte, []byte) {
 	}
 }
 
+// check whether a RTP packet contains an IDR, or the beginning of an IDR, without decoding it
+func rtpH264ContainsIDR(pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 1 {
+		return false
+	}
+
+	typ := h264.NALUType(pkt.Payload[0] & 0x1F)
+
+	switch typ {
+	case h264.NALUTypeIDR:
+		return true
+
+	case h264.NALUTypeSTAPA:
+		payload := pkt.Payload[1:]
+
+		for len(payload) >= 2 {
+			size := uint16(payload[0])<<8 | uint16(payload[1])
+			payload = payload[2:]
+
+			if size == 0 || int(size) > len(payload) {
+				return false
+			}
+
+			if h264.NALUType(payload[0]&0x1F) == h264.NALUTypeIDR {
+				return true
+			}
+			payload = payload[size:]
+		}
+
+	case h264.NALUTypeFUA:
+		if len(pkt.Payload) < 2 {
+			return false
+		}
+
+		start := pkt.Payload[1] >> 7
+		return start == 1 && h264.NALUType(pkt.Payload[1]&0x1F) == h264.NALUTypeIDR
+	}
+
+	return false
+}
+
 // UnitH264 is a H264 data unit.
 type UnitH264 struct {
 	RTPPackets []*rtp.Packet
@@ -320,3 +361,13 @@ func (t *formatProcessorH264) Process(unit Unit, hasNonRTSPReaders bool) error {
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorH264) ContainsKeyFrame(unit Unit) bool {
+	for _, pkt := range unit.GetRTPPackets() {
+		if rtpH264ContainsIDR(pkt) {
+			return true
+		}
+	}
+	return false
+}
@@ -173,3 +173,49 @@ func TestH264EmptyPacket(t *testing.T) {
 	// if all NALUs have been removed, no RTP packets must be generated.
 	require.Equal(t, []*rtp.Packet(nil), unit.RTPPackets)
 }
+
+func TestH264ContainsKeyFrame(t *testing.T) {
+	forma := &formats.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+	}
+
+	p, err := New(1472, forma, false, nil)
+	require.NoError(t, err)
+
+	enc := forma.CreateEncoder()
+
+	for _, ca := range []struct {
+		name string
+		au   [][]byte
+		key  bool
+	}{
+		{
+			"single idr",
+			[][]byte{{byte(h264.NALUTypeIDR), 1, 2}},
+			true,
+		},
+		{
+			"aggregated idr",
+			[][]byte{{7, 4, 5, 6}, {8, 1}, {byte(h264.NALUTypeIDR), 1, 2}},
+			true,
+		},
+		{
+			"fragmented idr",
+			[][]byte{append([]byte{byte(h264.NALUTypeIDR)}, bytes.Repeat([]byte{1, 2, 3, 4}, 1000)...)},
+			true,
+		},
+		{
+			"non-idr",
+			[][]byte{{byte(h264.NALUTypeNonIDR), 1, 2}},
+			false,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			pkts, err := enc.Encode(ca.au, 0)
+			require.NoError(t, err)
+
+			require.Equal(t, ca.key, p.ContainsKeyFrame(&UnitH264{RTPPackets: pkts[:1]}))
+		})
+	}
+}
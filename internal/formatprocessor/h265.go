@@ -76,6 +76,50 @@ func rtpH265ExtractVPSSPSPPS(pkt *rtp.Packet) ([]byte, []byte, []byte) {
 	}
 }
 
+func h265IsRandomAccess(typ h265.NALUType) bool {
+	return typ >= h265.NALUType_BLA_W_LP && typ <= h265.NALUType_RSV_IRAP_VCL23
+}
+
+// check whether a RTP packet contains a random access picture, or its beginning, without decoding it
+func rtpH265ContainsRandomAccess(pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 2 {
+		return false
+	}
+
+	typ := h265.NALUType((pkt.Payload[0] >> 1) & 0b111111)
+
+	switch typ {
+	case h265.NALUType_AggregationUnit:
+		payload := pkt.Payload[2:]
+
+		for len(payload) >= 2 {
+			size := uint16(payload[0])<<8 | uint16(payload[1])
+			payload = payload[2:]
+
+			if size == 0 || int(size) > len(payload) {
+				return false
+			}
+
+			if h265IsRandomAccess(h265.NALUType((payload[0] >> 1) & 0b111111)) {
+				return true
+			}
+			payload = payload[size:]
+		}
+
+		return false
+
+	case h265.NALUType_FragmentationUnit:
+		if len(pkt.Payload) < 3 {
+			return false
+		}
+
+		start := pkt.Payload[2] >> 7
+		return start == 1 && h265IsRandomAccess(h265.NALUType(pkt.Payload[2]&0b111111))
+	}
+
+	return h265IsRandomAccess(typ)
+}
+
 // UnitH265 is a H265 data unit.
 type UnitH265 struct {
 	RTPPackets []*rtp.Packet
@@ -341,3 +385,13 @@ func (t *formatProcessorH265) Process(unit Unit, hasNonRTSPReaders bool) error {
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorH265) ContainsKeyFrame(unit Unit) bool {
+	for _, pkt := range unit.GetRTPPackets() {
+		if rtpH265ContainsRandomAccess(pkt) {
+			return true
+		}
+	}
+	return false
+}
@@ -166,3 +166,48 @@ func TestH265EmptyPacket(t *testing.T) {
 	// if all NALUs have been removed, no RTP packets must be generated.
 	require.Equal(t, []*rtp.Packet(nil), unit.RTPPackets)
 }
+
+func TestH265ContainsKeyFrame(t *testing.T) {
+	forma := &formats.H265{
+		PayloadTyp: 96,
+	}
+
+	p, err := New(1472, forma, false, nil)
+	require.NoError(t, err)
+
+	enc := forma.CreateEncoder()
+
+	for _, ca := range []struct {
+		name string
+		au   [][]byte
+		key  bool
+	}{
+		{
+			"single idr",
+			[][]byte{{byte(h265.NALUType_IDR_W_RADL) << 1, 0, 1, 2}},
+			true,
+		},
+		{
+			"aggregated idr",
+			[][]byte{{byte(h265.NALUType_SPS_NUT) << 1, 0, 1}, {byte(h265.NALUType_CRA_NUT) << 1, 0, 1, 2}},
+			true,
+		},
+		{
+			"fragmented idr",
+			[][]byte{append([]byte{byte(h265.NALUType_IDR_N_LP) << 1, 0}, bytes.Repeat([]byte{1, 2, 3, 4}, 1000)...)},
+			true,
+		},
+		{
+			"non-idr",
+			[][]byte{{byte(h265.NALUType_TRAIL_R) << 1, 0, 1, 2}},
+			false,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			pkts, err := enc.Encode(ca.au, 0)
+			require.NoError(t, err)
+
+			require.Equal(t, ca.key, p.ContainsKeyFrame(&UnitH265{RTPPackets: pkts[:1]}))
+		})
+	}
+}
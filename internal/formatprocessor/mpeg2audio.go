@@ -103,3 +103,8 @@ func (t *formatProcessorMPEG2Audio) Process(unit Unit, hasNonRTSPReaders bool) e
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorMPEG2Audio) ContainsKeyFrame(_ Unit) bool {
+	return false
+}
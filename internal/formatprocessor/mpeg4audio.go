@@ -108,3 +108,8 @@ func (t *formatProcessorMPEG4Audio) Process(unit Unit, hasNonRTSPReaders bool) e
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorMPEG4Audio) ContainsKeyFrame(_ Unit) bool {
+	return false
+}
@@ -102,3 +102,8 @@ func (t *formatProcessorOpus) Process(unit Unit, hasNonRTSPReaders bool) error {
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorOpus) ContainsKeyFrame(_ Unit) bool {
+	return false
+}
@@ -17,6 +17,10 @@ const (
 type Processor interface {
 	// cleans and normalizes a data unit.
 	Process(Unit, bool) error
+
+	// checks whether a processed data unit contains a key frame.
+	// Audio and generic formats never contain key frames.
+	ContainsKeyFrame(Unit) bool
 }
 
 // New allocates a Processor.
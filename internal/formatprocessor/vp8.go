@@ -11,6 +11,61 @@ import (
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+// check whether a RTP packet contains the beginning of a key frame, without decoding it
+func rtpVP8ContainsKeyFrame(pkt *rtp.Packet) bool {
+	payload := pkt.Payload
+	if len(payload) < 1 {
+		return false
+	}
+
+	// key frames start from the first partition
+	start := (payload[0] >> 4) & 0x01
+	partitionID := payload[0] & 0x07
+	if start != 1 || partitionID != 0 {
+		return false
+	}
+
+	n := 1
+
+	// extended control bits
+	if (payload[0] >> 7) == 1 {
+		if len(payload) < 2 {
+			return false
+		}
+		ext := payload[1]
+		n++
+
+		// picture ID
+		if (ext >> 7) == 1 {
+			if len(payload) <= n {
+				return false
+			}
+			if (payload[n] >> 7) == 1 {
+				n += 2
+			} else {
+				n++
+			}
+		}
+
+		// TL0PICIDX
+		if ((ext >> 6) & 0x01) == 1 {
+			n++
+		}
+
+		// TID, KEYIDX
+		if ((ext>>5)&0x01) == 1 || ((ext>>4)&0x01) == 1 {
+			n++
+		}
+	}
+
+	if len(payload) <= n {
+		return false
+	}
+
+	// the P bit of the frame tag is zero in key frames
+	return (payload[n] & 0x01) == 0
+}
+
 // UnitVP8 is a VP8 data unit.
 type UnitVP8 struct {
 	RTPPackets []*rtp.Packet
@@ -104,3 +159,13 @@ func (t *formatProcessorVP8) Process(unit Unit, hasNonRTSPReaders bool) error {
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorVP8) ContainsKeyFrame(unit Unit) bool {
+	for _, pkt := range unit.GetRTPPackets() {
+		if rtpVP8ContainsKeyFrame(pkt) {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,18 @@ import (
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+// check whether a RTP packet contains the beginning of a key frame, without decoding it
+func rtpVP9ContainsKeyFrame(pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 1 {
+		return false
+	}
+
+	// the frame is not inter-predicted and this is its first packet
+	interPredicted := (pkt.Payload[0] >> 6) & 0x01
+	start := (pkt.Payload[0] >> 3) & 0x01
+	return interPredicted == 0 && start == 1
+}
+
 // UnitVP9 is a VP9 data unit.
 type UnitVP9 struct {
 	RTPPackets []*rtp.Packet
@@ -104,3 +116,13 @@ func (t *formatProcessorVP9) Process(unit Unit, hasNonRTSPReaders bool) error {
 
 	return nil
 }
+
+// ContainsKeyFrame implements Processor.
+func (t *formatProcessorVP9) ContainsKeyFrame(unit Unit) bool {
+	for _, pkt := range unit.GetRTPPackets() {
+		if rtpVP9ContainsKeyFrame(pkt) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+// Package jsonschema contains a generator of JSON Schemas from Go types.
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Provider is implemented by types that describe their own schema,
+// usually because they implement json.Marshaler.
+type Provider interface {
+	JSONSchema(g *Generator) map[string]interface{}
+}
+
+var (
+	providerType  = reflect.TypeOf((*Provider)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// Generator generates JSON Schemas from Go types.
+// Named structs are stored into Defs and referenced.
+// Default values of struct fields are obtained by calling UnmarshalJSON(nil),
+// if the struct implements json.Unmarshaler.
+type Generator struct {
+	// prefix of references (i.e. "#/$defs/" or "#/components/schemas/").
+	RefPrefix string
+
+	// names of definitions. If a type is not present, its Go name is used.
+	Names map[reflect.Type]string
+
+	// whether fields that can be decoded from null are nullable.
+	// This is needed by YAML files, where empty values are null.
+	AcceptNull bool
+
+	// definitions of named structs.
+	Defs map[string]interface{}
+}
+
+// NewGenerator allocates a Generator.
+func NewGenerator(refPrefix string) *Generator {
+	return &Generator{
+		RefPrefix: refPrefix,
+		Names:     make(map[reflect.Type]string),
+		Defs:      make(map[string]interface{}),
+	}
+}
+
+// Nullable returns a schema that accepts the values of s and null.
+func Nullable(s map[string]interface{}) map[string]interface{} {
+	// an empty schema already accepts anything
+	if len(s) == 0 {
+		return s
+	}
+
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+		return s
+	}
+
+	return map[string]interface{}{
+		"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}},
+	}
+}
+
+func (g *Generator) name(t reflect.Type) string {
+	if name, ok := g.Names[t]; ok {
+		return name
+	}
+	return strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+}
+
+func (g *Generator) ref(t reflect.Type, build func() map[string]interface{}) map[string]interface{} {
+	name := g.name(t)
+
+	if _, ok := g.Defs[name]; !ok {
+		// reserve the name, in order to support recursive types
+		g.Defs[name] = map[string]interface{}{}
+		g.Defs[name] = build()
+	}
+
+	return map[string]interface{}{"$ref": g.RefPrefix + name}
+}
+
+// inferSchema infers the schema of a json.Marshaler from the encoding of its zero value.
+func inferSchema(t reflect.Type) map[string]interface{} {
+	byts, err := json.Marshal(reflect.New(t).Elem().Interface())
+	if err != nil {
+		return map[string]interface{}{}
+	}
+
+	var v interface{}
+	json.Unmarshal(byts, &v) //nolint:errcheck
+
+	switch v.(type) {
+	case string:
+		return map[string]interface{}{"type": "string"}
+
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case float64:
+		return map[string]interface{}{"type": "number"}
+
+	case []interface{}:
+		return map[string]interface{}{"type": "array"}
+
+	case map[string]interface{}:
+		return map[string]interface{}{"type": "object"}
+	}
+
+	return map[string]interface{}{}
+}
+
+// Schema returns the schema of a type.
+func (g *Generator) Schema(t reflect.Type) map[string]interface{} {
+	// pointers are handled below, since their zero value can't be used to call the provider
+	if t.Kind() != reflect.Ptr && t.Implements(providerType) {
+		p := reflect.New(t).Elem().Interface().(Provider)
+		if t.Kind() == reflect.Struct {
+			return g.ref(t, func() map[string]interface{} { return p.JSONSchema(g) })
+		}
+		return p.JSONSchema(g)
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	if t.Implements(marshalerType) {
+		return inferSchema(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return Nullable(g.Schema(t.Elem()))
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.Schema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.Schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.Object(t)
+		}
+		return g.ref(t, func() map[string]interface{} { return g.Object(t) })
+	}
+
+	// interfaces can contain anything
+	return map[string]interface{}{}
+}
+
+func acceptsNull(t reflect.Type) bool {
+	return json.Unmarshal([]byte("null"), reflect.New(t).Interface()) == nil
+}
+
+func defaultValues(t reflect.Type) map[string]interface{} {
+	v := reflect.New(t)
+
+	// only types that implement json.Unmarshaler have default values
+	u, ok := v.Interface().(json.Unmarshaler)
+	if !ok {
+		return nil
+	}
+	u.UnmarshalJSON(nil) //nolint:errcheck
+
+	byts, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+
+	var ret map[string]interface{}
+	json.Unmarshal(byts, &ret) //nolint:errcheck
+	return ret
+}
+
+func (g *Generator) fillProperties(t reflect.Type, props map[string]interface{}) {
+	defaults := defaultValues(t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+
+		if tag == "-" {
+			continue
+		}
+
+		// fields of embedded structs are promoted
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			g.fillProperties(f.Type, props)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		key := tag
+		if key == "" {
+			key = f.Name
+		}
+
+		s := g.Schema(f.Type)
+
+		if g.AcceptNull && f.Type.Kind() != reflect.Ptr && acceptsNull(f.Type) {
+			s = Nullable(s)
+		}
+
+		if def, ok := defaults[key]; ok && def != nil {
+			if _, isRef := s["$ref"]; isRef {
+				s = map[string]interface{}{"allOf": []interface{}{s}}
+			}
+			s["default"] = def
+		}
+
+		props[key] = s
+	}
+}
+
+// Object returns the schema of a struct, without using a reference.
+func (g *Generator) Object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	g.fillProperties(t, props)
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
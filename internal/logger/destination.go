@@ -1,5 +1,9 @@
 package logger
 
+import (
+	"time"
+)
+
 // Destination is a log destination.
 type Destination int
 
@@ -14,7 +18,16 @@ const (
 	DestinationSyslog
 )
 
+type entry struct {
+	time     time.Time
+	level    Level
+	fields   []Field
+	prefixes int // number of prefixes replaced by fields
+	format   string
+	args     []interface{}
+}
+
 type destination interface {
-	log(Level, string, ...interface{})
+	log(*entry)
 	close()
 }
@@ -2,33 +2,253 @@ package logger
 
 import (
 	"bytes"
+	"compress/gzip"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
 )
 
 type destinationFile struct {
-	file *os.File
-	buf  bytes.Buffer
+	format           Format
+	filePath         string
+	maxSize          uint64
+	rotationInterval time.Duration
+	maxBackups       int
+	compress         bool
+
+	file   *os.File
+	size   uint64
+	period time.Time // start of the rotation period of the current file
+	buf    bytes.Buffer
+
+	// rotated files are compressed and pruned in the background
+	postMutex sync.Mutex
+	postWG    sync.WaitGroup
 }
 
-func newDestinationFile(filePath string) (destination, error) {
-	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+func newDestinationFile(
+	format Format,
+	filePath string,
+	maxSize uint64,
+	rotationInterval time.Duration,
+	maxBackups int,
+	compress bool,
+) (destination, error) {
+	d := &destinationFile{
+		format:           format,
+		filePath:         filePath,
+		maxSize:          maxSize,
+		rotationInterval: rotationInterval,
+		maxBackups:       maxBackups,
+		compress:         compress,
+	}
+
+	err := d.open()
 	if err != nil {
 		return nil, err
 	}
 
-	return &destinationFile{
-		file: f,
-	}, nil
+	return d, nil
 }
 
-func (d *destinationFile) log(level Level, format string, args ...interface{}) {
+func (d *destinationFile) open() error {
+	f, err := os.OpenFile(d.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	d.file = f
+	d.size = uint64(fi.Size())
+
+	// an existing file is rotated when the period of its last write has elapsed,
+	// even if the file has been reopened in the meantime.
+	if d.size != 0 {
+		d.period = d.periodStart(fi.ModTime())
+	} else {
+		d.period = d.periodStart(time.Now())
+	}
+
+	return nil
+}
+
+// periodStart returns the start of the rotation period that contains t.
+// Periods are aligned to wall-clock boundaries.
+func (d *destinationFile) periodStart(t time.Time) time.Time {
+	if d.rotationInterval == 0 {
+		return time.Time{}
+	}
+	return t.Truncate(d.rotationInterval)
+}
+
+func (d *destinationFile) log(e *entry) {
 	d.buf.Reset()
-	writeTime(&d.buf, false)
-	writeLevel(&d.buf, level, false)
-	writeContent(&d.buf, format, args)
-	d.file.Write(d.buf.Bytes())
+	writeEntry(&d.buf, d.format, e, false)
+
+	if d.needsRotation(uint64(d.buf.Len())) {
+		d.rotate()
+	}
+
+	if d.file == nil {
+		return
+	}
+
+	n, _ := d.file.Write(d.buf.Bytes())
+	d.size += uint64(n)
+}
+
+func (d *destinationFile) needsRotation(additional uint64) bool {
+	// an empty file is never rotated
+	if d.size == 0 {
+		return false
+	}
+
+	if d.maxSize != 0 && (d.size+additional) > d.maxSize {
+		return true
+	}
+
+	if d.rotationInterval != 0 && d.periodStart(time.Now()).After(d.period) {
+		return true
+	}
+
+	return false
+}
+
+// backupPath returns the path of a rotated file, i.e. mediamtx-2023-01-02T15-04-05.000.log
+func (d *destinationFile) backupPath(t time.Time) string {
+	ext := filepath.Ext(d.filePath)
+	return strings.TrimSuffix(d.filePath, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+func (d *destinationFile) rotate() {
+	if d.file != nil {
+		d.file.Close()
+		d.file = nil
+	}
+
+	backup := d.backupPath(time.Now())
+	err := os.Rename(d.filePath, backup)
+	if err != nil {
+		// try to keep writing into the current file
+		d.open() //nolint:errcheck
+		return
+	}
+
+	d.open() //nolint:errcheck
+
+	d.postWG.Add(1)
+	go d.postProcess(backup)
+}
+
+func (d *destinationFile) postProcess(backup string) {
+	defer d.postWG.Done()
+
+	d.postMutex.Lock()
+	defer d.postMutex.Unlock()
+
+	if d.compress {
+		compressFile(backup) //nolint:errcheck
+	}
+
+	if d.maxBackups > 0 {
+		d.removeOldBackups()
+	}
+}
+
+func compressFile(fpath string) error {
+	src, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(fpath+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dest)
+
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dest.Close()
+	} else {
+		dest.Close()
+	}
+	if err != nil {
+		os.Remove(fpath + ".gz")
+		return err
+	}
+
+	return os.Remove(fpath)
+}
+
+// backups returns rotated files, sorted from the newest to the oldest.
+func (d *destinationFile) backups() []string {
+	ext := filepath.Ext(d.filePath)
+	prefix := filepath.Base(strings.TrimSuffix(d.filePath, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(d.filePath))
+	if err != nil {
+		return nil
+	}
+
+	var ret []string
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(name[len(prefix):], ".gz")
+		if !strings.HasSuffix(ts, ext) {
+			continue
+		}
+		ts = strings.TrimSuffix(ts, ext)
+
+		if _, err := time.Parse(backupTimeFormat, ts); err != nil {
+			continue
+		}
+
+		ret = append(ret, filepath.Join(filepath.Dir(d.filePath), name))
+	}
+
+	// timestamps can be sorted alphabetically
+	sort.Sort(sort.Reverse(sort.StringSlice(ret)))
+
+	return ret
+}
+
+func (d *destinationFile) removeOldBackups() {
+	backups := d.backups()
+
+	if len(backups) > d.maxBackups {
+		for _, fpath := range backups[d.maxBackups:] {
+			os.Remove(fpath)
+		}
+	}
 }
 
 func (d *destinationFile) close() {
-	d.file.Close()
+	if d.file != nil {
+		d.file.Close()
+	}
+	d.postWG.Wait()
 }
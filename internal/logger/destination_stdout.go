@@ -6,18 +6,19 @@ import (
 )
 
 type destinationStdout struct {
-	buf bytes.Buffer
+	format Format
+	buf    bytes.Buffer
 }
 
-func newDestionationStdout() destination {
-	return &destinationStdout{}
+func newDestionationStdout(format Format) destination {
+	return &destinationStdout{
+		format: format,
+	}
 }
 
-func (d *destinationStdout) log(level Level, format string, args ...interface{}) {
+func (d *destinationStdout) log(e *entry) {
 	d.buf.Reset()
-	writeTime(&d.buf, true)
-	writeLevel(&d.buf, level, true)
-	writeContent(&d.buf, format, args)
+	writeEntry(&d.buf, d.format, e, true)
 	os.Stdout.Write(d.buf.Bytes())
 }
 
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"strings"
+)
+
+// Field is a structured field of a log entry.
+// It can be passed to Writer.Log() together with formatting arguments:
+// it is not printed by the text format, while the JSON format adds it to the entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// prefixMarker marks a "[...] " prefix that has been replaced by fields.
+type prefixMarker struct{}
+
+// WithField prepends a field to the arguments of a log entry.
+// The field replaces the "[...] " prefix that the caller adds to the format:
+// the text format prints the prefix, while the JSON format removes it.
+func WithField(key string, value interface{}, args []interface{}) []interface{} {
+	return WithFields([]Field{{Key: key, Value: value}}, args)
+}
+
+// WithFields is like WithField, with multiple fields replacing a single prefix.
+func WithFields(fields []Field, args []interface{}) []interface{} {
+	ret := make([]interface{}, 0, 1+len(fields)+len(args))
+	ret = append(ret, prefixMarker{})
+	for _, f := range fields {
+		ret = append(ret, f)
+	}
+	return append(ret, args...)
+}
+
+func splitFields(args []interface{}) ([]Field, int, []interface{}) {
+	var fields []Field
+	prefixes := 0
+	var rest []interface{}
+
+	for _, arg := range args {
+		switch x := arg.(type) {
+		case Field:
+			fields = append(fields, x)
+
+		case prefixMarker:
+			prefixes++
+
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return fields, prefixes, rest
+}
+
+func countVerbs(s string) int {
+	return strings.Count(s, "%") - 2*strings.Count(s, "%%")
+}
+
+// stripPrefixes removes the first n "[...] " prefixes, that are added by the Writer chain,
+// together with their arguments, since they are replaced by fields.
+func stripPrefixes(format string, args []interface{}, n int) (string, []interface{}) {
+	for i := 0; i < n && strings.HasPrefix(format, "["); i++ {
+		end := strings.Index(format, "] ")
+		if end < 0 {
+			break
+		}
+
+		v := countVerbs(format[:end])
+		if v > len(args) {
+			break
+		}
+
+		format = format[end+2:]
+		args = args[v:]
+	}
+
+	return format, args
+}
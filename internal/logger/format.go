@@ -0,0 +1,12 @@
+package logger
+
+// Format is a log format.
+type Format int
+
+const (
+	// FormatText writes logs as text lines.
+	FormatText Format = iota
+
+	// FormatJSON writes logs as JSON objects, one per line.
+	FormatJSON
+)
@@ -3,6 +3,7 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -12,25 +13,52 @@ import (
 
 // Logger is a log handler.
 type Logger struct {
-	level Level
+	level           Level
+	componentLevels map[string]Level
+	minLevel        Level
+	location        *time.Location
 
 	destinations []destination
 	mutex        sync.Mutex
 }
 
 // New allocates a log handler.
-func New(level Level, destinations []Destination, filePath string) (*Logger, error) {
+// componentLevels overrides level for entries whose "component" field is in the map.
+// When fileMaxSize or fileRotationInterval are not zero, the log file is rotated.
+// Timestamps are expressed in the given location.
+func New(
+	level Level,
+	componentLevels map[string]Level,
+	destinations []Destination,
+	filePath string,
+	fileMaxSize uint64,
+	fileRotationInterval time.Duration,
+	fileMaxBackups int,
+	fileCompress bool,
+	format Format,
+	location *time.Location,
+) (*Logger, error) {
 	lh := &Logger{
-		level: level,
+		level:           level,
+		componentLevels: componentLevels,
+		minLevel:        level,
+		location:        location,
+	}
+
+	for _, l := range componentLevels {
+		if l < lh.minLevel {
+			lh.minLevel = l
+		}
 	}
 
 	for _, destType := range destinations {
 		switch destType {
 		case DestinationStdout:
-			lh.destinations = append(lh.destinations, newDestionationStdout())
+			lh.destinations = append(lh.destinations, newDestionationStdout(format))
 
 		case DestinationFile:
-			dest, err := newDestinationFile(filePath)
+			dest, err := newDestinationFile(format, filePath, fileMaxSize,
+				fileRotationInterval, fileMaxBackups, fileCompress)
 			if err != nil {
 				lh.Close()
 				return nil, err
@@ -38,7 +66,7 @@ func New(level Level, destinations []Destination, filePath string) (*Logger, err
 			lh.destinations = append(lh.destinations, dest)
 
 		case DestinationSyslog:
-			dest, err := newDestinationSyslog()
+			dest, err := newDestinationSyslog(format)
 			if err != nil {
 				lh.Close()
 				return nil, err
@@ -74,11 +102,10 @@ func itoa(i int, wid int) []byte {
 	return b[bp:]
 }
 
-func writeTime(buf *bytes.Buffer, doColor bool) {
+func writeTime(buf *bytes.Buffer, now time.Time, doColor bool) {
 	var intbuf bytes.Buffer
 
 	// date
-	now := time.Now()
 	year, month, day := now.Date()
 	intbuf.Write(itoa(year, 4))
 	intbuf.WriteByte('/')
@@ -141,16 +168,96 @@ func writeContent(buf *bytes.Buffer, format string, args []interface{}) {
 	buf.WriteByte('\n')
 }
 
+func levelString(level Level) string {
+	switch level {
+	case Debug:
+		return "debug"
+
+	case Info:
+		return "info"
+
+	case Warn:
+		return "warn"
+	}
+	return "error"
+}
+
+func writeJSON(buf *bytes.Buffer, e *entry) {
+	m := make(map[string]interface{}, len(e.fields)+3)
+
+	for _, f := range e.fields {
+		if s, ok := f.Value.(fmt.Stringer); ok {
+			m[f.Key] = s.String()
+		} else {
+			m[f.Key] = f.Value
+		}
+	}
+
+	format, args := stripPrefixes(e.format, e.args, e.prefixes)
+	m["time"] = e.time.Format("2006-01-02T15:04:05.000Z07:00")
+	m["level"] = levelString(e.level)
+	m["message"] = fmt.Sprintf(format, args...)
+
+	byts, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+
+	buf.Write(byts)
+	buf.WriteByte('\n')
+}
+
+func writeEntry(buf *bytes.Buffer, format Format, e *entry, doColor bool) {
+	if format == FormatJSON {
+		writeJSON(buf, e)
+		return
+	}
+
+	writeTime(buf, e.time, doColor)
+	writeLevel(buf, e.level, doColor)
+	writeContent(buf, e.format, e.args)
+}
+
+// entryLevel returns the minimum level of an entry, that depends on its component.
+func (lh *Logger) entryLevel(fields []Field) Level {
+	for _, f := range fields {
+		if f.Key == "component" {
+			if component, ok := f.Value.(string); ok {
+				if l, ok := lh.componentLevels[component]; ok {
+					return l
+				}
+			}
+			break
+		}
+	}
+	return lh.level
+}
+
 // Log writes a log entry.
 func (lh *Logger) Log(level Level, format string, args ...interface{}) {
-	if level < lh.level {
+	if level < lh.minLevel {
+		return
+	}
+
+	fields, prefixes, args := splitFields(args)
+
+	if level < lh.entryLevel(fields) {
 		return
 	}
 
+	e := &entry{
+		time:     time.Now().In(lh.location),
+		level:    level,
+		fields:   fields,
+		prefixes: prefixes,
+		format:   format,
+		args:     args,
+	}
+
 	lh.mutex.Lock()
 	defer lh.mutex.Unlock()
 
 	for _, dest := range lh.destinations {
-		dest.log(level, format, args...)
+		dest.log(e)
 	}
 }
@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readLogFile(t *testing.T, fpath string) string {
+	f, err := os.Open(fpath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(fpath, ".gz") {
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = zr
+	}
+
+	byts, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(byts)
+}
+
+func TestLoggerComponentLevels(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-logger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "mediamtx.log")
+
+	l, err := New(Info, map[string]Level{"webrtc": Debug, "hls": Warn},
+		[]Destination{DestinationFile}, fpath, 0, 0, 0, false, FormatText, time.UTC)
+	require.NoError(t, err)
+
+	l.Log(Debug, "generic debug")
+	l.Log(Info, "generic info")
+	l.Log(Debug, "[WebRTC] webrtc debug", Field{Key: "component", Value: "webrtc"})
+	l.Log(Info, "[HLS] hls info", Field{Key: "component", Value: "hls"})
+	l.Log(Warn, "[HLS] hls warn", Field{Key: "component", Value: "hls"})
+	l.Close()
+
+	content := readLogFile(t, fpath)
+	require.NotContains(t, content, "generic debug")
+	require.Contains(t, content, "generic info")
+	require.Contains(t, content, "webrtc debug")
+	require.NotContains(t, content, "hls info")
+	require.Contains(t, content, "hls warn")
+}
+
+func TestLoggerRotation(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		name := "plain"
+		if compress {
+			name = "compressed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-logger")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			fpath := filepath.Join(dir, "mediamtx.log")
+
+			l, err := New(Info, nil, []Destination{DestinationFile}, fpath,
+				150, 0, 2, compress, FormatText, time.UTC)
+			require.NoError(t, err)
+
+			// each entry is about 60 bytes, therefore the file is rotated every 2 entries
+			for i := 0; i < 8; i++ {
+				l.Log(Info, "entry %d %s", i, strings.Repeat("a", 30))
+				// make backup names unique
+				time.Sleep(2 * time.Millisecond)
+			}
+			l.Close()
+
+			require.Contains(t, readLogFile(t, fpath), "entry 7")
+
+			backups := (&destinationFile{filePath: fpath}).backups()
+			require.Equal(t, 2, len(backups))
+
+			for _, backup := range backups {
+				require.Equal(t, compress, strings.HasSuffix(backup, ".gz"))
+			}
+
+			// backups are sorted from the newest to the oldest
+			require.Contains(t, readLogFile(t, backups[0]), "entry 5")
+			require.Contains(t, readLogFile(t, backups[1]), "entry 3")
+		})
+	}
+}
+
+func TestLoggerRotationInterval(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-logger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "mediamtx.log")
+
+	l, err := New(Info, nil, []Destination{DestinationFile}, fpath,
+		0, 50*time.Millisecond, 0, false, FormatText, time.UTC)
+	require.NoError(t, err)
+
+	l.Log(Info, "first")
+	time.Sleep(100 * time.Millisecond)
+	l.Log(Info, "second")
+	l.Close()
+
+	content := readLogFile(t, fpath)
+	require.NotContains(t, content, "first")
+	require.Contains(t, content, "second")
+
+	backups := (&destinationFile{filePath: fpath}).backups()
+	require.Equal(t, 1, len(backups))
+	require.Contains(t, readLogFile(t, backups[0]), "first")
+}
+
+func TestLoggerRotationIntervalReopen(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-logger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "mediamtx.log")
+
+	// a file written in a previous period, by a previous instance
+	err = os.WriteFile(fpath, []byte("old\n"), 0o644)
+	require.NoError(t, err)
+
+	mtime := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(fpath, mtime, mtime)
+	require.NoError(t, err)
+
+	l, err := New(Info, nil, []Destination{DestinationFile}, fpath,
+		0, time.Hour, 0, false, FormatText, time.UTC)
+	require.NoError(t, err)
+
+	l.Log(Info, "new")
+	l.Close()
+
+	content := readLogFile(t, fpath)
+	require.NotContains(t, content, "old")
+	require.Contains(t, content, "new")
+
+	backups := (&destinationFile{filePath: fpath}).backups()
+	require.Equal(t, 1, len(backups))
+	require.Equal(t, "old\n", readLogFile(t, backups[0]))
+}
+
+func TestLoggerJSONPrefixes(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-logger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "mediamtx.log")
+
+	l, err := New(Info, nil, []Destination{DestinationFile}, fpath, 0, 0, 0, false, FormatJSON, time.UTC)
+	require.NoError(t, err)
+
+	// prefixes that are not replaced by fields are kept
+	l.Log(Info, "[RTSP] [conn %v] [c->s] %s",
+		WithField("component", "rtsp",
+			WithField("remoteAddr", "127.0.0.1:5000",
+				[]interface{}{"127.0.0.1:5000", "OPTIONS"}))...)
+	l.Log(Info, "[conn %v] request rejected", "127.0.0.1:6000")
+	l.Close()
+
+	lines := strings.Split(strings.TrimSpace(readLogFile(t, fpath)), "\n")
+	require.Equal(t, 2, len(lines))
+
+	var entry map[string]interface{}
+	err = json.Unmarshal([]byte(lines[0]), &entry)
+	require.NoError(t, err)
+	require.Equal(t, "[c->s] OPTIONS", entry["message"])
+	require.Equal(t, "rtsp", entry["component"])
+	require.Equal(t, "127.0.0.1:5000", entry["remoteAddr"])
+
+	err = json.Unmarshal([]byte(lines[1]), &entry)
+	require.NoError(t, err)
+	require.Equal(t, "[conn 127.0.0.1:6000] request rejected", entry["message"])
+}
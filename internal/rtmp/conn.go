@@ -564,6 +564,25 @@ func (c *Conn) InitializeServer() (*url.URL, bool, error) {
 	}
 }
 
+// WriteStatusError writes a onStatus message with level "error".
+// It is used by servers to notify clients about rejected streams.
+func (c *Conn) WriteStatusError(code string, description string) error {
+	return c.mrw.Write(&message.CommandAMF0{
+		ChunkStreamID:   5,
+		MessageStreamID: 0x1000000,
+		Name:            "onStatus",
+		CommandID:       0,
+		Arguments: []interface{}{
+			nil,
+			flvio.AMFMap{
+				{K: "level", V: "error"},
+				{K: "code", V: code},
+				{K: "description", V: description},
+			},
+		},
+	})
+}
+
 // ReadMessage reads a message.
 func (c *Conn) ReadMessage() (message.Message, error) {
 	return c.mrw.Read()
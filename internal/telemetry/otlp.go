@@ -0,0 +1,378 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	otlpMaxQueuedSpans = 2048
+	otlpServiceName    = "mediamtx"
+
+	// values defined by the OTLP protocol.
+	otlpAggregationTemporalityCumulative = 2
+	otlpSpanKindServer                   = 2
+	otlpStatusCodeError                  = 2
+)
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(labels []Label) []otlpKeyValue {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	ret := make([]otlpKeyValue, len(labels))
+	for i, l := range labels {
+		ret[i] = otlpKeyValue{Key: l.Key, Value: otlpAnyValue{StringValue: l.Value}}
+	}
+	return ret
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// OTLPExporter periodically sends metrics and spans to an OpenTelemetry collector,
+// with the OTLP/HTTP protocol and the JSON encoding.
+type OTLPExporter struct {
+	endpoint       string
+	headers        map[string]string
+	interval       time.Duration
+	registry       *Registry
+	serviceVersion string
+	parent         logger.Writer
+
+	ctx        context.Context
+	ctxCancel  func()
+	httpClient *http.Client
+	startTime  time.Time
+	mutex      sync.Mutex
+	spans      []*Span
+	spansLost  bool
+	done       chan struct{}
+}
+
+// NewOTLPExporter allocates an OTLPExporter.
+// The endpoint is the base URL of the collector, i.e. http://localhost:4318.
+func NewOTLPExporter(
+	endpoint string,
+	headers map[string]string,
+	interval time.Duration,
+	registry *Registry,
+	serviceVersion string,
+	parent logger.Writer,
+) *OTLPExporter {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	e := &OTLPExporter{
+		endpoint:       strings.TrimSuffix(endpoint, "/"),
+		headers:        headers,
+		interval:       interval,
+		registry:       registry,
+		serviceVersion: serviceVersion,
+		parent:         parent,
+		ctx:            ctx,
+		ctxCancel:      ctxCancel,
+		httpClient: &http.Client{
+			Timeout: interval,
+		},
+		startTime: time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e
+}
+
+// Close sends pending data and stops the exporter.
+func (e *OTLPExporter) Close() {
+	e.ctxCancel()
+	<-e.done
+}
+
+// ExportSpan implements SpanExporter.
+func (e *OTLPExporter) ExportSpan(s *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.spans) >= otlpMaxQueuedSpans {
+		e.spansLost = true
+		return
+	}
+
+	e.spans = append(e.spans, s)
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	t := time.NewTicker(e.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			e.flush()
+
+		case <-e.ctx.Done():
+			// send spans of connections that have just been closed
+			e.flushSpans()
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) flush() {
+	err := e.post("/v1/metrics", e.metricsRequest())
+	if err != nil {
+		e.parent.Log(logger.Warn, "unable to export metrics: %v", err)
+	}
+
+	e.flushSpans()
+}
+
+func (e *OTLPExporter) flushSpans() {
+	e.mutex.Lock()
+	spans := e.spans
+	spansLost := e.spansLost
+	e.spans = nil
+	e.spansLost = false
+	e.mutex.Unlock()
+
+	if spansLost {
+		e.parent.Log(logger.Warn, "too many spans are pending, some of them have been discarded")
+	}
+
+	if len(spans) == 0 {
+		return
+	}
+
+	err := e.post("/v1/traces", e.tracesRequest(spans))
+	if err != nil {
+		e.parent.Log(logger.Warn, "unable to export traces: %v", err)
+	}
+}
+
+func (e *OTLPExporter) resource() otlpResource {
+	labels := []Label{{Key: "service.name", Value: otlpServiceName}}
+	if e.serviceVersion != "" {
+		labels = append(labels, Label{Key: "service.version", Value: e.serviceVersion})
+	}
+	return otlpResource{Attributes: otlpAttributes(labels)}
+}
+
+func (e *OTLPExporter) metricsRequest() *otlpMetricsRequest {
+	now := otlpTime(time.Now())
+	start := otlpTime(e.startTime)
+
+	// group points by name, preserving their order
+	var metrics []otlpMetric
+	index := make(map[string]int)
+
+	for _, p := range e.registry.Gather() {
+		i, ok := index[p.Name]
+		if !ok {
+			m := otlpMetric{Name: p.Name}
+			if p.Kind == KindCounter {
+				m.Sum = &otlpSum{
+					AggregationTemporality: otlpAggregationTemporalityCumulative,
+					IsMonotonic:            true,
+				}
+			} else {
+				m.Gauge = &otlpGauge{}
+			}
+
+			i = len(metrics)
+			index[p.Name] = i
+			metrics = append(metrics, m)
+		}
+
+		dp := otlpNumberDataPoint{
+			Attributes:   otlpAttributes(p.Labels),
+			TimeUnixNano: now,
+			AsDouble:     p.Value,
+		}
+
+		if metrics[i].Sum != nil {
+			dp.StartTimeUnixNano = start
+			metrics[i].Sum.DataPoints = append(metrics[i].Sum.DataPoints, dp)
+		} else {
+			metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, dp)
+		}
+	}
+
+	return &otlpMetricsRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: e.resource(),
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpServiceName, Version: e.serviceVersion},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func (e *OTLPExporter) tracesRequest(spans []*Span) *otlpTracesRequest {
+	out := make([]otlpSpan, len(spans))
+
+	for i, s := range spans {
+		s.mutex.Lock()
+
+		sp := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: otlpTime(s.start),
+			EndTimeUnixNano:   otlpTime(s.end),
+			Attributes:        otlpAttributes(s.attributes),
+		}
+
+		if s.parentSpanID != [8]byte{} {
+			sp.ParentSpanID = hex.EncodeToString(s.parentSpanID[:])
+		}
+
+		if s.errMessage != "" {
+			sp.Status = otlpStatus{Code: otlpStatusCodeError, Message: s.errMessage}
+		}
+
+		s.mutex.Unlock()
+
+		out[i] = sp
+	}
+
+	return &otlpTracesRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: e.resource(),
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: otlpServiceName, Version: e.serviceVersion},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func (e *OTLPExporter) post(path string, body interface{}) error {
+	byts, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint+path, bytes.NewReader(byts))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
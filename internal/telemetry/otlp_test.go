@@ -0,0 +1,132 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+func TestOTLPExporter(t *testing.T) {
+	metricsBodies := make(chan map[string]interface{}, 10)
+	tracesBodies := make(chan map[string]interface{}, 10)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "mytoken", r.Header.Get("Authorization"))
+
+		byts, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var body map[string]interface{}
+		err = json.Unmarshal(byts, &body)
+		require.NoError(t, err)
+
+		switch r.URL.Path {
+		case "/v1/metrics":
+			metricsBodies <- body
+
+		case "/v1/traces":
+			tracesBodies <- body
+		}
+	}))
+	defer s.Close()
+
+	r := NewRegistry()
+	r.Register(func(b *Batch) {
+		b.Gauge("paths", 1, "name", "mypath")
+		b.Counter("paths_bytes_received", 1234, "name", "mypath")
+	})
+
+	e := NewOTLPExporter(s.URL, map[string]string{"Authorization": "mytoken"},
+		50*time.Millisecond, r, "v1.2.3", nilLogger{})
+
+	tracer := NewTracer()
+	tracer.SetExporter(e)
+
+	root := tracer.Start("rtmp conn", "remoteAddr", "127.0.0.1:5000")
+	child := root.Child("authentication")
+	child.SetError(fmt.Errorf("wrong credentials"))
+	child.End()
+	root.End()
+	root.End()
+
+	var metrics map[string]interface{}
+	select {
+	case metrics = <-metricsBodies:
+	case <-time.After(2 * time.Second):
+		t.Fatal("metrics not received")
+	}
+
+	var traces map[string]interface{}
+	select {
+	case traces = <-tracesBodies:
+	case <-time.After(2 * time.Second):
+		t.Fatal("traces not received")
+	}
+
+	e.Close()
+
+	rm := metrics["resourceMetrics"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "mediamtx"}},
+		map[string]interface{}{"key": "service.version", "value": map[string]interface{}{"stringValue": "v1.2.3"}},
+	}, rm["resource"].(map[string]interface{})["attributes"])
+
+	ms := rm["scopeMetrics"].([]interface{})[0].(map[string]interface{})["metrics"].([]interface{})
+	require.Equal(t, 2, len(ms))
+
+	gauge := ms[0].(map[string]interface{})
+	require.Equal(t, "paths", gauge["name"])
+	dp := gauge["gauge"].(map[string]interface{})["dataPoints"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, float64(1), dp["asDouble"])
+
+	sum := ms[1].(map[string]interface{})
+	require.Equal(t, "paths_bytes_received", sum["name"])
+	require.Equal(t, true, sum["sum"].(map[string]interface{})["isMonotonic"])
+	require.Equal(t, float64(2), sum["sum"].(map[string]interface{})["aggregationTemporality"])
+	dp = sum["sum"].(map[string]interface{})["dataPoints"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, float64(1234), dp["asDouble"])
+
+	rs := traces["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	spans := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	require.Equal(t, 2, len(spans))
+
+	cs := spans[0].(map[string]interface{})
+	rts := spans[1].(map[string]interface{})
+
+	require.Equal(t, "authentication", cs["name"])
+	require.Equal(t, rts["traceId"], cs["traceId"])
+	require.Equal(t, rts["spanId"], cs["parentSpanId"])
+	require.Equal(t, map[string]interface{}{"code": float64(2), "message": "wrong credentials"}, cs["status"])
+
+	require.Equal(t, "rtmp conn", rts["name"])
+	require.Equal(t, nil, rts["parentSpanId"])
+	require.Equal(t, 32, len(rts["traceId"].(string)))
+	require.Equal(t, 16, len(rts["spanId"].(string)))
+}
+
+func TestTracerDisabled(t *testing.T) {
+	tracer := NewTracer()
+
+	s := tracer.Start("rtsp session")
+	require.Nil(t, s)
+
+	// methods can be called on a nil span
+	c := s.Child("path lookup")
+	c.SetAttribute("path", "mypath")
+	c.SetError(fmt.Errorf("error"))
+	c.End()
+	s.End()
+}
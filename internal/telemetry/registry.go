@@ -0,0 +1,135 @@
+// Package telemetry contains a metrics registry and a tracer,
+// that can be exported in the Prometheus text format and with OTLP.
+package telemetry
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind is the kind of a metric.
+type Kind int
+
+// kinds.
+const (
+	// KindGauge is a value that can go up and down.
+	KindGauge Kind = iota
+
+	// KindCounter is a cumulative value that can only increase.
+	KindCounter
+)
+
+// Label is a key-value pair attached to a metric or a span.
+type Label struct {
+	Key   string
+	Value string
+}
+
+func labelsFromPairs(kv []string) []Label {
+	if len(kv) == 0 {
+		return nil
+	}
+
+	ret := make([]Label, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		ret = append(ret, Label{Key: kv[i], Value: kv[i+1]})
+	}
+	return ret
+}
+
+// Point is the value of a metric with a given set of labels.
+type Point struct {
+	Name   string
+	Kind   Kind
+	Labels []Label
+	Value  float64
+}
+
+// Batch collects points.
+type Batch struct {
+	points []Point
+}
+
+// Gauge adds a gauge point. Labels are provided as key-value pairs.
+func (b *Batch) Gauge(name string, value float64, kv ...string) {
+	b.points = append(b.points, Point{
+		Name:   name,
+		Kind:   KindGauge,
+		Labels: labelsFromPairs(kv),
+		Value:  value,
+	})
+}
+
+// Counter adds a counter point. Labels are provided as key-value pairs.
+func (b *Batch) Counter(name string, value float64, kv ...string) {
+	b.points = append(b.points, Point{
+		Name:   name,
+		Kind:   KindCounter,
+		Labels: labelsFromPairs(kv),
+		Value:  value,
+	})
+}
+
+// Collector fills a batch with the current value of metrics.
+type Collector func(b *Batch)
+
+// Registry is a set of collectors.
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry allocates a Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector.
+func (r *Registry) Register(c Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Gather returns the current value of all metrics, in the order in which they were collected.
+func (r *Registry) Gather() []Point {
+	r.mutex.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mutex.Unlock()
+
+	var b Batch
+	for _, c := range collectors {
+		c(&b)
+	}
+	return b.points
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes all metrics in the Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	var buf bytes.Buffer
+
+	for _, p := range r.Gather() {
+		buf.WriteString(p.Name)
+
+		if len(p.Labels) != 0 {
+			buf.WriteByte('{')
+			for i, l := range p.Labels {
+				if i != 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(l.Key + "=\"" + labelValueReplacer.Replace(l.Value) + "\"")
+			}
+			buf.WriteByte('}')
+		}
+
+		buf.WriteString(" " + strconv.FormatFloat(p.Value, 'f', -1, 64) + "\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,31 @@
+package telemetry
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+
+	r.Register(func(b *Batch) {
+		b.Gauge("paths", 1, "name", "mypath", "state", "ready")
+		b.Counter("paths_bytes_received", 1234, "name", "mypath", "state", "ready")
+		b.Gauge("tracks_fps", 29.97, "path", "my\"path")
+	})
+	r.Register(func(b *Batch) {
+		b.Counter("webhook_deliveries", 0)
+	})
+
+	var buf bytes.Buffer
+	err := r.WritePrometheus(&buf)
+	require.NoError(t, err)
+
+	require.Equal(t, `paths{name="mypath",state="ready"} 1
+paths_bytes_received{name="mypath",state="ready"} 1234
+tracks_fps{path="my\"path"} 29.97
+webhook_deliveries 0
+`, buf.String())
+}
@@ -0,0 +1,153 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// SpanExporter receives ended spans.
+type SpanExporter interface {
+	ExportSpan(s *Span)
+}
+
+// Tracer creates spans.
+// Spans are created only when an exporter is set, otherwise a nil span is returned.
+// All methods of Span can be called on a nil span.
+type Tracer struct {
+	mutex    sync.RWMutex
+	exporter SpanExporter
+}
+
+// NewTracer allocates a Tracer.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// SetExporter sets the exporter. A nil exporter disables tracing.
+func (t *Tracer) SetExporter(e SpanExporter) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.exporter = e
+}
+
+func (t *Tracer) enabled() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.exporter != nil
+}
+
+func (t *Tracer) export(s *Span) {
+	t.mutex.RLock()
+	e := t.exporter
+	t.mutex.RUnlock()
+
+	if e != nil {
+		e.ExportSpan(s)
+	}
+}
+
+// Start starts a root span. Attributes are provided as key-value pairs.
+func (t *Tracer) Start(name string, kv ...string) *Span {
+	if t == nil || !t.enabled() {
+		return nil
+	}
+
+	s := &Span{
+		tracer:     t,
+		name:       name,
+		start:      time.Now(),
+		attributes: labelsFromPairs(kv),
+	}
+	rand.Read(s.traceID[:]) //nolint:errcheck
+	rand.Read(s.spanID[:])  //nolint:errcheck
+	return s
+}
+
+// Span is a timed operation.
+type Span struct {
+	tracer       *Tracer
+	traceID      [16]byte
+	spanID       [8]byte
+	parentSpanID [8]byte
+	name         string
+	start        time.Time
+
+	mutex      sync.Mutex
+	attributes []Label
+	errMessage string
+	end        time.Time
+	ended      bool
+}
+
+// Child starts a span whose parent is s. Attributes are provided as key-value pairs.
+func (s *Span) Child(name string, kv ...string) *Span {
+	if s == nil {
+		return nil
+	}
+
+	c := &Span{
+		tracer:       s.tracer,
+		traceID:      s.traceID,
+		parentSpanID: s.spanID,
+		name:         name,
+		start:        time.Now(),
+		attributes:   labelsFromPairs(kv),
+	}
+	rand.Read(c.spanID[:]) //nolint:errcheck
+	return c
+}
+
+// SetAttribute sets an attribute. It has no effect after End().
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	for i, a := range s.attributes {
+		if a.Key == key {
+			s.attributes[i].Value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, Label{Key: key, Value: value})
+}
+
+// SetError marks the span as failed. A nil error is ignored, as well as calls after End().
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.ended {
+		s.errMessage = err.Error()
+	}
+}
+
+// End ends the span and sends it to the exporter. Subsequent calls have no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+
+	s.tracer.export(s)
+}
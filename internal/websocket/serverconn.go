@@ -17,6 +17,14 @@ var (
 	writeTimeout = 2 * time.Second
 )
 
+// CloseTryAgainLater is the close code that notifies clients
+// that the server is temporarily unable to serve them.
+const CloseTryAgainLater = websocket.CloseTryAgainLater
+
+// ClosePolicyViolation is the close code that notifies clients
+// that they are not allowed to be served.
+const ClosePolicyViolation = websocket.ClosePolicyViolation
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -61,6 +69,12 @@ func (c *ServerConn) Close() {
 	close(c.terminate)
 }
 
+// WriteClose writes a close message with the given code and reason.
+func (c *ServerConn) WriteClose(code int, reason string) error {
+	return c.wc.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+}
+
 // RemoteAddr returns the remote address.
 func (c *ServerConn) RemoteAddr() net.Addr {
 	return c.wc.RemoteAddr()